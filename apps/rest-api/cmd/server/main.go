@@ -11,6 +11,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -26,10 +27,15 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	_ "rest-api/docs"
+	"rest-api/internal/cassette"
 	"rest-api/internal/config"
+	"rest-api/internal/fakeopenrouter"
 	"rest-api/internal/handlers"
 	"rest-api/internal/middleware"
+	"rest-api/internal/progression"
+	"rest-api/internal/prompts"
 	"rest-api/internal/repository"
+	"rest-api/internal/safety"
 	"rest-api/internal/services"
 )
 
@@ -59,7 +65,39 @@ func main() {
 	// Initialize services
 	authService := services.NewAuthService(postgresRepo, cfg.JWTSecret, cfg.JWTExpiration, cfg.RefreshExpiration)
 	profileService := services.NewProfileService(postgresRepo)
-	aiService := services.NewAIService(postgresRepo, mongoRepo, cfg.OpenRouterKey)
+	promptStore, err := prompts.Load(cfg.PromptsDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+	safetyGuard, err := safety.Load(cfg.SafetyRulesFile)
+	if err != nil {
+		log.Fatalf("Failed to load safety rules: %v", err)
+	}
+	progressionModels, err := progression.Load(cfg.ProgressionFile)
+	if err != nil {
+		log.Fatalf("Failed to load progression models: %v", err)
+	}
+	aiKey := cfg.OpenRouterKey
+	if cfg.AIProvider == "fake" {
+		// The real key never needs to reach the fake
+		aiKey = "fake-key"
+	}
+	aiService := services.NewAIService(postgresRepo, mongoRepo, aiKey, cfg.ModelCatalog, promptStore)
+	stopAIProvider, err := configureAIProvider(cfg, aiService.Client)
+	if err != nil {
+		log.Fatalf("Failed to set up AI provider: %v", err)
+	}
+	aiService.Safety = safetyGuard
+	aiService.Progression = progressionModels
+	aiService.StartPlanJobs(cfg.PlanJobWorkers, cfg.PlanJobQueue)
+	aiService.Cache, err = newAICache(cfg, mongoRepo)
+	if err != nil {
+		log.Fatalf("Failed to set up AI cache: %v", err)
+	}
+	aiService.Quota = services.AIQuota{
+		DailyTokens:   cfg.AIDailyTokenQuota,
+		MonthlyTokens: cfg.AIMonthlyTokenQuota,
+	}
 	healthService := services.NewHealthService(postgresRepo)
 	mediaService := services.NewMediaService(postgresRepo, mongoRepo)
 
@@ -84,16 +122,37 @@ func main() {
 	// Authenticated routes
 	authRouter := r.PathPrefix("/api").Subrouter()
 	authRouter.Use(h.AuthMiddleware)
+	authRouter.Use(middleware.AICacheMiddleware)
+	authRouter.Use(middleware.LanguageMiddleware)
 	{
 		authRouter.HandleFunc("/profile", h.SaveProfile).Methods("POST")
 		authRouter.HandleFunc("/profile", h.GetProfile).Methods("GET")
 		authRouter.HandleFunc("/chat", h.Chat).Methods("POST")
+		authRouter.HandleFunc("/chat/stream", h.ChatStream).Methods("POST")
 		authRouter.HandleFunc("/chat/history", h.GetChatHistory).Methods("GET")
+		authRouter.HandleFunc("/chat/threads", h.CreateChatThread).Methods("POST")
+		authRouter.HandleFunc("/chat/threads", h.GetChatThreads).Methods("GET")
+		authRouter.HandleFunc("/chat/threads/{thread_id}", h.UpdateChatThread).Methods("PATCH")
+		authRouter.HandleFunc("/chat/threads/{thread_id}", h.DeleteChatThread).Methods("DELETE")
+		authRouter.HandleFunc("/chat/actions/{action_id}/confirm", h.ConfirmChatAction).Methods("POST")
+		authRouter.HandleFunc("/chat/actions/{action_id}/cancel", h.CancelChatAction).Methods("POST")
+		authRouter.HandleFunc("/chat/{message_id}/feedback", h.RateChatMessage).Methods("POST")
+		authRouter.HandleFunc("/chat/{message_id}/regenerate", h.RegenerateChatMessage).Methods("POST")
 		authRouter.HandleFunc("/generate-plan", h.GeneratePlan).Methods("POST")
 		authRouter.HandleFunc("/workout-plan", h.GetWorkoutPlan).Methods("GET")
+		authRouter.HandleFunc("/workout-plan/versions", h.GetWorkoutPlanVersions).Methods("GET")
+		authRouter.HandleFunc("/workout-plan/versions/diff", h.DiffWorkoutPlanVersions).Methods("GET")
+		authRouter.HandleFunc("/workout-plan/versions/{version_id}/restore", h.RestoreWorkoutPlanVersion).Methods("POST")
 		authRouter.HandleFunc("/regenerate-plan", h.RegenerateWorkoutPlan).Methods("POST")
+		authRouter.HandleFunc("/workouts/{workout_id}/regenerate", h.RegenerateWorkout).Methods("POST")
+		authRouter.HandleFunc("/workouts/{workout_id}/exercises/{exercise_id}/substitute", h.SubstituteExercise).Methods("POST")
+		authRouter.HandleFunc("/generate-meal-plan", h.GenerateMealPlan).Methods("POST")
+		authRouter.HandleFunc("/meal-plan", h.GetMealPlan).Methods("GET")
+		authRouter.HandleFunc("/jobs/{job_id}", h.GetPlanJob).Methods("GET")
+		authRouter.HandleFunc("/jobs/{job_id}/events", h.StreamPlanJob).Methods("GET")
 		authRouter.HandleFunc("/complete-workout", h.CompleteWorkout).Methods("POST")
 		authRouter.HandleFunc("/progress", h.GetUserProgress).Methods("GET")
+		authRouter.HandleFunc("/reports/weekly", h.GetWeeklyReport).Methods("GET")
 
 		// Exercise media routes
 		authRouter.HandleFunc("/exercise/{exercise_id}/media", h.GetExerciseMedia).Methods("GET")
@@ -101,14 +160,27 @@ func main() {
 		authRouter.HandleFunc("/exercise/media/{media_id}", h.DeleteExerciseMedia).Methods("DELETE")
 		authRouter.HandleFunc("/rating", h.GetRating).Methods("GET")
 		authRouter.HandleFunc("/motivation", h.GetMotivationalMessage).Methods("GET")
+		authRouter.HandleFunc("/usage", h.GetUsage).Methods("GET")
 	}
 
-	// Start server
+	// Admin routes
+	adminRouter := r.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(middleware.AdminMiddleware(cfg.AdminAPIKey))
+	{
+		adminRouter.HandleFunc("/ai/models", h.GetAIModels).Methods("GET")
+		adminRouter.HandleFunc("/ai/cache", h.GetAICacheStats).Methods("GET")
+		adminRouter.HandleFunc("/ai/usage", h.GetAIUsageReport).Methods("GET")
+		adminRouter.HandleFunc("/safety/events", h.GetSafetyEvents).Methods("GET")
+		adminRouter.HandleFunc("/chat/feedback", h.GetRatedChatExchanges).Methods("GET")
+	}
+
+	// Start server. Plan generation runs in jobs and streams lift the write
+	// deadline, so WriteTimeout only has to cover interactive AI calls.
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      r,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 300 * time.Second,
+		WriteTimeout: 120 * time.Second,
 		IdleTimeout:  180 * time.Second,
 	}
 
@@ -120,6 +192,15 @@ func main() {
 		}
 	}()
 
+	// Reload the AI model catalog and prompt templates on SIGHUP
+	go watchReloadSignal(cfg, aiService)
+
+	// Generate the weekly reports of active users in the background
+	reportsCtx, stopReports := context.WithCancel(context.Background())
+	if cfg.WeeklyReportInterval > 0 {
+		aiService.StartWeeklyReports(reportsCtx, cfg.WeeklyReportInterval)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -132,9 +213,127 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server shutdown failed: %v", err)
 	}
+	stopReports()
+	if err := aiService.WaitBackground(ctx); err != nil {
+		log.Printf("Background AI work did not finish: %v", err)
+	}
+	stopAIProvider()
 	log.Println("Server shutdown gracefully")
 }
 
+// watchReloadSignal reloads the AI model catalog, the prompt templates and the
+// safety rules every time SIGHUP is received. Invalid files are rejected and the current ones stay in use.
+func watchReloadSignal(cfg *config.Config, aiService *services.AIService) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		if client := aiService.Client; client != nil {
+			if err := reloadModelCatalog(cfg, client); err != nil {
+				log.Printf("Failed to reload AI model catalog: %v", err)
+			} else {
+				log.Printf("AI model catalog reloaded: %d enabled models", len(client.Catalog().EnabledModels()))
+			}
+		}
+
+		if err := aiService.Prompts.Reload(); err != nil {
+			log.Printf("Failed to reload prompt templates: %v", err)
+		} else {
+			log.Println("Prompt templates reloaded")
+		}
+
+		if err := aiService.Safety.Reload(); err != nil {
+			log.Printf("Failed to reload safety rules: %v", err)
+		} else {
+			log.Printf("Safety rules reloaded: %d rules", aiService.Safety.RuleCount())
+		}
+
+		if err := aiService.Progression.Reload(); err != nil {
+			log.Printf("Failed to reload progression models: %v", err)
+		} else {
+			log.Printf("Progression models reloaded: %d models", aiService.Progression.ModelCount())
+		}
+	}
+}
+
+func reloadModelCatalog(cfg *config.Config, client *services.OpenRouterClient) error {
+	// AI_MODELS cannot change in a running process, so reloading only picks up file edits
+	catalog, err := config.LoadModelCatalog(cfg.AIModels, cfg.AIModelsFile)
+	if err != nil {
+		return err
+	}
+
+	client.SetCatalog(catalog)
+	return nil
+}
+
+// newAICache creates the AI response cache configured by AI_CACHE, nil when
+// it is off. The MongoDB backend gets its indexes here.
+func newAICache(cfg *config.Config, mongoRepo repository.MongoDBRep) (*services.ResponseCache, error) {
+	var store services.AICache
+	switch cfg.AICache {
+	case "memory":
+		store = services.NewLRUCache(cfg.AICacheSize)
+	case "mongo":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := mongoRepo.EnsureAICacheIndexes(ctx); err != nil {
+			return nil, fmt.Errorf("failed to create AI cache indexes: %w", err)
+		}
+		store = services.NewMongoAICache(mongoRepo)
+	default:
+		log.Println("INFO: AI response cache is disabled")
+		return nil, nil
+	}
+
+	return services.NewResponseCache(cfg.AICache, store, map[string]time.Duration{
+		services.AICallPlan:       cfg.AICachePlanTTL,
+		services.AICallMotivation: cfg.AICacheMotivationTTL,
+	}), nil
+}
+
+// configureAIProvider points the AI client at the fake provider and records
+// or replays its calls with a cassette, as configured. The returned function
+// stops the fake.
+func configureAIProvider(cfg *config.Config, client *services.OpenRouterClient) (func(), error) {
+	stop := func() {}
+	if client == nil {
+		return stop, nil
+	}
+
+	if cfg.AIProvider == "fake" {
+		// Without a script file every request gets the default answer
+		var script fakeopenrouter.Script
+		loaded, err := fakeopenrouter.LoadScript(cfg.AIFakeScript)
+		switch {
+		case err == nil:
+			script = loaded
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+
+		baseURL, closeFake, err := fakeopenrouter.Start(fakeopenrouter.New(script))
+		if err != nil {
+			return nil, err
+		}
+		client.SetBaseURL(baseURL)
+		stop = func() { _ = closeFake() }
+		log.Printf("INFO: Using the fake AI provider at %s", baseURL)
+	}
+
+	if cfg.AICassetteMode != "off" {
+		transport, err := cassette.New(cfg.AICassetteFile, cfg.AICassetteMode, nil, cfg.OpenRouterKey)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		client.SetTransport(transport)
+		log.Printf("INFO: AI provider calls are %sed with cassette %s", cfg.AICassetteMode, cfg.AICassetteFile)
+	}
+
+	return stop, nil
+}
+
 // runMigrations executes database migrations
 func runMigrations(databaseURL string) error {
 	// Use the migrations directory in the current working directory
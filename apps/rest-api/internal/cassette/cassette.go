@@ -0,0 +1,259 @@
+// Package cassette records HTTP interactions to a JSON file and replays them,
+// so code talking to the LLM provider can be tested without network access.
+//
+// In record mode requests go to the real transport and every interaction is
+// appended to the cassette with secrets redacted. In replay mode nothing is
+// sent; each request is answered by the first unused recorded interaction
+// with the same method, URL and body.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Modes of a Transport
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// Redacted replaces secrets in recorded interactions
+const Redacted = "REDACTED"
+
+// redactedHeaders never reach a cassette
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	// Body is the complete body, for streams all server-sent events
+	Body string `json:"body"`
+}
+
+// Cassette is the file format
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Transport records or replays interactions. It is safe for concurrent use.
+type Transport struct {
+	path string
+	mode string
+	// next sends requests in record mode
+	next    http.RoundTripper
+	secrets []string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New opens the cassette at path. Replay requires an existing cassette;
+// record starts a new one, replacing the file on the first interaction.
+// next is the transport used for recording, nil means http.DefaultTransport.
+// Occurrences of secrets in URLs, headers and bodies are redacted.
+func New(path, mode string, next http.RoundTripper, secrets ...string) (*Transport, error) {
+	t := &Transport{path: path, mode: mode, next: next}
+	for _, secret := range secrets {
+		if secret != "" {
+			t.secrets = append(t.secrets, secret)
+		}
+	}
+
+	switch mode {
+	case ModeRecord:
+		if t.next == nil {
+			t.next = http.DefaultTransport
+		}
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+		t.used = make([]bool, len(t.cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("invalid cassette mode %q: must be record or replay", mode)
+	}
+	return t, nil
+}
+
+// Interactions returns a copy of the recorded or loaded interactions
+func (t *Transport) Interactions() []Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Interaction(nil), t.cassette.Interactions...)
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.mode == ModeReplay {
+		return t.replay(req, body)
+	}
+	return t.record(req, body)
+}
+
+func (t *Transport) replay(req *http.Request, body []byte) (*http.Response, error) {
+	url := t.redact(req.URL.String())
+	key := canonicalBody(t.redact(string(body)))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, interaction := range t.cassette.Interactions {
+		recorded := interaction.Request
+		if t.used[i] || recorded.Method != req.Method || recorded.URL != url || canonicalBody(recorded.Body) != key {
+			continue
+		}
+		t.used[i] = true
+		return newResponse(req, interaction.Response), nil
+	}
+	return nil, fmt.Errorf("cassette %s: no recorded interaction for %s %s", t.path, req.Method, url)
+}
+
+// record sends the request and stores the interaction. The response body is
+// read completely before it is returned, so streams arrive all at once.
+func (t *Transport) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for cassette: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     t.redact(req.URL.String()),
+			Headers: t.redactHeaders(req.Header),
+			Body:    t.redact(string(body)),
+		},
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: t.redactHeaders(resp.Header),
+			Body:    t.redact(string(respBody)),
+		},
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	if err := t.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// save writes the whole cassette, so it is complete after every interaction
+func (t *Transport) save() error {
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(t.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+func (t *Transport) redact(text string) string {
+	for _, secret := range t.secrets {
+		text = strings.ReplaceAll(text, secret, Redacted)
+	}
+	return text
+}
+
+func (t *Transport) redactHeaders(headers http.Header) http.Header {
+	redacted := make(http.Header, len(headers))
+	for name, values := range headers {
+		for _, value := range values {
+			redacted.Add(name, t.redact(value))
+		}
+	}
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, Redacted)
+		}
+	}
+	return redacted
+}
+
+// readRequestBody reads the body and restores it for the next transport
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request for cassette: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// canonicalBody makes JSON bodies comparable regardless of key order and
+// whitespace, other bodies are compared as they are
+func canonicalBody(body string) string {
+	var value any
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return body
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return string(canonical)
+}
+
+func newResponse(req *http.Request, recorded Response) *http.Response {
+	headers := recorded.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}
+}
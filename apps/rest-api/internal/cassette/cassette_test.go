@@ -0,0 +1,108 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func post(t *testing.T, client *http.Client, url, body string) (int, string, error) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-secret")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), nil
+}
+
+func TestTransport_RecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("echo " + string(body)))
+	}))
+	path := filepath.Join(t.TempDir(), "cassettes", "test.json")
+
+	recorder, err := New(path, ModeRecord, nil, "sk-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body, err := post(t, &http.Client{Transport: recorder}, server.URL+"/chat", `{"b": 2, "a": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusCreated || body != `echo {"b": 2, "a": 1}` {
+		t.Errorf("Expected the real response while recording, got %d '%s'", status, body)
+	}
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-secret") || !strings.Contains(string(data), Redacted) {
+		t.Errorf("Expected the key to be redacted, got %s", data)
+	}
+
+	player, err := New(path, ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: player}
+
+	// Key order and whitespace of JSON bodies do not matter
+	status, body, err = post(t, client, server.URL+"/chat", `{"a":1,"b":2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusCreated || body != `echo {"b": 2, "a": 1}` {
+		t.Errorf("Expected the recorded response, got %d '%s'", status, body)
+	}
+	if calls != 1 {
+		t.Errorf("Expected replay to send nothing, got %d calls", calls)
+	}
+
+	// Every interaction is replayed once
+	if _, _, err := post(t, client, server.URL+"/chat", `{"a":1,"b":2}`); err == nil {
+		t.Error("Expected an error when the interaction was already used")
+	}
+	if _, _, err := post(t, client, server.URL+"/chat", `{"a":2}`); err == nil {
+		t.Error("Expected an error for an unrecorded request")
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte("{"), 0o644)
+
+	testCases := []struct {
+		name string
+		path string
+		mode string
+	}{
+		{"unknown mode", filepath.Join(dir, "a.json"), "rewind"},
+		{"missing cassette", filepath.Join(dir, "missing.json"), ModeReplay},
+		{"invalid cassette", invalid, ModeReplay},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := New(tc.path, tc.mode, nil); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
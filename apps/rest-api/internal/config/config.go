@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -19,6 +22,34 @@ type Config struct {
 	MongoURI          string
 	MongoDBName       string
 	SkipDatabase      bool
+	AdminAPIKey       string
+	AIModelsFile      string
+	AIModels          string
+	ModelCatalog      *ModelCatalog
+	PromptsDir        string
+	// SafetyRulesFile replaces the built-in health-safety rules when it exists
+	SafetyRulesFile string
+	// ProgressionFile replaces the built-in progression models when it exists
+	ProgressionFile string
+	// AIProvider is openrouter or fake, the local scripted stand-in
+	AIProvider   string
+	AIFakeScript string
+	// AICassetteMode records AI provider calls to AICassetteFile or replays them: off, record or replay
+	AICassetteMode string
+	AICassetteFile string
+	// AICache is the AI response cache backend: memory, mongo or off
+	AICache              string
+	AICacheSize          int
+	AICachePlanTTL       time.Duration
+	AICacheMotivationTTL time.Duration
+	// Token quotas per user, 0 disables the quota
+	AIDailyTokenQuota   int
+	AIMonthlyTokenQuota int
+	// Plan generation jobs run in a pool of PlanJobWorkers, at most PlanJobQueue wait
+	PlanJobWorkers int
+	PlanJobQueue   int
+	// WeeklyReportInterval is how often missing weekly reports are generated, 0 disables it
+	WeeklyReportInterval time.Duration
 }
 
 func Load() (*Config, error) {
@@ -37,17 +68,65 @@ func Load() (*Config, error) {
 		MongoURI:          getEnv("MONGOURI", "mongodb://localhost:27017/fitness_ai"),
 		MongoDBName:       getEnv("MONGODBNAME", "fitness_ai"),
 		SkipDatabase:      getEnv("SKIP_DATABASE", "") != "",
+		AdminAPIKey:       getEnv("ADMIN_API_KEY", ""),
+		AIModelsFile:      getEnv("AI_MODELS_FILE", "config/ai_models.json"),
+		AIModels:          getEnv("AI_MODELS", ""),
+		PromptsDir:        getEnv("PROMPTS_DIR", "config/prompts"),
+		SafetyRulesFile:   getEnv("SAFETY_RULES_FILE", "config/safety_rules.json"),
+		ProgressionFile:   getEnv("PROGRESSION_FILE", "config/progression.json"),
+		AIProvider:        getEnv("AI_PROVIDER", "openrouter"),
+		AIFakeScript:      getEnv("AI_FAKE_SCRIPT", "config/fake_ai.json"),
+		AICassetteMode:    getEnv("AI_CASSETTE_MODE", "off"),
+		AICassetteFile:    getEnv("AI_CASSETTE_FILE", "testdata/cassettes/ai.json"),
+		AICache:           getEnv("AI_CACHE", "memory"),
+		AICacheSize:       parseInt(getEnv("AI_CACHE_SIZE", "1000"), 1000),
+		// Plans depend only on the profile, motivation on progress that changes daily
+		AICachePlanTTL:       parseDuration(getEnv("AI_CACHE_PLAN_TTL", "24h")),
+		AICacheMotivationTTL: parseDuration(getEnv("AI_CACHE_MOTIVATION_TTL", "6h")),
+		AIDailyTokenQuota:    parseQuota(getEnv("AI_DAILY_TOKEN_QUOTA", "200000")),
+		AIMonthlyTokenQuota:  parseQuota(getEnv("AI_MONTHLY_TOKEN_QUOTA", "3000000")),
+		PlanJobWorkers:       parseInt(getEnv("PLAN_JOB_WORKERS", "4"), 4),
+		PlanJobQueue:         parseInt(getEnv("PLAN_JOB_QUEUE", "100"), 100),
+		WeeklyReportInterval: parseDuration(getEnv("WEEKLY_REPORT_INTERVAL", "1h")),
 	}
 
+	switch cfg.AICache {
+	case "memory", "mongo", "off":
+	default:
+		return nil, fmt.Errorf("invalid AI_CACHE %q: must be memory, mongo or off", cfg.AICache)
+	}
+
+	switch cfg.AIProvider {
+	case "openrouter", "fake":
+	default:
+		return nil, fmt.Errorf("invalid AI_PROVIDER %q: must be openrouter or fake", cfg.AIProvider)
+	}
+
+	switch cfg.AICassetteMode {
+	case "off", "record", "replay":
+	default:
+		return nil, fmt.Errorf("invalid AI_CASSETTE_MODE %q: must be off, record or replay", cfg.AICassetteMode)
+	}
+
+	catalog, err := LoadModelCatalog(cfg.AIModels, cfg.AIModelsFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AI model catalog: %w", err)
+	}
+	cfg.ModelCatalog = catalog
+
 	// Validate required fields
 	if !cfg.SkipDatabase && cfg.DatabaseURL == "" {
 		log.Fatal("DATABASE_URL is required")
 	}
 
-	if cfg.OpenRouterKey == "" {
+	if cfg.OpenRouterKey == "" && cfg.AIProvider != "fake" {
 		log.Println("WARNING: OPENROUTER_KEY is not set - AI features will be disabled")
 	}
 
+	if cfg.AdminAPIKey == "" {
+		log.Println("INFO: ADMIN_API_KEY is not set - admin endpoints are disabled")
+	}
+
 	if cfg.SkipDatabase {
 		log.Println("INFO: Running in database-free mode for AI testing")
 	}
@@ -55,6 +134,36 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// String prints the configuration without its secrets, the passwords of the
+// database URLs are masked
+func (c Config) String() string {
+	type plain Config
+	redacted := plain(c)
+	redacted.JWTSecret = redact(c.JWTSecret)
+	redacted.OpenRouterKey = redact(c.OpenRouterKey)
+	redacted.AdminAPIKey = redact(c.AdminAPIKey)
+	redacted.DatabaseURL = redactURL(c.DatabaseURL)
+	redacted.MongoURI = redactURL(c.MongoURI)
+	return fmt.Sprintf("%+v", redacted)
+}
+
+// redact hides a secret, an unset one stays empty
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+// redactURL masks the password of a URL, a URL that cannot be parsed is hidden
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return redact(value)
+	}
+	return u.Redacted()
+}
+
 // Helper function to read environment variables with fallback
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -72,3 +181,23 @@ func parseDuration(durationStr string) time.Duration {
 	}
 	return duration
 }
+
+// Helper function to parse a positive integer from env
+func parseInt(value string, fallback int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid integer '%s', defaulting to %d", value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// Helper function to parse a token quota from env, 0 means unlimited
+func parseQuota(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid token quota '%s', disabling the quota", value)
+		return 0
+	}
+	return n
+}
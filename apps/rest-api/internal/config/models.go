@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// AIModel describes one OpenRouter model and the parameters used when calling it
+type AIModel struct {
+	ID                     string  `json:"id"`
+	Priority               int     `json:"priority"`
+	SupportsJSON           bool    `json:"supports_json"`
+	SupportsTools          bool    `json:"supports_tools"`
+	ContextLength          int     `json:"context_length"`
+	MaxTokens              int     `json:"max_tokens"`
+	Temperature            float64 `json:"temperature"`
+	CostPerPromptToken     float64 `json:"cost_per_prompt_token"`
+	CostPerCompletionToken float64 `json:"cost_per_completion_token"`
+	Enabled                bool    `json:"enabled"`
+}
+
+// ModelCatalog is the set of AI models the server may route requests to
+type ModelCatalog struct {
+	Models []AIModel `json:"models"`
+}
+
+// DefaultModelCatalog is used when neither AI_MODELS nor the catalog file is provided
+func DefaultModelCatalog() *ModelCatalog {
+	return &ModelCatalog{
+		Models: []AIModel{
+			{ID: "deepseek/deepseek-chat-v3-0324:free", Priority: 1, SupportsJSON: true, SupportsTools: true, ContextLength: 163840, MaxTokens: 8192, Temperature: 0.7, Enabled: true},
+			{ID: "meta-llama/llama-3.3-70b-instruct:free", Priority: 2, SupportsJSON: true, SupportsTools: true, ContextLength: 131072, MaxTokens: 8192, Temperature: 0.7, Enabled: true},
+			{ID: "google/gemma-3-27b-it:free", Priority: 3, SupportsJSON: true, ContextLength: 96000, MaxTokens: 8192, Temperature: 0.7, Enabled: true},
+			{ID: "mistralai/mistral-small-3.1-24b-instruct:free", Priority: 4, SupportsJSON: true, SupportsTools: true, ContextLength: 96000, MaxTokens: 8192, Temperature: 0.7, Enabled: true},
+			{ID: "qwen/qwen-2.5-72b-instruct:free", Priority: 5, SupportsJSON: true, SupportsTools: true, ContextLength: 32768, MaxTokens: 8192, Temperature: 0.7, Enabled: true},
+		},
+	}
+}
+
+// LoadModelCatalog reads the catalog from inline JSON (AI_MODELS) if given,
+// otherwise from the catalog file. A missing file falls back to the defaults.
+func LoadModelCatalog(inline, path string) (*ModelCatalog, error) {
+	var data []byte
+	switch {
+	case inline != "":
+		data = []byte(inline)
+	case path != "":
+		fileData, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return DefaultModelCatalog(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read model catalog %s: %w", path, err)
+		}
+		data = fileData
+	default:
+		return DefaultModelCatalog(), nil
+	}
+
+	var catalog ModelCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse model catalog: %w", err)
+	}
+
+	if err := catalog.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &catalog, nil
+}
+
+// Validate checks that the catalog is usable: unique IDs, sane parameters
+// and at least one enabled model
+func (c *ModelCatalog) Validate() error {
+	if len(c.Models) == 0 {
+		return errors.New("model catalog is empty")
+	}
+
+	seen := make(map[string]bool)
+	enabled := 0
+	for i, m := range c.Models {
+		if m.ID == "" {
+			return fmt.Errorf("model #%d: id is required", i+1)
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("model %s: duplicate id", m.ID)
+		}
+		seen[m.ID] = true
+
+		if m.Priority < 1 {
+			return fmt.Errorf("model %s: priority must be >= 1", m.ID)
+		}
+		if m.ContextLength <= 0 {
+			return fmt.Errorf("model %s: context_length must be positive", m.ID)
+		}
+		if m.MaxTokens < 0 || m.MaxTokens > m.ContextLength {
+			return fmt.Errorf("model %s: max_tokens must be between 0 and context_length", m.ID)
+		}
+		if m.Temperature < 0 || m.Temperature > 2 {
+			return fmt.Errorf("model %s: temperature must be between 0 and 2", m.ID)
+		}
+		if m.CostPerPromptToken < 0 || m.CostPerCompletionToken < 0 {
+			return fmt.Errorf("model %s: costs must not be negative", m.ID)
+		}
+		if m.Enabled {
+			enabled++
+		}
+	}
+
+	if enabled == 0 {
+		return errors.New("model catalog has no enabled models")
+	}
+
+	return nil
+}
+
+// EnabledModels returns the enabled models ordered by priority
+func (c *ModelCatalog) EnabledModels() []AIModel {
+	var result []AIModel
+	for _, m := range c.Models {
+		if m.Enabled {
+			result = append(result, m)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Priority < result[j].Priority
+	})
+	return result
+}
+
+// Find returns the model with the given ID
+func (c *ModelCatalog) Find(id string) (AIModel, bool) {
+	for _, m := range c.Models {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return AIModel{}, false
+}
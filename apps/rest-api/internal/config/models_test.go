@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultModelCatalog_Valid(t *testing.T) {
+	if err := DefaultModelCatalog().Validate(); err != nil {
+		t.Errorf("Expected default catalog to be valid, got %v", err)
+	}
+}
+
+func TestLoadModelCatalog_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ai_models.json")
+	content := `{"models":[
+		{"id":"model-b","priority":2,"context_length":8000,"max_tokens":1000,"temperature":0.5,"enabled":true},
+		{"id":"model-a","priority":1,"context_length":8000,"max_tokens":1000,"temperature":0.5,"enabled":true},
+		{"id":"model-c","priority":3,"context_length":8000,"enabled":false}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, err := LoadModelCatalog("", path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	enabled := catalog.EnabledModels()
+	if len(enabled) != 2 {
+		t.Fatalf("Expected 2 enabled models, got %d", len(enabled))
+	}
+	if enabled[0].ID != "model-a" {
+		t.Errorf("Expected model-a first by priority, got %s", enabled[0].ID)
+	}
+}
+
+func TestLoadModelCatalog_InlineTakesPrecedence(t *testing.T) {
+	inline := `{"models":[{"id":"inline-model","priority":1,"context_length":4096,"enabled":true}]}`
+
+	catalog, err := LoadModelCatalog(inline, "does-not-exist.json")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := catalog.Find("inline-model"); !ok {
+		t.Error("Expected inline catalog to be used")
+	}
+}
+
+func TestLoadModelCatalog_MissingFileUsesDefaults(t *testing.T) {
+	catalog, err := LoadModelCatalog("", filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(catalog.Models) != len(DefaultModelCatalog().Models) {
+		t.Error("Expected default catalog when the file is missing")
+	}
+}
+
+func TestModelCatalog_Validate(t *testing.T) {
+	valid := AIModel{ID: "model", Priority: 1, ContextLength: 8000, MaxTokens: 1000, Temperature: 0.7, Enabled: true}
+
+	testCases := []struct {
+		name   string
+		mutate func(m *AIModel)
+	}{
+		{"missing id", func(m *AIModel) { m.ID = "" }},
+		{"zero priority", func(m *AIModel) { m.Priority = 0 }},
+		{"zero context length", func(m *AIModel) { m.ContextLength = 0 }},
+		{"max tokens above context", func(m *AIModel) { m.MaxTokens = 9000 }},
+		{"temperature too high", func(m *AIModel) { m.Temperature = 2.5 }},
+		{"negative cost", func(m *AIModel) { m.CostPerPromptToken = -1 }},
+		{"nothing enabled", func(m *AIModel) { m.Enabled = false }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := valid
+			tc.mutate(&m)
+			catalog := ModelCatalog{Models: []AIModel{m}}
+			if err := catalog.Validate(); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+
+	duplicate := ModelCatalog{Models: []AIModel{valid, valid}}
+	if err := duplicate.Validate(); err == nil {
+		t.Error("Expected error for duplicate ids")
+	}
+}
+
+func TestLoadModelCatalog_RepositoryFile(t *testing.T) {
+	if _, err := LoadModelCatalog("", "../../config/ai_models.json"); err != nil {
+		t.Errorf("Expected shipped catalog to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+// Package fakeopenrouter is a local stand-in for the OpenRouter chat
+// completions API. It answers from a script instead of a model, so the AI
+// features can be tested and run without an API key, costs or latency.
+//
+// A reply can be content, tool calls, an HTTP error, a malformed body or a
+// stream that is slow or breaks midway. Replies queued with Enqueue are
+// served first, in order; otherwise the first matching script rule answers,
+// and the script's default reply covers everything else.
+package fakeopenrouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultContent answers requests no rule matches
+const defaultContent = "This is a response from the fake AI provider."
+
+// Message is a chat message of a request
+type Message struct {
+	Role       string          `json:"role"`
+	Content    string          `json:"content"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// Request is a received chat completion request
+type Request struct {
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Stream         bool            `json:"stream,omitempty"`
+	Tools          json.RawMessage `json:"tools,omitempty"`
+	ResponseFormat json.RawMessage `json:"response_format,omitempty"`
+}
+
+// LastMessage is the content of the newest message
+func (r Request) LastMessage() string {
+	if len(r.Messages) == 0 {
+		return ""
+	}
+	return r.Messages[len(r.Messages)-1].Content
+}
+
+// Usage is the token usage reported with a reply
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Reply is what the fake answers
+type Reply struct {
+	Content string `json:"content,omitempty"`
+	// ToolCalls are sent as the message's tool_calls, as OpenRouter encodes them
+	ToolCalls json.RawMessage `json:"tool_calls,omitempty"`
+	Usage     *Usage          `json:"usage,omitempty"`
+
+	// Status answers with an HTTP error and the Error message
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	RetryAfter string `json:"retry_after,omitempty"`
+	// Raw is sent as the body (or as the only stream event) instead of a valid completion
+	Raw string `json:"raw,omitempty"`
+
+	// Streams send ChunkSize characters per event (default 8), ChunkDelayMS apart
+	ChunkSize    int `json:"chunk_size,omitempty"`
+	ChunkDelayMS int `json:"chunk_delay_ms,omitempty"`
+	// StreamError ends a stream with an error event after the content
+	StreamError string `json:"stream_error,omitempty"`
+	// DelayMS waits before answering at all
+	DelayMS int `json:"delay_ms,omitempty"`
+}
+
+// Rule answers requests matching all of its conditions
+type Rule struct {
+	// Model must equal the requested model
+	Model string `json:"model,omitempty"`
+	// Contains must be part of the last message
+	Contains string `json:"contains,omitempty"`
+	// System must be part of the first (system) message
+	System string `json:"system,omitempty"`
+	// Times limits how often the rule answers, 0 means unlimited
+	Times int   `json:"times,omitempty"`
+	Reply Reply `json:"reply"`
+}
+
+func (r *Rule) matches(req Request) bool {
+	if r.Model != "" && r.Model != req.Model {
+		return false
+	}
+	if r.Contains != "" && !strings.Contains(req.LastMessage(), r.Contains) {
+		return false
+	}
+	if r.System != "" && (len(req.Messages) == 0 || !strings.Contains(req.Messages[0].Content, r.System)) {
+		return false
+	}
+	return true
+}
+
+// Script configures the answers of a Server
+type Script struct {
+	Rules   []Rule `json:"rules"`
+	Default *Reply `json:"default,omitempty"`
+}
+
+// LoadScript reads a script from a JSON file
+func LoadScript(path string) (Script, error) {
+	var script Script
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return script, fmt.Errorf("failed to read fake AI script: %w", err)
+	}
+	if err := json.Unmarshal(data, &script); err != nil {
+		return script, fmt.Errorf("failed to parse fake AI script %s: %w", path, err)
+	}
+	return script, nil
+}
+
+// Server is the fake API as an http.Handler. It is safe for concurrent use.
+type Server struct {
+	mu       sync.Mutex
+	script   Script
+	used     []int
+	queue    []Reply
+	requests []Request
+}
+
+// New creates a fake answering from script
+func New(script Script) *Server {
+	return &Server{script: script, used: make([]int, len(script.Rules))}
+}
+
+// Enqueue adds replies that answer the next requests, before any rule
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, replies...)
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// next records the request and picks its reply
+func (s *Server) next(req Request) Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+
+	if len(s.queue) > 0 {
+		reply := s.queue[0]
+		s.queue = s.queue[1:]
+		return reply
+	}
+	for i := range s.script.Rules {
+		rule := &s.script.Rules[i]
+		if (rule.Times > 0 && s.used[i] >= rule.Times) || !rule.matches(req) {
+			continue
+		}
+		s.used[i]++
+		return rule.Reply
+	}
+	if s.script.Default != nil {
+		return *s.script.Default
+	}
+	return Reply{Content: defaultContent}
+}
+
+// ServeHTTP answers POST /chat/completions under any prefix, e.g. /api/v1
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	reply := s.next(req)
+	if !sleep(r, reply.DelayMS) {
+		return
+	}
+
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		if reply.RetryAfter != "" {
+			w.Header().Set("Retry-After", reply.RetryAfter)
+		}
+		writeError(w, reply.Status, reply.Error)
+		return
+	}
+
+	if req.Stream {
+		s.stream(w, r, reply)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if reply.Raw != "" {
+		fmt.Fprint(w, reply.Raw)
+		return
+	}
+
+	message := map[string]any{"role": "assistant", "content": reply.Content}
+	if len(reply.ToolCalls) > 0 {
+		message["tool_calls"] = reply.ToolCalls
+	}
+	response := map[string]any{
+		"choices": []any{map[string]any{"message": message}},
+	}
+	if reply.Usage != nil {
+		response["usage"] = reply.Usage
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// stream sends the content as server-sent events like OpenRouter does
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, reply Reply) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	send := func(data string) {
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// Keep-alive comments are sent before the first token
+	fmt.Fprint(w, ": OPENROUTER PROCESSING\n\n")
+
+	if reply.Raw != "" {
+		send(reply.Raw)
+		return
+	}
+
+	size := reply.ChunkSize
+	if size <= 0 {
+		size = 8
+	}
+	content := []rune(reply.Content)
+	for start := 0; start < len(content); start += size {
+		if start > 0 && !sleep(r, reply.ChunkDelayMS) {
+			return
+		}
+		delta, _ := json.Marshal(string(content[start:min(start+size, len(content))]))
+		send(fmt.Sprintf(`{"choices":[{"delta":{"content":%s}}]}`, delta))
+	}
+
+	if reply.StreamError != "" {
+		message, _ := json.Marshal(reply.StreamError)
+		send(fmt.Sprintf(`{"error":{"message":%s,"code":502}}`, message))
+		return
+	}
+
+	final := map[string]any{"choices": []any{map[string]any{"delta": map[string]any{}, "finish_reason": "stop"}}}
+	if reply.Usage != nil {
+		final["usage"] = reply.Usage
+	}
+	encoded, _ := json.Marshal(final)
+	send(string(encoded))
+	send("[DONE]")
+}
+
+// sleep waits ms milliseconds and reports false when the client went away
+func sleep(r *http.Request, ms int) bool {
+	if ms <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "code": status},
+	})
+}
+
+// Start serves the fake on a free loopback port and returns its base URL,
+// to be used in place of https://openrouter.ai/api/v1
+func Start(s *Server) (string, func() error, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to listen for the fake AI provider: %w", err)
+	}
+
+	server := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Fake AI provider stopped: %v\n", err)
+		}
+	}()
+
+	return "http://" + listener.Addr().String() + "/api/v1", server.Close, nil
+}
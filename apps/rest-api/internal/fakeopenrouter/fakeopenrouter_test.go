@@ -0,0 +1,142 @@
+package fakeopenrouter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func complete(t *testing.T, url string, request string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Post(url+"/api/v1/chat/completions", "application/json", strings.NewReader(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestServer_Replies(t *testing.T) {
+	fake := New(Script{Rules: []Rule{
+		{Contains: "squat", Times: 1, Reply: Reply{Content: "Keep your chest up."}},
+		{Model: "broken", Reply: Reply{Status: http.StatusTooManyRequests, Error: "slow down", RetryAfter: "3"}},
+		{Contains: "json", Reply: Reply{Raw: `{"choices":[`}},
+	}})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	fake.Enqueue(Reply{Content: "queued"})
+
+	testCases := []struct {
+		name     string
+		request  string
+		status   int
+		contains string
+	}{
+		{"queued reply first", `{"model":"m","messages":[{"role":"user","content":"squat?"}]}`, 200, `"content":"queued"`},
+		{"matching rule", `{"model":"m","messages":[{"role":"user","content":"squat?"}]}`, 200, "Keep your chest up."},
+		{"rule used up", `{"model":"m","messages":[{"role":"user","content":"squat?"}]}`, 200, defaultContent},
+		{"error", `{"model":"broken","messages":[]}`, 429, "slow down"},
+		{"malformed body", `{"model":"m","messages":[{"role":"user","content":"json please"}]}`, 200, `{"choices":[`},
+		{"invalid request", `{`, 400, "invalid request body"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := complete(t, server.URL, tc.request)
+			if resp.StatusCode != tc.status || !strings.Contains(body, tc.contains) {
+				t.Errorf("Expected %d with '%s', got %d '%s'", tc.status, tc.contains, resp.StatusCode, body)
+			}
+			if tc.status == http.StatusTooManyRequests && resp.Header.Get("Retry-After") != "3" {
+				t.Error("Expected the Retry-After header")
+			}
+		})
+	}
+
+	if requests := fake.Requests(); len(requests) != 5 || requests[0].LastMessage() != "squat?" {
+		t.Errorf("Expected the decoded requests to be kept, got %+v", requests)
+	}
+}
+
+func TestServer_Stream(t *testing.T) {
+	fake := New(Script{})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	fake.Enqueue(
+		Reply{Content: "Keep your chest up.", ChunkSize: 5, ChunkDelayMS: 1, Usage: &Usage{TotalTokens: 7}},
+		Reply{Content: "Keep", StreamError: "provider crashed"},
+	)
+
+	_, body := complete(t, server.URL, `{"model":"m","stream":true,"messages":[]}`)
+	var content strings.Builder
+	for _, line := range strings.Split(body, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("Invalid chunk '%s': %v", data, err)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+	}
+	if content.String() != "Keep your chest up." {
+		t.Errorf("Expected the content in chunks, got '%s'", content.String())
+	}
+	if strings.Count(body, "data: ") != 6 || !strings.Contains(body, `"total_tokens":7`) || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("Expected 4 deltas, usage and [DONE], got %s", body)
+	}
+
+	_, body = complete(t, server.URL, `{"model":"m","stream":true,"messages":[]}`)
+	if !strings.Contains(body, `"error":{"message":"provider crashed"`) || strings.Contains(body, "[DONE]") {
+		t.Errorf("Expected the stream to end with an error, got %s", body)
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	os.WriteFile(path, []byte(`{"rules":[{"contains":"hi","reply":{"content":"hello"}}],"default":{"content":"what?"}}`), 0o644)
+
+	script, err := LoadScript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(script.Rules) != 1 || script.Rules[0].Reply.Content != "hello" || script.Default.Content != "what?" {
+		t.Errorf("Unexpected script %+v", script)
+	}
+
+	if _, err := LoadScript(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing script")
+	}
+}
+
+func TestStart(t *testing.T) {
+	baseURL, stop, err := Start(New(Script{Default: &Reply{Content: "started"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	resp, err := http.Post(baseURL+"/chat/completions", "application/json", strings.NewReader(`{"messages":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "started") {
+		t.Errorf("Expected the default reply, got '%s'", body)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rest-api/internal/models"
+)
+
+// GetAIModels godoc
+// @Summary Get AI model health
+// @Description Get circuit breaker state, latency and error rate of every AI model
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin key"
+// @Success 200 {object} models.AIModelsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /admin/ai/models [get]
+func (h *Handlers) GetAIModels(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.AIService.GetAIModelStatuses(r.Context())
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.AIModelsResponse{
+		Models: statuses,
+	})
+}
+
+// GetAICacheStats godoc
+// @Summary Get AI response cache metrics
+// @Description Get the cache backend and hits, misses, bypasses and errors per AI call type
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin key"
+// @Success 200 {object} models.AICacheResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/ai/cache [get]
+func (h *Handlers) GetAICacheStats(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, h.AIService.GetAICacheStats(r.Context()))
+}
+
+// GetAIUsageReport godoc
+// @Summary Get AI token usage of all users
+// @Description Get token usage and cost per feature and model, and the top users, in the current UTC day or month
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin key"
+// @Param period query string false "day or month (default month)"
+// @Success 200 {object} models.AIUsageReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/ai/usage [get]
+func (h *Handlers) GetAIUsageReport(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "month"
+	}
+
+	report, err := h.AIService.GetAIUsageReport(r.Context(), period)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// GetSafetyEvents godoc
+// @Summary Get flagged safety events
+// @Description Get the newest chat messages and plans that matched a health-safety rule, for review
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin key"
+// @Param limit query int false "Maximum number of events (default 50, max 500)"
+// @Success 200 {object} models.SafetyEventList
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/safety/events [get]
+func (h *Handlers) GetSafetyEvents(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := h.AIService.GetSafetyEvents(r.Context(), limit)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.SafetyEventList{
+		Events: events,
+	})
+}
+
+// GetRatedChatExchanges godoc
+// @Summary Export rated chat answers
+// @Description Export chat answers users rated, including answers later replaced by regeneration, newest messages first. format=jsonl returns one exchange per line for prompt tuning
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin key"
+// @Param rating query string false "up or down (default both)"
+// @Param since query string false "Only messages since this RFC 3339 time"
+// @Param limit query int false "Maximum number of messages (default 100, max 1000)"
+// @Param format query string false "json (default) or jsonl"
+// @Success 200 {object} models.RatedChatExchangeList
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/chat/feedback [get]
+func (h *Handlers) GetRatedChatExchanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid since, expected RFC 3339")
+			return
+		}
+		since = parsed
+	}
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "jsonl" {
+		respondWithError(w, http.StatusBadRequest, "format must be 'json' or 'jsonl'")
+		return
+	}
+
+	exchanges, err := h.AIService.GetRatedChatExchanges(r.Context(), query.Get("rating"), since, limit)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	if format == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="chat-feedback.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		for _, exchange := range exchanges {
+			_ = encoder.Encode(exchange)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.RatedChatExchangeList{
+		Exchanges: exchanges,
+	})
+}
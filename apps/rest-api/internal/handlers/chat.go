@@ -3,13 +3,15 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"rest-api/internal/models"
+	"rest-api/internal/services"
 )
 
 // Chat godoc
 // @Summary Chat with AI
-// @Description Send message to AI assistant
+// @Description Send message to AI assistant. The message goes to the thread in thread_id, or to the default thread when it is omitted. The assistant can read the user's profile, plan and progress; plan changes it proposes are returned in pending_actions and applied only after confirmation
 // @Tags chat
 // @Accept json
 // @Produce json
@@ -18,6 +20,9 @@ import (
 // @Success 200 {object} models.ChatResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Router /api/chat [post]
 func (h *Handlers) Chat(w http.ResponseWriter, r *http.Request) {
 	var req models.ChatRequest
@@ -26,28 +31,97 @@ func (h *Handlers) Chat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := h.AIService.Chat(r.Context(), req.Message)
+	response, err := h.AIService.Chat(r.Context(), req.ThreadID, req.Message)
 	if err != nil {
 		handleServiceError(w, err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, models.ChatResponse{
-		Response: response,
-	})
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// ChatStream godoc
+// @Summary Chat with AI (streaming)
+// @Description Send message to AI assistant and receive the answer as Server-Sent Events. Emits "delta" events with partial content, then a final "done" event with the full response or an "error" event.
+// @Tags chat
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param request body models.ChatRequest true "Chat message"
+// @Success 200 {object} models.ChatStreamDelta
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /api/chat/stream [post]
+func (h *Handlers) ChatStream(w http.ResponseWriter, r *http.Request) {
+	var req models.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// Long answers may outlive the server-wide WriteTimeout
+	_ = rc.SetWriteDeadline(time.Time{})
+	started := false
+
+	onDelta := func(content string) error {
+		if !started {
+			startEventStream(w)
+			started = true
+		}
+		if err := writeEvent(w, "delta", models.ChatStreamDelta{Content: content}); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	response, err := h.AIService.ChatStream(r.Context(), req.ThreadID, req.Message, onDelta)
+	if err != nil {
+		// Nothing was streamed yet, so a regular JSON error can still be sent
+		if !started {
+			handleServiceError(w, err)
+			return
+		}
+		if r.Context().Err() != nil {
+			// Client is gone, nobody to report to
+			return
+		}
+		message := "Internal server error"
+		if svcErr, ok := err.(services.ServiceError); ok {
+			message = svcErr.Message
+		}
+		_ = writeEvent(w, "error", models.ErrorResponse{
+			Error:   http.StatusText(http.StatusInternalServerError),
+			Message: message,
+		})
+		_ = rc.Flush()
+		return
+	}
+
+	if !started {
+		startEventStream(w)
+	}
+	_ = writeEvent(w, "done", response)
+	_ = rc.Flush()
 }
 
 // GetChatHistory godoc
 // @Summary Get chat history
-// @Description Get user's chat history with AI
+// @Description Get the messages of a chat thread, the default thread when thread_id is omitted
 // @Tags chat
 // @Produce json
 // @Security BearerAuth
+// @Param thread_id query string false "Thread ID"
 // @Success 200 {object} models.ChatHistory
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Router /api/chat/history [get]
 func (h *Handlers) GetChatHistory(w http.ResponseWriter, r *http.Request) {
-	history, err := h.AIService.GetChatHistory(r.Context())
+	history, err := h.AIService.GetChatHistory(r.Context(), r.URL.Query().Get("thread_id"))
 	if err != nil {
 		handleServiceError(w, err)
 		return
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ConfirmChatAction godoc
+// @Summary Confirm chat action
+// @Description Apply a plan change proposed by the AI assistant
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Param action_id path string true "Action ID"
+// @Success 200 {object} models.ChatActionResult
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/chat/actions/{action_id}/confirm [post]
+func (h *Handlers) ConfirmChatAction(w http.ResponseWriter, r *http.Request) {
+	result, err := h.AIService.ConfirmChatAction(r.Context(), mux.Vars(r)["action_id"])
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// CancelChatAction godoc
+// @Summary Cancel chat action
+// @Description Reject a plan change proposed by the AI assistant
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Param action_id path string true "Action ID"
+// @Success 200 {object} models.ChatAction
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/chat/actions/{action_id}/cancel [post]
+func (h *Handlers) CancelChatAction(w http.ResponseWriter, r *http.Request) {
+	action, err := h.AIService.CancelChatAction(r.Context(), mux.Vars(r)["action_id"])
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, action)
+}
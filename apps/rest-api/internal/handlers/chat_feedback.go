@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"rest-api/internal/models"
+)
+
+// RateChatMessage godoc
+// @Summary Rate chat answer
+// @Description Give the current answer of a chat message a thumbs up or down, with an optional reason. Rating again replaces the earlier rating
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID"
+// @Param request body models.ChatFeedbackRequest true "Rating"
+// @Success 200 {object} models.ChatMessage
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/chat/{message_id}/feedback [post]
+func (h *Handlers) RateChatMessage(w http.ResponseWriter, r *http.Request) {
+	var req models.ChatFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	message, err := h.AIService.RateChatMessage(r.Context(), mux.Vars(r)["message_id"], &req)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, message)
+}
+
+// RegenerateChatMessage godoc
+// @Summary Regenerate chat answer
+// @Description Answer a chat message again. The previous answer and its rating are kept in the message's variants, the chat history shows the new answer
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID"
+// @Success 200 {object} models.ChatResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /api/chat/{message_id}/regenerate [post]
+func (h *Handlers) RegenerateChatMessage(w http.ResponseWriter, r *http.Request) {
+	response, err := h.AIService.RegenerateChatMessage(r.Context(), mux.Vars(r)["message_id"])
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -20,6 +20,19 @@ func TestChat_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestUpdateChatThread_InvalidJSON(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("PATCH", "/chat/threads/abc", bytes.NewBuffer([]byte("invalid json")))
+	w := httptest.NewRecorder()
+
+	h.UpdateChatThread(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestGetChatHistory_Handler(t *testing.T) {
 	h := &Handlers{AIService: nil}
 
@@ -64,3 +77,57 @@ func TestGetUserProgress_Handler(t *testing.T) {
 
 	h.GetUserProgress(w, req)
 }
+
+func TestChatStream_InvalidJSON(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("POST", "/chat/stream", bytes.NewBuffer([]byte("invalid json")))
+	w := httptest.NewRecorder()
+
+	h.ChatStream(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetSafetyEvents_InvalidLimit(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("GET", "/admin/safety/events?limit=abc", nil)
+	w := httptest.NewRecorder()
+
+	h.GetSafetyEvents(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestRateChatMessage_InvalidJSON(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("POST", "/chat/abc/feedback", bytes.NewBuffer([]byte("invalid json")))
+	w := httptest.NewRecorder()
+
+	h.RateChatMessage(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetRatedChatExchanges_InvalidQuery(t *testing.T) {
+	h := &Handlers{}
+
+	for _, query := range []string{"since=yesterday", "limit=0", "format=csv"} {
+		req := httptest.NewRequest("GET", "/admin/chat/feedback?"+query, nil)
+		w := httptest.NewRecorder()
+
+		h.GetRatedChatExchanges(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"rest-api/internal/models"
+)
+
+// CreateChatThread godoc
+// @Summary Create chat thread
+// @Description Start a new named conversation with the AI assistant
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateChatThreadRequest false "Thread title"
+// @Success 201 {object} models.ChatThread
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/chat/threads [post]
+func (h *Handlers) CreateChatThread(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateChatThreadRequest
+	// The body is optional
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	thread, err := h.AIService.CreateChatThread(r.Context(), &req)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, thread)
+}
+
+// GetChatThreads godoc
+// @Summary List chat threads
+// @Description List the user's chat threads, most recently active first. Archived threads are included with archived=true
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Param archived query bool false "Include archived threads"
+// @Success 200 {object} models.ChatThreadList
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/chat/threads [get]
+func (h *Handlers) GetChatThreads(w http.ResponseWriter, r *http.Request) {
+	includeArchived := r.URL.Query().Get("archived") == "true"
+
+	threads, err := h.AIService.GetChatThreads(r.Context(), includeArchived)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.ChatThreadList{
+		Threads: threads,
+	})
+}
+
+// UpdateChatThread godoc
+// @Summary Update chat thread
+// @Description Rename, archive or unarchive a chat thread. The default thread cannot be archived
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param thread_id path string true "Thread ID"
+// @Param request body models.UpdateChatThreadRequest true "Thread changes"
+// @Success 200 {object} models.ChatThread
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/chat/threads/{thread_id} [patch]
+func (h *Handlers) UpdateChatThread(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateChatThreadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	thread, err := h.AIService.UpdateChatThread(r.Context(), mux.Vars(r)["thread_id"], &req)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, thread)
+}
+
+// DeleteChatThread godoc
+// @Summary Delete chat thread
+// @Description Delete a chat thread with all its messages. The default thread cannot be deleted
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Param thread_id path string true "Thread ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/chat/threads/{thread_id} [delete]
+func (h *Handlers) DeleteChatThread(w http.ResponseWriter, r *http.Request) {
+	if err := h.AIService.DeleteChatThread(r.Context(), mux.Vars(r)["thread_id"]); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": "Chat thread deleted successfully",
+	})
+}
@@ -2,7 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"rest-api/internal/middleware"
 	"rest-api/internal/models"
@@ -53,8 +58,30 @@ func respondWithError(w http.ResponseWriter, code int, message string) {
 	})
 }
 
+func startEventStream(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeEvent(w http.ResponseWriter, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
 func handleServiceError(w http.ResponseWriter, err error) {
 	if svcErr, ok := err.(services.ServiceError); ok {
+		var quotaErr *services.QuotaExceededError
+		if errors.As(svcErr.Err, &quotaErr) {
+			retryAfter := int(math.Ceil(time.Until(quotaErr.ResetsAt).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+		}
 		respondWithError(w, svcErr.Code, svcErr.Message)
 	} else {
 		respondWithError(w, http.StatusInternalServerError, "Internal server error")
@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"rest-api/internal/models"
+	"rest-api/internal/services"
 )
 
 func TestRespondWithJSON(t *testing.T) {
@@ -80,3 +82,19 @@ func TestNewHandlers(t *testing.T) {
 		t.Error("Expected non-nil handlers")
 	}
 }
+
+func TestHandleServiceError_QuotaRetryAfter(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := services.NewServiceError(http.StatusTooManyRequests, "Quota exceeded", &services.QuotaExceededError{
+		Period: "daily", Limit: 100, Used: 120, ResetsAt: time.Now().Add(90 * time.Second),
+	})
+
+	handleServiceError(w, err)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "90" {
+		t.Errorf("Expected Retry-After 90, got '%s'", retryAfter)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"rest-api/internal/models"
+	"rest-api/internal/services"
+)
+
+// respondWithJob answers a submitted job with 202 and where to poll it
+func respondWithJob(w http.ResponseWriter, job *models.PlanJob) {
+	w.Header().Set("Location", "/api/jobs/"+job.ID.Hex())
+	respondWithJSON(w, http.StatusAccepted, job)
+}
+
+// GetPlanJob godoc
+// @Summary Get plan job
+// @Description Get the status and progress of a plan generation job. Once it succeeded the job contains the user's plan, a failed job contains the error
+// @Tags workout
+// @Produce json
+// @Security BearerAuth
+// @Param job_id path string true "Job ID"
+// @Success 200 {object} models.PlanJob
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/jobs/{job_id} [get]
+func (h *Handlers) GetPlanJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.AIService.GetPlanJob(r.Context(), mux.Vars(r)["job_id"])
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, job)
+}
+
+// StreamPlanJob godoc
+// @Summary Stream plan job progress
+// @Description Follow a plan generation job as Server-Sent Events. Emits a "progress" event with the job whenever its status or stage changes, then a "done" event with the job and plan or a "failed" event with the job and error
+// @Tags workout
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param job_id path string true "Job ID"
+// @Success 200 {object} models.PlanJob
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/jobs/{job_id}/events [get]
+func (h *Handlers) StreamPlanJob(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// A job may run longer than the server-wide WriteTimeout
+	_ = rc.SetWriteDeadline(time.Time{})
+	started := false
+
+	onUpdate := func(job *models.PlanJob) error {
+		if !started {
+			startEventStream(w)
+			started = true
+		}
+
+		event := "progress"
+		switch job.Status {
+		case models.PlanJobSucceeded:
+			event = "done"
+		case models.PlanJobFailed:
+			event = "failed"
+		}
+		if err := writeEvent(w, event, job); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	err := h.AIService.WatchPlanJob(r.Context(), mux.Vars(r)["job_id"], onUpdate)
+	if err == nil || r.Context().Err() != nil {
+		return
+	}
+	// Nothing was streamed yet, so a regular JSON error can still be sent
+	if !started {
+		handleServiceError(w, err)
+		return
+	}
+	message := "Internal server error"
+	if svcErr, ok := err.(services.ServiceError); ok {
+		message = svcErr.Message
+	}
+	_ = writeEvent(w, "error", models.ErrorResponse{
+		Error:   http.StatusText(http.StatusInternalServerError),
+		Message: message,
+	})
+	_ = rc.Flush()
+}
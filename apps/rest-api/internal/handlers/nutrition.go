@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"rest-api/internal/models"
+)
+
+// GenerateMealPlan godoc
+// @Summary Generate meal plan
+// @Description Start generating a weekly meal plan for the calorie and macro targets derived from the profile, its dietary restrictions and allergies, and return the job to poll. The new meal plan replaces the current one. A repeated request while a generation is running returns the running job
+// @Tags nutrition
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} models.PlanJob
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/generate-meal-plan [post]
+func (h *Handlers) GenerateMealPlan(w http.ResponseWriter, r *http.Request) {
+	job, err := h.AIService.SubmitPlanJob(r.Context(), models.PlanJobMealPlan, models.PlanJobOptions{})
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJob(w, job)
+}
+
+// GetMealPlan godoc
+// @Summary Get meal plan
+// @Description Get the user's current weekly meal plan with its daily targets
+// @Tags nutrition
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.MealPlan
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/meal-plan [get]
+func (h *Handlers) GetMealPlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := h.AIService.GetMealPlan(r.Context())
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, plan)
+}
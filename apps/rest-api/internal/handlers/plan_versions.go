@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"rest-api/internal/models"
+)
+
+// GetWorkoutPlanVersions godoc
+// @Summary List workout plan versions
+// @Description List the versions of the user's workout plan, newest first, with why and by whom each was made. The plan snapshots are left out
+// @Tags workout
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.WorkoutPlanVersionList
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/workout-plan/versions [get]
+func (h *Handlers) GetWorkoutPlanVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.AIService.GetWorkoutPlanVersions(r.Context())
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.WorkoutPlanVersionList{
+		Versions: versions,
+	})
+}
+
+// DiffWorkoutPlanVersions godoc
+// @Summary Compare workout plan versions
+// @Description List the workouts and exercises that were added, removed or changed between two plan versions. Occurrences of a base workout with the same change are merged
+// @Tags workout
+// @Produce json
+// @Security BearerAuth
+// @Param from query string true "Version ID to compare from"
+// @Param to query string false "Version ID to compare to, default the latest version"
+// @Success 200 {object} models.WorkoutPlanDiff
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/workout-plan/versions/diff [get]
+func (h *Handlers) DiffWorkoutPlanVersions(w http.ResponseWriter, r *http.Request) {
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		respondWithError(w, http.StatusBadRequest, "from is required")
+		return
+	}
+
+	diff, err := h.AIService.DiffWorkoutPlanVersions(r.Context(), from, r.URL.Query().Get("to"))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, diff)
+}
+
+// RestoreWorkoutPlanVersion godoc
+// @Summary Restore workout plan version
+// @Description Make a copy of an earlier version the current plan. The restore is kept as a new version; workouts completed since stay done, the others are planned again
+// @Tags workout
+// @Produce json
+// @Security BearerAuth
+// @Param version_id path string true "Version ID"
+// @Success 200 {object} models.WorkoutPlan
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/workout-plan/versions/{version_id}/restore [post]
+func (h *Handlers) RestoreWorkoutPlanVersion(w http.ResponseWriter, r *http.Request) {
+	plan, err := h.AIService.RestoreWorkoutPlanVersion(r.Context(), mux.Vars(r)["version_id"])
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, plan)
+}
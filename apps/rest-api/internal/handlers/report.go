@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// GetWeeklyReport godoc
+// @Summary Get weekly progress report
+// @Description Get the report of a finished week: completions, adherence to the scheduled workouts, streaks and weight trend, with a summary written by the AI. Reports that were not generated yet are generated on request.
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Param week query string false "Any date of the week (YYYY-MM-DD), default the last finished week"
+// @Success 200 {object} models.WeeklyReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/reports/weekly [get]
+func (h *Handlers) GetWeeklyReport(w http.ResponseWriter, r *http.Request) {
+	var week time.Time
+	if value := r.URL.Query().Get("week"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid week, expected YYYY-MM-DD")
+			return
+		}
+		week = parsed
+	}
+
+	report, err := h.AIService.GetWeeklyReport(r.Context(), week)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
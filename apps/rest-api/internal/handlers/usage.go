@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// GetUsage godoc
+// @Summary Get AI token usage
+// @Description Get the user's AI token usage and cost per feature in the current UTC day and month, with the quota limits
+// @Tags usage
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.AIUsageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/usage [get]
+func (h *Handlers) GetUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := h.AIService.GetUsage(r.Context())
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, usage)
+}
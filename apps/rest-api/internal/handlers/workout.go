@@ -2,61 +2,90 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
+	"github.com/gorilla/mux"
+
 	"rest-api/internal/models"
 )
 
 // GeneratePlan godoc
 // @Summary Generate workout plan
-// @Description Generate a new workout plan based on user profile
+// @Description Start generating a workout plan based on the user profile and return the job to poll. Set generator to "rules" to skip the AI; the rule-based generator is also used automatically when the AI fails. A repeated request while a generation is running returns the running job
 // @Tags workout
+// @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} models.WorkoutPlan
+// @Param request body models.WorkoutPlanRequest false "Generation options"
+// @Success 202 {object} models.PlanJob
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/generate-plan [post]
 func (h *Handlers) GeneratePlan(w http.ResponseWriter, r *http.Request) {
-	plan, err := h.AIService.GenerateWorkoutPlan(r.Context())
+	var req models.WorkoutPlanRequest
+	// The body is optional
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+	if req.Generator != "" && req.Generator != models.PlanSourceAI && req.Generator != models.PlanSourceRules {
+		respondWithError(w, http.StatusBadRequest, "generator must be 'ai' or 'rules'")
+		return
+	}
+
+	job, err := h.AIService.SubmitPlanJob(r.Context(), models.PlanJobGenerate, models.PlanJobOptions{Generator: req.Generator})
 	if err != nil {
 		handleServiceError(w, err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, plan)
+	respondWithJob(w, job)
 }
 
 // GetWorkoutPlan godoc
 // @Summary Get workout plan
-// @Description Get user's current workout plan
+// @Description Get user's current workout plan without generating one. While the plan is being generated the generate job is returned instead. When the user reached a phase of a long plan that is not generated yet, the job generating it is returned as phase_job
 // @Tags workout
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} models.WorkoutPlan
+// @Success 202 {object} models.PlanJob
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Router /api/workout-plan [get]
 func (h *Handlers) GetWorkoutPlan(w http.ResponseWriter, r *http.Request) {
-	plan, err := h.AIService.GenerateWorkoutPlan(r.Context())
+	plan, job, err := h.AIService.GetWorkoutPlan(r.Context())
 	if err != nil {
 		handleServiceError(w, err)
 		return
 	}
+	if job != nil {
+		respondWithJob(w, job)
+		return
+	}
 
 	respondWithJSON(w, http.StatusOK, plan)
 }
 
 // RegenerateWorkoutPlan godoc
 // @Summary Regenerate workout plan
-// @Description Regenerate workout plan based on user feedback
+// @Description Start regenerating the workout plan based on user feedback and return the job to poll. A repeated request while a regeneration is running returns the running job
 // @Tags workout
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body models.RegenerateWorkoutPlanRequest true "Regeneration feedback"
-// @Success 200 {object} models.WorkoutPlan
+// @Success 202 {object} models.PlanJob
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/regenerate-plan [post]
 func (h *Handlers) RegenerateWorkoutPlan(w http.ResponseWriter, r *http.Request) {
 	var req models.RegenerateWorkoutPlanRequest
@@ -65,13 +94,83 @@ func (h *Handlers) RegenerateWorkoutPlan(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	plan, err := h.AIService.RegenerateWorkoutPlan(r.Context(), req.Comments)
+	job, err := h.AIService.SubmitPlanJob(r.Context(), models.PlanJobRegenerate, models.PlanJobOptions{Comments: req.Comments})
 	if err != nil {
 		handleServiceError(w, err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, plan)
+	respondWithJob(w, job)
+}
+
+// RegenerateWorkout godoc
+// @Summary Regenerate a single workout
+// @Description Start regenerating the base workout of a planned workout from comments and return the job to poll. The new version replaces this and every later planned occurrence of the base workout, keeping their IDs and dates; completed and expired workouts and the rest of the plan are not changed
+// @Tags workout
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param workout_id path string true "Workout ID"
+// @Param request body models.RegenerateWorkoutPlanRequest true "Regeneration comments"
+// @Success 202 {object} models.PlanJob
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/workouts/{workout_id}/regenerate [post]
+func (h *Handlers) RegenerateWorkout(w http.ResponseWriter, r *http.Request) {
+	var req models.RegenerateWorkoutPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	job, err := h.AIService.SubmitPlanJob(r.Context(), models.PlanJobRegenerateWorkout, models.PlanJobOptions{
+		WorkoutID: mux.Vars(r)["workout_id"],
+		Comments:  req.Comments,
+	})
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJob(w, job)
+}
+
+// SubstituteExercise godoc
+// @Summary Substitute exercise
+// @Description Propose library alternatives for an exercise of a planned workout that train the same muscle group with the user's equipment and health issues. With an alternative in the body it replaces the exercise in this workout, or with scope "future" in every upcoming occurrence of the base workout
+// @Tags workout
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param workout_id path string true "Workout ID"
+// @Param exercise_id path string true "Exercise ID"
+// @Param request body models.ExerciseSubstitutionRequest false "Chosen alternative"
+// @Success 200 {object} models.ExerciseSubstitutionResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/workouts/{workout_id}/exercises/{exercise_id}/substitute [post]
+func (h *Handlers) SubstituteExercise(w http.ResponseWriter, r *http.Request) {
+	var req models.ExerciseSubstitutionRequest
+	// The body is optional
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	vars := mux.Vars(r)
+	response, err := h.AIService.SubstituteExercise(r.Context(), vars["workout_id"], vars["exercise_id"], &req)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
 }
 
 // GetRating godoc
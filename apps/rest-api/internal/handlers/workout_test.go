@@ -5,6 +5,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestGeneratePlan_Handler(t *testing.T) {
@@ -52,6 +56,72 @@ func TestRegenerateWorkoutPlan_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestRegenerateWorkout_InvalidJSON(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("POST", "/workouts/1/regenerate", bytes.NewBuffer([]byte("invalid json")))
+	w := httptest.NewRecorder()
+
+	h.RegenerateWorkout(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestSubstituteExercise_InvalidJSON(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("POST", "/workouts/1/exercises/2/substitute", bytes.NewBuffer([]byte("invalid json")))
+	w := httptest.NewRecorder()
+
+	h.SubstituteExercise(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetWeeklyReport_InvalidWeek(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("GET", "/reports/weekly?week=last", nil)
+	w := httptest.NewRecorder()
+
+	h.GetWeeklyReport(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestDiffWorkoutPlanVersions_MissingFrom(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("GET", "/workout-plan/versions/diff", nil)
+	w := httptest.NewRecorder()
+
+	h.DiffWorkoutPlanVersions(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestRespondWithJob(t *testing.T) {
+	job := &models.PlanJob{ID: primitive.NewObjectID(), Status: models.PlanJobQueued}
+	w := httptest.NewRecorder()
+
+	respondWithJob(w, job)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/api/jobs/"+job.ID.Hex() {
+		t.Errorf("Expected the job location, got '%s'", location)
+	}
+}
+
 func TestGetRating_Handler(t *testing.T) {
 	h := &Handlers{AIService: nil}
 
@@ -0,0 +1,108 @@
+package i18n
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minDetectWords is the number of words below which text is too short to tell
+const minDetectWords = 6
+
+// stopwords are frequent words of each language written in Latin script.
+// Words shared by several languages count for all of them.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "your", "with", "for", "this", "that", "of", "to", "it", "be", "on", "should", "can", "will", "have", "what", "do", "not", "if", "or", "more", "each", "keep", "at", "by", "from"},
+	"es": {"el", "la", "los", "las", "y", "es", "un", "una", "con", "para", "por", "que", "tu", "tus", "del", "al", "muy", "pero", "como", "más", "puedes", "cada", "también", "esto", "está", "son", "debes", "hacer", "entre", "en"},
+	"de": {"der", "die", "das", "und", "ist", "ein", "eine", "mit", "für", "nicht", "du", "dein", "deine", "auf", "zu", "den", "dem", "sich", "auch", "wie", "oder", "bei", "sind", "kannst", "wenn", "jede", "noch", "sehr", "im", "dich"},
+	"fr": {"le", "la", "les", "et", "est", "un", "une", "avec", "pour", "pas", "vous", "votre", "tu", "ton", "des", "du", "dans", "sur", "qui", "que", "ce", "mais", "ou", "très", "chaque", "aussi", "peux", "faire", "en", "au"},
+	"it": {"il", "lo", "la", "gli", "le", "e", "è", "un", "una", "con", "per", "non", "che", "di", "del", "della", "sono", "anche", "ogni", "più", "puoi", "fare", "molto", "tuo", "tua", "questo", "nel", "alla", "in", "ai"},
+	"pt": {"o", "a", "os", "as", "e", "é", "um", "uma", "com", "para", "não", "que", "do", "da", "dos", "das", "você", "seu", "sua", "mais", "cada", "também", "isso", "está", "são", "pode", "fazer", "muito", "no", "na"},
+}
+
+// letterHints are letters that only some of the languages use
+var letterHints = map[rune][]string{
+	'ñ': {"es"}, '¿': {"es"}, '¡': {"es"},
+	'ß': {"de"}, 'ä': {"de"}, 'ö': {"de"}, 'ü': {"de"},
+	'ç': {"fr", "pt"}, 'è': {"fr", "it"}, 'ê': {"fr", "pt"}, 'à': {"fr", "it", "pt"}, 'œ': {"fr"},
+	'ã': {"pt"}, 'õ': {"pt"},
+}
+
+var stopwordSets = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(stopwords))
+	for code, words := range stopwords {
+		sets[code] = make(map[string]bool, len(words))
+		for _, word := range words {
+			sets[code][word] = true
+		}
+	}
+	return sets
+}()
+
+// Detect guesses the language of text. It returns "" when the text is too
+// short or too mixed to tell, so callers only act on clear mismatches.
+func Detect(text string) string {
+	var latin, cyrillic, other int
+	for _, r := range text {
+		switch {
+		case !unicode.IsLetter(r):
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		default:
+			other++
+		}
+	}
+	letters := latin + cyrillic + other
+	if letters == 0 {
+		return ""
+	}
+	if cyrillic*10 >= letters*6 {
+		return "ru"
+	}
+	if latin*10 < letters*6 {
+		return ""
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < minDetectWords {
+		return ""
+	}
+
+	scores := make(map[string]float64, len(stopwordSets))
+	for _, word := range words {
+		for code, set := range stopwordSets {
+			if set[word] {
+				scores[code]++
+			}
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		for _, code := range letterHints[r] {
+			scores[code] += 0.5
+		}
+	}
+
+	best, bestScore, second := "", 0.0, 0.0
+	for code, score := range scores {
+		if score > bestScore {
+			best, bestScore, second = code, score, bestScore
+		} else if score > second {
+			second = score
+		}
+	}
+
+	// Too few function words or no clear winner
+	if bestScore < 3 || bestScore < second*1.5 {
+		return ""
+	}
+	return best
+}
+
+// Mismatch reports whether text is clearly not written in the language
+func Mismatch(text, code string) bool {
+	detected := Detect(text)
+	return detected != "" && detected != code
+}
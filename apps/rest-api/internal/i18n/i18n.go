@@ -0,0 +1,90 @@
+// Package i18n knows the languages AI responses can be given in: how users
+// ask for them, how to recognize them in generated text and the few fixed
+// messages that are shown without asking the model.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the language of prompts and of users without a preference
+const Default = "en"
+
+// Language is a supported response language
+type Language struct {
+	// Code is the ISO 639-1 code, e.g. "de"
+	Code string
+	// Name is the English name used in prompts
+	Name string
+	// Native is the name in the language itself
+	Native string
+}
+
+// PromptName names the language for instructions to the model, e.g. "German (Deutsch)"
+func (l Language) PromptName() string {
+	if l.Native == l.Name {
+		return l.Name
+	}
+	return l.Name + " (" + l.Native + ")"
+}
+
+var languages = map[string]Language{
+	"en": {Code: "en", Name: "English", Native: "English"},
+	"es": {Code: "es", Name: "Spanish", Native: "Español"},
+	"de": {Code: "de", Name: "German", Native: "Deutsch"},
+	"fr": {Code: "fr", Name: "French", Native: "Français"},
+	"it": {Code: "it", Name: "Italian", Native: "Italiano"},
+	"pt": {Code: "pt", Name: "Portuguese", Native: "Português"},
+	"ru": {Code: "ru", Name: "Russian", Native: "Русский"},
+}
+
+// Get returns a supported language
+func Get(code string) (Language, bool) {
+	language, ok := languages[code]
+	return language, ok
+}
+
+// Codes lists the supported language codes, sorted
+func Codes() []string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Normalize reduces a language tag such as "pt-BR" or "de_AT" to a supported
+// code, "" when the language is not supported
+func Normalize(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	if _, ok := languages[base]; ok {
+		return base
+	}
+	return ""
+}
+
+// ParseAcceptLanguage picks the supported language the client prefers most
+// from an Accept-Language header, "" when none is supported
+func ParseAcceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		// Equal weights keep the order of the header
+		if code := Normalize(tag); code != "" && q > bestQ {
+			best, bestQ = code, q
+		}
+	}
+	return best
+}
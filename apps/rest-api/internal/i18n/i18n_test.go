@@ -0,0 +1,85 @@
+package i18n
+
+import "testing"
+
+func TestParseAcceptLanguage(t *testing.T) {
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{"de-DE,de;q=0.9,en;q=0.8", "de"},
+		{"ja,pt-BR;q=0.7,en;q=0.5", "pt"},
+		{"en;q=0.3, es;q=0.9", "es"},
+		{"fr, de", "fr"},
+		{"ja, zh-CN", ""},
+		{"*", ""},
+		{"de;q=0, it;q=0.1", "it"},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			if got := ParseAcceptLanguage(tc.header); got != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	testCases := map[string]string{"DE_at": "de", "pt-BR": "pt", "ru": "ru", "xx": "", "": ""}
+	for tag, expected := range testCases {
+		if got := Normalize(tag); got != expected {
+			t.Errorf("Normalize(%q): expected '%s', got '%s'", tag, expected, got)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"english", "Keep your back straight and lower the weight slowly with control.", "en"},
+		{"spanish", "Mantén la espalda recta y baja el peso despacio con control para proteger las rodillas.", "es"},
+		{"german", "Halte den Rücken gerade und senke das Gewicht langsam, damit die Knie nicht nach innen fallen.", "de"},
+		{"french", "Garde le dos droit et descends le poids lentement avec contrôle pour protéger tes genoux.", "fr"},
+		{"italian", "Tieni la schiena dritta e abbassa il peso lentamente per proteggere le ginocchia, è molto importante.", "it"},
+		{"portuguese", "Mantenha as costas retas e abaixe o peso devagar, isso é muito importante para os joelhos.", "pt"},
+		{"russian", "Держи спину прямой и опускай вес медленно, под контролем.", "ru"},
+		{"too short", "Squats: 3x10", ""},
+		{"no letters", "3 x 10 @ 60s", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Detect(tc.text); got != tc.expected {
+				t.Errorf("Expected '%s', got '%s'", tc.expected, got)
+			}
+		})
+	}
+
+	if Mismatch("Squats: 3x10", "de") {
+		t.Error("Expected unclear text not to be a mismatch")
+	}
+	if !Mismatch("Keep your back straight and lower the weight slowly with control.", "de") {
+		t.Error("Expected English text to mismatch German")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	if Message("de", MessageWeek) != "Woche" {
+		t.Error("Expected the German message")
+	}
+	if Message("xx", MessageWeek) != "Week" {
+		t.Error("Expected English for unknown languages")
+	}
+	for _, code := range Codes() {
+		for key := range messages[Default] {
+			if messages[code][key] == "" {
+				t.Errorf("Message %s is not translated to %s", key, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package i18n
+
+// Keys of the fixed messages
+const (
+	MessageKeepPushing  = "keep_pushing"
+	MessageDoingAmazing = "doing_amazing"
+	MessageCrushingIt   = "crushing_it"
+	MessageWeek         = "week"
+	MessageDeload       = "deload"
+	MessageOffTopic     = "off_topic"
+)
+
+var messages = map[string]map[string]string{
+	"en": {
+		MessageKeepPushing:  "Keep pushing forward! Every workout counts.",
+		MessageDoingAmazing: "You're doing amazing! Keep up the great work!",
+		MessageCrushingIt:   "You're crushing it! Keep up the excellent work!",
+		MessageWeek:         "Week",
+		MessageDeload:       "Deload",
+		MessageOffTopic:     "I can only help with fitness, nutrition and your training plan.",
+	},
+	"es": {
+		MessageKeepPushing:  "¡Sigue adelante! Cada entrenamiento cuenta.",
+		MessageDoingAmazing: "¡Lo estás haciendo genial! ¡Sigue así!",
+		MessageCrushingIt:   "¡Lo estás bordando! ¡Sigue con este gran trabajo!",
+		MessageWeek:         "Semana",
+		MessageDeload:       "Descarga",
+		MessageOffTopic:     "Solo puedo ayudarte con fitness, nutrición y tu plan de entrenamiento.",
+	},
+	"de": {
+		MessageKeepPushing:  "Bleib dran! Jedes Training zählt.",
+		MessageDoingAmazing: "Du machst das großartig! Weiter so!",
+		MessageCrushingIt:   "Du rockst das! Mach weiter so!",
+		MessageWeek:         "Woche",
+		MessageDeload:       "Deload",
+		MessageOffTopic:     "Ich kann dir nur bei Fitness, Ernährung und deinem Trainingsplan helfen.",
+	},
+	"fr": {
+		MessageKeepPushing:  "Continue comme ça ! Chaque séance compte.",
+		MessageDoingAmazing: "Tu fais un travail formidable ! Continue !",
+		MessageCrushingIt:   "Tu assures ! Continue ce super travail !",
+		MessageWeek:         "Semaine",
+		MessageDeload:       "Décharge",
+		MessageOffTopic:     "Je peux seulement t'aider avec le fitness, la nutrition et ton programme d'entraînement.",
+	},
+	"it": {
+		MessageKeepPushing:  "Continua così! Ogni allenamento conta.",
+		MessageDoingAmazing: "Stai andando alla grande! Continua così!",
+		MessageCrushingIt:   "Sei fortissimo! Continua con questo ottimo lavoro!",
+		MessageWeek:         "Settimana",
+		MessageDeload:       "Scarico",
+		MessageOffTopic:     "Posso aiutarti solo con fitness, alimentazione e il tuo piano di allenamento.",
+	},
+	"pt": {
+		MessageKeepPushing:  "Continue em frente! Cada treino conta.",
+		MessageDoingAmazing: "Você está indo muito bem! Continue assim!",
+		MessageCrushingIt:   "Você está arrasando! Continue com o ótimo trabalho!",
+		MessageWeek:         "Semana",
+		MessageDeload:       "Descarga",
+		MessageOffTopic:     "Só posso ajudar com fitness, nutrição e o seu plano de treino.",
+	},
+	"ru": {
+		MessageKeepPushing:  "Продолжай в том же духе! Каждая тренировка на счету.",
+		MessageDoingAmazing: "У тебя отлично получается! Так держать!",
+		MessageCrushingIt:   "Ты просто молодец! Продолжай в том же темпе!",
+		MessageWeek:         "Неделя",
+		MessageDeload:       "Разгрузка",
+		MessageOffTopic:     "Я могу помочь только с фитнесом, питанием и твоим планом тренировок.",
+	},
+}
+
+// Message returns a fixed message in the language, English when it is not translated
+func Message(code, key string) string {
+	if message, ok := messages[code][key]; ok {
+		return message
+	}
+	return messages[Default][key]
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminMiddleware guards operational endpoints with a shared admin key.
+// When no key is configured admin endpoints are disabled entirely.
+func AdminMiddleware(adminKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if adminKey == "" {
+				http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+				return
+			}
+
+			key := r.Header.Get(AdminKeyHeader)
+			if key == "" {
+				http.Error(w, "Admin key required", http.StatusUnauthorized)
+				return
+			}
+
+			if subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+				http.Error(w, "Invalid admin key", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// AICacheHeader set to "bypass" skips AI response cache lookups for a request.
+// The fresh response still replaces the cached one.
+const AICacheHeader = "X-AI-Cache"
+
+const AICacheBypassKey contextKey = "aiCacheBypass"
+
+func AICacheMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get(AICacheHeader), "bypass") {
+			ctx := context.WithValue(r.Context(), AICacheBypassKey, true)
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func IsAICacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(AICacheBypassKey).(bool)
+	return bypass
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAICacheMiddleware(t *testing.T) {
+	testCases := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"bypass", true},
+		{"BYPASS", true},
+		{"refresh", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			var bypassed bool
+			handler := AICacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bypassed = IsAICacheBypassed(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/api/motivation", nil)
+			if tc.header != "" {
+				req.Header.Set(AICacheHeader, tc.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if bypassed != tc.expected {
+				t.Errorf("Expected bypass %v for header '%s', got %v", tc.expected, tc.header, bypassed)
+			}
+		})
+	}
+}
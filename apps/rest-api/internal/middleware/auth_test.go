@@ -181,3 +181,51 @@ func TestGetUserIDFromContext_WrongType(t *testing.T) {
 		t.Error("Expected not to find user ID with wrong type")
 	}
 }
+
+func TestAdminMiddleware(t *testing.T) {
+	handler := AdminMiddleware("secret-admin-key")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		name     string
+		key      string
+		expected int
+	}{
+		{"missing key", "", http.StatusUnauthorized},
+		{"wrong key", "wrong", http.StatusForbidden},
+		{"valid key", "secret-admin-key", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/ai/models", nil)
+			if tc.key != "" {
+				req.Header.Set(AdminKeyHeader, tc.key)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expected {
+				t.Errorf("Expected status %d, got %d", tc.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestAdminMiddleware_Disabled(t *testing.T) {
+	handler := AdminMiddleware("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/admin/ai/models", nil)
+	req.Header.Set(AdminKeyHeader, "anything")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"rest-api/internal/i18n"
+)
+
+const LanguageKey contextKey = "language"
+
+// LanguageMiddleware stores the supported language preferred by the client's
+// Accept-Language header. Users' profile language takes precedence over it.
+func LanguageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if language := i18n.ParseAcceptLanguage(r.Header.Get("Accept-Language")); language != "" {
+			ctx := context.WithValue(r.Context(), LanguageKey, language)
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LanguageFromContext returns the Accept-Language preference, "" when there is none
+func LanguageFromContext(ctx context.Context) string {
+	language, _ := ctx.Value(LanguageKey).(string)
+	return language
+}
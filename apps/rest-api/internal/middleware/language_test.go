@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLanguageMiddleware(t *testing.T) {
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"de-DE,de;q=0.9,en;q=0.8", "de"},
+		{"ja", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			var language string
+			handler := LanguageMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				language = LanguageFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/api/motivation", nil)
+			req.Header.Set("Accept-Language", tc.header)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if language != tc.expected {
+				t.Errorf("Expected language '%s' for header '%s', got '%s'", tc.expected, tc.header, language)
+			}
+		})
+	}
+}
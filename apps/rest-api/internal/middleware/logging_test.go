@@ -105,3 +105,23 @@ func TestLoggingMiddleware_Timing(t *testing.T) {
 		t.Errorf("Expected duration >= 10ms, got %v", duration)
 	}
 }
+
+func TestLoggingMiddleware_Flush(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data: chunk\n\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Expected flush to be supported, got %v", err)
+		}
+	})
+
+	loggedHandler := LoggingMiddleware(testHandler)
+
+	req := httptest.NewRequest("GET", "/stream", nil)
+	w := httptest.NewRecorder()
+
+	loggedHandler.ServeHTTP(w, req)
+
+	if !w.Flushed {
+		t.Error("Expected response to be flushed")
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// AIModelStatus describes the circuit breaker state and health of one AI model
+type AIModelStatus struct {
+	Model               string     `json:"model"`
+	Priority            int        `json:"priority"`
+	State               string     `json:"state"`
+	Requests            int        `json:"requests"`
+	Failures            int        `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	ErrorRate           float64    `json:"error_rate"`
+	AvgLatencyMs        int64      `json:"avg_latency_ms"`
+	LastLatencyMs       int64      `json:"last_latency_ms"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastUsedAt          *time.Time `json:"last_used_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+type AIModelsResponse struct {
+	Models []AIModelStatus `json:"models"`
+}
+
+// AICacheEntry is a cached AI response stored by the Mongo-backed cache
+type AICacheEntry struct {
+	Key       string    `bson:"key" json:"key"`
+	Value     string    `bson:"value" json:"value"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// AICacheStats counts cache lookups of one AI call type
+type AICacheStats struct {
+	CallType string  `json:"call_type"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	Bypassed int64   `json:"bypassed"`
+	Errors   int64   `json:"errors"`
+	HitRate  float64 `json:"hit_rate"`
+}
+
+type AICacheResponse struct {
+	Backend string         `json:"backend"`
+	Stats   []AICacheStats `json:"stats"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Features that consume AI tokens
+const (
+	AIFeatureChat       = "chat"
+	AIFeaturePlan       = "plan"
+	AIFeatureRegenerate = "regenerate"
+	AIFeatureMotivation = "motivation"
+	AIFeatureSummary    = "summary"
+	AIFeatureMealPlan   = "meal_plan"
+	AIFeatureReport     = "weekly_report"
+)
+
+// AIUsageRecord is the token usage of one completion
+type AIUsageRecord struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID           int                `bson:"user_id" json:"user_id"`
+	Feature          string             `bson:"feature" json:"feature"`
+	Model            string             `bson:"model" json:"model"`
+	PromptTokens     int                `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int                `bson:"completion_tokens" json:"completion_tokens"`
+	TotalTokens      int                `bson:"total_tokens" json:"total_tokens"`
+	CostUSD          float64            `bson:"cost_usd" json:"cost_usd"`
+	// Estimated is set when the provider reported no usage and tokens were estimated from the text
+	Estimated bool      `bson:"estimated" json:"estimated"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// AIUsageTotal sums the usage of one feature and model
+type AIUsageTotal struct {
+	Feature          string  `bson:"feature" json:"feature"`
+	Model            string  `bson:"model" json:"model"`
+	Requests         int     `bson:"requests" json:"requests"`
+	PromptTokens     int     `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int     `bson:"completion_tokens" json:"completion_tokens"`
+	TotalTokens      int     `bson:"total_tokens" json:"total_tokens"`
+	CostUSD          float64 `bson:"cost_usd" json:"cost_usd"`
+}
+
+// AIUsageUserTotal sums the usage of one user
+type AIUsageUserTotal struct {
+	UserID      int     `bson:"user_id" json:"user_id"`
+	Requests    int     `bson:"requests" json:"requests"`
+	TotalTokens int     `bson:"total_tokens" json:"total_tokens"`
+	CostUSD     float64 `bson:"cost_usd" json:"cost_usd"`
+}
+
+// AIUsagePeriod is a user's usage in the current quota period. A limit of 0 means unlimited.
+type AIUsagePeriod struct {
+	Since       time.Time      `json:"since"`
+	ResetsAt    time.Time      `json:"resets_at"`
+	TotalTokens int            `json:"total_tokens"`
+	CostUSD     float64        `json:"cost_usd"`
+	Limit       int            `json:"limit"`
+	Remaining   *int           `json:"remaining,omitempty"`
+	Usage       []AIUsageTotal `json:"usage"`
+}
+
+type AIUsageResponse struct {
+	Daily   AIUsagePeriod `json:"daily"`
+	Monthly AIUsagePeriod `json:"monthly"`
+}
+
+// AIUsageReport is the usage of all users since a point in time
+type AIUsageReport struct {
+	Since       time.Time          `json:"since"`
+	TotalTokens int                `json:"total_tokens"`
+	CostUSD     float64            `json:"cost_usd"`
+	Usage       []AIUsageTotal     `json:"usage"`
+	Users       []AIUsageUserTotal `json:"users"`
+}
@@ -7,16 +7,73 @@ import (
 )
 
 type ChatMessage struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    int                `bson:"user_id" json:"user_id"`
-	Message   string             `bson:"message" json:"message"`
-	Response  string             `bson:"response" json:"response"`
-	IsUser    bool               `bson:"is_user" json:"is_user"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        int                `bson:"user_id" json:"user_id"`
+	ThreadID      primitive.ObjectID `bson:"thread_id,omitempty" json:"thread_id"`
+	Message       string             `bson:"message" json:"message"`
+	Response      string             `bson:"response" json:"response"`
+	IsUser        bool               `bson:"is_user" json:"is_user"`
+	PromptVersion string             `bson:"prompt_version,omitempty" json:"prompt_version,omitempty"`
+	// Feedback is the user's rating of Response
+	Feedback *ChatFeedback `bson:"feedback,omitempty" json:"feedback,omitempty"`
+	// Variants are the earlier answers replaced by regeneration, oldest first
+	Variants  []ChatVariant `bson:"variants,omitempty" json:"variants,omitempty"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	// RegeneratedAt is when Response replaced an earlier answer
+	RegeneratedAt *time.Time `bson:"regenerated_at,omitempty" json:"regenerated_at,omitempty"`
+}
+
+// Chat feedback ratings
+const (
+	ChatRatingUp   = "up"
+	ChatRatingDown = "down"
+)
+
+// ChatFeedback is a thumbs up or down on an answer
+type ChatFeedback struct {
+	Rating    string    `bson:"rating" json:"rating"`
+	Reason    string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// ChatVariant is an answer that was replaced by a regenerated one, kept with its rating
+type ChatVariant struct {
+	Response      string        `bson:"response" json:"response"`
+	PromptVersion string        `bson:"prompt_version,omitempty" json:"prompt_version,omitempty"`
+	Feedback      *ChatFeedback `bson:"feedback,omitempty" json:"feedback,omitempty"`
+	CreatedAt     time.Time     `bson:"created_at" json:"created_at"`
+}
+
+type ChatFeedbackRequest struct {
+	Rating string `json:"rating" validate:"required,oneof=up down"`
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// RatedChatExchange is a rated answer as exported for prompt tuning
+type RatedChatExchange struct {
+	MessageID     primitive.ObjectID `json:"message_id"`
+	UserID        int                `json:"user_id"`
+	ThreadID      primitive.ObjectID `json:"thread_id"`
+	Message       string             `json:"message"`
+	Response      string             `json:"response"`
+	PromptVersion string             `json:"prompt_version,omitempty"`
+	Rating        string             `json:"rating"`
+	Reason        string             `json:"reason,omitempty"`
+	// Current is false for answers that were replaced by regeneration
+	Current bool `json:"current"`
+	// Alternatives are the other answers to the same message
+	Alternatives []string  `json:"alternatives,omitempty"`
+	RatedAt      time.Time `json:"rated_at"`
+}
+
+type RatedChatExchangeList struct {
+	Exchanges []RatedChatExchange `json:"exchanges"`
 }
 
 type ChatRequest struct {
 	Message string `json:"message" validate:"required,max=500"`
+	// ThreadID addresses a chat thread, empty means the user's default thread
+	ThreadID string `json:"thread_id,omitempty"`
 }
 
 type ChatHistory struct {
@@ -24,5 +81,60 @@ type ChatHistory struct {
 }
 
 type ChatResponse struct {
-	Response string `json:"response"`
+	// MessageID identifies the stored exchange, e.g. for feedback or regeneration
+	MessageID primitive.ObjectID `json:"message_id"`
+	Response  string             `json:"response"`
+	// PendingActions are plan changes proposed in this answer that wait for the user's confirmation
+	PendingActions []ChatAction `json:"pending_actions,omitempty"`
+	// Safety is set when the answer was refused or carries a health disclaimer
+	Safety *SafetyNotice `json:"safety,omitempty"`
+}
+
+// ChatStreamDelta is the payload of a "delta" event sent by /api/chat/stream
+type ChatStreamDelta struct {
+	Content string `json:"content"`
+}
+
+// ChatSummary is the rolling summary of the older history of a chat thread
+type ChatSummary struct {
+	UserID   int                `bson:"user_id" json:"user_id"`
+	ThreadID primitive.ObjectID `bson:"thread_id" json:"thread_id"`
+	Summary  string             `bson:"summary" json:"summary"`
+	// SummarizedUntil is the creation time of the newest message included in the summary
+	SummarizedUntil time.Time `bson:"summarized_until" json:"summarized_until"`
+	MessageCount    int       `bson:"message_count" json:"message_count"`
+	PromptVersion   string    `bson:"prompt_version" json:"prompt_version"`
+	UpdatedAt       time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// DefaultChatThreadTitle is the title of the thread created for every user
+const DefaultChatThreadTitle = "General"
+
+// ChatThread is a named conversation. Every user has one default thread that
+// holds the messages written before threads existed.
+type ChatThread struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        int                `bson:"user_id" json:"user_id"`
+	Title         string             `bson:"title" json:"title"`
+	IsDefault     bool               `bson:"is_default" json:"is_default"`
+	Archived      bool               `bson:"archived" json:"archived"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+	LastMessageAt time.Time          `bson:"last_message_at" json:"last_message_at"`
+	// Migrated is set on the default thread once older messages without a thread were moved into it
+	Migrated bool `bson:"migrated,omitempty" json:"-"`
+}
+
+type CreateChatThreadRequest struct {
+	Title string `json:"title" validate:"max=100"`
+}
+
+// UpdateChatThreadRequest renames or (un)archives a thread, omitted fields are unchanged
+type UpdateChatThreadRequest struct {
+	Title    *string `json:"title,omitempty" validate:"omitempty,max=100"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+type ChatThreadList struct {
+	Threads []ChatThread `json:"threads"`
 }
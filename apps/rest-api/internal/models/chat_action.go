@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Plan changes the chat coach can propose
+const (
+	ChatActionReplaceExercise   = "replace_exercise"
+	ChatActionRescheduleWorkout = "reschedule_workout"
+)
+
+// Lifecycle of a proposed change
+const (
+	ChatActionPending   = "pending"
+	ChatActionConfirmed = "confirmed"
+	ChatActionCancelled = "cancelled"
+)
+
+// ChatAction is a plan change proposed by the chat coach. It is only applied
+// once the user confirms it.
+type ChatAction struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   int                `bson:"user_id" json:"user_id"`
+	ThreadID primitive.ObjectID `bson:"thread_id" json:"thread_id"`
+	Type     string             `bson:"type" json:"type"`
+	// Summary describes the change for the confirmation dialog
+	Summary   string           `bson:"summary" json:"summary"`
+	Params    ChatActionParams `bson:"params" json:"params"`
+	Status    string           `bson:"status" json:"status"`
+	ExpiresAt time.Time        `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time        `bson:"created_at" json:"created_at"`
+	// ResolvedAt is set when the action is confirmed or cancelled
+	ResolvedAt *time.Time `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+}
+
+type ChatActionParams struct {
+	WorkoutID string `bson:"workout_id" json:"workout_id"`
+	// ExerciseName and NewExercise are set for replace_exercise
+	ExerciseName string    `bson:"exercise_name,omitempty" json:"exercise_name,omitempty"`
+	NewExercise  *Exercise `bson:"new_exercise,omitempty" json:"new_exercise,omitempty"`
+	// ScheduledDate is set for reschedule_workout
+	ScheduledDate *time.Time `bson:"scheduled_date,omitempty" json:"scheduled_date,omitempty"`
+}
+
+// ChatActionResult is the outcome of a confirmed action
+type ChatActionResult struct {
+	Action  ChatAction `json:"action"`
+	Workout *Workout   `json:"workout"`
+}
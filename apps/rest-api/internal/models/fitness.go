@@ -16,12 +16,29 @@ type FitnessProfile struct {
 	Timeframe        string    `json:"timeframe" validate:"required,oneof=1month 3months 6months 1year"`
 	FitnessLevel     string    `json:"fitness_level" validate:"required,oneof=beginner intermediate advanced"`
 	AvailableMinutes int       `json:"available_minutes" validate:"required,gte=30,lte=1000"`
+	Equipment        []string  `json:"equipment" validate:"dive,oneof=dumbbells barbell kettlebell resistance_bands pull_up_bar bench"`
 	UpdatedAt        time.Time `json:"updated_at"`
+	// Language of AI responses, empty follows the Accept-Language header
+	Language string `json:"language,omitempty" validate:"omitempty,oneof=en es de fr it pt ru"`
+	// Sex refines the calorie estimate of meal plans, empty uses an average
+	Sex                 string   `json:"sex,omitempty" validate:"omitempty,oneof=male female"`
+	DietaryRestrictions []string `json:"dietary_restrictions,omitempty" validate:"dive,oneof=vegetarian vegan pescatarian gluten_free lactose_free halal kosher"`
+	Allergies           []string `json:"allergies,omitempty" validate:"max=20,dive,max=50"`
 }
 
+// Equipment is the equipment a profile can list, bodyweight exercises need none
+var Equipment = []string{"dumbbells", "barbell", "kettlebell", "resistance_bands", "pull_up_bar", "bench"}
+
+// Plan sources: generated by the AI model or by the rule-based generator
+const (
+	PlanSourceAI    = "ai"
+	PlanSourceRules = "rules"
+)
+
 type WorkoutPlanRequest struct {
-	UserID     int  `json:"-"`
-	Regenerate bool `json:"regenerate"` // Flag to force regeneration
+	UserID     int    `json:"-"`
+	Regenerate bool   `json:"regenerate"` // Flag to force regeneration
+	Generator  string `json:"generator,omitempty" validate:"omitempty,oneof=ai rules"`
 }
 
 type RegenerateWorkoutPlanRequest struct {
@@ -29,13 +46,45 @@ type RegenerateWorkoutPlanRequest struct {
 }
 
 type WorkoutPlan struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    int                `bson:"user_id" json:"user_id"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
-	Status    bool               `bson:"status" json:"status"`
-	Title     string             `bson:"title" json:"title"`
-	Workouts  []Workout          `bson:"workouts" json:"workouts"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        int                `bson:"user_id" json:"user_id"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+	Status        bool               `bson:"status" json:"status"`
+	Title         string             `bson:"title" json:"title"`
+	Source        string             `bson:"source" json:"source,omitempty"`
+	PromptVersion string             `bson:"prompt_version" json:"prompt_version,omitempty"`
+	Workouts      []Workout          `bson:"workouts" json:"workouts"`
+	// Disclaimers are health-safety notes for the user's health issues and the plan content
+	Disclaimers []string `bson:"disclaimers,omitempty" json:"disclaimers,omitempty"`
+	// Phases are the mesocycles of long timeframes, Workouts only holds the
+	// generated ones
+	Phases []PlanPhase `bson:"phases,omitempty" json:"phases,omitempty"`
+	// PhaseJob is the job generating the phases the user has reached, it is
+	// not stored with the plan
+	PhaseJob *PlanJob `bson:"-" json:"phase_job,omitempty"`
+}
+
+// Focuses of plan phases
+const (
+	PhaseHypertrophy = "hypertrophy"
+	PhaseStrength    = "strength"
+	PhasePeaking     = "peaking"
+	PhaseRecovery    = "recovery"
+)
+
+// PlanPhase is a mesocycle of a long plan with its own base workouts. A
+// phase is generated when the user reaches it.
+type PlanPhase struct {
+	// Number counts the phases from 1
+	Number    int       `bson:"number" json:"number"`
+	Focus     string    `bson:"focus" json:"focus"`
+	StartWeek int       `bson:"start_week" json:"start_week"`
+	Weeks     int       `bson:"weeks" json:"weeks"`
+	StartDate time.Time `bson:"start_date" json:"start_date"`
+	// Generated is set once the workouts of the phase are scheduled
+	Generated bool   `bson:"generated" json:"generated"`
+	Source    string `bson:"source,omitempty" json:"source,omitempty"`
 }
 
 type ShortWorkoutPlan struct {
@@ -45,18 +94,29 @@ type ShortWorkoutPlan struct {
 	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
 	Status          bool               `bson:"status" json:"status"`
 	Title           string             `bson:"title" json:"title"`
+	Source          string             `bson:"source" json:"source,omitempty"`
+	PromptVersion   string             `bson:"prompt_version" json:"prompt_version,omitempty"`
 	BaseWorkouts    []Workout          `bson:"base_workouts" json:"base_workouts"`
 	Timeframe       string             `bson:"timeframe" json:"timeframe"`
 	WorkoutsPerWeek int                `bson:"workouts_per_week" json:"workouts_per_week"`
+	// Phase is the plan phase of the base workouts, 0 for plans without phases
+	Phase int `bson:"phase,omitempty" json:"phase,omitempty"`
 }
 
 type Workout struct {
-	WorkoutID     primitive.ObjectID `bson:"workout_id,omitempty" json:"workout_id"`
-	Name          string             `bson:"name" json:"name"`
-	Description   string             `bson:"description,omitempty" json:"description,omitempty"`
-	Status        string             `bson:"status" json:"status"`
-	ScheduledDate time.Time          `bson:"scheduled_date" json:"scheduled_date"`
-	Exercises     []Exercise         `bson:"exercises" json:"exercises"`
+	WorkoutID primitive.ObjectID `bson:"workout_id,omitempty" json:"workout_id"`
+	Name      string             `bson:"name" json:"name"`
+	// BaseWorkout is the name of the base workout a scheduled workout repeats
+	BaseWorkout   string    `bson:"base_workout,omitempty" json:"base_workout,omitempty"`
+	Description   string    `bson:"description,omitempty" json:"description,omitempty"`
+	Status        string    `bson:"status" json:"status"`
+	ScheduledDate time.Time `bson:"scheduled_date" json:"scheduled_date"`
+	// Week is the week of the schedule from 1, Phase the plan phase of long
+	// timeframes and Deload marks deload weeks; all are unset on base workouts
+	Week      int        `bson:"week,omitempty" json:"week,omitempty"`
+	Phase     int        `bson:"phase,omitempty" json:"phase,omitempty"`
+	Deload    bool       `bson:"deload,omitempty" json:"deload,omitempty"`
+	Exercises []Exercise `bson:"exercises" json:"exercises"`
 }
 
 type Exercise struct {
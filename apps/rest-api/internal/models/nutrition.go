@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sex values of a profile
+const (
+	SexMale   = "male"
+	SexFemale = "female"
+)
+
+// Dietary restrictions a profile may list
+const (
+	DietVegetarian  = "vegetarian"
+	DietVegan       = "vegan"
+	DietPescatarian = "pescatarian"
+	DietGlutenFree  = "gluten_free"
+	DietLactoseFree = "lactose_free"
+	DietHalal       = "halal"
+	DietKosher      = "kosher"
+)
+
+// DietaryRestrictions are the supported restrictions in display order
+var DietaryRestrictions = []string{
+	DietVegetarian, DietVegan, DietPescatarian, DietGlutenFree, DietLactoseFree, DietHalal, DietKosher,
+}
+
+// Meal types of a meal plan day
+const (
+	MealBreakfast = "breakfast"
+	MealLunch     = "lunch"
+	MealDinner    = "dinner"
+	MealSnack     = "snack"
+)
+
+// NutrientRange is a daily target and the range a day's total must fall into
+type NutrientRange struct {
+	Target float64 `bson:"target" json:"target"`
+	Min    float64 `bson:"min" json:"min"`
+	Max    float64 `bson:"max" json:"max"`
+}
+
+// Contains reports whether value lies within the range
+func (r NutrientRange) Contains(value float64) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+// NutritionTargets are the daily targets derived from the fitness profile
+type NutritionTargets struct {
+	// BMR and TDEE are the basal and total daily energy expenditure in kcal
+	BMR      int           `bson:"bmr" json:"bmr"`
+	TDEE     int           `bson:"tdee" json:"tdee"`
+	Calories NutrientRange `bson:"calories" json:"calories"`
+	ProteinG NutrientRange `bson:"protein_g" json:"protein_g"`
+	CarbsG   NutrientRange `bson:"carbs_g" json:"carbs_g"`
+	FatG     NutrientRange `bson:"fat_g" json:"fat_g"`
+}
+
+// Macros are the calories and macronutrients of a meal or day
+type Macros struct {
+	Calories float64 `bson:"calories" json:"calories"`
+	ProteinG float64 `bson:"protein_g" json:"protein_g"`
+	CarbsG   float64 `bson:"carbs_g" json:"carbs_g"`
+	FatG     float64 `bson:"fat_g" json:"fat_g"`
+}
+
+// Add sums two macro totals
+func (m Macros) Add(other Macros) Macros {
+	return Macros{
+		Calories: m.Calories + other.Calories,
+		ProteinG: m.ProteinG + other.ProteinG,
+		CarbsG:   m.CarbsG + other.CarbsG,
+		FatG:     m.FatG + other.FatG,
+	}
+}
+
+type Meal struct {
+	Type        string   `bson:"type" json:"type"`
+	Name        string   `bson:"name" json:"name"`
+	Description string   `bson:"description,omitempty" json:"description,omitempty"`
+	Ingredients []string `bson:"ingredients" json:"ingredients"`
+	Macros      `bson:",inline"`
+}
+
+type MealDay struct {
+	Day   int    `bson:"day" json:"day"`
+	Meals []Meal `bson:"meals" json:"meals"`
+	// Totals are summed from the meals, not taken from the model
+	Totals Macros `bson:"totals" json:"totals"`
+}
+
+// MealPlan is the user's weekly meal plan, a new one replaces the previous
+type MealPlan struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        int                `bson:"user_id" json:"user_id"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+	Title         string             `bson:"title" json:"title"`
+	Goal          string             `bson:"goal" json:"goal"`
+	Targets       NutritionTargets   `bson:"targets" json:"targets"`
+	Days          []MealDay          `bson:"days" json:"days"`
+	PromptVersion string             `bson:"prompt_version" json:"prompt_version,omitempty"`
+	// DietaryRestrictions and Allergies the plan was generated for
+	DietaryRestrictions []string `bson:"dietary_restrictions,omitempty" json:"dietary_restrictions,omitempty"`
+	Allergies           []string `bson:"allergies,omitempty" json:"allergies,omitempty"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Plan job types
+const (
+	PlanJobGenerate   = "generate"
+	PlanJobRegenerate = "regenerate"
+	PlanJobMealPlan   = "meal_plan"
+	// PlanJobRegenerateWorkout regenerates a single workout of the plan
+	PlanJobRegenerateWorkout = "regenerate_workout"
+	// PlanJobPhase generates the phases of a long plan the user has reached
+	PlanJobPhase = "phase"
+)
+
+// Plan job statuses
+const (
+	PlanJobQueued    = "queued"
+	PlanJobRunning   = "running"
+	PlanJobSucceeded = "succeeded"
+	PlanJobFailed    = "failed"
+)
+
+// Plan job stages, reported while a job is running
+const (
+	PlanStageQueued     = "queued"
+	PlanStageProfile    = "loading_profile"
+	PlanStageGenerating = "generating"
+	PlanStageRepairing  = "repairing"
+	PlanStageScheduling = "scheduling"
+	PlanStageSaving     = "saving"
+	PlanStageDone       = "done"
+)
+
+// PlanJob is a plan generation, regeneration, phase generation or meal plan generation running in the background
+type PlanJob struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID int                `bson:"user_id" json:"user_id"`
+	Type   string             `bson:"type" json:"type"`
+	Status string             `bson:"status" json:"status"`
+	// Active is set while the job is queued or running. A user has at most one active job.
+	Active         bool   `bson:"active" json:"-"`
+	Stage          string `bson:"stage" json:"stage"`
+	Progress       int    `bson:"progress" json:"progress"`
+	PlanJobOptions `bson:",inline"`
+	// Error and ErrorCode describe why a job failed, ErrorCode is the HTTP status the request would have had
+	Error     string `bson:"error,omitempty" json:"error,omitempty"`
+	ErrorCode int    `bson:"error_code,omitempty" json:"error_code,omitempty"`
+	// Plan is the user's plan once the job succeeded, it is not stored with the job
+	Plan *WorkoutPlan `bson:"-" json:"plan,omitempty"`
+	// MealPlan replaces Plan for meal plan jobs
+	MealPlan   *MealPlan  `bson:"-" json:"meal_plan,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `bson:"updated_at" json:"updated_at"`
+	StartedAt  *time.Time `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// PlanJobOptions are the options of the request that submitted a job
+type PlanJobOptions struct {
+	Generator string `bson:"generator,omitempty" json:"generator,omitempty"`
+	Comments  string `bson:"comments,omitempty" json:"comments,omitempty"`
+	// WorkoutID is the workout of a regenerate_workout job
+	WorkoutID string `bson:"workout_id,omitempty" json:"workout_id,omitempty"`
+}
+
+// Finished reports whether the job succeeded or failed
+func (j *PlanJob) Finished() bool {
+	return j.Status == PlanJobSucceeded || j.Status == PlanJobFailed
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons for a new plan version
+const (
+	// PlanChangeInitial is the plan the user had before versions were kept
+	PlanChangeInitial           = "initial"
+	PlanChangeGenerate          = "generate"
+	PlanChangeRegenerate        = "regenerate"
+	PlanChangeRegenerateWorkout = "regenerate_workout"
+	PlanChangeSubstitute        = "substitute"
+	PlanChangeChat              = "chat"
+	PlanChangeRestore           = "restore"
+	// PlanChangePhase is the generation of a later phase of a long plan
+	PlanChangePhase = "phase"
+)
+
+// PlanAuthorUser marks versions the user made by hand, the others carry the
+// plan source (ai or rules)
+const PlanAuthorUser = "user"
+
+// WorkoutPlanVersion is an immutable snapshot of a user's plan, stored every
+// time the plan changes. Lists leave out the snapshots.
+type WorkoutPlanVersion struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID int                `bson:"user_id" json:"user_id"`
+	// Version numbers the user's versions from 1
+	Version int    `bson:"version" json:"version"`
+	Reason  string `bson:"reason" json:"reason"`
+	Author  string `bson:"author,omitempty" json:"author,omitempty"`
+	// Comments are the user's regeneration comments or a description of the edit
+	Comments string `bson:"comments,omitempty" json:"comments,omitempty"`
+	Title    string `bson:"title" json:"title"`
+	// RestoredFrom is the version a restore copied
+	RestoredFrom int               `bson:"restored_from,omitempty" json:"restored_from,omitempty"`
+	Plan         *WorkoutPlan      `bson:"plan,omitempty" json:"plan,omitempty"`
+	ShortPlan    *ShortWorkoutPlan `bson:"short_plan,omitempty" json:"short_plan,omitempty"`
+	CreatedAt    time.Time         `bson:"created_at" json:"created_at"`
+}
+
+type WorkoutPlanVersionList struct {
+	Versions []WorkoutPlanVersion `json:"versions"`
+}
+
+// Changes of a workout in a plan diff
+const (
+	WorkoutDiffAdded   = "added"
+	WorkoutDiffRemoved = "removed"
+	WorkoutDiffChanged = "changed"
+)
+
+// WorkoutPlanDiff lists the exercise changes between two plan versions. From
+// and To are the versions without their snapshots.
+type WorkoutPlanDiff struct {
+	From     WorkoutPlanVersion `json:"from"`
+	To       WorkoutPlanVersion `json:"to"`
+	Workouts []WorkoutDiff      `json:"workouts"`
+}
+
+// WorkoutDiff is a change of the scheduled workouts of one base workout.
+// Occurrences with the same change are merged, Workouts names them.
+type WorkoutDiff struct {
+	BaseWorkout string   `json:"base_workout"`
+	Change      string   `json:"change"`
+	Workouts    []string `json:"workouts"`
+	// Added and Removed are the whole exercise list for added and removed workouts
+	Added   []Exercise       `json:"added,omitempty"`
+	Removed []Exercise       `json:"removed,omitempty"`
+	Changed []ExerciseChange `json:"changed,omitempty"`
+}
+
+// ExerciseChange is an exercise of both versions, Fields names what differs
+type ExerciseChange struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+	From   Exercise `json:"from"`
+	To     Exercise `json:"to"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WeeklyReport summarizes a user's training week. The stats are computed,
+// the summary is written by the model from them.
+type WeeklyReport struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID int                `bson:"user_id" json:"user_id"`
+	// WeekStart is Monday 00:00 UTC, WeekEnd the following Monday
+	WeekStart time.Time   `bson:"week_start" json:"week_start"`
+	WeekEnd   time.Time   `bson:"week_end" json:"week_end"`
+	Stats     WeeklyStats `bson:"stats" json:"stats"`
+	// Summary is empty when the model was unavailable, such reports are not stored
+	Summary       string    `bson:"summary" json:"summary"`
+	Language      string    `bson:"language,omitempty" json:"language,omitempty"`
+	PromptVersion string    `bson:"prompt_version,omitempty" json:"prompt_version,omitempty"`
+	CreatedAt     time.Time `bson:"created_at" json:"created_at"`
+}
+
+// WeeklyStats are the numbers of a weekly report
+type WeeklyStats struct {
+	// Completed counts the workouts completed during the week
+	Completed int `bson:"completed" json:"completed"`
+	// Scheduled counts the plan's workouts scheduled for the week, ScheduledDone
+	// those of them that are done and Missed those that expired
+	Scheduled     int `bson:"scheduled" json:"scheduled"`
+	ScheduledDone int `bson:"scheduled_done" json:"scheduled_done"`
+	Missed        int `bson:"missed" json:"missed"`
+	// Adherence is ScheduledDone in percent of Scheduled, 0 without scheduled workouts
+	Adherence  int `bson:"adherence" json:"adherence"`
+	ActiveDays int `bson:"active_days" json:"active_days"`
+	// PreviousCompleted is Completed of the week before, for the trend
+	PreviousCompleted int `bson:"previous_completed" json:"previous_completed"`
+	// DayStreak is the run of consecutive training days that ends on the
+	// week's last training day, WeekStreak the number of weeks in a row with a workout
+	DayStreak  int `bson:"day_streak" json:"day_streak"`
+	WeekStreak int `bson:"week_streak" json:"week_streak"`
+	// MuscleGroups counts the sets of the completed workouts per muscle group
+	MuscleGroups map[string]int `bson:"muscle_groups,omitempty" json:"muscle_groups,omitempty"`
+	// WeightKg is the profile weight at the time of the report, WeightChangeKg
+	// the change since the previous stored report
+	WeightKg       float64  `bson:"weight_kg,omitempty" json:"weight_kg,omitempty"`
+	WeightChangeKg *float64 `bson:"weight_change_kg,omitempty" json:"weight_change_kg,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SafetyNotice tells the client that an answer was refused or carries a
+// disclaimer because of health-safety rules
+type SafetyNotice struct {
+	// Action is refuse or disclaim
+	Action     string   `json:"action"`
+	Categories []string `json:"categories"`
+}
+
+// SafetyEvent is an exchange flagged by a health-safety rule, kept for review
+type SafetyEvent struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   int                `bson:"user_id" json:"user_id"`
+	Feature  string             `bson:"feature" json:"feature"`
+	ThreadID primitive.ObjectID `bson:"thread_id,omitempty" json:"thread_id,omitempty"`
+	// Action is the strongest action of the matched rules
+	Action     string   `bson:"action" json:"action"`
+	Rules      []string `bson:"rules" json:"rules"`
+	Categories []string `bson:"categories" json:"categories"`
+	// Input is what the user sent, Output what the model answered before it was changed
+	Input     string    `bson:"input" json:"input"`
+	Output    string    `bson:"output,omitempty" json:"output,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+type SafetyEventList struct {
+	Events []SafetyEvent `json:"events"`
+}
@@ -0,0 +1,39 @@
+package models
+
+// Where a chosen substitute is applied
+const (
+	SubstituteScopeWorkout = "workout"
+	SubstituteScopeFuture  = "future"
+)
+
+// ExerciseSubstitutionRequest picks one of the proposed alternatives. Without
+// an alternative only the proposals are returned.
+type ExerciseSubstitutionRequest struct {
+	Alternative string `json:"alternative,omitempty"`
+	// Scope is "workout" (default) for this workout only or "future" for
+	// every upcoming occurrence of its base workout
+	Scope string `json:"scope,omitempty"`
+}
+
+// ExerciseAlternative is a library exercise prescribed like the one it replaces
+type ExerciseAlternative struct {
+	Name        string `json:"name"`
+	MuscleGroup string `json:"muscle_group"`
+	Equipment   string `json:"equipment"`
+	Sets        int    `json:"sets"`
+	Reps        int    `json:"reps"`
+	RestSec     int    `json:"rest_sec,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+	Technique   string `json:"technique,omitempty"`
+	// Related is set when the exercise trains a related muscle group, because
+	// the library has nothing for the replaced exercise's group
+	Related bool `json:"related,omitempty"`
+}
+
+type ExerciseSubstitutionResponse struct {
+	// Exercise is the exercise being substituted
+	Exercise     Exercise              `json:"exercise"`
+	Alternatives []ExerciseAlternative `json:"alternatives,omitempty"`
+	// Workouts are the changed workouts once an alternative is applied
+	Workouts []Workout `json:"workouts,omitempty"`
+}
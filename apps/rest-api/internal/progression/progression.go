@@ -0,0 +1,273 @@
+// Package progression changes the sets and reps of a plan's scheduled
+// workouts week over week.
+//
+// A linear model adds reps and sets at a fixed rate. A double progression
+// model raises the reps through a range, then adds a set and starts over at
+// the bottom of the range. Every few weeks a deload week cuts the sets and
+// pauses the progression. Models are selected by goal and fitness level; the
+// embedded default_models.json is used unless a models file replaces it.
+package progression
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sync"
+
+	"rest-api/internal/models"
+)
+
+//go:embed default_models.json
+var defaultModels []byte
+
+// Types of progression
+const (
+	TypeLinear = "linear"
+	TypeDouble = "double"
+	// TypeNone keeps the sets and reps, deload weeks still apply
+	TypeNone = "none"
+)
+
+// Limits of the sets and reps of a valid plan, no model goes beyond them
+const (
+	MaxSets = 10
+	MaxReps = 50
+)
+
+// Model describes how the exercises of a base workout change from week to
+// week. Exercises with a single rep are timed holds, only their sets progress.
+type Model struct {
+	ID string `json:"id"`
+	// Goals and FitnessLevels select the model, empty lists match all
+	Goals         []string `json:"goals,omitempty"`
+	FitnessLevels []string `json:"fitness_levels,omitempty"`
+	Type          string   `json:"type"`
+	// EveryWeeks is how many training weeks one step takes
+	EveryWeeks int `json:"every_weeks"`
+	// RepsStep and SetsStep are added per step. In double progression the reps
+	// rise by RepsStep until they are RepRange above the base reps, and the
+	// next step adds SetsStep sets and goes back to the base reps.
+	RepsStep int `json:"reps_step"`
+	SetsStep int `json:"sets_step"`
+	RepRange int `json:"rep_range,omitempty"`
+	// MaxReps and MaxSets cap the progression, base values above them are kept
+	MaxReps int `json:"max_reps"`
+	MaxSets int `json:"max_sets"`
+	// DeloadEvery makes every n-th week a deload week with DeloadSetsPercent
+	// of the sets, 0 disables deloads
+	DeloadEvery       int `json:"deload_every,omitempty"`
+	DeloadSetsPercent int `json:"deload_sets_percent,omitempty"`
+}
+
+// Models is a validated model set
+type Models struct {
+	Models []Model `json:"models"`
+}
+
+// ParseModels parses and validates a JSON model set
+func ParseModels(data []byte) (*Models, error) {
+	var set Models
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse progression models: %w", err)
+	}
+	if err := set.validate(); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func (m *Models) validate() error {
+	if len(m.Models) == 0 {
+		return errors.New("progression models are empty")
+	}
+
+	seen := make(map[string]bool)
+	for i, model := range m.Models {
+		if model.ID == "" {
+			return fmt.Errorf("progression model #%d: id is required", i+1)
+		}
+		if seen[model.ID] {
+			return fmt.Errorf("progression model %s: duplicate id", model.ID)
+		}
+		seen[model.ID] = true
+
+		switch model.Type {
+		case TypeLinear, TypeNone:
+		case TypeDouble:
+			if model.RepsStep < 1 || model.SetsStep < 1 || model.RepRange < model.RepsStep {
+				return fmt.Errorf("progression model %s: double progression needs reps_step, sets_step and a rep_range of at least reps_step", model.ID)
+			}
+		default:
+			return fmt.Errorf("progression model %s: type must be linear, double or none", model.ID)
+		}
+		if model.Type != TypeNone && model.EveryWeeks < 1 {
+			return fmt.Errorf("progression model %s: every_weeks must be at least 1", model.ID)
+		}
+		if model.RepsStep < 0 || model.SetsStep < 0 {
+			return fmt.Errorf("progression model %s: steps must not be negative", model.ID)
+		}
+		if model.MaxSets < 1 || model.MaxSets > MaxSets || model.MaxReps < 1 || model.MaxReps > MaxReps {
+			return fmt.Errorf("progression model %s: max_sets must be 1-%d and max_reps 1-%d", model.ID, MaxSets, MaxReps)
+		}
+		if model.DeloadEvery < 0 || model.DeloadEvery == 1 {
+			return fmt.Errorf("progression model %s: deload_every must be 0 or at least 2", model.ID)
+		}
+		if model.DeloadEvery > 0 && (model.DeloadSetsPercent < 1 || model.DeloadSetsPercent > 100) {
+			return fmt.Errorf("progression model %s: deload_sets_percent must be 1-100", model.ID)
+		}
+	}
+	return nil
+}
+
+// Select returns the first model for the goal and fitness level, a model
+// that changes nothing when none matches
+func (m *Models) Select(goal, level string) Model {
+	for _, model := range m.Models {
+		if (len(model.Goals) == 0 || slices.Contains(model.Goals, goal)) &&
+			(len(model.FitnessLevels) == 0 || slices.Contains(model.FitnessLevels, level)) {
+			return model
+		}
+	}
+	return Model{ID: TypeNone, Type: TypeNone}
+}
+
+// Deload reports whether the week (from 1) is a deload week
+func (m Model) Deload(week int) bool {
+	return m.DeloadEvery > 0 && week%m.DeloadEvery == 0
+}
+
+// steps counts the progression steps reached by the week. Deload weeks do
+// not count as training weeks.
+func (m Model) steps(week int) int {
+	if m.Type == TypeNone || m.EveryWeeks < 1 {
+		return 0
+	}
+	trained := week - 1
+	if m.DeloadEvery > 0 {
+		trained -= (week - 1) / m.DeloadEvery
+	}
+	return trained / m.EveryWeeks
+}
+
+// Apply returns the exercise as prescribed for the week (from 1), given its
+// week 1 prescription. Deload weeks repeat the week before with fewer sets.
+func (m Model) Apply(exercise models.Exercise, week int) models.Exercise {
+	if week < 1 {
+		return exercise
+	}
+
+	// A deload week keeps the load of the week before
+	deload := m.Deload(week)
+	steps := m.steps(week)
+	if deload {
+		steps = m.steps(week - 1)
+	}
+	hold := exercise.Reps <= 1
+	switch m.Type {
+	case TypeLinear:
+		exercise.Sets = raise(exercise.Sets, steps*m.SetsStep, m.MaxSets)
+		if !hold {
+			exercise.Reps = raise(exercise.Reps, steps*m.RepsStep, m.MaxReps)
+		}
+	case TypeDouble:
+		positions := m.RepRange/m.RepsStep + 1
+		cycles := steps / positions
+		base := exercise.Sets
+		exercise.Sets = raise(base, cycles*m.SetsStep, m.MaxSets)
+		switch {
+		case hold:
+		case exercise.Sets-base < cycles*m.SetsStep:
+			// Without sets left to add the reps stay at the top of the range
+			exercise.Reps = raise(exercise.Reps, m.RepRange, m.MaxReps)
+		default:
+			exercise.Reps = raise(exercise.Reps, steps%positions*m.RepsStep, m.MaxReps)
+		}
+	}
+
+	if deload {
+		sets := int(math.Round(float64(exercise.Sets*m.DeloadSetsPercent) / 100))
+		exercise.Sets = max(sets, 1)
+	}
+	return exercise
+}
+
+// raise adds to a value up to the limit, values already above it are kept
+func raise(value, add, limit int) int {
+	if value >= limit {
+		return value
+	}
+	return min(value+add, limit)
+}
+
+// Store holds the active models. It is safe for concurrent use.
+type Store struct {
+	path string
+
+	mu     sync.RWMutex
+	models *Models
+}
+
+// Load reads the models from path. A missing file (or an empty path)
+// selects the embedded default models.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+var defaultStore = sync.OnceValue(func() *Store {
+	s, err := Load("")
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded progression models: %v", err))
+	}
+	return s
+})
+
+// Default returns the store with the embedded models
+func Default() *Store {
+	return defaultStore()
+}
+
+// Reload re-reads the models file. On error the current models stay active.
+func (s *Store) Reload() error {
+	data := defaultModels
+	if s.path != "" {
+		fileData, err := os.ReadFile(s.path)
+		switch {
+		case err == nil:
+			data = fileData
+		case !errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("failed to read progression models %s: %w", s.path, err)
+		}
+	}
+
+	set, err := ParseModels(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.models = set
+	s.mu.Unlock()
+	return nil
+}
+
+// Select returns the active model for the goal and fitness level
+func (s *Store) Select(goal, level string) Model {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.models.Select(goal, level)
+}
+
+// ModelCount is the number of active models
+func (s *Store) ModelCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.models.Models)
+}
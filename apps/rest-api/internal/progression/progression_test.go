@@ -0,0 +1,171 @@
+package progression
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rest-api/internal/models"
+)
+
+func TestModel_Apply(t *testing.T) {
+	linear := Model{Type: TypeLinear, EveryWeeks: 2, RepsStep: 2, SetsStep: 1, MaxReps: 16, MaxSets: 5, DeloadEvery: 4, DeloadSetsPercent: 50}
+	double := Model{Type: TypeDouble, EveryWeeks: 1, RepsStep: 2, SetsStep: 1, RepRange: 4, MaxReps: 15, MaxSets: 4}
+	deloadOnly := Model{Type: TypeNone, DeloadEvery: 3, DeloadSetsPercent: 50}
+
+	testCases := []struct {
+		name  string
+		model Model
+		sets  int
+		reps  int
+		week  int
+		want  [2]int
+	}{
+		{"linear first week", linear, 3, 10, 1, [2]int{3, 10}},
+		{"linear within the first step", linear, 3, 10, 2, [2]int{3, 10}},
+		{"linear first step", linear, 3, 10, 3, [2]int{4, 12}},
+		{"linear deload", linear, 3, 10, 4, [2]int{2, 12}},
+		{"linear after deload", linear, 3, 10, 5, [2]int{4, 12}},
+		{"linear second step", linear, 3, 10, 6, [2]int{5, 14}},
+		{"linear capped", linear, 3, 10, 9, [2]int{5, 16}},
+		{"linear capped deload", linear, 3, 10, 12, [2]int{3, 16}},
+		{"linear hold", linear, 2, 1, 3, [2]int{3, 1}},
+		{"linear above the caps", linear, 6, 20, 9, [2]int{6, 20}},
+		{"linear invalid week", linear, 3, 10, 0, [2]int{3, 10}},
+		{"double first week", double, 3, 8, 1, [2]int{3, 8}},
+		{"double reps rise", double, 3, 8, 3, [2]int{3, 12}},
+		{"double adds a set", double, 3, 8, 4, [2]int{4, 8}},
+		{"double second cycle", double, 3, 8, 6, [2]int{4, 12}},
+		{"double sets capped", double, 3, 8, 7, [2]int{4, 12}},
+		{"double sets capped later", double, 3, 8, 20, [2]int{4, 12}},
+		{"double hold", double, 2, 1, 4, [2]int{3, 1}},
+		{"none keeps the exercise", deloadOnly, 3, 10, 2, [2]int{3, 10}},
+		{"none deload", deloadOnly, 3, 10, 3, [2]int{2, 10}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exercise := models.Exercise{Name: "Squats", Sets: tc.sets, Reps: tc.reps, RestSec: 60}
+			got := tc.model.Apply(exercise, tc.week)
+			if got.Sets != tc.want[0] || got.Reps != tc.want[1] {
+				t.Errorf("Expected %dx%d in week %d, got %dx%d", tc.want[0], tc.want[1], tc.week, got.Sets, got.Reps)
+			}
+			if got.Name != exercise.Name || got.RestSec != exercise.RestSec {
+				t.Errorf("Expected the other fields to be kept, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestDefaultModels(t *testing.T) {
+	store := Default()
+	base := models.Exercise{Sets: 3, Reps: 10}
+
+	for _, goal := range []string{"weight_loss", "muscle_gain", "endurance", "flexibility", "general_fitness"} {
+		for _, level := range []string{"beginner", "intermediate", "advanced"} {
+			model := store.Select(goal, level)
+			if model.Type == TypeNone {
+				t.Errorf("Expected a progression for %s/%s", goal, level)
+				continue
+			}
+			if first, twelfth := model.Apply(base, 1), model.Apply(base, 12); first == twelfth {
+				t.Errorf("Expected week 12 of %s to differ from week 1, got %+v", model.ID, twelfth)
+			}
+			for week := 1; week <= 52; week++ {
+				if got := model.Apply(base, week); got.Sets < 1 || got.Sets > model.MaxSets || got.Reps > model.MaxReps {
+					t.Errorf("Expected %s to stay within its caps, got %+v in week %d", model.ID, got, week)
+				}
+			}
+		}
+	}
+
+	testCases := []struct {
+		goal     string
+		level    string
+		expected string
+	}{
+		{"muscle_gain", "beginner", "beginner"},
+		{"muscle_gain", "advanced", "muscle_gain"},
+		{"endurance", "intermediate", "conditioning"},
+		{"general_fitness", "intermediate", "general"},
+	}
+	for _, tc := range testCases {
+		if model := store.Select(tc.goal, tc.level); model.ID != tc.expected {
+			t.Errorf("Expected %s for %s/%s, got %s", tc.expected, tc.goal, tc.level, model.ID)
+		}
+	}
+}
+
+func TestLoad_FileOverridesDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progression.json")
+	set := `{"models": [{"id": "strength", "goals": ["muscle_gain"], "type": "linear", "every_weeks": 1, "sets_step": 1, "max_reps": 12, "max_sets": 6}]}`
+	if err := os.WriteFile(path, []byte(set), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.ModelCount() != 1 {
+		t.Errorf("Expected the file to replace the default models, got %d models", store.ModelCount())
+	}
+	if model := store.Select("muscle_gain", "advanced"); model.ID != "strength" {
+		t.Errorf("Expected the model of the file, got %s", model.ID)
+	}
+	if model := store.Select("endurance", "advanced"); model.Type != TypeNone {
+		t.Errorf("Expected no progression without a matching model, got %s", model.ID)
+	}
+
+	// A broken file is rejected and the loaded models stay active
+	if err := os.WriteFile(path, []byte(`{"models": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err == nil {
+		t.Error("Expected empty models to be rejected")
+	}
+	if store.ModelCount() != 1 {
+		t.Errorf("Expected the previous models to stay active, got %d models", store.ModelCount())
+	}
+}
+
+func TestLoad_MissingFileUsesDefaults(t *testing.T) {
+	store, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.ModelCount() != Default().ModelCount() {
+		t.Errorf("Expected the default models, got %d models", store.ModelCount())
+	}
+}
+
+func TestParseModels_Invalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		models   string
+		expected string
+	}{
+		{"empty", `{"models": []}`, "empty"},
+		{"missing id", `{"models": [{"type": "none", "max_reps": 10, "max_sets": 3}]}`, "id is required"},
+		{"duplicate id", `{"models": [
+			{"id": "a", "type": "none", "max_reps": 10, "max_sets": 3},
+			{"id": "a", "type": "none", "max_reps": 10, "max_sets": 3}]}`, "duplicate"},
+		{"unknown type", `{"models": [{"id": "a", "type": "wave", "every_weeks": 1, "max_reps": 10, "max_sets": 3}]}`, "type"},
+		{"double without range", `{"models": [{"id": "a", "type": "double", "every_weeks": 1, "reps_step": 2, "sets_step": 1, "rep_range": 1, "max_reps": 10, "max_sets": 3}]}`, "rep_range"},
+		{"no interval", `{"models": [{"id": "a", "type": "linear", "reps_step": 1, "max_reps": 10, "max_sets": 3}]}`, "every_weeks"},
+		{"negative step", `{"models": [{"id": "a", "type": "linear", "every_weeks": 1, "reps_step": -1, "max_reps": 10, "max_sets": 3}]}`, "negative"},
+		{"caps beyond a valid plan", `{"models": [{"id": "a", "type": "linear", "every_weeks": 1, "max_reps": 60, "max_sets": 3}]}`, "max_reps"},
+		{"deload every week", `{"models": [{"id": "a", "type": "none", "max_reps": 10, "max_sets": 3, "deload_every": 1, "deload_sets_percent": 50}]}`, "deload_every"},
+		{"deload without percent", `{"models": [{"id": "a", "type": "none", "max_reps": 10, "max_sets": 3, "deload_every": 4}]}`, "deload_sets_percent"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseModels([]byte(tc.models))
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing '%s', got %v", tc.expected, err)
+			}
+		})
+	}
+}
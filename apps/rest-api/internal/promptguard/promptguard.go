@@ -0,0 +1,145 @@
+// Package promptguard keeps user text that enters prompts from being read as
+// instructions (prompt injection).
+//
+// User text is sanitized and placed between <user_input> delimiters that the
+// prompts declare as data. Instruction-override attempts are detected by
+// pattern so they can be rejected or flagged, and model output is checked for
+// leaked prompts and content that does not belong in a fitness answer.
+package promptguard
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Delimiters around user text in prompts
+const (
+	OpenTag  = "<user_input>"
+	CloseTag = "</user_input>"
+)
+
+// MaxInputLength caps a single piece of user text in a prompt, in runes
+const MaxInputLength = 4000
+
+// minLeakLength is the length of a prompt line that is considered leaked
+// when the output repeats it
+const minLeakLength = 50
+
+var (
+	// roleTokens are chat template tokens of common models
+	roleTokens    = regexp.MustCompile(`(?i)<\|[a-z_]*\|>|\[/?inst\]|<</?sys>>`)
+	delimiterTags = regexp.MustCompile(`(?i)<\s*/?\s*user_input\s*>`)
+	blankLines    = regexp.MustCompile(`\n{3,}`)
+	spaces        = regexp.MustCompile(`[ \t]+`)
+
+	links  = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.[a-z0-9-]+\.`)
+	markup = regexp.MustCompile("(?i)<\\s*/?\\s*(script|iframe|img|a|style|html|body|div)\\b|```|\\{\\{|\\}\\}")
+)
+
+// overridePatterns match attempts to change the model's instructions, by ID
+var overridePatterns = []struct {
+	id string
+	re *regexp.Regexp
+}{
+	{"ignore_instructions", regexp.MustCompile(`\b(ignore|disregard|forget|override|bypass)\b[^.!?\n]{0,40}\b((previous|prior|above|earlier|preceding|all|any|your|the|system|original)\b[^.!?\n]{0,20}\b(instructions?|prompts?|directions)|(previous|prior|above|earlier|preceding|your|system|original)\b[^.!?\n]{0,20}\b(rules|guidelines|constraints|guardrails))\b`)},
+	{"role_override", regexp.MustCompile(`\b(you are now (an? )?([a-z]+ ){0,2}(ai|assistant|bot|model|chatbot|gpt|dan)\b|from now on,? you (are|will)|you are no longer|you're no longer|pretend (to be|you are)|roleplay as|act as an? (unrestricted|unfiltered|different|new)\b)`)},
+	{"prompt_extraction", regexp.MustCompile(`\b(reveal|show|print|repeat|output|tell me|what (is|are))\b[^.!?\n]{0,30}\b(system prompt|(your|the) (instructions|prompt|system message)|hidden (instructions|prompt)|initial prompt)`)},
+	{"new_instructions", regexp.MustCompile(`\b(new|updated|real|actual) system (instructions|prompt|message)\b`)},
+	// The same override in the other supported languages
+	{"ignore_instructions_translated", regexp.MustCompile(`(ignoriere|vergiss)[^.!?\n]{0,30}(anweisungen|regeln)|(ignora|olvida)[^.!?\n]{0,30}instrucciones|(ignore|oublie)[^.!?\n]{0,30}(les|tes|vos) (instructions|consignes)|(ignora|dimentica)[^.!?\n]{0,30}istruzioni|(ignore|esqueça)[^.!?\n]{0,30}instruções|(игнорируй|забудь)[^.!?\n]{0,30}(инструкции|правила)`)},
+	{"role_marker", regexp.MustCompile(`(?m)^\s*(system|assistant|developer)\s*:|<\|[a-z_]*\|>|\[/?inst\]|<</?sys>>`)},
+	{"mode_switch", regexp.MustCompile(`\b(developer mode|jailbreak|dan mode|do anything now|god mode|unfiltered mode)\b`)},
+	{"delimiter_escape", regexp.MustCompile(`<\s*/?\s*user_input\s*>`)},
+}
+
+// stripFormat removes control, zero-width and direction override
+// characters, keeping newlines and tabs
+func stripFormat(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// Sanitize removes control, zero-width and direction override characters
+// and model role tokens, collapses runs of blank lines and limits the length
+func Sanitize(text string) string {
+	clean := roleTokens.ReplaceAllString(stripFormat(text), "")
+	clean = blankLines.ReplaceAllString(clean, "\n\n")
+	clean = strings.TrimSpace(clean)
+	if runes := []rune(clean); len(runes) > MaxInputLength {
+		clean = string(runes[:MaxInputLength])
+	}
+	return clean
+}
+
+// Quote sanitizes text and places it between the user input delimiters.
+// Delimiter tags in the text are removed so it cannot close the block early.
+func Quote(text string) string {
+	clean := delimiterTags.ReplaceAllString(Sanitize(text), "")
+	if !strings.Contains(clean, "\n") {
+		return OpenTag + clean + CloseTag
+	}
+	return OpenTag + "\n" + clean + "\n" + CloseTag
+}
+
+// normalize prepares text for matching
+func normalize(text string) string {
+	return spaces.ReplaceAllString(strings.ToLower(stripFormat(text)), " ")
+}
+
+// Detect returns the IDs of the instruction-override patterns the text
+// matches, empty for ordinary user text
+func Detect(text string) []string {
+	normalized := normalize(text)
+	var ids []string
+	for _, pattern := range overridePatterns {
+		if pattern.re.MatchString(normalized) {
+			ids = append(ids, pattern.id)
+		}
+	}
+	return ids
+}
+
+// Leaks reports whether the output repeats a line of the prompt, which the
+// model only does when it was talked into revealing its instructions. Quoted
+// user input in the prompt is not part of the instructions and is skipped.
+func Leaks(output, prompt string) bool {
+	normalizedOutput := normalize(output)
+	quoted := false
+	for _, line := range strings.Split(prompt, "\n") {
+		switch {
+		case quoted:
+			quoted = !strings.Contains(line, CloseTag)
+			continue
+		case strings.Contains(line, OpenTag):
+			quoted = !strings.Contains(line, CloseTag)
+			continue
+		}
+		line = strings.TrimSpace(normalize(line))
+		if len([]rune(line)) >= minLeakLength && strings.Contains(normalizedOutput, line) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckContent returns why a text field of generated content does not belong
+// in a fitness plan, empty when it is fine
+func CheckContent(text string) string {
+	switch {
+	case links.MatchString(text):
+		return "contains a link"
+	case markup.MatchString(text):
+		return "contains markup or code"
+	case len(Detect(text)) > 0:
+		return "contains instructions instead of fitness content"
+	}
+	return ""
+}
@@ -0,0 +1,307 @@
+// Package prompts loads the versioned text/template files used to build AI prompts.
+//
+// Templates live in templates/<name>/<version>.tmpl and define their parts
+// (system, user, ...) as named templates. manifest.json assigns each version
+// of a prompt a weight for A/B selection. A version can be translated in
+// templates/<name>/<version>.<language>.tmpl. Files in the override directory
+// replace or add to the embedded ones.
+package prompts
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"rest-api/internal/promptguard"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+const manifestFile = "manifest.json"
+
+// Prompt is one version of a prompt template
+type Prompt struct {
+	Name    string
+	Version string
+	// Language is set on translations of a version
+	Language string
+	tmpl     *template.Template
+}
+
+// ID identifies the prompt version, e.g. "plan/v1" or "chat/v1.de" for a
+// translation. It is stored with generated content.
+func (p *Prompt) ID() string {
+	if p.Language != "" {
+		return p.Name + "/" + p.Version + "." + p.Language
+	}
+	return p.Name + "/" + p.Version
+}
+
+// Execute renders a part of the prompt, such as "system" or "user"
+func (p *Prompt) Execute(part string, data any) (string, error) {
+	var sb strings.Builder
+	if err := p.tmpl.ExecuteTemplate(&sb, part, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s part %s: %w", p.ID(), part, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+type weightedVersion struct {
+	prompt *Prompt
+	weight int
+}
+
+// set is an immutable snapshot of loaded prompts
+type set struct {
+	versions map[string]map[string]*Prompt
+	weighted map[string][]weightedVersion
+	// translations are keyed by name, version and language
+	translations map[string]map[string]map[string]*Prompt
+}
+
+// Store holds the loaded prompts. It is safe for concurrent use.
+type Store struct {
+	overrideDir string
+
+	mu      sync.RWMutex
+	current *set
+}
+
+// Load parses the embedded templates and the templates in overrideDir.
+// A missing override directory is not an error.
+func Load(overrideDir string) (*Store, error) {
+	s := &Store{overrideDir: overrideDir}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+var defaultStore = sync.OnceValue(func() *Store {
+	s, err := Load("")
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded prompt templates: %v", err))
+	}
+	return s
+})
+
+// Default returns the store of the embedded templates only
+func Default() *Store {
+	return defaultStore()
+}
+
+// Reload re-reads all templates. On error the previously loaded prompts stay active.
+func (s *Store) Reload() error {
+	templatesFS, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return err
+	}
+
+	sources := []fs.FS{templatesFS}
+	if s.overrideDir != "" {
+		info, err := os.Stat(s.overrideDir)
+		switch {
+		case err == nil && info.IsDir():
+			sources = append(sources, os.DirFS(s.overrideDir))
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("failed to read prompt directory %s: %w", s.overrideDir, err)
+		}
+	}
+
+	loaded, err := load(sources)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.current = loaded
+	s.mu.Unlock()
+	return nil
+}
+
+func load(sources []fs.FS) (*set, error) {
+	files := make(map[string]map[string]string)
+	weights := make(map[string]map[string]int)
+
+	// Later sources override earlier ones, per template file and per manifest entry
+	for _, source := range sources {
+		matches, err := fs.Glob(source, "*/*.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			data, err := fs.ReadFile(source, match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read prompt %s: %w", match, err)
+			}
+			name, file := path.Split(match)
+			name = strings.TrimSuffix(name, "/")
+			if files[name] == nil {
+				files[name] = make(map[string]string)
+			}
+			files[name][strings.TrimSuffix(file, ".tmpl")] = string(data)
+		}
+
+		data, err := fs.ReadFile(source, manifestFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt manifest: %w", err)
+		}
+		var manifest map[string]map[string]int
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse prompt manifest: %w", err)
+		}
+		for name, versions := range manifest {
+			weights[name] = versions
+		}
+	}
+
+	loaded := &set{
+		versions:     make(map[string]map[string]*Prompt),
+		weighted:     make(map[string][]weightedVersion),
+		translations: make(map[string]map[string]map[string]*Prompt),
+	}
+
+	for name, versions := range files {
+		loaded.versions[name] = make(map[string]*Prompt)
+		for file, text := range versions {
+			tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse prompt %s/%s: %w", name, file, err)
+			}
+			version, language, translated := strings.Cut(file, ".")
+			prompt := &Prompt{Name: name, Version: version, Language: language, tmpl: tmpl}
+			if !translated {
+				loaded.versions[name][version] = prompt
+				continue
+			}
+			if loaded.translations[name] == nil {
+				loaded.translations[name] = make(map[string]map[string]*Prompt)
+			}
+			if loaded.translations[name][version] == nil {
+				loaded.translations[name][version] = make(map[string]*Prompt)
+			}
+			loaded.translations[name][version][language] = prompt
+		}
+	}
+
+	for name, versions := range loaded.translations {
+		for version := range versions {
+			if _, ok := loaded.versions[name][version]; !ok {
+				return nil, fmt.Errorf("prompt %s/%s is translated but has no template", name, version)
+			}
+		}
+	}
+
+	for name, versions := range weights {
+		total := 0
+		for version, weight := range versions {
+			if weight < 0 {
+				return nil, fmt.Errorf("prompt %s/%s: weight must not be negative", name, version)
+			}
+			prompt, ok := loaded.versions[name][version]
+			if !ok {
+				return nil, fmt.Errorf("prompt %s/%s is in the manifest but has no template", name, version)
+			}
+			if weight > 0 {
+				loaded.weighted[name] = append(loaded.weighted[name], weightedVersion{prompt: prompt, weight: weight})
+				total += weight
+			}
+		}
+		if total == 0 {
+			return nil, fmt.Errorf("prompt %s has no version with a positive weight", name)
+		}
+		// Map order is random, selection must not be
+		sort.Slice(loaded.weighted[name], func(i, j int) bool {
+			return loaded.weighted[name][i].prompt.Version < loaded.weighted[name][j].prompt.Version
+		})
+	}
+
+	for name := range loaded.versions {
+		if _, ok := loaded.weighted[name]; !ok {
+			return nil, fmt.Errorf("prompt %s is missing from the manifest", name)
+		}
+	}
+
+	return loaded, nil
+}
+
+var funcs = template.FuncMap{
+	"join": strings.Join,
+	"inc":  func(i int) int { return i + 1 },
+	// quote places user text between delimiters the prompts declare as data
+	"quote": promptguard.Quote,
+}
+
+// Select picks a version of the named prompt by weight. A non-empty key (such
+// as the user ID) always gets the same version while the weights are unchanged,
+// so a user stays in one arm of an A/B test.
+func (s *Store) Select(name, key string) (*Prompt, error) {
+	s.mu.RLock()
+	versions := s.current.weighted[name]
+	s.mu.RUnlock()
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("unknown prompt %s", name)
+	}
+
+	total := 0
+	for _, v := range versions {
+		total += v.weight
+	}
+
+	var pick int
+	if key != "" {
+		h := fnv.New32a()
+		h.Write([]byte(name + ":" + key))
+		pick = int(h.Sum32() % uint32(total))
+	} else {
+		pick = rand.N(total)
+	}
+
+	for _, v := range versions {
+		if pick < v.weight {
+			return v.prompt, nil
+		}
+		pick -= v.weight
+	}
+	return versions[len(versions)-1].prompt, nil
+}
+
+// Translate returns the translation of the prompt version into language, or
+// the prompt itself when there is none
+func (s *Store) Translate(prompt *Prompt, language string) *Prompt {
+	s.mu.RLock()
+	translation, ok := s.current.translations[prompt.Name][prompt.Version][language]
+	s.mu.RUnlock()
+
+	if !ok {
+		return prompt
+	}
+	return translation
+}
+
+// Get returns a specific version of the named prompt
+func (s *Store) Get(name, version string) (*Prompt, error) {
+	s.mu.RLock()
+	prompt, ok := s.current.versions[name][version]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt %s/%s", name, version)
+	}
+	return prompt, nil
+}
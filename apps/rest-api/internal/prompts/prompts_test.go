@@ -0,0 +1,192 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	store, err := Load("")
+	if err != nil {
+		t.Fatalf("Expected embedded templates to load, got %v", err)
+	}
+
+	for _, name := range []string{"plan", "regenerate", "chat", "motivation"} {
+		prompt, err := store.Select(name, "1")
+		if err != nil {
+			t.Errorf("Expected prompt %s, got %v", name, err)
+			continue
+		}
+		if prompt.ID() != name+"/v1" {
+			t.Errorf("Expected %s/v1, got %s", name, prompt.ID())
+		}
+	}
+}
+
+func TestLoad_MissingOverrideDir(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Expected a missing override directory to be ignored, got %v", err)
+	}
+}
+
+func TestLoad_OverrideAndABSelection(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "chat/v1.tmpl", `{{define "system"}}overridden v1{{end}}`)
+	writeFile(t, dir, "chat/v2.tmpl", `{{define "system"}}new v2{{end}}`)
+	writeFile(t, dir, "manifest.json", `{"chat": {"v1": 1, "v2": 3}}`)
+
+	store, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Expected override to load, got %v", err)
+	}
+
+	v1, _ := store.Get("chat", "v1")
+	if text, _ := v1.Execute("system", nil); text != "overridden v1" {
+		t.Errorf("Expected the override to replace v1, got '%s'", text)
+	}
+
+	// Embedded prompts missing from the override keep their weights
+	if _, err := store.Select("plan", "1"); err != nil {
+		t.Errorf("Expected embedded plan prompt to stay available, got %v", err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		prompt, err := store.Select("chat", strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[prompt.Version]++
+	}
+	if counts["v2"] < 1300 || counts["v2"] > 1700 {
+		t.Errorf("Expected about 75%% of users on v2, got %v", counts)
+	}
+
+	for i := 0; i < 10; i++ {
+		first, _ := store.Select("chat", "42")
+		second, _ := store.Select("chat", "42")
+		if first != second {
+			t.Fatal("Expected the same user to always get the same version")
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			name:     "manifest without template",
+			files:    map[string]string{"manifest.json": `{"chat": {"v9": 1}}`},
+			expected: "has no template",
+		},
+		{
+			name:     "template without manifest entry",
+			files:    map[string]string{"coach/v1.tmpl": `{{define "system"}}hi{{end}}`},
+			expected: "missing from the manifest",
+		},
+		{
+			name:     "no positive weight",
+			files:    map[string]string{"manifest.json": `{"chat": {"v1": 0}}`},
+			expected: "positive weight",
+		},
+		{
+			name:     "translation without template",
+			files:    map[string]string{"chat/v9.de.tmpl": `{{define "system"}}hallo{{end}}`},
+			expected: "is translated but has no template",
+		},
+		{
+			name:     "template syntax error",
+			files:    map[string]string{"chat/v1.tmpl": `{{define "system"}}{{.Broken{{end}}`},
+			expected: "failed to parse prompt chat/v1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				writeFile(t, dir, name, content)
+			}
+
+			_, err := Load(dir)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing '%s', got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "chat/v1.tmpl", `{{define "system"}}hello{{end}}`)
+	writeFile(t, dir, "chat/v1.de.tmpl", `{{define "system"}}hallo{{end}}`)
+
+	store, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, _ := store.Select("chat", "1")
+	translated := store.Translate(prompt, "de")
+	if text, _ := translated.Execute("system", nil); text != "hallo" || translated.ID() != "chat/v1.de" {
+		t.Errorf("Expected the German translation, got %s '%s'", translated.ID(), text)
+	}
+	if store.Translate(prompt, "ja") != prompt {
+		t.Error("Expected the prompt itself without a translation")
+	}
+}
+
+func TestReload_KeepsPreviousOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "chat/v1.tmpl", `{{define "system"}}first{{end}}`)
+
+	store, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "chat/v1.tmpl", `{{define "system"}}{{end`)
+	if err := store.Reload(); err == nil {
+		t.Fatal("Expected reload of a broken template to fail")
+	}
+
+	prompt, _ := store.Get("chat", "v1")
+	if text, _ := prompt.Execute("system", nil); text != "first" {
+		t.Errorf("Expected the previous template to stay active, got '%s'", text)
+	}
+
+	writeFile(t, dir, "chat/v1.tmpl", `{{define "system"}}second{{end}}`)
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	prompt, _ = store.Get("chat", "v1")
+	if text, _ := prompt.Execute("system", nil); text != "second" {
+		t.Errorf("Expected the reloaded template, got '%s'", text)
+	}
+}
+
+func TestSelect_Unknown(t *testing.T) {
+	if _, err := Default().Select("unknown", ""); err == nil {
+		t.Error("Expected an error for an unknown prompt")
+	}
+	if _, err := Default().Get("chat", "v99"); err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+}
@@ -15,11 +15,21 @@ import (
 
 type MongoDBRepository struct {
 	chatCollection       *mongo.Collection
+	summaryCollection    *mongo.Collection
+	threadCollection     *mongo.Collection
+	actionCollection     *mongo.Collection
 	workoutCollection    *mongo.Collection
 	shortPlanCollection  *mongo.Collection
+	mealPlanCollection   *mongo.Collection
 	completionCollection *mongo.Collection
 	progressCollection   *mongo.Collection
 	mediaCollection      *mongo.Collection
+	aiCacheCollection    *mongo.Collection
+	aiUsageCollection    *mongo.Collection
+	safetyCollection     *mongo.Collection
+	planJobCollection    *mongo.Collection
+	reportCollection     *mongo.Collection
+	versionCollection    *mongo.Collection
 }
 
 func NewMongoDBRepository(uri, dbName string) (MongoDBRep, error) {
@@ -39,29 +49,105 @@ func NewMongoDBRepository(uri, dbName string) (MongoDBRep, error) {
 	fmt.Println("MongoDB connection successful")
 
 	db := client.Database(dbName)
-	return &MongoDBRepository{
+	repo := &MongoDBRepository{
 		chatCollection:       db.Collection("chat_messages"),
+		summaryCollection:    db.Collection("chat_summaries"),
+		threadCollection:     db.Collection("chat_threads"),
+		actionCollection:     db.Collection("chat_actions"),
 		workoutCollection:    db.Collection("workout_plans"),
 		shortPlanCollection:  db.Collection("short_plans"),
+		mealPlanCollection:   db.Collection("meal_plans"),
 		completionCollection: db.Collection("workout_completions"),
 		progressCollection:   db.Collection("user_progress"),
 		mediaCollection:      db.Collection("exercise_media"),
-	}, nil
+		aiCacheCollection:    db.Collection("ai_cache"),
+		aiUsageCollection:    db.Collection("ai_usage"),
+		safetyCollection:     db.Collection("safety_events"),
+		planJobCollection:    db.Collection("plan_jobs"),
+		reportCollection:     db.Collection("weekly_reports"),
+		versionCollection:    db.Collection("workout_plan_versions"),
+	}
+
+	if err := repo.ensureChatIndexes(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create chat indexes: %w", err)
+	}
+	if _, err := repo.aiUsageCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create AI usage indexes: %w", err)
+	}
+	if _, err := repo.safetyCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: -1}},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create safety event indexes: %w", err)
+	}
+	if err := repo.ensurePlanJobIndexes(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create plan job indexes: %w", err)
+	}
+	if _, err := repo.reportCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "week_start", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create weekly report indexes: %w", err)
+	}
+	if _, err := repo.completionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "completed_at", Value: 1}},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create workout completion indexes: %w", err)
+	}
+	if _, err := repo.versionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create plan version indexes: %w", err)
+	}
+
+	return repo, nil
+}
+
+// EnsureAICacheIndexes makes cache keys unique and lets MongoDB delete expired entries
+func (m *MongoDBRepository) EnsureAICacheIndexes(ctx context.Context) error {
+	_, err := m.aiCacheCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
 }
 
 func (m *MongoDBRepository) SaveChatMessage(ctx context.Context, msg *models.ChatMessage) error {
-	_, err := m.chatCollection.InsertOne(ctx, bson.M{
-		"user_id":    msg.UserID,
-		"message":    msg.Message,
-		"response":   msg.Response,
-		"is_user":    msg.IsUser,
-		"created_at": time.Now(),
+	now := time.Now()
+	result, err := m.chatCollection.InsertOne(ctx, bson.M{
+		"user_id":        msg.UserID,
+		"thread_id":      msg.ThreadID,
+		"message":        msg.Message,
+		"response":       msg.Response,
+		"is_user":        msg.IsUser,
+		"prompt_version": msg.PromptVersion,
+		"created_at":     now,
 	})
+	if err != nil {
+		return err
+	}
+	msg.ID = result.InsertedID.(primitive.ObjectID)
+	msg.CreatedAt = now
+
+	// Threads are listed by their latest activity
+	_, err = m.threadCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": msg.ThreadID, "user_id": msg.UserID},
+		bson.M{"$set": bson.M{"last_message_at": now}},
+	)
 	return err
 }
 
-func (m *MongoDBRepository) GetChatHistory(ctx context.Context, userID int) ([]models.ChatMessage, error) {
-	filter := bson.M{"user_id": userID}
+func (m *MongoDBRepository) GetChatHistory(ctx context.Context, userID int, threadID primitive.ObjectID) ([]models.ChatMessage, error) {
+	filter := bson.M{"user_id": userID, "thread_id": threadID}
 	cursor, err := m.chatCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
 	if err != nil {
 		return nil, err
@@ -74,6 +160,361 @@ func (m *MongoDBRepository) GetChatHistory(ctx context.Context, userID int) ([]m
 	return messages, nil
 }
 
+func (m *MongoDBRepository) GetChatMessage(ctx context.Context, userID int, messageID primitive.ObjectID) (*models.ChatMessage, error) {
+	var message models.ChatMessage
+	err := m.chatCollection.FindOne(ctx, bson.M{"_id": messageID, "user_id": userID}).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (m *MongoDBRepository) UpdateChatMessage(ctx context.Context, message *models.ChatMessage) error {
+	result, err := m.chatCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": message.ID, "user_id": message.UserID},
+		bson.M{"$set": bson.M{
+			"response":       message.Response,
+			"prompt_version": message.PromptVersion,
+			"feedback":       message.Feedback,
+			"variants":       message.Variants,
+			"regenerated_at": message.RegeneratedAt,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (m *MongoDBRepository) GetRatedChatMessages(ctx context.Context, rating string, since time.Time, limit int) ([]models.ChatMessage, error) {
+	rated := bson.M{"$in": []string{models.ChatRatingUp, models.ChatRatingDown}}
+	if rating != "" {
+		rated = bson.M{"$eq": rating}
+	}
+	filter := bson.M{
+		"$or": []bson.M{
+			{"feedback.rating": rated},
+			{"variants.feedback.rating": rated},
+		},
+		"created_at": bson.M{"$gte": since},
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(int64(limit))
+	cursor, err := m.chatCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []models.ChatMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (m *MongoDBRepository) GetChatSummary(ctx context.Context, userID int, threadID primitive.ObjectID) (*models.ChatSummary, error) {
+	var summary models.ChatSummary
+	err := m.summaryCollection.FindOne(ctx, bson.M{"user_id": userID, "thread_id": threadID}).Decode(&summary)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (m *MongoDBRepository) SaveChatSummary(ctx context.Context, summary *models.ChatSummary) error {
+	_, err := m.summaryCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": summary.UserID, "thread_id": summary.ThreadID},
+		bson.M{"$set": summary},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// ensureChatIndexes allows a single default thread per user
+func (m *MongoDBRepository) ensureChatIndexes(ctx context.Context) error {
+	if _, err := m.chatCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "thread_id", Value: 1}, {Key: "created_at", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	if _, err := m.summaryCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "thread_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+	_, err := m.threadCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_message_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"is_default": true}),
+		},
+	})
+	return err
+}
+
+func (m *MongoDBRepository) EnsureDefaultChatThread(ctx context.Context, userID int) (*models.ChatThread, error) {
+	now := time.Now()
+	filter := bson.M{"user_id": userID, "is_default": true}
+	_, err := m.threadCollection.UpdateOne(
+		ctx,
+		filter,
+		bson.M{"$setOnInsert": bson.M{
+			"title":           models.DefaultChatThreadTitle,
+			"archived":        false,
+			"created_at":      now,
+			"updated_at":      now,
+			"last_message_at": now,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	// A concurrent request created the thread first
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	var thread models.ChatThread
+	if err := m.threadCollection.FindOne(ctx, filter).Decode(&thread); err != nil {
+		return nil, err
+	}
+
+	if !thread.Migrated {
+		if err := m.migrateChatThread(ctx, &thread); err != nil {
+			return nil, err
+		}
+	}
+	return &thread, nil
+}
+
+// migrateChatThread moves the messages and the summary from before threads
+// existed into the default thread, once per user
+func (m *MongoDBRepository) migrateChatThread(ctx context.Context, thread *models.ChatThread) error {
+	userID := thread.UserID
+	if _, err := m.chatCollection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "thread_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"thread_id": thread.ID}},
+	); err != nil {
+		return fmt.Errorf("failed to migrate chat messages: %w", err)
+	}
+	if _, err := m.summaryCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userID, "thread_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"thread_id": thread.ID}},
+	); err != nil {
+		return fmt.Errorf("failed to migrate chat summary: %w", err)
+	}
+
+	if _, err := m.threadCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": thread.ID},
+		bson.M{"$set": bson.M{"migrated": true}},
+	); err != nil {
+		return fmt.Errorf("failed to mark chat thread as migrated: %w", err)
+	}
+	thread.Migrated = true
+	return nil
+}
+
+func (m *MongoDBRepository) CreateChatThread(ctx context.Context, thread *models.ChatThread) error {
+	result, err := m.threadCollection.InsertOne(ctx, thread)
+	if err != nil {
+		return err
+	}
+	thread.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (m *MongoDBRepository) GetChatThreads(ctx context.Context, userID int, includeArchived bool) ([]models.ChatThread, error) {
+	filter := bson.M{"user_id": userID}
+	if !includeArchived {
+		filter["archived"] = false
+	}
+	cursor, err := m.threadCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"last_message_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+
+	threads := []models.ChatThread{}
+	if err := cursor.All(ctx, &threads); err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+func (m *MongoDBRepository) GetChatThread(ctx context.Context, userID int, threadID primitive.ObjectID) (*models.ChatThread, error) {
+	var thread models.ChatThread
+	err := m.threadCollection.FindOne(ctx, bson.M{"_id": threadID, "user_id": userID}).Decode(&thread)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+func (m *MongoDBRepository) UpdateChatThread(ctx context.Context, thread *models.ChatThread) error {
+	result, err := m.threadCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": thread.ID, "user_id": thread.UserID},
+		bson.M{"$set": bson.M{
+			"title":      thread.Title,
+			"archived":   thread.Archived,
+			"updated_at": thread.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteChatThread removes the thread with its messages and summary
+func (m *MongoDBRepository) DeleteChatThread(ctx context.Context, userID int, threadID primitive.ObjectID) error {
+	filter := bson.M{"user_id": userID, "thread_id": threadID}
+	if _, err := m.chatCollection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	if _, err := m.summaryCollection.DeleteOne(ctx, filter); err != nil {
+		return err
+	}
+
+	result, err := m.threadCollection.DeleteOne(ctx, bson.M{"_id": threadID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (m *MongoDBRepository) SaveChatAction(ctx context.Context, action *models.ChatAction) error {
+	result, err := m.actionCollection.InsertOne(ctx, action)
+	if err != nil {
+		return err
+	}
+	action.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (m *MongoDBRepository) GetChatAction(ctx context.Context, userID int, actionID primitive.ObjectID) (*models.ChatAction, error) {
+	var action models.ChatAction
+	err := m.actionCollection.FindOne(ctx, bson.M{"_id": actionID, "user_id": userID}).Decode(&action)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+func (m *MongoDBRepository) UpdateChatActionStatus(ctx context.Context, userID int, actionID primitive.ObjectID, from, to string) (bool, error) {
+	update := bson.M{"$set": bson.M{"status": to, "resolved_at": time.Now()}}
+	// An action reopened after a failed confirmation is unresolved again
+	if to == models.ChatActionPending {
+		update = bson.M{"$set": bson.M{"status": to}, "$unset": bson.M{"resolved_at": ""}}
+	}
+	result, err := m.actionCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": actionID, "user_id": userID, "status": from},
+		update,
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// planJobRetention is how long finished plan jobs can still be polled
+const planJobRetention = 7 * 24 * time.Hour
+
+// ensurePlanJobIndexes allows one active job per user and lets MongoDB delete
+// old finished jobs
+func (m *MongoDBRepository) ensurePlanJobIndexes(ctx context.Context) error {
+	_, err := m.planJobCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "finished_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(planJobRetention.Seconds())),
+		},
+	})
+	return err
+}
+
+func (m *MongoDBRepository) CreatePlanJob(ctx context.Context, job *models.PlanJob) error {
+	result, err := m.planJobCollection.InsertOne(ctx, job)
+	if err != nil {
+		return err
+	}
+	job.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (m *MongoDBRepository) GetPlanJob(ctx context.Context, userID int, jobID primitive.ObjectID) (*models.PlanJob, error) {
+	var job models.PlanJob
+	err := m.planJobCollection.FindOne(ctx, bson.M{"_id": jobID, "user_id": userID}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (m *MongoDBRepository) GetActivePlanJob(ctx context.Context, userID int) (*models.PlanJob, error) {
+	var job models.PlanJob
+	err := m.planJobCollection.FindOne(ctx, bson.M{"user_id": userID, "active": true}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (m *MongoDBRepository) UpdatePlanJob(ctx context.Context, job *models.PlanJob) error {
+	result, err := m.planJobCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": job.ID, "user_id": job.UserID},
+		bson.M{"$set": job},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
 func (m *MongoDBRepository) SaveWorkoutPlan(ctx context.Context, plan *models.WorkoutPlan) error {
 	_, err := m.workoutCollection.UpdateOne(
 		ctx,
@@ -119,6 +560,88 @@ func (m *MongoDBRepository) GetShortPlan(ctx context.Context, userID int) (*mode
 	return &plan, err
 }
 
+// versionNumberAttempts bounds the retries when concurrent saves pick the same version number
+const versionNumberAttempts = 3
+
+func (m *MongoDBRepository) SaveWorkoutPlanVersion(ctx context.Context, version *models.WorkoutPlanVersion) error {
+	for attempt := 1; ; attempt++ {
+		var latest models.WorkoutPlanVersion
+		err := m.versionCollection.FindOne(
+			ctx,
+			bson.M{"user_id": version.UserID},
+			options.FindOne().SetSort(bson.M{"version": -1}).SetProjection(bson.M{"version": 1}),
+		).Decode(&latest)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		version.Version = latest.Version + 1
+		result, err := m.versionCollection.InsertOne(ctx, version)
+		if mongo.IsDuplicateKeyError(err) && attempt < versionNumberAttempts {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		version.ID = result.InsertedID.(primitive.ObjectID)
+		return nil
+	}
+}
+
+func (m *MongoDBRepository) GetWorkoutPlanVersions(ctx context.Context, userID int) ([]models.WorkoutPlanVersion, error) {
+	cursor, err := m.versionCollection.Find(
+		ctx,
+		bson.M{"user_id": userID},
+		options.Find().
+			SetSort(bson.M{"version": -1}).
+			SetProjection(bson.M{"plan": 0, "short_plan": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := []models.WorkoutPlanVersion{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (m *MongoDBRepository) GetWorkoutPlanVersion(ctx context.Context, userID int, versionID primitive.ObjectID) (*models.WorkoutPlanVersion, error) {
+	var version models.WorkoutPlanVersion
+	err := m.versionCollection.FindOne(ctx, bson.M{"_id": versionID, "user_id": userID}).Decode(&version)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (m *MongoDBRepository) SaveMealPlan(ctx context.Context, plan *models.MealPlan) error {
+	_, err := m.mealPlanCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": plan.UserID},
+		bson.M{"$set": plan},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (m *MongoDBRepository) GetMealPlan(ctx context.Context, userID int) (*models.MealPlan, error) {
+	var plan models.MealPlan
+	err := m.mealPlanCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&plan)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
 func (m *MongoDBRepository) updateExpiredWorkouts(ctx context.Context, plan *models.WorkoutPlan) {
 	now := time.Now()
 	updated := false
@@ -424,6 +947,86 @@ func (m *MongoDBRepository) getMaxConsecutiveDays(ctx context.Context, userID in
 	return maxConsecutive
 }
 
+func (m *MongoDBRepository) GetWorkoutCompletions(ctx context.Context, userID int, since, until time.Time) ([]models.WorkoutCompletion, error) {
+	cursor, err := m.completionCollection.Find(
+		ctx,
+		bson.M{"user_id": userID, "completed_at": bson.M{"$gte": since, "$lt": until}},
+		options.Find().SetSort(bson.M{"completed_at": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	completions := []models.WorkoutCompletion{}
+	if err := cursor.All(ctx, &completions); err != nil {
+		return nil, err
+	}
+	return completions, nil
+}
+
+func (m *MongoDBRepository) GetActiveUserIDs(ctx context.Context, since time.Time) ([]int, error) {
+	completed, err := m.completionCollection.Distinct(ctx, "user_id", bson.M{"completed_at": bson.M{"$gte": since}})
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := []int{}
+	for _, value := range completed {
+		switch id := value.(type) {
+		case int32:
+			userIDs = append(userIDs, int(id))
+		case int64:
+			userIDs = append(userIDs, int(id))
+		}
+	}
+	return userIDs, nil
+}
+
+func (m *MongoDBRepository) SaveWeeklyReport(ctx context.Context, report *models.WeeklyReport) error {
+	result, err := m.reportCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": report.UserID, "week_start": report.WeekStart},
+		bson.M{"$set": report},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	if id, ok := result.UpsertedID.(primitive.ObjectID); ok {
+		report.ID = id
+	}
+	return nil
+}
+
+func (m *MongoDBRepository) GetWeeklyReport(ctx context.Context, userID int, weekStart time.Time) (*models.WeeklyReport, error) {
+	var report models.WeeklyReport
+	err := m.reportCollection.FindOne(ctx, bson.M{"user_id": userID, "week_start": weekStart}).Decode(&report)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (m *MongoDBRepository) GetPreviousWeeklyReport(ctx context.Context, userID int, weekStart time.Time) (*models.WeeklyReport, error) {
+	var report models.WeeklyReport
+	err := m.reportCollection.FindOne(
+		ctx,
+		bson.M{"user_id": userID, "week_start": bson.M{"$lt": weekStart}},
+		options.FindOne().SetSort(bson.M{"week_start": -1}),
+	).Decode(&report)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
 // Exercise media operations
 func (m *MongoDBRepository) SaveExerciseMedia(ctx context.Context, media *models.ExerciseMedia) error {
 	media.CreatedAt = time.Now()
@@ -463,3 +1066,131 @@ func (m *MongoDBRepository) DeleteExerciseMedia(ctx context.Context, mediaID str
 	_, err = m.mediaCollection.DeleteOne(ctx, bson.M{"_id": objID})
 	return err
 }
+
+func (m *MongoDBRepository) GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error) {
+	// The TTL monitor runs only once a minute, so expired entries are filtered here too
+	var entry models.AICacheEntry
+	err := m.aiCacheCollection.FindOne(ctx, bson.M{
+		"key":        key,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (m *MongoDBRepository) SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error {
+	_, err := m.aiCacheCollection.UpdateOne(
+		ctx,
+		bson.M{"key": entry.Key},
+		bson.M{"$set": entry},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (m *MongoDBRepository) SaveAIUsage(ctx context.Context, record *models.AIUsageRecord) error {
+	_, err := m.aiUsageCollection.InsertOne(ctx, record)
+	return err
+}
+
+func aiUsageMatch(userID int, since time.Time) bson.M {
+	match := bson.M{"created_at": bson.M{"$gte": since}}
+	if userID != 0 {
+		match["user_id"] = userID
+	}
+	return match
+}
+
+func (m *MongoDBRepository) GetAIUsageTotals(ctx context.Context, userID int, since time.Time) ([]models.AIUsageTotal, error) {
+	cursor, err := m.aiUsageCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: aiUsageMatch(userID, since)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               bson.M{"feature": "$feature", "model": "$model"},
+			"requests":          bson.M{"$sum": 1},
+			"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+			"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+			"total_tokens":      bson.M{"$sum": "$total_tokens"},
+			"cost_usd":          bson.M{"$sum": "$cost_usd"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":               0,
+			"feature":           "$_id.feature",
+			"model":             "$_id.model",
+			"requests":          1,
+			"prompt_tokens":     1,
+			"completion_tokens": 1,
+			"total_tokens":      1,
+			"cost_usd":          1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "feature", Value: 1}, {Key: "model", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	totals := []models.AIUsageTotal{}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+func (m *MongoDBRepository) GetAIUsageByUser(ctx context.Context, since time.Time, limit int) ([]models.AIUsageUserTotal, error) {
+	cursor, err := m.aiUsageCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: aiUsageMatch(0, since)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          "$user_id",
+			"requests":     bson.M{"$sum": 1},
+			"total_tokens": bson.M{"$sum": "$total_tokens"},
+			"cost_usd":     bson.M{"$sum": "$cost_usd"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":          0,
+			"user_id":      "$_id",
+			"requests":     1,
+			"total_tokens": 1,
+			"cost_usd":     1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "total_tokens", Value: -1}, {Key: "user_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.AIUsageUserTotal{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (m *MongoDBRepository) SaveSafetyEvent(ctx context.Context, event *models.SafetyEvent) error {
+	result, err := m.safetyCollection.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+	event.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (m *MongoDBRepository) GetSafetyEvents(ctx context.Context, limit int) ([]models.SafetyEvent, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(int64(limit))
+	cursor, err := m.safetyCollection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []models.SafetyEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
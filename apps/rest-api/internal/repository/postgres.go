@@ -64,11 +64,26 @@ func (r *PostgresRepository) SaveFitnessProfile(ctx context.Context, userID int,
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
+	// The array columns are NOT NULL
+	equipment := profile.Equipment
+	if equipment == nil {
+		equipment = []string{}
+	}
+	restrictions := profile.DietaryRestrictions
+	if restrictions == nil {
+		restrictions = []string{}
+	}
+	allergies := profile.Allergies
+	if allergies == nil {
+		allergies = []string{}
+	}
+
 	// Upsert fitness profile
 	_, err = tx.Exec(ctx,
 		`INSERT INTO fitness_profiles 
-			(user_id, height_cm, weight_kg, age, fitness_goal, timeframe, fitness_level, weekly_time_minutes)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			(user_id, height_cm, weight_kg, age, fitness_goal, timeframe, fitness_level, weekly_time_minutes, equipment, language,
+			sex, dietary_restrictions, allergies)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		ON CONFLICT (user_id) DO UPDATE SET
 			height_cm = EXCLUDED.height_cm,
 			weight_kg = EXCLUDED.weight_kg,
@@ -77,9 +92,15 @@ func (r *PostgresRepository) SaveFitnessProfile(ctx context.Context, userID int,
 			timeframe = EXCLUDED.timeframe,
 			fitness_level = EXCLUDED.fitness_level,
 			weekly_time_minutes = EXCLUDED.weekly_time_minutes,
+			equipment = EXCLUDED.equipment,
+			language = EXCLUDED.language,
+			sex = EXCLUDED.sex,
+			dietary_restrictions = EXCLUDED.dietary_restrictions,
+			allergies = EXCLUDED.allergies,
 			updated_at = NOW()`,
 		userID, profile.Height, profile.Weight, profile.Age,
-		profile.Goal, profile.Timeframe, profile.FitnessLevel, profile.AvailableMinutes)
+		profile.Goal, profile.Timeframe, profile.FitnessLevel, profile.AvailableMinutes, equipment, profile.Language,
+		profile.Sex, restrictions, allergies)
 
 	if err != nil {
 		return fmt.Errorf("error saving fitness profile: %w", err)
@@ -117,13 +138,15 @@ func (r *PostgresRepository) GetFitnessProfile(ctx context.Context, userID int)
 	var profile models.FitnessProfile
 	err := r.pool.QueryRow(ctx,
 		`SELECT height_cm, weight_kg, age, fitness_goal, timeframe, 
-				fitness_level, weekly_time_minutes, updated_at
+				fitness_level, weekly_time_minutes, equipment, language,
+				sex, dietary_restrictions, allergies, updated_at
 		FROM fitness_profiles 
 		WHERE user_id = $1`,
 		userID).Scan(
 		&profile.Height, &profile.Weight, &profile.Age,
 		&profile.Goal, &profile.Timeframe, &profile.FitnessLevel,
-		&profile.AvailableMinutes, &profile.UpdatedAt)
+		&profile.AvailableMinutes, &profile.Equipment, &profile.Language,
+		&profile.Sex, &profile.DietaryRestrictions, &profile.Allergies, &profile.UpdatedAt)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"time"
+
 	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Repository interface {
@@ -30,24 +34,94 @@ type Repository interface {
 type MongoDBRep interface {
 	// Chat operations
 	SaveChatMessage(ctx context.Context, message *models.ChatMessage) error
-	GetChatHistory(ctx context.Context, userID int) ([]models.ChatMessage, error)
+	GetChatHistory(ctx context.Context, userID int, threadID primitive.ObjectID) ([]models.ChatMessage, error)
+	GetChatSummary(ctx context.Context, userID int, threadID primitive.ObjectID) (*models.ChatSummary, error)
+	SaveChatSummary(ctx context.Context, summary *models.ChatSummary) error
+	// GetChatMessage returns nil when the user has no such message. UpdateChatMessage
+	// stores the response, variants and feedback of a message.
+	GetChatMessage(ctx context.Context, userID int, messageID primitive.ObjectID) (*models.ChatMessage, error)
+	UpdateChatMessage(ctx context.Context, message *models.ChatMessage) error
+	// GetRatedChatMessages returns the newest messages with a rated answer or variant,
+	// rating "" matches both ratings
+	GetRatedChatMessages(ctx context.Context, rating string, since time.Time, limit int) ([]models.ChatMessage, error)
+
+	// Chat thread operations. EnsureDefaultChatThread creates the default
+	// thread on first use and moves older messages without a thread into it
+	// once.
+	EnsureDefaultChatThread(ctx context.Context, userID int) (*models.ChatThread, error)
+	CreateChatThread(ctx context.Context, thread *models.ChatThread) error
+	GetChatThreads(ctx context.Context, userID int, includeArchived bool) ([]models.ChatThread, error)
+	GetChatThread(ctx context.Context, userID int, threadID primitive.ObjectID) (*models.ChatThread, error)
+	UpdateChatThread(ctx context.Context, thread *models.ChatThread) error
+	DeleteChatThread(ctx context.Context, userID int, threadID primitive.ObjectID) error
+
+	// Chat action operations. UpdateChatActionStatus only moves an action that
+	// is still in status from and reports whether it did.
+	SaveChatAction(ctx context.Context, action *models.ChatAction) error
+	GetChatAction(ctx context.Context, userID int, actionID primitive.ObjectID) (*models.ChatAction, error)
+	UpdateChatActionStatus(ctx context.Context, userID int, actionID primitive.ObjectID, from, to string) (bool, error)
 
 	// Workout plan operations
 	SaveWorkoutPlan(ctx context.Context, plan *models.WorkoutPlan) error
 	GetWorkoutPlan(ctx context.Context, userID int) (*models.WorkoutPlan, error)
 	GetWorkoutByID(ctx context.Context, userID int, workoutID string) (*models.Workout, error)
 
+	// Meal plan operations, a user has one meal plan
+	SaveMealPlan(ctx context.Context, plan *models.MealPlan) error
+	GetMealPlan(ctx context.Context, userID int) (*models.MealPlan, error)
+
+	// Plan job operations. CreatePlanJob fails with a duplicate key error when
+	// the user already has an active job.
+	CreatePlanJob(ctx context.Context, job *models.PlanJob) error
+	GetPlanJob(ctx context.Context, userID int, jobID primitive.ObjectID) (*models.PlanJob, error)
+	GetActivePlanJob(ctx context.Context, userID int) (*models.PlanJob, error)
+	UpdatePlanJob(ctx context.Context, job *models.PlanJob) error
+
 	// Short plan operations
 	SaveShortPlan(ctx context.Context, plan *models.ShortWorkoutPlan) error
 	GetShortPlan(ctx context.Context, userID int) (*models.ShortWorkoutPlan, error)
 
+	// Plan version operations. SaveWorkoutPlanVersion numbers the version after
+	// the user's latest one. GetWorkoutPlanVersions returns the versions newest
+	// first without their snapshots, GetWorkoutPlanVersion nil when the user
+	// has no such version.
+	SaveWorkoutPlanVersion(ctx context.Context, version *models.WorkoutPlanVersion) error
+	GetWorkoutPlanVersions(ctx context.Context, userID int) ([]models.WorkoutPlanVersion, error)
+	GetWorkoutPlanVersion(ctx context.Context, userID int, versionID primitive.ObjectID) (*models.WorkoutPlanVersion, error)
+
 	// Progress operations
 	CompleteWorkout(ctx context.Context, userID int, workoutID string) error
 	GetUserProgress(ctx context.Context, userID int) (*models.UserProgress, error)
 	GetRating(ctx context.Context) ([]models.UserRating, error)
+	// GetWorkoutCompletions returns the user's completions in [since, until), oldest first
+	GetWorkoutCompletions(ctx context.Context, userID int, since, until time.Time) ([]models.WorkoutCompletion, error)
+	// GetActiveUserIDs returns the users who completed a workout since the given time
+	GetActiveUserIDs(ctx context.Context, since time.Time) ([]int, error)
+
+	// Weekly report operations, a user has one report per week. GetWeeklyReport
+	// returns nil when the week has no report, GetPreviousWeeklyReport the
+	// newest report of an earlier week.
+	SaveWeeklyReport(ctx context.Context, report *models.WeeklyReport) error
+	GetWeeklyReport(ctx context.Context, userID int, weekStart time.Time) (*models.WeeklyReport, error)
+	GetPreviousWeeklyReport(ctx context.Context, userID int, weekStart time.Time) (*models.WeeklyReport, error)
 
 	// Exercise media operations
 	SaveExerciseMedia(ctx context.Context, media *models.ExerciseMedia) error
 	GetExerciseMedia(ctx context.Context, exerciseID string) ([]models.ExerciseMedia, error)
 	DeleteExerciseMedia(ctx context.Context, mediaID string) error
+
+	// AI response cache operations. EnsureAICacheIndexes is only needed when
+	// responses are cached in MongoDB.
+	EnsureAICacheIndexes(ctx context.Context) error
+	GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error)
+	SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error
+
+	// AI usage operations. A userID of 0 aggregates over all users.
+	SaveAIUsage(ctx context.Context, record *models.AIUsageRecord) error
+	GetAIUsageTotals(ctx context.Context, userID int, since time.Time) ([]models.AIUsageTotal, error)
+	GetAIUsageByUser(ctx context.Context, since time.Time, limit int) ([]models.AIUsageUserTotal, error)
+
+	// Safety event operations, newest events first
+	SaveSafetyEvent(ctx context.Context, event *models.SafetyEvent) error
+	GetSafetyEvents(ctx context.Context, limit int) ([]models.SafetyEvent, error)
 }
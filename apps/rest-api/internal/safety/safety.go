@@ -0,0 +1,292 @@
+// Package safety classifies chat messages, profiles and AI output against
+// health-safety rules.
+//
+// Input rules match what the user wrote (symptoms, injuries, eating
+// disorders, medication), output rules match what the model answered. Each
+// rule either refuses with referral text, adds a disclaimer or only flags the
+// exchange for review. The embedded default_rules.json is used unless a rules
+// file replaces it.
+package safety
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+//go:embed default_rules.json
+var defaultRules []byte
+
+// Stages a rule applies to
+const (
+	StageInput  = "input"
+	StageOutput = "output"
+)
+
+// Actions of a rule, from weakest to strongest
+const (
+	// ActionFlag only logs the exchange for review
+	ActionFlag = "flag"
+	// ActionDisclaim adds the rule's message as a disclaimer to the answer
+	ActionDisclaim = "disclaim"
+	// ActionRefuse replaces the answer with the rule's referral message
+	ActionRefuse = "refuse"
+)
+
+var severity = map[string]int{
+	ActionFlag:     1,
+	ActionDisclaim: 2,
+	ActionRefuse:   3,
+}
+
+// Rule matches text by case-insensitive regular expressions
+type Rule struct {
+	ID       string   `json:"id"`
+	Category string   `json:"category"`
+	Stage    string   `json:"stage"`
+	Action   string   `json:"action"`
+	Patterns []string `json:"patterns"`
+	Message  string   `json:"message"`
+
+	compiled []*regexp.Regexp
+}
+
+// Rules is a validated rule set
+type Rules struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParseRules parses and validates a JSON rule set
+func ParseRules(data []byte) (*Rules, error) {
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse safety rules: %w", err)
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// compile validates the rules and compiles their patterns
+func (r *Rules) compile() error {
+	if len(r.Rules) == 0 {
+		return errors.New("safety rules are empty")
+	}
+
+	seen := make(map[string]bool)
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.ID == "" {
+			return fmt.Errorf("safety rule #%d: id is required", i+1)
+		}
+		if seen[rule.ID] {
+			return fmt.Errorf("safety rule %s: duplicate id", rule.ID)
+		}
+		seen[rule.ID] = true
+
+		if rule.Category == "" {
+			return fmt.Errorf("safety rule %s: category is required", rule.ID)
+		}
+		if rule.Stage != StageInput && rule.Stage != StageOutput {
+			return fmt.Errorf("safety rule %s: stage must be input or output", rule.ID)
+		}
+		if severity[rule.Action] == 0 {
+			return fmt.Errorf("safety rule %s: action must be flag, disclaim or refuse", rule.ID)
+		}
+		if rule.Action != ActionFlag && rule.Message == "" {
+			return fmt.Errorf("safety rule %s: message is required for action %s", rule.ID, rule.Action)
+		}
+		if len(rule.Patterns) == 0 {
+			return fmt.Errorf("safety rule %s: at least one pattern is required", rule.ID)
+		}
+
+		rule.compiled = make([]*regexp.Regexp, 0, len(rule.Patterns))
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return fmt.Errorf("safety rule %s: invalid pattern %q: %w", rule.ID, pattern, err)
+			}
+			rule.compiled = append(rule.compiled, re)
+		}
+	}
+	return nil
+}
+
+// Check classifies text against the rules of a stage
+func (r *Rules) Check(stage, text string) Verdict {
+	var verdict Verdict
+	for _, rule := range r.Rules {
+		if rule.Stage != stage || !rule.matches(text) {
+			continue
+		}
+		verdict.Matches = append(verdict.Matches, Match{
+			RuleID:   rule.ID,
+			Category: rule.Category,
+			Action:   rule.Action,
+			Message:  rule.Message,
+		})
+		if severity[rule.Action] > severity[verdict.Action] {
+			verdict.Action = rule.Action
+		}
+	}
+	return verdict
+}
+
+func (r *Rule) matches(text string) bool {
+	for _, re := range r.compiled {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match is a rule that matched
+type Match struct {
+	RuleID   string
+	Category string
+	Action   string
+	Message  string
+}
+
+// Verdict is the outcome of a check. Action is the strongest action of the
+// matched rules, empty when nothing matched.
+type Verdict struct {
+	Action  string
+	Matches []Match
+}
+
+// Flagged reports whether any rule matched
+func (v Verdict) Flagged() bool {
+	return len(v.Matches) > 0
+}
+
+// Merge combines the verdicts of several checks
+func Merge(verdicts ...Verdict) Verdict {
+	var merged Verdict
+	for _, v := range verdicts {
+		merged.Matches = append(merged.Matches, v.Matches...)
+		if severity[v.Action] > severity[merged.Action] {
+			merged.Action = v.Action
+		}
+	}
+	return merged
+}
+
+// RuleIDs lists the matched rules without duplicates
+func (v Verdict) RuleIDs() []string {
+	var ids []string
+	for _, m := range v.Matches {
+		if !slices.Contains(ids, m.RuleID) {
+			ids = append(ids, m.RuleID)
+		}
+	}
+	return ids
+}
+
+// Categories lists the matched categories without duplicates
+func (v Verdict) Categories() []string {
+	var categories []string
+	for _, m := range v.Matches {
+		if !slices.Contains(categories, m.Category) {
+			categories = append(categories, m.Category)
+		}
+	}
+	return categories
+}
+
+// Messages lists the distinct messages of the matched rules with the given action
+func (v Verdict) Messages(action string) []string {
+	var messages []string
+	for _, m := range v.Matches {
+		if m.Action == action && !slices.Contains(messages, m.Message) {
+			messages = append(messages, m.Message)
+		}
+	}
+	return messages
+}
+
+// Guard holds the active rules. It is safe for concurrent use.
+type Guard struct {
+	path string
+
+	mu    sync.RWMutex
+	rules *Rules
+}
+
+// Load reads the rules from path. A missing file (or an empty path) selects
+// the embedded default rules.
+func Load(path string) (*Guard, error) {
+	g := &Guard{path: path}
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+var defaultGuard = sync.OnceValue(func() *Guard {
+	g, err := Load("")
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded safety rules: %v", err))
+	}
+	return g
+})
+
+// Default returns the guard with the embedded rules
+func Default() *Guard {
+	return defaultGuard()
+}
+
+// Reload re-reads the rules file. On error the current rules stay active.
+func (g *Guard) Reload() error {
+	data := defaultRules
+	if g.path != "" {
+		fileData, err := os.ReadFile(g.path)
+		switch {
+		case err == nil:
+			data = fileData
+		case !errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("failed to read safety rules %s: %w", g.path, err)
+		}
+	}
+
+	rules, err := ParseRules(data)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	g.rules = rules
+	g.mu.Unlock()
+	return nil
+}
+
+// RuleCount is the number of active rules
+func (g *Guard) RuleCount() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.rules.Rules)
+}
+
+// CheckInput classifies what the user wrote
+func (g *Guard) CheckInput(text string) Verdict {
+	return g.check(StageInput, text)
+}
+
+// CheckOutput scans an answer of the model for unsafe advice
+func (g *Guard) CheckOutput(text string) Verdict {
+	return g.check(StageOutput, text)
+}
+
+func (g *Guard) check(stage, text string) Verdict {
+	g.mu.RLock()
+	rules := g.rules
+	g.mu.RUnlock()
+	return rules.Check(stage, text)
+}
@@ -0,0 +1,150 @@
+package safety
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultRules_Input(t *testing.T) {
+	guard := Default()
+
+	testCases := []struct {
+		text     string
+		action   string
+		category string
+	}{
+		{"I get chest pain when I run, should I keep going?", ActionRefuse, "cardiac"},
+		{"I passed out during my workout yesterday", ActionRefuse, "cardiac"},
+		{"How can I make myself throw up after eating?", ActionRefuse, "eating_disorder"},
+		{"Is it ok to eat 600 calories to lose weight fast?", ActionRefuse, "eating_disorder"},
+		{"I sprained my ankle last week, what can I train?", ActionDisclaim, "injury"},
+		{"My knee hurts when I squat", ActionDisclaim, "injury"},
+		{"I'm on beta blockers, what heart rate should I aim for?", ActionDisclaim, "medication"},
+		{"I'm pregnant, can I still do deadlifts?", ActionDisclaim, "pregnancy"},
+		{"How do I build a bigger chest?", "", ""},
+		{"I broke my squat record today!", "", ""},
+		{"What should I eat before a run?", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			verdict := guard.CheckInput(tc.text)
+			if verdict.Action != tc.action {
+				t.Fatalf("Expected action '%s', got '%s' (%v)", tc.action, verdict.Action, verdict.RuleIDs())
+			}
+			if tc.category != "" && verdict.Categories()[0] != tc.category {
+				t.Errorf("Expected category %s, got %v", tc.category, verdict.Categories())
+			}
+		})
+	}
+}
+
+func TestDefaultRules_Output(t *testing.T) {
+	guard := Default()
+
+	testCases := []struct {
+		text   string
+		action string
+	}{
+		{"You could double your insulin dose on training days.", ActionRefuse},
+		{"Try eating 800 calories a day for a month.", ActionRefuse},
+		{"Just push through the pain, it gets easier.", ActionRefuse},
+		{"It sounds like a sprain, rest it for a few days.", ActionDisclaim},
+		{"Warm up for 10 minutes, then do 3 sets of 10 squats.", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			if verdict := guard.CheckOutput(tc.text); verdict.Action != tc.action {
+				t.Errorf("Expected action '%s', got '%s' (%v)", tc.action, verdict.Action, verdict.RuleIDs())
+			}
+		})
+	}
+}
+
+func TestVerdict_StrongestActionWins(t *testing.T) {
+	verdict := Default().CheckInput("I injured my back and now I have chest pain")
+
+	if verdict.Action != ActionRefuse {
+		t.Errorf("Expected refuse to win over disclaim, got %s", verdict.Action)
+	}
+	if len(verdict.Matches) != 2 {
+		t.Errorf("Expected both rules to match, got %v", verdict.RuleIDs())
+	}
+	if messages := verdict.Messages(ActionDisclaim); len(messages) != 1 {
+		t.Errorf("Expected the injury disclaimer, got %v", messages)
+	}
+}
+
+func TestLoad_FileOverridesDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "safety_rules.json")
+	rules := `{"rules": [{"id": "creatine", "category": "supplements", "stage": "input", "action": "flag", "patterns": ["creatine"]}]}`
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	guard, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guard.RuleCount() != 1 {
+		t.Errorf("Expected the file to replace the default rules, got %d rules", guard.RuleCount())
+	}
+	if verdict := guard.CheckInput("Is CREATINE safe?"); verdict.Action != ActionFlag {
+		t.Errorf("Expected a case-insensitive flag, got '%s'", verdict.Action)
+	}
+	if verdict := guard.CheckInput("I have chest pain"); verdict.Flagged() {
+		t.Errorf("Expected default rules to be inactive, got %v", verdict.RuleIDs())
+	}
+
+	// A broken file is rejected and the loaded rules stay active
+	if err := os.WriteFile(path, []byte(`{"rules": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Reload(); err == nil {
+		t.Error("Expected empty rules to be rejected")
+	}
+	if guard.RuleCount() != 1 {
+		t.Errorf("Expected the previous rules to stay active, got %d rules", guard.RuleCount())
+	}
+}
+
+func TestLoad_MissingFileUsesDefaults(t *testing.T) {
+	guard, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guard.RuleCount() != Default().RuleCount() {
+		t.Errorf("Expected the default rules, got %d rules", guard.RuleCount())
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rules    string
+		expected string
+	}{
+		{"empty", `{"rules": []}`, "empty"},
+		{"missing id", `{"rules": [{"category": "c", "stage": "input", "action": "flag", "patterns": ["x"]}]}`, "id is required"},
+		{"duplicate id", `{"rules": [
+			{"id": "a", "category": "c", "stage": "input", "action": "flag", "patterns": ["x"]},
+			{"id": "a", "category": "c", "stage": "input", "action": "flag", "patterns": ["y"]}]}`, "duplicate"},
+		{"unknown stage", `{"rules": [{"id": "a", "category": "c", "stage": "both", "action": "flag", "patterns": ["x"]}]}`, "stage"},
+		{"unknown action", `{"rules": [{"id": "a", "category": "c", "stage": "input", "action": "block", "patterns": ["x"]}]}`, "action"},
+		{"refuse without message", `{"rules": [{"id": "a", "category": "c", "stage": "input", "action": "refuse", "patterns": ["x"]}]}`, "message"},
+		{"no patterns", `{"rules": [{"id": "a", "category": "c", "stage": "input", "action": "flag"}]}`, "pattern"},
+		{"invalid pattern", `{"rules": [{"id": "a", "category": "c", "stage": "input", "action": "flag", "patterns": ["(x"]}]}`, "invalid pattern"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseRules([]byte(tc.rules))
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing '%s', got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -7,34 +7,94 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"rest-api/internal/config"
+	"rest-api/internal/i18n"
 	"rest-api/internal/models"
+	"rest-api/internal/progression"
+	"rest-api/internal/promptguard"
+	"rest-api/internal/prompts"
 	"rest-api/internal/repository"
+	"rest-api/internal/safety"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// Retry policies per call site: interactive calls give up early and must
+// finish within the server WriteTimeout, plan generation runs in jobs and
+// may take longer
+var (
+	chatRetryPolicy = RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    4 * time.Second,
+		Budget:      60 * time.Second,
+	}
+	planRetryPolicy = RetryPolicy{
+		MaxAttempts: 6,
+		BaseDelay:   1 * time.Second,
+		MaxDelay:    10 * time.Second,
+		Budget:      4 * time.Minute,
+	}
+	motivationRetryPolicy = RetryPolicy{
+		MaxAttempts: 2,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    1 * time.Second,
+		Budget:      15 * time.Second,
+	}
+	reportRetryPolicy = RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   1 * time.Second,
+		MaxDelay:    4 * time.Second,
+		Budget:      30 * time.Second,
+	}
+)
+
 type AIService struct {
 	BaseService
 	Client *OpenRouterClient
+	// Prompts holds the prompt templates, nil means the embedded defaults
+	Prompts *prompts.Store
+	// Cache holds plan and motivation responses, nil disables caching
+	Cache *ResponseCache
+	Quota AIQuota
+	// Safety holds the health-safety rules, nil means the embedded defaults
+	Safety *safety.Guard
+	// Progression holds the progression models, nil means the embedded defaults
+	Progression *progression.Store
+
+	// summarizing tracks threads whose chat summary is being updated
+	summarizing sync.Map
+	background  sync.WaitGroup
+
+	planJobsOnce sync.Once
+	planQueue    chan *planTask
 }
 
-func NewAIService(repo repository.Repository, mongoRepo repository.MongoDBRep, openrouterKey string) *AIService {
+func NewAIService(repo repository.Repository, mongoRepo repository.MongoDBRep, openrouterKey string, catalog *config.ModelCatalog, promptStore *prompts.Store) *AIService {
 	var client *OpenRouterClient
 
 	if openrouterKey != "" {
-		client = NewOpenRouterClient(openrouterKey)
+		client = NewOpenRouterClient(openrouterKey, catalog)
 	}
 
-	return &AIService{
+	service := &AIService{
 		BaseService: BaseService{Repo: repo, MongoDBRepo: mongoRepo},
 		Client:      client,
+		Prompts:     promptStore,
 	}
+	if client != nil {
+		client.SetUsageFunc(service.recordUsage)
+	}
+	return service
 }
 
-func (s *AIService) GenerateWorkoutPlan(ctx context.Context) (*models.WorkoutPlan, error) {
+// GenerateWorkoutPlan returns the user's current plan or creates one. The
+// generator is models.PlanSourceAI (default) or models.PlanSourceRules.
+func (s *AIService) GenerateWorkoutPlan(ctx context.Context, generator string) (*models.WorkoutPlan, error) {
 	userID, err := s.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, err
@@ -55,6 +115,7 @@ func (s *AIService) GenerateWorkoutPlan(ctx context.Context) (*models.WorkoutPla
 	}
 
 	// Get user profile
+	reportPlanStage(ctx, models.PlanStageProfile)
 	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
 	if err != nil {
 		return nil, NewServiceError(
@@ -64,294 +125,434 @@ func (s *AIService) GenerateWorkoutPlan(ctx context.Context) (*models.WorkoutPla
 		)
 	}
 
-	// Generate new plan
-	if s.Client == nil {
-		return nil, NewServiceError(
-			http.StatusServiceUnavailable,
-			"AI service unavailable",
-			nil,
-		)
+	workoutsPerWeek := workoutsPerWeekFor(profile)
+
+	// Long timeframes start with their first phase, the others are generated later
+	now := time.Now()
+	phases := planPhases(profile.Timeframe, now)
+	span := s.firstPhase(profile.Timeframe, phases, now)
+
+	// Generate new plan, falling back to the rule-based generator when the AI is unavailable
+	var generatedData *generatedPlan
+	source := models.PlanSourceAI
+	if generator == models.PlanSourceRules || s.Client == nil {
+		generatedData = generateRuleBasedPhase(profile, workoutsPerWeek, span)
+		source = models.PlanSourceRules
+	} else {
+		reportPlanStage(ctx, models.PlanStageGenerating)
+		generatedData, err = s.generateAIPlan(ctx, userID, profile, workoutsPerWeek, s.planGuidance(profile, phases, 0, nil))
+		if err != nil {
+			if ctx.Err() != nil || IsQuotaExceeded(err) {
+				return nil, err
+			}
+			fmt.Printf("AI plan generation failed, using rule-based plan: %v\n", err)
+			generatedData = generateRuleBasedPhase(profile, workoutsPerWeek, span)
+			source = models.PlanSourceRules
+		}
+	}
+	if len(phases) > 0 {
+		phases[0].Generated = true
+		phases[0].Source = source
 	}
 
-	// Calculate required workouts
-	workoutsPerWeek := profile.AvailableMinutes / 50
-	if workoutsPerWeek < 2 {
-		workoutsPerWeek = 2
+	disclaimers := s.guardPlan(ctx, userID, profile, generatedData.Workouts)
+
+	// Create full workout plan
+	workoutPlan := &models.WorkoutPlan{
+		UserID:        userID,
+		Title:         generatedData.Title,
+		Workouts:      generatedData.Workouts,
+		Status:        true,
+		Source:        source,
+		PromptVersion: generatedData.PromptVersion,
+		Disclaimers:   disclaimers,
+		Phases:        phases,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
-	if workoutsPerWeek > 6 {
-		workoutsPerWeek = 6
+
+	// Generate full schedule for timeframe
+	reportPlanStage(ctx, models.PlanStageScheduling)
+	language, _ := responseLanguage(ctx, profile)
+	fullSchedule := s.generateFullSchedule(generatedData.Workouts, workoutsPerWeek, span, language.Code, s.phaseProgression(profile, span))
+
+	// Replace workouts with full schedule
+	workoutPlan.Workouts = fullSchedule
+
+	// Save both short and full plans
+	shortPlan := &models.ShortWorkoutPlan{
+		UserID:          userID,
+		Title:           generatedData.Title,
+		BaseWorkouts:    generatedData.Workouts,
+		Timeframe:       profile.Timeframe,
+		WorkoutsPerWeek: workoutsPerWeek,
+		Phase:           span.Number,
+		Status:          true,
+		Source:          source,
+		PromptVersion:   generatedData.PromptVersion,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	reportPlanStage(ctx, models.PlanStageSaving)
+	if err := s.savePlan(ctx, workoutPlan, shortPlan, planChange{
+		reason: models.PlanChangeGenerate,
+		author: source,
+	}); err != nil {
+		fmt.Printf("Failed to save workout plan: %v\n", err)
 	}
 
-	// Prepare system message with JSON schema
-	systemContent := fmt.Sprintf(`You are a fitness expert. Generate a workout plan with EXACTLY %d workouts and respond with ONLY valid JSON.
-
-JSON structure:
-{
-  "title": "Workout Plan Title",
-  "workouts": [
-    {
-      "name": "Workout Name",
-      "description": "Brief description",
-      "status": "planned",
-      "exercises": [
-        {
-          "name": "Exercise Name",
-          "muscle_group": "Target Muscle",
-          "sets": 3,
-          "reps": 12,
-          "rest_sec": 60,
-          "notes": "Form tips",
-          "technique": "How to perform"
-        }
-      ]
-    }
-  ]
+	return workoutPlan, nil
 }
 
-IMPORTANT: Create EXACTLY %d different workouts in the workouts array.`, workoutsPerWeek, workoutsPerWeek)
+// generateAIPlan asks the model for the base workouts of a new plan or plan
+// phase, described by the timeframe guidance
+func (s *AIService) generateAIPlan(ctx context.Context, userID int, profile *models.FitnessProfile, workoutsPerWeek int, guidance string) (*generatedPlan, error) {
+	ctx = withAIFeature(ctx, models.AIFeaturePlan)
 
-	// Prepare user prompt with profile data
-	userPrompt := s.formatWorkoutPrompt(profile)
+	prompt, err := s.selectPrompt(promptPlan, userID)
+	if err != nil {
+		return nil, err
+	}
 
-	// Prepare messages for OpenRouter
-	messages := []OpenRouterMessage{
-		{Role: "system", Content: systemContent},
-		{Role: "user", Content: userPrompt},
+	language, _ := responseLanguage(ctx, profile)
+	messages, err := renderPrompt(prompt, planPromptData{
+		Profile:           profile,
+		WorkoutsPerWeek:   workoutsPerWeek,
+		Rules:             planConstraintsPrompt(workoutsPerWeek),
+		TimeframeGuidance: guidance,
+		Beginner:          profile.FitnessLevel == "beginner",
+		Language:          promptLanguage(language),
+	}, "system", "user")
+	if err != nil {
+		return nil, err
+	}
+
+	// Identical profiles produce identical prompts, so validated plans are shared
+	key := s.cacheKey(AICallPlan, prompt, messages, true)
+	if content, ok := s.Cache.get(ctx, AICallPlan, key); ok {
+		plan, err := parseGeneratedPlan(content, workoutsPerWeek)
+		if err == nil && len(validateWorkoutPlan(plan, workoutsPerWeek)) == 0 {
+			plan.PromptVersion = prompt.ID()
+			return plan, nil
+		}
 	}
 
-	// Call AI with structured response requirement
-	content, err := s.Client.CreateChatCompletion(ctx, messages, true)
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+
+	// Call AI and repair the plan until it passes validation
+	plan, err := s.generateValidatedPlan(ctx, prompt, messages, workoutsPerWeek, language)
 	if err != nil {
-		fmt.Printf("AI REQUEST FAILED during generate plan due to: %s", err)
-		return nil, NewServiceError(
-			http.StatusInternalServerError,
-			"AI request failed",
-			err,
-		)
+		return nil, err
 	}
 
-	// Log raw response for debugging
-	fmt.Printf("Raw AI response: %s\n", content)
+	if content, err := json.Marshal(plan); err == nil {
+		s.Cache.set(ctx, AICallPlan, key, string(content))
+	}
+	return plan, nil
+}
 
-	// Clean response - remove markdown code blocks if present
-	cleanContent := strings.TrimSpace(content)
-	if strings.HasPrefix(cleanContent, "```json") {
-		cleanContent = strings.TrimPrefix(cleanContent, "```json")
-		cleanContent = strings.TrimSuffix(cleanContent, "```")
-		cleanContent = strings.TrimSpace(cleanContent)
+// workoutsPerWeekFor derives the number of weekly workouts from the available time
+func workoutsPerWeekFor(profile *models.FitnessProfile) int {
+	workoutsPerWeek := profile.AvailableMinutes / 50
+	if workoutsPerWeek < 2 {
+		workoutsPerWeek = 2
 	}
-	if strings.HasPrefix(cleanContent, "```") {
-		cleanContent = strings.TrimPrefix(cleanContent, "```")
-		cleanContent = strings.TrimSuffix(cleanContent, "```")
-		cleanContent = strings.TrimSpace(cleanContent)
+	if workoutsPerWeek > 6 {
+		workoutsPerWeek = 6
 	}
+	return workoutsPerWeek
+}
 
-	var generatedData struct {
-		Title    string           `json:"title"`
-		Workouts []models.Workout `json:"workouts"`
+// Chat answers a message in the given thread, an empty threadID selects the
+// default thread. The coach may look up the user's data with tools and
+// propose plan changes, which are returned as pending actions.
+func (s *AIService) Chat(ctx context.Context, threadID, message string) (*models.ChatResponse, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := json.Unmarshal([]byte(cleanContent), &generatedData); err != nil {
+	if s.Client == nil {
 		return nil, NewServiceError(
-			http.StatusInternalServerError,
-			"Failed to parse AI response",
-			fmt.Errorf("JSON parse error: %v, cleaned content: %s", err, cleanContent),
+			http.StatusServiceUnavailable,
+			"AI service unavailable",
+			nil,
 		)
 	}
 
-	// Fill missing fields with default values
-	for i := range generatedData.Workouts {
-		if generatedData.Workouts[i].Status == "" {
-			generatedData.Workouts[i].Status = "planned"
-		}
-		for j := range generatedData.Workouts[i].Exercises {
-			if generatedData.Workouts[i].Exercises[j].RestSec == 0 {
-				generatedData.Workouts[i].Exercises[j].RestSec = 60
-			}
-		}
+	thread, err := s.activeChatThread(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Trim workouts to required count
-	if len(generatedData.Workouts) > workoutsPerWeek {
-		generatedData.Workouts = generatedData.Workouts[:workoutsPerWeek]
+	// Risky messages get the referral text instead of an answer from the model
+	input := s.safetyGuard().CheckInput(message)
+	if input.Action == safety.ActionRefuse {
+		return s.refuseChat(ctx, userID, thread.ID, message, input)
 	}
 
-	// Create full workout plan
-	now := time.Now()
-	workoutPlan := &models.WorkoutPlan{
-		UserID:    userID,
-		Title:     generatedData.Title,
-		Workouts:  generatedData.Workouts,
-		Status:    true,
-		CreatedAt: now,
-		UpdatedAt: now,
+	ctx = withAIFeature(ctx, models.AIFeatureChat)
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
 	}
 
-	// Generate full schedule for timeframe
-	totalWeeks := s.getWeeksFromTimeframe(profile.Timeframe)
-	totalWorkouts := workoutsPerWeek * totalWeeks
-	fullSchedule := s.generateFullSchedule(generatedData.Workouts, workoutsPerWeek, totalWorkouts)
+	request, err := s.buildChatMessages(ctx, userID, thread.ID, message, true, input.Categories(), primitive.NilObjectID)
+	if err != nil {
+		return nil, err
+	}
 
-	// Replace workouts with full schedule
-	workoutPlan.Workouts = fullSchedule
+	// Call AI
+	response, actions, err := s.runChatTools(ctx, userID, thread.ID, request.messages)
+	if err != nil {
+		fmt.Printf("ERROR: AI REQUEST FAILED in Chat: %v\n", err)
+		return nil, newAIRequestError(err)
+	}
+	response = s.enforceLanguage(ctx, request.messages, response, request.language, chatRetryPolicy)
+	guarded := s.guardChatResponse(ctx, userID, thread.ID, message, input, request, response)
 
-	// Save both short and full plans
-	shortPlan := &models.ShortWorkoutPlan{
-		UserID:          userID,
-		Title:           generatedData.Title,
-		BaseWorkouts:    generatedData.Workouts,
-		Timeframe:       profile.Timeframe,
-		WorkoutsPerWeek: workoutsPerWeek,
-		Status:          true,
-		CreatedAt:       now,
-		UpdatedAt:       now,
+	// Save chat message
+	chatMsg := &models.ChatMessage{
+		UserID:        userID,
+		ThreadID:      thread.ID,
+		Message:       message,
+		Response:      guarded.response,
+		IsUser:        true,
+		PromptVersion: request.promptID,
 	}
 
-	if err := s.MongoDBRepo.SaveShortPlan(ctx, shortPlan); err != nil {
-		fmt.Printf("Failed to save short plan: %v\n", err)
+	if err := s.MongoDBRepo.SaveChatMessage(ctx, chatMsg); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save chat message",
+			err,
+		)
 	}
 
-	if err := s.MongoDBRepo.SaveWorkoutPlan(ctx, workoutPlan); err != nil {
-		fmt.Printf("Failed to save workout plan: %v\n", err)
+	if request.summaryDue {
+		s.startChatSummary(ctx, userID, thread.ID)
 	}
 
-	return workoutPlan, nil
+	return &models.ChatResponse{
+		MessageID:      chatMsg.ID,
+		Response:       guarded.response,
+		PendingActions: actions,
+		Safety:         guarded.notice,
+	}, nil
 }
 
-func (s *AIService) Chat(ctx context.Context, message string) (string, error) {
+// ChatStream works like Chat but delivers the answer incrementally through
+// onDelta. The exchange is persisted once the stream completes or is aborted
+// (e.g. the client disconnected), keeping whatever part of the answer arrived.
+// Safety disclaimers and corrections follow the answer as a last delta. The
+// answer is already delivered, so its language is not enforced.
+func (s *AIService) ChatStream(ctx context.Context, threadID, message string, onDelta func(string) error) (*models.ChatResponse, error) {
 	userID, err := s.GetUserIDFromContext(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if s.Client == nil {
-		return "", NewServiceError(
+		return nil, NewServiceError(
 			http.StatusServiceUnavailable,
 			"AI service unavailable",
 			nil,
 		)
 	}
 
-	// Get chat history
-	history, err := s.MongoDBRepo.GetChatHistory(ctx, userID)
+	thread, err := s.activeChatThread(ctx, userID, threadID)
 	if err != nil {
-		return "", NewServiceError(
-			http.StatusInternalServerError,
-			"Failed to get chat history",
-			err,
-		)
+		return nil, err
 	}
 
-	// Get user's fitness profile to check if they're a beginner
-	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
-	isBeginner := false
-	if err == nil && profile != nil && profile.FitnessLevel == "beginner" {
-		isBeginner = true
+	input := s.safetyGuard().CheckInput(message)
+	if input.Action == safety.ActionRefuse {
+		refusal, err := s.refuseChat(ctx, userID, thread.ID, message, input)
+		if err != nil {
+			return nil, err
+		}
+		if err := onDelta(refusal.Response); err != nil {
+			return nil, err
+		}
+		return refusal, nil
 	}
 
-	// Build conversation context with beginner mode if needed
-	systemContent := "You are a helpful fitness assistant. Provide concise and helpful responses about fitness, nutrition, and health."
-	if isBeginner {
-		systemContent += " IMPORTANT: The user is a beginner with limited fitness knowledge. Explain concepts in very simple terms as if explaining to a kid. Avoid technical jargon, use basic language, and include extra safety tips."
+	ctx = withAIFeature(ctx, models.AIFeatureChat)
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
 	}
 
-	messages := []OpenRouterMessage{
-		{
-			Role:    "system",
-			Content: systemContent,
-		},
+	request, err := s.buildChatMessages(ctx, userID, thread.ID, message, false, input.Categories(), primitive.NilObjectID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add history to context
-	start := 0
-	if len(history) > 10 {
-		start = len(history) - 10
+	response, streamErr := s.Client.CreateChatCompletionStream(ctx, request.messages, chatRetryPolicy, onDelta)
+	if streamErr != nil {
+		fmt.Printf("ERROR: AI stream interrupted in ChatStream: %v\n", streamErr)
 	}
 
-	for i := start; i < len(history); i++ {
-		msg := history[i]
-		messages = append(messages, OpenRouterMessage{
-			Role:    "user",
-			Content: msg.Message,
-		})
-		messages = append(messages, OpenRouterMessage{
-			Role:    "assistant",
-			Content: msg.Response,
-		})
+	// Nothing to keep if the model never answered
+	if response == "" {
+		if streamErr == nil {
+			streamErr = errors.New("empty response from AI")
+		}
+		return nil, newAIRequestError(streamErr)
 	}
 
-	// Add current message
-	messages = append(messages, OpenRouterMessage{
-		Role:    "user",
-		Content: message,
-	})
+	// The answer is already on the client, disclaimers and corrections follow it
+	guarded := s.guardChatResponse(ctx, userID, thread.ID, message, input, request, response)
+	if guarded.addition != "" && streamErr == nil {
+		if err := onDelta(guarded.addition); err != nil {
+			streamErr = err
+		}
+	}
 
-	// Call AI
-	response, err := s.Client.CreateChatCompletion(ctx, messages, false)
-	if err != nil {
-		fmt.Printf("ERROR: AI REQUEST FAILED in Chat: %v\n", err)
-		return "", NewServiceError(
+	// The request context is likely cancelled on abort, but the message must still be saved
+	chatMsg := &models.ChatMessage{
+		UserID:        userID,
+		ThreadID:      thread.ID,
+		Message:       message,
+		Response:      guarded.response,
+		IsUser:        true,
+		PromptVersion: request.promptID,
+	}
+
+	if err := s.MongoDBRepo.SaveChatMessage(context.WithoutCancel(ctx), chatMsg); err != nil {
+		return nil, NewServiceError(
 			http.StatusInternalServerError,
-			"AI request failed",
+			"Failed to save chat message",
 			err,
 		)
 	}
 
-	// Save chat message
-	chatMsg := &models.ChatMessage{
-		UserID:   userID,
-		Message:  message,
-		Response: response,
-		IsUser:   true,
+	if request.summaryDue {
+		s.startChatSummary(ctx, userID, thread.ID)
 	}
 
-	if err := s.MongoDBRepo.SaveChatMessage(ctx, chatMsg); err != nil {
-		return "", NewServiceError(
+	if streamErr != nil {
+		return nil, NewServiceError(
 			http.StatusInternalServerError,
-			"Failed to save chat message",
-			err,
+			"AI stream interrupted",
+			streamErr,
 		)
 	}
 
-	return response, nil
+	return &models.ChatResponse{
+		MessageID: chatMsg.ID,
+		Response:  guarded.response,
+		Safety:    guarded.notice,
+	}, nil
 }
 
-func (s *AIService) formatWorkoutPrompt(profile *models.FitnessProfile) string {
-	var sb strings.Builder
+// chatRequest is the conversation sent to the model for a chat message
+type chatRequest struct {
+	messages []OpenRouterMessage
+	promptID string
+	// summaryDue is set when older history should be folded into the rolling summary
+	summaryDue bool
+	// language is the user's response language, zero without a preference
+	language i18n.Language
+}
 
-	sb.WriteString("Create a personalized workout plan with the following specifications:\n")
-	fmt.Fprintf(&sb, "- Age: %d\n", profile.Age)
-	fmt.Fprintf(&sb, "- Height: %.1f cm\n", profile.Height)
-	fmt.Fprintf(&sb, "- Weight: %.1f kg\n", profile.Weight)
-	fmt.Fprintf(&sb, "- Fitness Goal: %s\n", profile.Goal)
-	fmt.Fprintf(&sb, "- Timeframe: %s\n", profile.Timeframe)
-	fmt.Fprintf(&sb, "- Fitness Level: %s\n", profile.FitnessLevel)
-	fmt.Fprintf(&sb, "- Available Time: %d minutes per week\n", profile.AvailableMinutes)
+// buildChatMessages prepares the system prompt with the rolling summary of
+// the thread's older history, followed by its newest messages that fit the
+// token budget. With tools the prompt explains the coach's tools, caution
+// lists the health-safety categories the message touches. A non-zero before
+// limits the context to the history preceding that message, to answer it again.
+func (s *AIService) buildChatMessages(ctx context.Context, userID int, threadID primitive.ObjectID, message string, tools bool, caution []string, before primitive.ObjectID) (*chatRequest, error) {
+	// Get chat history
+	history, err := s.MongoDBRepo.GetChatHistory(ctx, userID, threadID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get chat history",
+			err,
+		)
+	}
 
-	if len(profile.HealthIssues) > 0 {
-		sb.WriteString("- Health Issues: ")
-		sb.WriteString(strings.Join(profile.HealthIssues, ", "))
-		sb.WriteString("\n")
+	// Without the summary the newest messages are still enough to answer
+	summary, err := s.MongoDBRepo.GetChatSummary(ctx, userID, threadID)
+	if err != nil {
+		fmt.Printf("Failed to get chat summary for user %d: %v\n", userID, err)
+		summary = nil
 	}
 
-	// Add timeframe-specific guidance
-	sb.WriteString("\n")
-	sb.WriteString(s.getTimeframeGuidance(profile.Timeframe, profile.AvailableMinutes))
-	sb.WriteString("\n")
+	if !before.IsZero() {
+		var cut *models.ChatMessage
+		history, cut = historyBefore(history, before)
+		// A summary that covers the message would tell the model what came after it
+		if summary != nil && cut != nil && !summary.SummarizedUntil.Before(cut.CreatedAt) {
+			summary = nil
+		}
+	}
 
-	sb.WriteString("\nThe plan should include:\n")
-	sb.WriteString("1. Weekly schedule with specific exercises\n")
-	sb.WriteString("2. Sets, reps, and rest periods\n")
-	sb.WriteString("3. Progression plan\n")
-	sb.WriteString("4. Safety considerations\n")
-	sb.WriteString("5. Format in JSON\n")
+	// Get user's fitness profile to check if they're a beginner and their language
+	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
+	if err != nil {
+		profile = nil
+	}
+	isBeginner := profile != nil && profile.FitnessLevel == "beginner"
+	language, _ := responseLanguage(ctx, profile)
 
-	// Add beginner mode instructions if user is a beginner
-	if profile.FitnessLevel == "beginner" {
-		sb.WriteString("\nIMPORTANT: This user is a beginner. Please explain all exercises in very simple terms as if explaining to someone with no fitness experience. Use basic language, avoid technical jargon, and include extra safety tips. Provide detailed step-by-step instructions for each exercise.\n")
+	// Build conversation context with beginner mode if needed
+	prompt, err := s.selectPrompt(promptChat, userID)
+	if err != nil {
+		return nil, err
+	}
+	prompt = s.translatePrompt(prompt, language)
+	data := chatPromptData{Beginner: isBeginner, Tools: tools, Caution: caution, Language: promptLanguage(language)}
+	if summary != nil {
+		data.Summary = summary.Summary
+	}
+	messages, err := renderPrompt(prompt, data, "system")
+	if err != nil {
+		return nil, err
 	}
 
-	return sb.String()
+	// Add the history that is not in the summary yet, newest first until the budget is used up
+	budget := s.chatBudget()
+	historyTokens := budget.input - estimateMessageTokens(messages) - estimateTokens(message)
+	unsummarized := unsummarizedHistory(history, summary)
+	for _, msg := range recentHistory(unsummarized, chatRecentExchanges+chatSummaryBatch, historyTokens) {
+		messages = append(messages, OpenRouterMessage{
+			Role:    "user",
+			Content: promptguard.Sanitize(msg.Message),
+		})
+		messages = append(messages, OpenRouterMessage{
+			Role:    "assistant",
+			Content: msg.Response,
+		})
+	}
+
+	// Add current message, an override attempt is delimited as quoted user text
+	content := promptguard.Sanitize(message)
+	if len(promptguard.Detect(content)) > 0 {
+		content = promptguard.Quote(content)
+	}
+	messages = append(messages, OpenRouterMessage{
+		Role:    "user",
+		Content: content,
+	})
+
+	return &chatRequest{
+		messages:   messages,
+		promptID:   prompt.ID(),
+		summaryDue: summaryDue(unsummarized, budget.input/2),
+		language:   language,
+	}, nil
+}
+
+// historyBefore returns the history preceding the message and the message itself
+func historyBefore(history []models.ChatMessage, messageID primitive.ObjectID) ([]models.ChatMessage, *models.ChatMessage) {
+	for i := range history {
+		if history[i].ID == messageID {
+			return history[:i], &history[i]
+		}
+	}
+	return history, nil
 }
 
 func (s *AIService) getTimeframeGuidance(timeframe string, availableMinutes int) string {
@@ -415,37 +616,11 @@ func (s *AIService) generateWorkoutSchedule(workoutsPerWeek, totalWeeks int) str
 		schedule = append(schedule, workoutDate.Format("Jan 2"))
 	}
 
-	return fmt.Sprintf("Week 1: %s (pattern repeats for %d weeks)", strings.Join(schedule, ", "), totalWeeks)
-}
-
-func (s *AIService) fixJSONWithAI(ctx context.Context, content, errorMsg string) (string, error) {
-	messages := []OpenRouterMessage{
-		{Role: "system", Content: "Fix this JSON to be valid. Return only the corrected JSON without any additional comments."},
-		{Role: "user", Content: fmt.Sprintf("Error: %s\nJSON: %s\nFix this JSON", errorMsg, content)},
-	}
-	response, err := s.Client.CreateChatCompletion(ctx, messages, false)
-	if err != nil {
-		fmt.Printf("ERROR: AI request failed in fixJSONWithAI: %v\n", err)
-	}
-	return response, err
+	return fmt.Sprintf("Week 1: %s (pattern repeats for %d weeks, sets and reps of later weeks are progressed automatically, so prescribe week 1)", strings.Join(schedule, ", "), totalWeeks)
 }
 
-func (s *AIService) createFallbackWorkouts(count int) []models.Workout {
-	workouts := []models.Workout{
-		{Name: "Upper Body", Description: "Chest, back, shoulders", Status: "planned", Exercises: []models.Exercise{{Name: "Push-ups", MuscleGroup: "Chest", Sets: 3, Reps: 12, RestSec: 60}}},
-		{Name: "Lower Body", Description: "Legs and glutes", Status: "planned", Exercises: []models.Exercise{{Name: "Squats", MuscleGroup: "Legs", Sets: 3, Reps: 15, RestSec: 60}}},
-		{Name: "Core", Description: "Abs and core", Status: "planned", Exercises: []models.Exercise{{Name: "Plank", MuscleGroup: "Core", Sets: 3, Reps: 1, RestSec: 60}}},
-		{Name: "Cardio", Description: "Cardiovascular training", Status: "planned", Exercises: []models.Exercise{{Name: "Walking", MuscleGroup: "Cardio", Sets: 1, Reps: 1, RestSec: 0}}},
-	}
-
-	if count > len(workouts) {
-		count = len(workouts)
-	}
-	return workouts[:count]
-}
-
-func (s *AIService) calculateWorkoutDate(workoutIndex, workoutsPerWeek int) time.Time {
-	now := time.Now()
+// calculateWorkoutDate spreads the workouts of a schedule that begins on start over the week
+func (s *AIService) calculateWorkoutDate(start time.Time, workoutIndex, workoutsPerWeek int) time.Time {
 	weekNumber := workoutIndex / workoutsPerWeek
 	positionInWeek := workoutIndex % workoutsPerWeek
 
@@ -460,32 +635,56 @@ func (s *AIService) calculateWorkoutDate(workoutIndex, workoutsPerWeek int) time
 	}
 
 	daysFromStart := weekNumber*7 + dayInWeek
-	workoutDate := now.AddDate(0, 0, daysFromStart)
+	workoutDate := start.AddDate(0, 0, daysFromStart)
 	// Return date without time (start of day)
 	return time.Date(workoutDate.Year(), workoutDate.Month(), workoutDate.Day(), 0, 0, 0, 0, workoutDate.Location())
 }
 
-func (s *AIService) generateFullSchedule(baseWorkouts []models.Workout, workoutsPerWeek, totalWorkouts int) []models.Workout {
+// progressionModel selects how the user's schedule progresses week over week
+func (s *AIService) progressionModel(profile *models.FitnessProfile) progression.Model {
+	store := s.Progression
+	if store == nil {
+		store = progression.Default()
+	}
+	return store.Select(profile.Goal, profile.FitnessLevel)
+}
+
+// generateFullSchedule repeats the base workouts over the weeks of the
+// phase, naming each with its week in the given language. Plans without
+// phases are scheduled as one phase numbered 0. The base workouts prescribe
+// the first week of the phase, the model progresses the later weeks.
+func (s *AIService) generateFullSchedule(baseWorkouts []models.Workout, workoutsPerWeek int, phase models.PlanPhase, language string, model progression.Model) []models.Workout {
 	var fullSchedule []models.Workout
+	week := i18n.Message(language, i18n.MessageWeek)
+	deload := i18n.Message(language, i18n.MessageDeload)
 
-	for i := 0; i < totalWorkouts; i++ {
+	for i := 0; i < workoutsPerWeek*phase.Weeks; i++ {
 		// Cycle through base workouts
 		baseIndex := i % len(baseWorkouts)
 		workout := baseWorkouts[baseIndex]
+		phaseWeek := (i / workoutsPerWeek) + 1
+		weekNumber := phase.StartWeek + phaseWeek - 1
 
 		// Create new workout with unique ID and date
 		scheduledWorkout := models.Workout{
 			WorkoutID:     primitive.NewObjectID(),
-			Name:          fmt.Sprintf("%s - Week %d", workout.Name, (i/workoutsPerWeek)+1),
+			Name:          fmt.Sprintf("%s - %s %d", workout.Name, week, weekNumber),
+			BaseWorkout:   workout.Name,
 			Description:   workout.Description,
 			Status:        "planned",
-			ScheduledDate: s.calculateWorkoutDate(i, workoutsPerWeek),
+			ScheduledDate: s.calculateWorkoutDate(phase.StartDate, i, workoutsPerWeek),
+			Week:          weekNumber,
+			Phase:         phase.Number,
+			Deload:        model.Deload(phaseWeek),
 			Exercises:     make([]models.Exercise, len(workout.Exercises)),
 		}
+		if scheduledWorkout.Deload {
+			scheduledWorkout.Name += fmt.Sprintf(" (%s)", deload)
+		}
 
 		// Copy exercises with new IDs
 		for j, exercise := range workout.Exercises {
-			scheduledWorkout.Exercises[j] = models.Exercise{
+			scheduledWorkout.Exercises[j] = model.Apply(models.Exercise{
 				ExerciseID:  primitive.NewObjectID(),
 				Name:        exercise.Name,
 				MuscleGroup: exercise.MuscleGroup,
@@ -494,7 +693,7 @@ func (s *AIService) generateFullSchedule(baseWorkouts []models.Workout, workouts
 				RestSec:     exercise.RestSec,
 				Notes:       exercise.Notes,
 				Technique:   exercise.Technique,
-			}
+			}, phaseWeek)
 		}
 
 		fullSchedule = append(fullSchedule, scheduledWorkout)
@@ -503,15 +702,6 @@ func (s *AIService) generateFullSchedule(baseWorkouts []models.Workout, workouts
 	return fullSchedule
 }
 
-func (s *AIService) GetChatHistory(ctx context.Context) ([]models.ChatMessage, error) {
-	userID, err := s.GetUserIDFromContext(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	return s.MongoDBRepo.GetChatHistory(ctx, userID)
-}
-
 func (s *AIService) RegenerateWorkoutPlan(ctx context.Context, userComments string) (*models.WorkoutPlan, error) {
 	userID, err := s.GetUserIDFromContext(ctx)
 	if err != nil {
@@ -526,6 +716,11 @@ func (s *AIService) RegenerateWorkoutPlan(ctx context.Context, userComments stri
 		)
 	}
 
+	ctx = withAIFeature(ctx, models.AIFeatureRegenerate)
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+
 	// Get short plan for context
 	currentShortPlan, err := s.MongoDBRepo.GetShortPlan(ctx, userID)
 	if err != nil {
@@ -537,6 +732,7 @@ func (s *AIService) RegenerateWorkoutPlan(ctx context.Context, userComments stri
 	}
 
 	// Get user profile
+	reportPlanStage(ctx, models.PlanStageProfile)
 	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
 	if err != nil {
 		return nil, NewServiceError(
@@ -547,50 +743,15 @@ func (s *AIService) RegenerateWorkoutPlan(ctx context.Context, userComments stri
 	}
 
 	// Calculate required workouts for regeneration
-	workoutsPerWeek := profile.AvailableMinutes / 50
-	if workoutsPerWeek < 2 {
-		workoutsPerWeek = 2
-	}
-	if workoutsPerWeek > 6 {
-		workoutsPerWeek = 6
-	}
-
-	// Prepare system message
-	systemContent := fmt.Sprintf(`You are a fitness expert. You MUST follow user feedback exactly. Create EXACTLY %d workouts and respond with ONLY valid JSON.
-
-CRITICAL: User feedback in the prompt is MANDATORY and must be implemented precisely. Do not ignore any user requirements.
-
-JSON structure:
-{
-  "title": "Updated Plan Title",
-  "workouts": [
-    {
-      "name": "Workout Name",
-      "description": "Brief description",
-      "status": "planned",
-      "exercises": [
-        {
-          "name": "Exercise Name",
-          "muscle_group": "Target Muscle",
-          "sets": 3,
-          "reps": 12,
-          "rest_sec": 60,
-          "notes": "Form tips",
-          "technique": "How to perform"
-        }
-      ]
-    }
-  ]
-}
-
-IMPORTANT: Create EXACTLY %d different workouts. Follow ALL user requirements from the prompt.`, workoutsPerWeek, workoutsPerWeek)
+	workoutsPerWeek := workoutsPerWeekFor(profile)
 
 	// Prepare prompt with short plan and comments
 	if currentShortPlan == nil {
+		fallbackPlan := generateRuleBasedPlan(profile, workoutsPerWeek)
 		currentShortPlan = &models.ShortWorkoutPlan{
 			UserID:          userID,
-			Title:           "Basic Workout Plan",
-			BaseWorkouts:    s.createFallbackWorkouts(workoutsPerWeek),
+			Title:           fallbackPlan.Title,
+			BaseWorkouts:    fallbackPlan.Workouts,
 			Timeframe:       profile.Timeframe,
 			WorkoutsPerWeek: workoutsPerWeek,
 			Status:          true,
@@ -598,110 +759,82 @@ IMPORTANT: Create EXACTLY %d different workouts. Follow ALL user requirements fr
 			UpdatedAt:       time.Now(),
 		}
 	}
-	userPrompt := s.formatRegeneratePrompt(profile, currentShortPlan, userComments, workoutsPerWeek)
-
-	// Prepare messages for OpenRouter
-	messages := []OpenRouterMessage{
-		{Role: "system", Content: systemContent},
-		{Role: "user", Content: userPrompt},
-	}
 
-	// Call AI
-	fmt.Printf("Starting AI request...\n")
-	content, err := s.Client.CreateChatCompletion(ctx, messages, true)
+	prompt, err := s.selectPrompt(promptRegenerate, userID)
 	if err != nil {
-		fmt.Printf("AI REQUEST FAILED when regenerating plan: %v\n", err)
-		return nil, NewServiceError(
-			http.StatusInternalServerError,
-			"AI request failed",
-			err,
-		)
-	}
-	fmt.Printf("AI request completed\n")
-
-	// Log raw response for debugging
-	fmt.Printf("Raw AI response: %s\n", content)
-
-	// Clean response - remove markdown code blocks if present
-	cleanContent := strings.TrimSpace(content)
-	if strings.HasPrefix(cleanContent, "```json") {
-		cleanContent = strings.TrimPrefix(cleanContent, "```json")
-		cleanContent = strings.TrimSuffix(cleanContent, "```")
-		cleanContent = strings.TrimSpace(cleanContent)
-	}
-	if strings.HasPrefix(cleanContent, "```") {
-		cleanContent = strings.TrimPrefix(cleanContent, "```")
-		cleanContent = strings.TrimSuffix(cleanContent, "```")
-		cleanContent = strings.TrimSpace(cleanContent)
+		return nil, err
 	}
 
-	var generatedData struct {
-		Title    string           `json:"title"`
-		Workouts []models.Workout `json:"workouts"`
+	// The regenerated plan starts over, with the first phase of long timeframes
+	now := time.Now()
+	phases := planPhases(profile.Timeframe, now)
+	span := s.firstPhase(profile.Timeframe, phases, now)
+
+	language, _ := responseLanguage(ctx, profile)
+	messages, err := renderPrompt(prompt, planPromptData{
+		Profile:           profile,
+		WorkoutsPerWeek:   workoutsPerWeek,
+		Rules:             planConstraintsPrompt(workoutsPerWeek),
+		TimeframeGuidance: s.planGuidance(profile, phases, 0, nil),
+		Beginner:          profile.FitnessLevel == "beginner",
+		Language:          promptLanguage(language),
+		Plan:              currentShortPlan,
+		Comments:          userComments,
+	}, "system", "user")
+	if err != nil {
+		return nil, err
 	}
 
-	if err := json.Unmarshal([]byte(cleanContent), &generatedData); err != nil {
-		res, err := s.fixJSONWithAI(ctx, cleanContent, err.Error())
-		fmt.Printf("%s", res)
-		if err != nil {
-			return nil, NewServiceError(
-				http.StatusInternalServerError,
-				"Failed to parse AI response",
-				fmt.Errorf("JSON parse error: %v, cleaned content: %s", err, cleanContent),
-			)
-		}
-		if err := json.Unmarshal([]byte(res), &generatedData); err != nil {
-			return nil, NewServiceError(
-				http.StatusInternalServerError,
-				"Failed to parse AI response",
-				fmt.Errorf("JSON parse error: %v, cleaned content: %s", err, cleanContent),
-			)
-		}
+	// Call AI and repair the plan until it passes validation
+	reportPlanStage(ctx, models.PlanStageGenerating)
+	generatedData, err := s.generateValidatedPlan(ctx, prompt, messages, workoutsPerWeek, language)
+	if err != nil {
+		return nil, err
 	}
 
-	// Fill missing fields with default values
-	for i := range generatedData.Workouts {
-		if generatedData.Workouts[i].Status == "" {
-			generatedData.Workouts[i].Status = "planned"
-		}
-		for j := range generatedData.Workouts[i].Exercises {
-			if generatedData.Workouts[i].Exercises[j].RestSec == 0 {
-				generatedData.Workouts[i].Exercises[j].RestSec = 60
-			}
-		}
+	disclaimers := s.guardPlan(ctx, userID, profile, generatedData.Workouts)
+	if len(phases) > 0 {
+		phases[0].Generated = true
+		phases[0].Source = models.PlanSourceAI
 	}
 
 	// Update short plan
-	now := time.Now()
 	currentShortPlan.Title = generatedData.Title
 	currentShortPlan.BaseWorkouts = generatedData.Workouts
+	currentShortPlan.Timeframe = profile.Timeframe
+	currentShortPlan.Phase = span.Number
+	currentShortPlan.Source = models.PlanSourceAI
+	currentShortPlan.PromptVersion = generatedData.PromptVersion
 	currentShortPlan.UpdatedAt = now
 
-	// Save updated short plan
-	if err := s.MongoDBRepo.SaveShortPlan(ctx, currentShortPlan); err != nil {
-		fmt.Printf("Failed to save updated short plan: %v\n", err)
-	}
-
 	// Create full plan
 	updatedPlan := &models.WorkoutPlan{
-		UserID:    userID,
-		Title:     generatedData.Title,
-		Workouts:  generatedData.Workouts,
-		Status:    true,
-		CreatedAt: now,
-		UpdatedAt: now,
+		UserID:        userID,
+		Title:         generatedData.Title,
+		Workouts:      generatedData.Workouts,
+		Status:        true,
+		Source:        models.PlanSourceAI,
+		PromptVersion: generatedData.PromptVersion,
+		Disclaimers:   disclaimers,
+		Phases:        phases,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 
 	// Generate full schedule for timeframe
-	totalWeeks := s.getWeeksFromTimeframe(profile.Timeframe)
-	totalWorkouts := workoutsPerWeek * totalWeeks
-	fullSchedule := s.generateFullSchedule(generatedData.Workouts, workoutsPerWeek, totalWorkouts)
+	reportPlanStage(ctx, models.PlanStageScheduling)
+	fullSchedule := s.generateFullSchedule(generatedData.Workouts, workoutsPerWeek, span, language.Code, s.phaseProgression(profile, span))
 
 	// Replace workouts with full schedule
 	updatedPlan.Workouts = fullSchedule
 
 	// Save updated plan
-	if err := s.MongoDBRepo.SaveWorkoutPlan(ctx, updatedPlan); err != nil {
+	reportPlanStage(ctx, models.PlanStageSaving)
+	if err := s.savePlan(ctx, updatedPlan, currentShortPlan, planChange{
+		reason:   models.PlanChangeRegenerate,
+		author:   models.PlanSourceAI,
+		comments: userComments,
+	}); err != nil {
 		return nil, NewServiceError(
 			http.StatusInternalServerError,
 			"Failed to save updated workout plan",
@@ -765,79 +898,61 @@ func (s *AIService) GenerateMotivationalMessage(ctx context.Context) (string, er
 		return "", err
 	}
 
+	// Without a profile the Accept-Language preference still applies
+	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
+	if err != nil {
+		profile = nil
+	}
+	language, _ := responseLanguage(ctx, profile)
+
 	progress, err := s.MongoDBRepo.GetUserProgress(ctx, userID)
 	if err != nil {
-		return "Keep pushing forward! Every workout counts.", nil
+		return i18n.Message(language.Code, i18n.MessageKeepPushing), nil
 	}
 
 	if s.Client == nil {
-		return "You're doing amazing! Keep up the great work!", nil
+		return i18n.Message(language.Code, i18n.MessageDoingAmazing), nil
 	}
 
-	messages := []OpenRouterMessage{
-		{Role: "system", Content: "Generate a short motivational fitness message. Be encouraging and specific."},
-		{Role: "user", Content: fmt.Sprintf("User: %d workouts, %d consecutive days, %s level. Motivate them!", progress.TotalWorkouts, progress.ConsecutiveDays, progress.Level)},
+	ctx = withAIFeature(ctx, models.AIFeatureMotivation)
+	prompt, err := s.selectPrompt(promptMotivation, userID)
+	if err != nil {
+		return "", err
+	}
+	prompt = s.translatePrompt(prompt, language)
+	messages, err := renderPrompt(prompt, motivationPromptData{
+		Progress: progress,
+		Language: promptLanguage(language),
+	}, "system", "user")
+	if err != nil {
+		return "", err
 	}
 
-	response, err := s.Client.CreateChatCompletion(ctx, messages, false)
+	inLanguage := func(response string) bool {
+		return language.Code == "" || !i18n.Mismatch(response, language.Code)
+	}
+	response, err := s.cachedCompletion(ctx, AICallMotivation, prompt, messages, false, motivationRetryPolicy, inLanguage)
 	if err != nil {
 		fmt.Printf("ERROR: AI request failed in GenerateMotivationalMessage: %v\n", err)
-		return "You're crushing it! Keep up the excellent work!", nil
+		return i18n.Message(language.Code, i18n.MessageCrushingIt), nil
 	}
 
+	response = s.enforceLanguage(ctx, messages, response, language, motivationRetryPolicy)
+	if !inLanguage(response) {
+		return i18n.Message(language.Code, i18n.MessageCrushingIt), nil
+	}
 	return response, nil
 }
 
-func (s *AIService) formatRegeneratePrompt(profile *models.FitnessProfile, currentShortPlan *models.ShortWorkoutPlan, userComments string, requiredWorkouts int) string {
-	var sb strings.Builder
-
-	sb.WriteString("Update the existing workout plan based on user feedback.\n\n")
-	sb.WriteString("User Profile:\n")
-	fmt.Fprintf(&sb, "- Age: %d\n", profile.Age)
-	fmt.Fprintf(&sb, "- Height: %.1f cm\n", profile.Height)
-	fmt.Fprintf(&sb, "- Weight: %.1f kg\n", profile.Weight)
-	fmt.Fprintf(&sb, "- Fitness Goal: %s\n", profile.Goal)
-	fmt.Fprintf(&sb, "- Fitness Level: %s\n", profile.FitnessLevel)
-	fmt.Fprintf(&sb, "- Available Time: %d minutes per week\n", profile.AvailableMinutes)
-
-	if len(profile.HealthIssues) > 0 {
-		sb.WriteString("- Health Issues: ")
-		sb.WriteString(strings.Join(profile.HealthIssues, ", "))
-		sb.WriteString("\n")
-	}
-
-	sb.WriteString("\nCurrent Base Workouts:\n")
-	fmt.Fprintf(&sb, "Title: %s\n", currentShortPlan.Title)
-	for i, workout := range currentShortPlan.BaseWorkouts {
-		fmt.Fprintf(&sb, "Workout %d: %s - %s\n", i+1, workout.Name, workout.Description)
-		for j, exercise := range workout.Exercises {
-			fmt.Fprintf(&sb, "  Exercise %d: %s (%s) - %d sets x %d reps\n",
-				j+1, exercise.Name, exercise.MuscleGroup, exercise.Sets, exercise.Reps)
-		}
-	}
-
-	sb.WriteString("\n\n=== CRITICAL USER REQUIREMENTS ===\n")
-	sb.WriteString("MUST FOLLOW THESE COMMENTS EXACTLY:\n")
-	sb.WriteString(userComments)
-	sb.WriteString("\n=== END CRITICAL REQUIREMENTS ===\n\n")
-	sb.WriteString("The above user comments are MANDATORY and must be implemented precisely.\n")
-
-	// Add timeframe-specific guidance
-	sb.WriteString("\n")
-	sb.WriteString(s.getTimeframeGuidance(profile.Timeframe, profile.AvailableMinutes))
-	sb.WriteString("\n")
-
-	sb.WriteString(fmt.Sprintf("\n\nPlease update the workout plan with EXACTLY %d workouts based on the user's feedback while maintaining:", requiredWorkouts))
-	sb.WriteString("\n1. Appropriate difficulty for their fitness level")
-	sb.WriteString("\n2. Alignment with their fitness goals")
-	sb.WriteString("\n3. Consideration of their health issues")
-	sb.WriteString("\n4. Time constraints")
-	sb.WriteString("\n5. Progressive overload principles")
-
-	// Add beginner mode instructions if user is a beginner
-	if profile.FitnessLevel == "beginner" {
-		sb.WriteString("\n\nIMPORTANT: This user is a beginner. Please explain all exercises in very simple terms as if explaining to someone with no fitness experience. Use basic language, avoid technical jargon, and include extra safety tips. Provide detailed step-by-step instructions for each exercise.\n")
+// GetAIModelStatuses reports circuit breaker state and health stats of every AI model
+func (s *AIService) GetAIModelStatuses(ctx context.Context) ([]models.AIModelStatus, error) {
+	if s.Client == nil {
+		return nil, NewServiceError(
+			http.StatusServiceUnavailable,
+			"AI service unavailable",
+			nil,
+		)
 	}
 
-	return sb.String()
+	return s.Client.Router().Snapshot(), nil
 }
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+	"rest-api/internal/prompts"
+	"rest-api/internal/repository"
+)
+
+// AI call types with cacheable responses. Chat and regeneration depend on
+// history and user comments and are never cached.
+const (
+	AICallPlan       = "plan"
+	AICallMotivation = "motivation"
+)
+
+var cachedCallTypes = []string{AICallPlan, AICallMotivation}
+
+// AICache stores AI responses by key until their TTL expires
+type AICache interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+}
+
+// LRUCache is an in-memory AICache that evicts the least recently used entry when full
+type LRUCache struct {
+	capacity int
+	now      func() time.Time
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		now:      time.Now,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(ctx context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return "", false, nil
+	}
+	entry := element.Value.(*lruEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return "", false, nil
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+func (c *LRUCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of stored entries, including expired ones not yet evicted
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// MongoAICache is an AICache shared by all server instances. Expired entries
+// are removed by a TTL index on the ai_cache collection.
+type MongoAICache struct {
+	repo repository.MongoDBRep
+}
+
+func NewMongoAICache(repo repository.MongoDBRep) *MongoAICache {
+	return &MongoAICache{repo: repo}
+}
+
+func (c *MongoAICache) Get(ctx context.Context, key string) (string, bool, error) {
+	entry, err := c.repo.GetAICacheEntry(ctx, key)
+	if err != nil || entry == nil {
+		return "", false, err
+	}
+	return entry.Value, true, nil
+}
+
+func (c *MongoAICache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	now := time.Now()
+	return c.repo.SaveAICacheEntry(ctx, &models.AICacheEntry{
+		Key:       key,
+		Value:     value,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+}
+
+type cacheCounters struct {
+	hits     atomic.Int64
+	misses   atomic.Int64
+	bypassed atomic.Int64
+	errors   atomic.Int64
+}
+
+// ResponseCache puts an AICache in front of the model with a TTL per call
+// type and counts hits. A nil *ResponseCache disables caching.
+type ResponseCache struct {
+	backend  string
+	store    AICache
+	ttls     map[string]time.Duration
+	counters map[string]*cacheCounters
+}
+
+// NewResponseCache caches the call types with a positive TTL in ttls
+func NewResponseCache(backend string, store AICache, ttls map[string]time.Duration) *ResponseCache {
+	counters := make(map[string]*cacheCounters, len(cachedCallTypes))
+	for _, callType := range cachedCallTypes {
+		counters[callType] = &cacheCounters{}
+	}
+	return &ResponseCache{backend: backend, store: store, ttls: ttls, counters: counters}
+}
+
+func (c *ResponseCache) enabled(callType string) bool {
+	return c != nil && c.ttls[callType] > 0 && c.counters[callType] != nil
+}
+
+// get looks up a response. Cache errors are logged and treated as a miss.
+func (c *ResponseCache) get(ctx context.Context, callType, key string) (string, bool) {
+	if !c.enabled(callType) {
+		return "", false
+	}
+	counters := c.counters[callType]
+
+	if middleware.IsAICacheBypassed(ctx) {
+		counters.bypassed.Add(1)
+		return "", false
+	}
+
+	value, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		counters.errors.Add(1)
+		fmt.Printf("AI cache lookup failed for %s: %v\n", callType, err)
+		return "", false
+	}
+	if !ok {
+		counters.misses.Add(1)
+		return "", false
+	}
+	counters.hits.Add(1)
+	return value, true
+}
+
+func (c *ResponseCache) set(ctx context.Context, callType, key, value string) {
+	if !c.enabled(callType) {
+		return
+	}
+	if err := c.store.Set(ctx, key, value, c.ttls[callType]); err != nil {
+		c.counters[callType].errors.Add(1)
+		fmt.Printf("AI cache store failed for %s: %v\n", callType, err)
+	}
+}
+
+// Stats reports lookup counters per call type
+func (c *ResponseCache) Stats() models.AICacheResponse {
+	if c == nil {
+		return models.AICacheResponse{Backend: "off", Stats: []models.AICacheStats{}}
+	}
+
+	stats := make([]models.AICacheStats, 0, len(cachedCallTypes))
+	for _, callType := range cachedCallTypes {
+		counters := c.counters[callType]
+		stat := models.AICacheStats{
+			CallType: callType,
+			Hits:     counters.hits.Load(),
+			Misses:   counters.misses.Load(),
+			Bypassed: counters.bypassed.Load(),
+			Errors:   counters.errors.Load(),
+		}
+		if lookups := stat.Hits + stat.Misses; lookups > 0 {
+			stat.HitRate = float64(stat.Hits) / float64(lookups)
+		}
+		stats = append(stats, stat)
+	}
+	return models.AICacheResponse{Backend: c.backend, Stats: stats}
+}
+
+// aiCacheKey hashes everything that determines a response: the call type, the
+// prompt template version, the primary model, the response format and the
+// messages with whitespace normalized. User IDs are not part of the key, so
+// users with identical inputs share responses.
+func aiCacheKey(callType, promptID, model string, requireJSON bool, messages []OpenRouterMessage) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t\x00", callType, promptID, model, requireJSON)
+	for _, message := range messages {
+		fmt.Fprintf(h, "%s\x00%s\x00", message.Role, strings.Join(strings.Fields(message.Content), " "))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheKey builds the cache key of a request to the current primary model
+func (s *AIService) cacheKey(callType string, prompt *prompts.Prompt, messages []OpenRouterMessage, requireJSON bool) string {
+	var model string
+	if s.Client != nil {
+		if routed := s.Client.Router().Models(); len(routed) > 0 {
+			model = routed[0]
+		}
+	}
+	return aiCacheKey(callType, prompt.ID(), model, requireJSON, messages)
+}
+
+// cachedCompletion returns the cached response for the messages or, within the
+// user's quota, asks the model and caches the answer. Responses valid rejects
+// are neither served from nor stored in the cache, nil accepts all.
+func (s *AIService) cachedCompletion(ctx context.Context, callType string, prompt *prompts.Prompt, messages []OpenRouterMessage, requireJSON bool, policy RetryPolicy, valid func(string) bool) (string, error) {
+	key := s.cacheKey(callType, prompt, messages, requireJSON)
+	if response, ok := s.Cache.get(ctx, callType, key); ok && (valid == nil || valid(response)) {
+		return response, nil
+	}
+
+	if err := s.checkQuota(ctx); err != nil {
+		return "", err
+	}
+
+	response, err := s.Client.CreateChatCompletionWithPolicy(ctx, messages, requireJSON, policy)
+	if err != nil {
+		return "", err
+	}
+
+	if valid == nil || valid(response) {
+		s.Cache.set(ctx, callType, key, response)
+	}
+	return response, nil
+}
+
+// GetAICacheStats reports AI response cache hit metrics
+func (s *AIService) GetAICacheStats(ctx context.Context) models.AICacheResponse {
+	return s.Cache.Stats()
+}
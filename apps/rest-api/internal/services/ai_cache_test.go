@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+func TestLRUCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRUCache(2)
+	ctx := context.Background()
+
+	_ = cache.Set(ctx, "a", "1", time.Hour)
+	_ = cache.Set(ctx, "b", "2", time.Hour)
+	if _, ok, _ := cache.Get(ctx, "a"); !ok {
+		t.Fatal("Expected 'a' to be cached")
+	}
+	_ = cache.Set(ctx, "c", "3", time.Hour)
+
+	if _, ok, _ := cache.Get(ctx, "b"); ok {
+		t.Error("Expected least recently used 'b' to be evicted")
+	}
+	if value, ok, _ := cache.Get(ctx, "a"); !ok || value != "1" {
+		t.Errorf("Expected 'a' to stay cached, got '%s' %v", value, ok)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+}
+
+func TestLRUCache_Expiry(t *testing.T) {
+	cache := NewLRUCache(10)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = cache.Set(ctx, "key", "value", time.Minute)
+	now = now.Add(59 * time.Second)
+	if _, ok, _ := cache.Get(ctx, "key"); !ok {
+		t.Error("Expected entry before its TTL")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok, _ := cache.Get(ctx, "key"); ok {
+		t.Error("Expected entry to expire after its TTL")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected expired entry to be removed, got %d entries", cache.Len())
+	}
+}
+
+func TestAICacheKey(t *testing.T) {
+	messages := []OpenRouterMessage{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "Age: 30\nGoal: weight_loss"}}
+	key := aiCacheKey(AICallPlan, "plan/v1", "model-a", true, messages)
+
+	reformatted := []OpenRouterMessage{{Role: "system", Content: "  Be   brief. "}, {Role: "user", Content: "Age: 30\n\n  Goal: weight_loss\n"}}
+	if aiCacheKey(AICallPlan, "plan/v1", "model-a", true, reformatted) != key {
+		t.Error("Expected whitespace differences to produce the same key")
+	}
+
+	changed := []OpenRouterMessage{messages[0], {Role: "user", Content: "Age: 31\nGoal: weight_loss"}}
+	testCases := []struct {
+		name string
+		key  string
+	}{
+		{"call type", aiCacheKey(AICallMotivation, "plan/v1", "model-a", true, messages)},
+		{"prompt version", aiCacheKey(AICallPlan, "plan/v2", "model-a", true, messages)},
+		{"model", aiCacheKey(AICallPlan, "plan/v1", "model-b", true, messages)},
+		{"response format", aiCacheKey(AICallPlan, "plan/v1", "model-a", false, messages)},
+		{"content", aiCacheKey(AICallPlan, "plan/v1", "model-a", true, changed)},
+	}
+	for _, tc := range testCases {
+		if tc.key == key {
+			t.Errorf("Expected a different %s to change the key", tc.name)
+		}
+	}
+}
+
+type failingAICache struct{}
+
+func (failingAICache) Get(ctx context.Context, key string) (string, bool, error) {
+	return "", false, errors.New("cache down")
+}
+
+func (failingAICache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return errors.New("cache down")
+}
+
+func TestResponseCache_Stats(t *testing.T) {
+	cache := NewResponseCache("memory", NewLRUCache(10), map[string]time.Duration{AICallMotivation: time.Hour})
+	ctx := context.Background()
+
+	cache.get(ctx, AICallMotivation, "key")
+	cache.set(ctx, AICallMotivation, "key", "value")
+	cache.get(ctx, AICallMotivation, "key")
+	cache.get(ctx, AICallMotivation, "key")
+	cache.get(context.WithValue(ctx, middleware.AICacheBypassKey, true), AICallMotivation, "key")
+
+	// Plan has no TTL and is not cached
+	cache.set(ctx, AICallPlan, "plan", "value")
+	if _, ok := cache.get(ctx, AICallPlan, "plan"); ok {
+		t.Error("Expected call type without TTL not to be cached")
+	}
+
+	stats := cache.Stats()
+	if stats.Backend != "memory" || len(stats.Stats) != 2 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	motivation := stats.Stats[1]
+	if motivation.Hits != 2 || motivation.Misses != 1 || motivation.Bypassed != 1 || motivation.Errors != 0 {
+		t.Errorf("Unexpected motivation counters: %+v", motivation)
+	}
+	if motivation.HitRate < 0.66 || motivation.HitRate > 0.67 {
+		t.Errorf("Expected hit rate 2/3, got %f", motivation.HitRate)
+	}
+
+	failing := NewResponseCache("mongo", failingAICache{}, map[string]time.Duration{AICallMotivation: time.Hour})
+	failing.set(ctx, AICallMotivation, "key", "value")
+	if _, ok := failing.get(ctx, AICallMotivation, "key"); ok {
+		t.Error("Expected a failing cache to miss")
+	}
+	if errs := failing.Stats().Stats[1].Errors; errs != 2 {
+		t.Errorf("Expected 2 errors, got %d", errs)
+	}
+
+	var disabled *ResponseCache
+	if disabled.Stats().Backend != "off" {
+		t.Error("Expected a nil cache to report backend 'off'")
+	}
+}
+
+func TestAIService_GenerateMotivationalMessage_Cached(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := planServer(t, []string{"Keep going!"}, &requests)
+	defer server.Close()
+
+	mongoRepo := &mockMongoDBRepo{progress: &models.UserProgress{TotalWorkouts: 7, ConsecutiveDays: 3, Level: "Bronze"}}
+	service := &AIService{
+		BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo},
+		Client:      newTestClient(server.URL, "model-a"),
+		Cache: NewResponseCache("mongo", NewMongoAICache(mongoRepo), map[string]time.Duration{
+			AICallMotivation: time.Hour,
+		}),
+	}
+
+	for userID := 1; userID <= 3; userID++ {
+		ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
+		message, err := service.GenerateMotivationalMessage(ctx)
+		if err != nil || message != "Keep going!" {
+			t.Fatalf("Expected the model message, got '%s' (%v)", message, err)
+		}
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected users with the same progress to share one model call, got %d", len(requests))
+	}
+	if len(mongoRepo.cacheEntries) != 1 {
+		t.Errorf("Expected one Mongo cache entry, got %d", len(mongoRepo.cacheEntries))
+	}
+
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+	ctx = context.WithValue(ctx, middleware.AICacheBypassKey, true)
+	if _, err := service.GenerateMotivationalMessage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("Expected the bypass header to call the model, got %d calls", len(requests))
+	}
+}
+
+func TestAIService_GenerateWorkoutPlan_CachedAcrossUsers(t *testing.T) {
+	valid, _ := json.Marshal(generatedPlan{
+		Title:    "Plan",
+		Workouts: []models.Workout{validTestWorkout("Day A"), validTestWorkout("Day B")},
+	})
+
+	var requests []OpenRouterRequest
+	server := planServer(t, []string{string(valid)}, &requests)
+	defer server.Close()
+
+	repo := newMockProfileRepo()
+	for userID := 1; userID <= 2; userID++ {
+		repo.profiles[userID] = &models.FitnessProfile{
+			Age: 30, Height: 180, Weight: 80,
+			Goal: "general_fitness", FitnessLevel: "beginner",
+			AvailableMinutes: 120, Timeframe: "1month",
+		}
+	}
+	service := &AIService{
+		BaseService: BaseService{Repo: repo, MongoDBRepo: &mockMongoDBRepo{}},
+		Client:      newTestClient(server.URL, "model-a"),
+		Cache:       NewResponseCache("memory", NewLRUCache(10), map[string]time.Duration{AICallPlan: time.Hour}),
+	}
+
+	var workoutIDs []string
+	for userID := 1; userID <= 2; userID++ {
+		ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
+		plan, err := service.GenerateWorkoutPlan(ctx, models.PlanSourceAI)
+		if err != nil {
+			t.Fatalf("Expected a plan, got %v", err)
+		}
+		if plan.Source != models.PlanSourceAI || plan.PromptVersion != "plan/v1" || plan.UserID != userID {
+			t.Errorf("Unexpected plan for user %d: %+v", userID, plan)
+		}
+		workoutIDs = append(workoutIDs, plan.Workouts[0].WorkoutID.Hex())
+	}
+
+	if len(requests) != 1 {
+		t.Errorf("Expected identical profiles to share one model call, got %d", len(requests))
+	}
+	if workoutIDs[0] == workoutIDs[1] {
+		t.Error("Expected each user to get their own workout IDs")
+	}
+	if stats := service.GetAICacheStats(context.Background()).Stats[0]; stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", stats)
+	}
+}
@@ -2,14 +2,38 @@ package services
 
 import (
 	"context"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
 	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Mock repository for testing
 type mockMongoDBRepo struct {
 	getRatingFunc func(ctx context.Context) ([]models.UserRating, error)
+	cacheEntries  map[string]*models.AICacheEntry
+	progress      *models.UserProgress
+	usage         []models.AIUsageRecord
+	chatHistory   []models.ChatMessage
+	chatSummary   *models.ChatSummary
+	threads       []*models.ChatThread
+	actions       []*models.ChatAction
+	plans         map[int]*models.WorkoutPlan
+	shortPlans    map[int]*models.ShortWorkoutPlan
+	mealPlans     map[int]*models.MealPlan
+	safetyEvents  []models.SafetyEvent
+	completions   []models.WorkoutCompletion
+	reports       []models.WeeklyReport
+	versions      []models.WorkoutPlanVersion
+	// jobs is shared with the plan job workers
+	jobsMu sync.Mutex
+	jobs   []models.PlanJob
 }
 
 func (m *mockMongoDBRepo) GetRating(ctx context.Context) ([]models.UserRating, error) {
@@ -24,19 +48,200 @@ func (m *mockMongoDBRepo) GetRating(ctx context.Context) ([]models.UserRating, e
 }
 
 func (m *mockMongoDBRepo) SaveChatMessage(ctx context.Context, message *models.ChatMessage) error {
+	message.ID = primitive.NewObjectID()
+	message.CreatedAt = time.Now()
+	m.chatHistory = append(m.chatHistory, *message)
+	return nil
+}
+
+func (m *mockMongoDBRepo) GetChatMessage(ctx context.Context, userID int, messageID primitive.ObjectID) (*models.ChatMessage, error) {
+	for _, message := range m.chatHistory {
+		if message.UserID == userID && message.ID == messageID {
+			found := message
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockMongoDBRepo) UpdateChatMessage(ctx context.Context, message *models.ChatMessage) error {
+	for i := range m.chatHistory {
+		if m.chatHistory[i].UserID == message.UserID && m.chatHistory[i].ID == message.ID {
+			m.chatHistory[i] = *message
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+// GetRatedChatMessages returns rated messages newest first like the real repository
+func (m *mockMongoDBRepo) GetRatedChatMessages(ctx context.Context, rating string, since time.Time, limit int) ([]models.ChatMessage, error) {
+	rated := func(feedback *models.ChatFeedback) bool {
+		return feedback != nil && (rating == "" || feedback.Rating == rating)
+	}
+	messages := []models.ChatMessage{}
+	for i := len(m.chatHistory) - 1; i >= 0 && len(messages) < limit; i-- {
+		message := m.chatHistory[i]
+		if message.CreatedAt.Before(since) {
+			continue
+		}
+		match := rated(message.Feedback)
+		for _, variant := range message.Variants {
+			match = match || rated(variant.Feedback)
+		}
+		if match {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (m *mockMongoDBRepo) GetChatHistory(ctx context.Context, userID int, threadID primitive.ObjectID) ([]models.ChatMessage, error) {
+	history := []models.ChatMessage{}
+	for _, message := range m.chatHistory {
+		if message.UserID == userID && message.ThreadID == threadID {
+			history = append(history, message)
+		}
+	}
+	return history, nil
+}
+
+func (m *mockMongoDBRepo) GetChatSummary(ctx context.Context, userID int, threadID primitive.ObjectID) (*models.ChatSummary, error) {
+	if m.chatSummary == nil || m.chatSummary.ThreadID != threadID {
+		return nil, nil
+	}
+	return m.chatSummary, nil
+}
+
+func (m *mockMongoDBRepo) SaveChatSummary(ctx context.Context, summary *models.ChatSummary) error {
+	m.chatSummary = summary
+	return nil
+}
+
+// EnsureDefaultChatThread moves messages and the summary without a thread into the default thread like the real repository
+func (m *mockMongoDBRepo) EnsureDefaultChatThread(ctx context.Context, userID int) (*models.ChatThread, error) {
+	var thread *models.ChatThread
+	for _, t := range m.threads {
+		if t.UserID == userID && t.IsDefault {
+			thread = t
+		}
+	}
+	if thread == nil {
+		thread = &models.ChatThread{UserID: userID, Title: models.DefaultChatThreadTitle, IsDefault: true}
+		if err := m.CreateChatThread(ctx, thread); err != nil {
+			return nil, err
+		}
+	}
+
+	if !thread.Migrated {
+		for i := range m.chatHistory {
+			if m.chatHistory[i].UserID == userID && m.chatHistory[i].ThreadID.IsZero() {
+				m.chatHistory[i].ThreadID = thread.ID
+			}
+		}
+		if m.chatSummary != nil && m.chatSummary.UserID == userID && m.chatSummary.ThreadID.IsZero() {
+			m.chatSummary.ThreadID = thread.ID
+		}
+		thread.Migrated = true
+	}
+
+	copied := *thread
+	return &copied, nil
+}
+
+func (m *mockMongoDBRepo) CreateChatThread(ctx context.Context, thread *models.ChatThread) error {
+	thread.ID = primitive.NewObjectID()
+	saved := *thread
+	m.threads = append(m.threads, &saved)
 	return nil
 }
 
-func (m *mockMongoDBRepo) GetChatHistory(ctx context.Context, userID int) ([]models.ChatMessage, error) {
-	return []models.ChatMessage{}, nil
+func (m *mockMongoDBRepo) GetChatThreads(ctx context.Context, userID int, includeArchived bool) ([]models.ChatThread, error) {
+	threads := []models.ChatThread{}
+	for _, thread := range m.threads {
+		if thread.UserID == userID && (includeArchived || !thread.Archived) {
+			threads = append(threads, *thread)
+		}
+	}
+	return threads, nil
+}
+
+func (m *mockMongoDBRepo) GetChatThread(ctx context.Context, userID int, threadID primitive.ObjectID) (*models.ChatThread, error) {
+	for _, thread := range m.threads {
+		if thread.UserID == userID && thread.ID == threadID {
+			copied := *thread
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockMongoDBRepo) UpdateChatThread(ctx context.Context, thread *models.ChatThread) error {
+	for i, t := range m.threads {
+		if t.UserID == thread.UserID && t.ID == thread.ID {
+			saved := *thread
+			m.threads[i] = &saved
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (m *mockMongoDBRepo) DeleteChatThread(ctx context.Context, userID int, threadID primitive.ObjectID) error {
+	history := m.chatHistory[:0]
+	for _, message := range m.chatHistory {
+		if message.UserID != userID || message.ThreadID != threadID {
+			history = append(history, message)
+		}
+	}
+	m.chatHistory = history
+
+	for i, thread := range m.threads {
+		if thread.UserID == userID && thread.ID == threadID {
+			m.threads = append(m.threads[:i], m.threads[i+1:]...)
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (m *mockMongoDBRepo) SaveChatAction(ctx context.Context, action *models.ChatAction) error {
+	action.ID = primitive.NewObjectID()
+	saved := *action
+	m.actions = append(m.actions, &saved)
+	return nil
+}
+
+func (m *mockMongoDBRepo) GetChatAction(ctx context.Context, userID int, actionID primitive.ObjectID) (*models.ChatAction, error) {
+	for _, action := range m.actions {
+		if action.UserID == userID && action.ID == actionID {
+			copied := *action
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockMongoDBRepo) UpdateChatActionStatus(ctx context.Context, userID int, actionID primitive.ObjectID, from, to string) (bool, error) {
+	for _, action := range m.actions {
+		if action.UserID == userID && action.ID == actionID && action.Status == from {
+			action.Status = to
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (m *mockMongoDBRepo) SaveWorkoutPlan(ctx context.Context, plan *models.WorkoutPlan) error {
+	if m.plans == nil {
+		m.plans = make(map[int]*models.WorkoutPlan)
+	}
+	m.plans[plan.UserID] = plan
 	return nil
 }
 
 func (m *mockMongoDBRepo) GetWorkoutPlan(ctx context.Context, userID int) (*models.WorkoutPlan, error) {
-	return nil, nil
+	return m.plans[userID], nil
 }
 
 func (m *mockMongoDBRepo) GetWorkoutByID(ctx context.Context, userID int, workoutID string) (*models.Workout, error) {
@@ -48,17 +253,118 @@ func (m *mockMongoDBRepo) CompleteWorkout(ctx context.Context, userID int, worko
 }
 
 func (m *mockMongoDBRepo) GetUserProgress(ctx context.Context, userID int) (*models.UserProgress, error) {
+	return m.progress, nil
+}
+
+func (m *mockMongoDBRepo) GetWorkoutCompletions(ctx context.Context, userID int, since, until time.Time) ([]models.WorkoutCompletion, error) {
+	completions := []models.WorkoutCompletion{}
+	for _, completion := range m.completions {
+		if completion.UserID == userID && !completion.CompletedAt.Before(since) && completion.CompletedAt.Before(until) {
+			completions = append(completions, completion)
+		}
+	}
+	return completions, nil
+}
+
+func (m *mockMongoDBRepo) GetActiveUserIDs(ctx context.Context, since time.Time) ([]int, error) {
+	userIDs := []int{}
+	for _, completion := range m.completions {
+		if !completion.CompletedAt.Before(since) && !slices.Contains(userIDs, completion.UserID) {
+			userIDs = append(userIDs, completion.UserID)
+		}
+	}
+	slices.Sort(userIDs)
+	return userIDs, nil
+}
+
+func (m *mockMongoDBRepo) SaveWeeklyReport(ctx context.Context, report *models.WeeklyReport) error {
+	report.ID = primitive.NewObjectID()
+	m.reports = append(m.reports, *report)
+	return nil
+}
+
+func (m *mockMongoDBRepo) GetWeeklyReport(ctx context.Context, userID int, weekStart time.Time) (*models.WeeklyReport, error) {
+	for _, report := range m.reports {
+		if report.UserID == userID && report.WeekStart.Equal(weekStart) {
+			found := report
+			return &found, nil
+		}
+	}
 	return nil, nil
 }
 
+func (m *mockMongoDBRepo) GetPreviousWeeklyReport(ctx context.Context, userID int, weekStart time.Time) (*models.WeeklyReport, error) {
+	var previous *models.WeeklyReport
+	for i, report := range m.reports {
+		if report.UserID == userID && report.WeekStart.Before(weekStart) && (previous == nil || report.WeekStart.After(previous.WeekStart)) {
+			previous = &m.reports[i]
+		}
+	}
+	return previous, nil
+}
+
 func (m *mockMongoDBRepo) GetShortPlan(ctx context.Context, userID int) (*models.ShortWorkoutPlan, error) {
-	return nil, nil
+	return m.shortPlans[userID], nil
 }
 
 func (m *mockMongoDBRepo) SaveShortPlan(ctx context.Context, plan *models.ShortWorkoutPlan) error {
+	if m.shortPlans == nil {
+		m.shortPlans = make(map[int]*models.ShortWorkoutPlan)
+	}
+	m.shortPlans[plan.UserID] = plan
 	return nil
 }
 
+// SaveWorkoutPlanVersion stores a copy, like the database, so later changes
+// to the plan do not show up in the version
+func (m *mockMongoDBRepo) SaveWorkoutPlanVersion(ctx context.Context, version *models.WorkoutPlanVersion) error {
+	data, err := bson.Marshal(version)
+	if err != nil {
+		return err
+	}
+	var stored models.WorkoutPlanVersion
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	version.ID = primitive.NewObjectID()
+	version.Version = len(m.versions) + 1
+	stored.ID, stored.Version = version.ID, version.Version
+	m.versions = append(m.versions, stored)
+	return nil
+}
+
+func (m *mockMongoDBRepo) GetWorkoutPlanVersions(ctx context.Context, userID int) ([]models.WorkoutPlanVersion, error) {
+	versions := []models.WorkoutPlanVersion{}
+	for i := len(m.versions) - 1; i >= 0; i-- {
+		if version := m.versions[i]; version.UserID == userID {
+			version.Plan, version.ShortPlan = nil, nil
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (m *mockMongoDBRepo) GetWorkoutPlanVersion(ctx context.Context, userID int, versionID primitive.ObjectID) (*models.WorkoutPlanVersion, error) {
+	for _, version := range m.versions {
+		if version.UserID == userID && version.ID == versionID {
+			return &version, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockMongoDBRepo) SaveMealPlan(ctx context.Context, plan *models.MealPlan) error {
+	if m.mealPlans == nil {
+		m.mealPlans = make(map[int]*models.MealPlan)
+	}
+	m.mealPlans[plan.UserID] = plan
+	return nil
+}
+
+func (m *mockMongoDBRepo) GetMealPlan(ctx context.Context, userID int) (*models.MealPlan, error) {
+	return m.mealPlans[userID], nil
+}
+
 func (m *mockMongoDBRepo) SaveExerciseMedia(ctx context.Context, media *models.ExerciseMedia) error {
 	return nil
 }
@@ -71,6 +377,138 @@ func (m *mockMongoDBRepo) DeleteExerciseMedia(ctx context.Context, mediaID strin
 	return nil
 }
 
+func (m *mockMongoDBRepo) GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error) {
+	entry, ok := m.cacheEntries[key]
+	if !ok || !entry.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+func (m *mockMongoDBRepo) EnsureAICacheIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockMongoDBRepo) SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error {
+	if m.cacheEntries == nil {
+		m.cacheEntries = make(map[string]*models.AICacheEntry)
+	}
+	m.cacheEntries[entry.Key] = entry
+	return nil
+}
+
+func (m *mockMongoDBRepo) SaveAIUsage(ctx context.Context, record *models.AIUsageRecord) error {
+	m.usage = append(m.usage, *record)
+	return nil
+}
+
+func (m *mockMongoDBRepo) GetAIUsageTotals(ctx context.Context, userID int, since time.Time) ([]models.AIUsageTotal, error) {
+	totals := []models.AIUsageTotal{}
+	index := map[string]int{}
+	for _, record := range m.usage {
+		if (userID != 0 && record.UserID != userID) || record.CreatedAt.Before(since) {
+			continue
+		}
+		key := record.Feature + "/" + record.Model
+		i, ok := index[key]
+		if !ok {
+			i = len(totals)
+			index[key] = i
+			totals = append(totals, models.AIUsageTotal{Feature: record.Feature, Model: record.Model})
+		}
+		totals[i].Requests++
+		totals[i].PromptTokens += record.PromptTokens
+		totals[i].CompletionTokens += record.CompletionTokens
+		totals[i].TotalTokens += record.TotalTokens
+		totals[i].CostUSD += record.CostUSD
+	}
+	return totals, nil
+}
+
+func (m *mockMongoDBRepo) GetAIUsageByUser(ctx context.Context, since time.Time, limit int) ([]models.AIUsageUserTotal, error) {
+	users := []models.AIUsageUserTotal{}
+	index := map[int]int{}
+	for _, record := range m.usage {
+		if record.CreatedAt.Before(since) {
+			continue
+		}
+		i, ok := index[record.UserID]
+		if !ok {
+			i = len(users)
+			index[record.UserID] = i
+			users = append(users, models.AIUsageUserTotal{UserID: record.UserID})
+		}
+		users[i].Requests++
+		users[i].TotalTokens += record.TotalTokens
+		users[i].CostUSD += record.CostUSD
+	}
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (m *mockMongoDBRepo) SaveSafetyEvent(ctx context.Context, event *models.SafetyEvent) error {
+	event.ID = primitive.NewObjectID()
+	m.safetyEvents = append(m.safetyEvents, *event)
+	return nil
+}
+
+func (m *mockMongoDBRepo) GetSafetyEvents(ctx context.Context, limit int) ([]models.SafetyEvent, error) {
+	events := []models.SafetyEvent{}
+	for i := len(m.safetyEvents) - 1; i >= 0 && len(events) < limit; i-- {
+		events = append(events, m.safetyEvents[i])
+	}
+	return events, nil
+}
+
+func (m *mockMongoDBRepo) CreatePlanJob(ctx context.Context, job *models.PlanJob) error {
+	m.jobsMu.Lock()
+	defer m.jobsMu.Unlock()
+	for _, existing := range m.jobs {
+		if existing.UserID == job.UserID && existing.Active {
+			return mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}
+		}
+	}
+	job.ID = primitive.NewObjectID()
+	m.jobs = append(m.jobs, *job)
+	return nil
+}
+
+func (m *mockMongoDBRepo) GetPlanJob(ctx context.Context, userID int, jobID primitive.ObjectID) (*models.PlanJob, error) {
+	m.jobsMu.Lock()
+	defer m.jobsMu.Unlock()
+	for _, job := range m.jobs {
+		if job.ID == jobID && job.UserID == userID {
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockMongoDBRepo) GetActivePlanJob(ctx context.Context, userID int) (*models.PlanJob, error) {
+	m.jobsMu.Lock()
+	defer m.jobsMu.Unlock()
+	for _, job := range m.jobs {
+		if job.UserID == userID && job.Active {
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockMongoDBRepo) UpdatePlanJob(ctx context.Context, job *models.PlanJob) error {
+	m.jobsMu.Lock()
+	defer m.jobsMu.Unlock()
+	for i := range m.jobs {
+		if m.jobs[i].ID == job.ID {
+			m.jobs[i] = *job
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
 func TestAIService_GetRating(t *testing.T) {
 	mockRepo := &mockMongoDBRepo{}
 	service := &AIService{
@@ -119,3 +557,45 @@ func TestAIService_GetRating_Error(t *testing.T) {
 		t.Error("Expected error, got nil")
 	}
 }
+
+func TestAIService_GenerateFullSchedule_Progression(t *testing.T) {
+	service := &AIService{}
+	base := []models.Workout{
+		{Name: "Upper Body", Exercises: []models.Exercise{{Name: "Push-ups", MuscleGroup: "Chest", Sets: 3, Reps: 10}}},
+		{Name: "Lower Body", Exercises: []models.Exercise{{Name: "Squats", MuscleGroup: "Legs", Sets: 3, Reps: 12}}},
+	}
+	// Muscle gain for intermediates: reps 10-14, then a set more, deload every 5th week
+	model := service.progressionModel(&models.FitnessProfile{Goal: "muscle_gain", FitnessLevel: "intermediate"})
+
+	schedule := service.generateFullSchedule(base, 2, models.PlanPhase{StartWeek: 1, Weeks: 12, StartDate: time.Now()}, "de", model)
+
+	testCases := []struct {
+		index  int
+		name   string
+		sets   int
+		reps   int
+		deload bool
+	}{
+		{0, "Upper Body - Woche 1", 3, 10, false},
+		{1, "Lower Body - Woche 1", 3, 12, false},
+		{4, "Upper Body - Woche 3", 3, 12, false},
+		{6, "Upper Body - Woche 4", 3, 13, false},
+		{8, "Upper Body - Woche 5 (Deload)", 2, 13, true},
+		{10, "Upper Body - Woche 6", 3, 14, false},
+		{12, "Upper Body - Woche 7", 4, 10, false},
+		{22, "Upper Body - Woche 12", 4, 14, false},
+	}
+	for _, tc := range testCases {
+		workout := schedule[tc.index]
+		exercise := workout.Exercises[0]
+		if workout.Name != tc.name || workout.Deload != tc.deload || exercise.Sets != tc.sets || exercise.Reps != tc.reps {
+			t.Errorf("Expected %s with %dx%d, got %s with %dx%d", tc.name, tc.sets, tc.reps, workout.Name, exercise.Sets, exercise.Reps)
+		}
+		if workout.Week != tc.index/2+1 || workout.BaseWorkout == "" {
+			t.Errorf("Expected %s to know its week and base workout, got %+v", tc.name, workout)
+		}
+	}
+	if base[0].Exercises[0].Sets != 3 || base[0].Exercises[0].Reps != 10 {
+		t.Errorf("Expected the base workouts to be unchanged, got %+v", base[0].Exercises[0])
+	}
+}
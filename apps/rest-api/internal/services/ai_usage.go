@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+var ErrAIQuotaExceeded = errors.New("AI token quota exceeded")
+
+// usageReportUsers is the number of top users in the admin usage report
+const usageReportUsers = 50
+
+// AIQuota limits the tokens a user may consume per UTC day and month, 0 means unlimited
+type AIQuota struct {
+	DailyTokens   int
+	MonthlyTokens int
+}
+
+// QuotaExceededError describes which quota was hit and when it resets
+type QuotaExceededError struct {
+	Period   string
+	Limit    int
+	Used     int
+	ResetsAt time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s AI token quota of %d exceeded (%d used), resets at %s",
+		e.Period, e.Limit, e.Used, e.ResetsAt.Format(time.RFC3339))
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrAIQuotaExceeded
+}
+
+// IsQuotaExceeded reports whether err is a ServiceError caused by an exhausted quota
+func IsQuotaExceeded(err error) bool {
+	var svcErr ServiceError
+	return errors.As(err, &svcErr) && errors.Is(svcErr.Err, ErrAIQuotaExceeded)
+}
+
+type quotaPeriod struct {
+	name     string
+	since    time.Time
+	resetsAt time.Time
+	limit    int
+}
+
+func quotaPeriods(now time.Time, quota AIQuota) []quotaPeriod {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return []quotaPeriod{
+		{name: "daily", since: day, resetsAt: day.AddDate(0, 0, 1), limit: quota.DailyTokens},
+		{name: "monthly", since: month, resetsAt: month.AddDate(0, 1, 0), limit: quota.MonthlyTokens},
+	}
+}
+
+type aiFeatureKey struct{}
+
+// withAIFeature tags the AI calls made with ctx for usage accounting
+func withAIFeature(ctx context.Context, feature string) context.Context {
+	return context.WithValue(ctx, aiFeatureKey{}, feature)
+}
+
+// estimateTokens approximates the token count of text at about 4 characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// estimateMessageTokens approximates the prompt tokens of messages, including per-message overhead
+func estimateMessageTokens(messages []OpenRouterMessage) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += estimateTokens(message.Content) + 4
+	}
+	return tokens
+}
+
+// recordUsage stores the usage of one completion for the user and feature of ctx.
+// It is registered as the client's UsageFunc.
+func (s *AIService) recordUsage(ctx context.Context, usage TokenUsage) {
+	userID, _ := middleware.GetUserIDFromContext(ctx)
+	feature, _ := ctx.Value(aiFeatureKey{}).(string)
+
+	record := &models.AIUsageRecord{
+		UserID:           userID,
+		Feature:          feature,
+		Model:            usage.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.PromptTokens + usage.CompletionTokens,
+		Estimated:        usage.Estimated,
+		CreatedAt:        time.Now(),
+	}
+	if s.Client != nil {
+		if model, ok := s.Client.Catalog().Find(usage.Model); ok {
+			record.CostUSD = float64(usage.PromptTokens)*model.CostPerPromptToken +
+				float64(usage.CompletionTokens)*model.CostPerCompletionToken
+		}
+	}
+
+	// Usage must be recorded even when the request was cancelled mid-stream
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := s.MongoDBRepo.SaveAIUsage(saveCtx, record); err != nil {
+		fmt.Printf("Failed to record AI usage for user %d: %v\n", userID, err)
+	}
+}
+
+func (s *AIService) usedTokens(ctx context.Context, userID int, since time.Time) (int, float64, []models.AIUsageTotal, error) {
+	totals, err := s.MongoDBRepo.GetAIUsageTotals(ctx, userID, since)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	tokens, cost := 0, 0.0
+	for _, total := range totals {
+		tokens += total.TotalTokens
+		cost += total.CostUSD
+	}
+	return tokens, cost, totals, nil
+}
+
+// checkQuota rejects an AI call with 429 once the user of ctx has used up the
+// daily or monthly token quota. Usage lookup failures do not block the call.
+func (s *AIService) checkQuota(ctx context.Context) error {
+	userID, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	for _, period := range quotaPeriods(time.Now(), s.Quota) {
+		if period.limit <= 0 {
+			continue
+		}
+
+		used, _, _, err := s.usedTokens(ctx, userID, period.since)
+		if err != nil {
+			fmt.Printf("Failed to check AI quota for user %d: %v\n", userID, err)
+			return nil
+		}
+		if used >= period.limit {
+			quotaErr := &QuotaExceededError{Period: period.name, Limit: period.limit, Used: used, ResetsAt: period.resetsAt}
+			return NewServiceError(
+				http.StatusTooManyRequests,
+				fmt.Sprintf("You have used your %s AI quota of %d tokens, it resets at %s",
+					period.name, period.limit, period.resetsAt.Format(time.RFC3339)),
+				quotaErr,
+			)
+		}
+	}
+	return nil
+}
+
+// GetUsage reports the current user's token usage in the daily and monthly quota periods
+func (s *AIService) GetUsage(ctx context.Context) (*models.AIUsageResponse, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	periods := quotaPeriods(time.Now(), s.Quota)
+	usage := make([]models.AIUsagePeriod, len(periods))
+	for i, period := range periods {
+		used, cost, totals, err := s.usedTokens(ctx, userID, period.since)
+		if err != nil {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to get AI usage",
+				err,
+			)
+		}
+
+		usage[i] = models.AIUsagePeriod{
+			Since:       period.since,
+			ResetsAt:    period.resetsAt,
+			TotalTokens: used,
+			CostUSD:     cost,
+			Limit:       period.limit,
+			Usage:       totals,
+		}
+		if period.limit > 0 {
+			remaining := max(period.limit-used, 0)
+			usage[i].Remaining = &remaining
+		}
+	}
+
+	return &models.AIUsageResponse{Daily: usage[0], Monthly: usage[1]}, nil
+}
+
+// GetAIUsageReport aggregates the usage of all users in the current "day" or "month"
+func (s *AIService) GetAIUsageReport(ctx context.Context, period string) (*models.AIUsageReport, error) {
+	var since time.Time
+	periods := quotaPeriods(time.Now(), s.Quota)
+	switch period {
+	case "day":
+		since = periods[0].since
+	case "month":
+		since = periods[1].since
+	default:
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Period must be 'day' or 'month'",
+			nil,
+		)
+	}
+
+	tokens, cost, totals, err := s.usedTokens(ctx, 0, since)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get AI usage",
+			err,
+		)
+	}
+
+	users, err := s.MongoDBRepo.GetAIUsageByUser(ctx, since, usageReportUsers)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get AI usage",
+			err,
+		)
+	}
+
+	return &models.AIUsageReport{
+		Since:       since,
+		TotalTokens: tokens,
+		CostUSD:     cost,
+		Usage:       totals,
+		Users:       users,
+	}, nil
+}
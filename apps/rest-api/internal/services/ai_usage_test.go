@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rest-api/internal/config"
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+func TestQuotaPeriods(t *testing.T) {
+	now := time.Date(2026, time.March, 31, 15, 30, 0, 0, time.UTC)
+	periods := quotaPeriods(now, AIQuota{DailyTokens: 10, MonthlyTokens: 100})
+
+	daily, monthly := periods[0], periods[1]
+	if !daily.since.Equal(time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC)) || !daily.resetsAt.Equal(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected daily period %v - %v", daily.since, daily.resetsAt)
+	}
+	if !monthly.since.Equal(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)) || !monthly.resetsAt.Equal(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected monthly period %v - %v", monthly.since, monthly.resetsAt)
+	}
+	if daily.limit != 10 || monthly.limit != 100 {
+		t.Errorf("Unexpected limits %d/%d", daily.limit, monthly.limit)
+	}
+}
+
+// newUsageTestService returns a service whose model answers with 150 tokens per call
+func newUsageTestService(t *testing.T, quota AIQuota) (*AIService, *mockMongoDBRepo, *int) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"Stay hydrated."}}],"usage":{"prompt_tokens":120,"completion_tokens":30,"total_tokens":150}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newTestClient(server.URL)
+	client.SetCatalog(&config.ModelCatalog{Models: []config.AIModel{{
+		ID: "model-a", Priority: 1, Enabled: true,
+		CostPerPromptToken: 0.000001, CostPerCompletionToken: 0.000002,
+	}}})
+
+	mongoRepo := &mockMongoDBRepo{}
+	service := &AIService{
+		BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo},
+		Client:      client,
+		Quota:       quota,
+	}
+	client.SetUsageFunc(service.recordUsage)
+	return service, mongoRepo, &calls
+}
+
+func TestAIService_Chat_RecordsUsageAndEnforcesQuota(t *testing.T) {
+	service, mongoRepo, calls := newUsageTestService(t, AIQuota{DailyTokens: 200})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.Chat(ctx, "", "How much water?"); err != nil {
+			t.Fatalf("Expected chat %d within quota, got %v", i+1, err)
+		}
+	}
+
+	_, err := service.Chat(ctx, "", "And more?")
+	serviceErr, ok := err.(ServiceError)
+	if !ok || serviceErr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 once the quota is used up, got %v", err)
+	}
+	var quotaErr *QuotaExceededError
+	if !errors.As(serviceErr.Err, &quotaErr) || quotaErr.Period != "daily" || quotaErr.Used != 300 {
+		t.Errorf("Expected a daily quota error with 300 tokens used, got %v", serviceErr.Err)
+	}
+	if !IsQuotaExceeded(err) {
+		t.Error("Expected IsQuotaExceeded to detect the quota error")
+	}
+	if *calls != 2 {
+		t.Errorf("Expected the model not to be called over quota, got %d calls", *calls)
+	}
+
+	if len(mongoRepo.usage) != 2 {
+		t.Fatalf("Expected 2 usage records, got %d", len(mongoRepo.usage))
+	}
+	record := mongoRepo.usage[0]
+	if record.UserID != 1 || record.Feature != models.AIFeatureChat || record.Model != "model-a" || record.TotalTokens != 150 {
+		t.Errorf("Unexpected usage record %+v", record)
+	}
+	if math.Abs(record.CostUSD-0.00018) > 1e-12 {
+		t.Errorf("Expected cost 0.00018, got %f", record.CostUSD)
+	}
+}
+
+func TestAIService_GenerateWorkoutPlan_QuotaExceeded(t *testing.T) {
+	service, mongoRepo, calls := newUsageTestService(t, AIQuota{MonthlyTokens: 100})
+	service.Repo.(*mockProfileRepo).profiles[1] = &models.FitnessProfile{
+		Goal: "general_fitness", FitnessLevel: "beginner", AvailableMinutes: 120, Timeframe: "1month",
+	}
+	mongoRepo.usage = []models.AIUsageRecord{{UserID: 1, Feature: models.AIFeatureChat, TotalTokens: 100, CreatedAt: time.Now()}}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	_, err := service.GenerateWorkoutPlan(ctx, models.PlanSourceAI)
+	if !IsQuotaExceeded(err) {
+		t.Fatalf("Expected the quota error instead of a rule-based fallback, got %v", err)
+	}
+	if *calls != 0 {
+		t.Errorf("Expected no model calls, got %d", *calls)
+	}
+
+	// The rule-based generator does not use AI tokens
+	if _, err := service.GenerateWorkoutPlan(ctx, models.PlanSourceRules); err != nil {
+		t.Errorf("Expected rule-based generation over quota, got %v", err)
+	}
+}
+
+func TestAIService_GetUsage(t *testing.T) {
+	service, mongoRepo, _ := newUsageTestService(t, AIQuota{DailyTokens: 1000})
+	now := time.Now()
+	mongoRepo.usage = []models.AIUsageRecord{
+		{UserID: 1, Feature: models.AIFeatureChat, Model: "model-a", TotalTokens: 300, CostUSD: 0.01, CreatedAt: now},
+		{UserID: 1, Feature: models.AIFeaturePlan, Model: "model-a", TotalTokens: 500, CostUSD: 0.02, CreatedAt: now},
+		{UserID: 2, Feature: models.AIFeatureChat, Model: "model-a", TotalTokens: 900, CostUSD: 0.03, CreatedAt: now},
+	}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	usage, err := service.GetUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Daily.TotalTokens != 800 || usage.Daily.Limit != 1000 || usage.Daily.Remaining == nil || *usage.Daily.Remaining != 200 {
+		t.Errorf("Unexpected daily usage %+v", usage.Daily)
+	}
+	if len(usage.Daily.Usage) != 2 {
+		t.Errorf("Expected usage of 2 features, got %+v", usage.Daily.Usage)
+	}
+	if usage.Monthly.Limit != 0 || usage.Monthly.Remaining != nil {
+		t.Errorf("Expected an unlimited monthly quota, got %+v", usage.Monthly)
+	}
+
+	report, err := service.GetAIUsageReport(context.Background(), "day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TotalTokens != 1700 || len(report.Users) != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	if _, err := service.GetAIUsageReport(context.Background(), "week"); err == nil {
+		t.Error("Expected an error for an unknown period")
+	}
+}
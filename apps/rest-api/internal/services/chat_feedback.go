@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"rest-api/internal/models"
+	"rest-api/internal/safety"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxChatFeedbackReasonLen = 500
+	// maxChatVariants bounds how often an answer can be regenerated
+	maxChatVariants = 5
+
+	defaultRatedExchangeLimit = 100
+	maxRatedExchangeLimit     = 1000
+)
+
+// chatMessage looks up a stored exchange of the user
+func (s *AIService) chatMessage(ctx context.Context, userID int, messageID string) (*models.ChatMessage, error) {
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Invalid message ID format",
+			err,
+		)
+	}
+
+	message, err := s.MongoDBRepo.GetChatMessage(ctx, userID, id)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get chat message",
+			err,
+		)
+	}
+	if message == nil {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Chat message not found",
+			nil,
+		)
+	}
+	return message, nil
+}
+
+func (s *AIService) updateChatMessage(ctx context.Context, message *models.ChatMessage) error {
+	if err := s.MongoDBRepo.UpdateChatMessage(ctx, message); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return NewServiceError(
+				http.StatusNotFound,
+				"Chat message not found",
+				err,
+			)
+		}
+		return NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to update chat message",
+			err,
+		)
+	}
+	return nil
+}
+
+// RateChatMessage stores a thumbs up or down on the current answer of a
+// message, replacing an earlier rating
+func (s *AIService) RateChatMessage(ctx context.Context, messageID string, req *models.ChatFeedbackRequest) (*models.ChatMessage, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Rating != models.ChatRatingUp && req.Rating != models.ChatRatingDown {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Rating must be 'up' or 'down'",
+			nil,
+		)
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len([]rune(reason)) > maxChatFeedbackReasonLen {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Reason must be at most 500 characters",
+			nil,
+		)
+	}
+
+	message, err := s.chatMessage(ctx, userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	message.Feedback = &models.ChatFeedback{
+		Rating:    req.Rating,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if err := s.updateChatMessage(ctx, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// RegenerateChatMessage answers a stored message again. The model sees the
+// conversation as it was before the message; the previous answer and its
+// rating are kept as a variant.
+func (s *AIService) RegenerateChatMessage(ctx context.Context, messageID string) (*models.ChatResponse, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Client == nil {
+		return nil, NewServiceError(
+			http.StatusServiceUnavailable,
+			"AI service unavailable",
+			nil,
+		)
+	}
+
+	message, err := s.chatMessage(ctx, userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	thread, err := s.activeChatThread(ctx, userID, message.ThreadID.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	// The referral text would only be repeated
+	input := s.safetyGuard().CheckInput(message.Message)
+	if input.Action == safety.ActionRefuse {
+		return nil, NewServiceError(
+			http.StatusConflict,
+			"This answer can't be regenerated",
+			nil,
+		)
+	}
+	if len(message.Variants) >= maxChatVariants {
+		return nil, NewServiceError(
+			http.StatusConflict,
+			fmt.Sprintf("An answer can be regenerated at most %d times", maxChatVariants),
+			nil,
+		)
+	}
+
+	ctx = withAIFeature(ctx, models.AIFeatureChat)
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+
+	request, err := s.buildChatMessages(ctx, userID, thread.ID, message.Message, true, input.Categories(), message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	response, actions, err := s.runChatTools(ctx, userID, thread.ID, request.messages)
+	if err != nil {
+		fmt.Printf("ERROR: AI REQUEST FAILED in RegenerateChatMessage: %v\n", err)
+		return nil, newAIRequestError(err)
+	}
+	response = s.enforceLanguage(ctx, request.messages, response, request.language, chatRetryPolicy)
+	guarded := s.guardChatResponse(ctx, userID, thread.ID, message.Message, input, request, response)
+
+	answeredAt := message.CreatedAt
+	if message.RegeneratedAt != nil {
+		answeredAt = *message.RegeneratedAt
+	}
+	now := time.Now()
+	message.Variants = append(message.Variants, models.ChatVariant{
+		Response:      message.Response,
+		PromptVersion: message.PromptVersion,
+		Feedback:      message.Feedback,
+		CreatedAt:     answeredAt,
+	})
+	message.Response = guarded.response
+	message.PromptVersion = request.promptID
+	message.Feedback = nil
+	message.RegeneratedAt = &now
+	if err := s.updateChatMessage(context.WithoutCancel(ctx), message); err != nil {
+		return nil, err
+	}
+
+	return &models.ChatResponse{
+		MessageID:      message.ID,
+		Response:       guarded.response,
+		PendingActions: actions,
+		Safety:         guarded.notice,
+	}, nil
+}
+
+// GetRatedChatExchanges exports rated answers, current ones and replaced
+// variants, for prompt tuning. rating "" exports both ratings.
+func (s *AIService) GetRatedChatExchanges(ctx context.Context, rating string, since time.Time, limit int) ([]models.RatedChatExchange, error) {
+	if rating != "" && rating != models.ChatRatingUp && rating != models.ChatRatingDown {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Rating must be 'up' or 'down'",
+			nil,
+		)
+	}
+	if limit <= 0 {
+		limit = defaultRatedExchangeLimit
+	}
+	if limit > maxRatedExchangeLimit {
+		limit = maxRatedExchangeLimit
+	}
+
+	messages, err := s.MongoDBRepo.GetRatedChatMessages(ctx, rating, since, limit)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get rated chat messages",
+			err,
+		)
+	}
+
+	exchanges := []models.RatedChatExchange{}
+	for _, message := range messages {
+		answers := append(slices.Clone(message.Variants), models.ChatVariant{
+			Response:      message.Response,
+			PromptVersion: message.PromptVersion,
+			Feedback:      message.Feedback,
+		})
+		for i, answer := range answers {
+			if answer.Feedback == nil || (rating != "" && answer.Feedback.Rating != rating) {
+				continue
+			}
+
+			var alternatives []string
+			for j, other := range answers {
+				if j != i {
+					alternatives = append(alternatives, other.Response)
+				}
+			}
+			exchanges = append(exchanges, models.RatedChatExchange{
+				MessageID:     message.ID,
+				UserID:        message.UserID,
+				ThreadID:      message.ThreadID,
+				Message:       message.Message,
+				Response:      answer.Response,
+				PromptVersion: answer.PromptVersion,
+				Rating:        answer.Feedback.Rating,
+				Reason:        answer.Feedback.Reason,
+				Current:       i == len(answers)-1,
+				Alternatives:  alternatives,
+				RatedAt:       answer.Feedback.CreatedAt,
+			})
+		}
+	}
+	return exchanges, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAIService_RateChatMessage(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{`{"content":"Three sets of ten."}`}, &requests)
+	mongoRepo := &mockMongoDBRepo{}
+	service := newToolTestService(server.URL, mongoRepo)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	response, err := service.Chat(ctx, "", "How many sets of squats?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.MessageID.IsZero() {
+		t.Fatal("Expected the response to carry the message ID")
+	}
+	messageID := response.MessageID.Hex()
+
+	testCases := []struct {
+		name      string
+		userID    int
+		messageID string
+		req       models.ChatFeedbackRequest
+		status    int
+	}{
+		{"thumbs down with reason", 1, messageID, models.ChatFeedbackRequest{Rating: "down", Reason: " too vague "}, 0},
+		{"invalid rating", 1, messageID, models.ChatFeedbackRequest{Rating: "meh"}, http.StatusBadRequest},
+		{"invalid ID", 1, "nope", models.ChatFeedbackRequest{Rating: "up"}, http.StatusBadRequest},
+		{"unknown message", 1, primitive.NewObjectID().Hex(), models.ChatFeedbackRequest{Rating: "up"}, http.StatusNotFound},
+		{"message of another user", 2, messageID, models.ChatFeedbackRequest{Rating: "up"}, http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, tc.userID)
+			message, err := service.RateChatMessage(ctx, tc.messageID, &tc.req)
+			if tc.status != 0 {
+				if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != tc.status {
+					t.Errorf("Expected status %d, got %v", tc.status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if message.Feedback == nil || message.Feedback.Rating != "down" || message.Feedback.Reason != "too vague" {
+				t.Errorf("Unexpected feedback %+v", message.Feedback)
+			}
+		})
+	}
+
+	if stored := mongoRepo.chatHistory[0].Feedback; stored == nil || stored.Rating != "down" {
+		t.Errorf("Expected the rating to be stored, got %+v", stored)
+	}
+}
+
+func TestAIService_RegenerateChatMessage(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{
+		`{"content":"Three sets of ten."}`,
+		`{"content":"Rest two minutes."}`,
+		`{"content":"Four sets of eight."}`,
+	}, &requests)
+	mongoRepo := &mockMongoDBRepo{}
+	service := newToolTestService(server.URL, mongoRepo)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	first, err := service.Chat(ctx, "", "How many sets of squats?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Chat(ctx, "", "How long should I rest?"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.RateChatMessage(ctx, first.MessageID.Hex(), &models.ChatFeedbackRequest{Rating: "down"}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := service.RegenerateChatMessage(ctx, first.MessageID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Response != "Four sets of eight." || response.MessageID != first.MessageID {
+		t.Errorf("Unexpected response %+v", response)
+	}
+
+	// The model sees the conversation as it was before the message
+	last := requests[len(requests)-1].Messages
+	if last[len(last)-1].Content != "How many sets of squats?" {
+		t.Errorf("Expected the original message last, got %+v", last[len(last)-1])
+	}
+	for _, message := range last {
+		if message.Content == "How long should I rest?" || message.Content == "Three sets of ten." {
+			t.Errorf("Expected later history and the old answer to be left out, got %+v", last)
+		}
+	}
+
+	message := mongoRepo.chatHistory[0]
+	if message.Response != "Four sets of eight." || message.Feedback != nil || message.RegeneratedAt == nil {
+		t.Errorf("Expected the new answer to be current, got %+v", message)
+	}
+	if len(message.Variants) != 1 || message.Variants[0].Response != "Three sets of ten." ||
+		message.Variants[0].Feedback == nil || message.Variants[0].Feedback.Rating != "down" {
+		t.Errorf("Expected the old answer and its rating as variant, got %+v", message.Variants)
+	}
+	if len(mongoRepo.chatHistory) != 2 {
+		t.Errorf("Expected no new history entry, got %d", len(mongoRepo.chatHistory))
+	}
+}
+
+func TestAIService_RegenerateChatMessage_Errors(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{`{"content":"Keep going."}`}, &requests)
+	mongoRepo := &mockMongoDBRepo{}
+	service := newToolTestService(server.URL, mongoRepo)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	refused, err := service.Chat(ctx, "", "I get chest pain when I run, should I push on?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exhausted, err := service.Chat(ctx, "", "How many sets of squats?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range maxChatVariants {
+		if _, err := service.RegenerateChatMessage(ctx, exhausted.MessageID.Hex()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		service   *AIService
+		messageID string
+		status    int
+	}{
+		{"refused message", service, refused.MessageID.Hex(), http.StatusConflict},
+		{"too many variants", service, exhausted.MessageID.Hex(), http.StatusConflict},
+		{"unknown message", service, primitive.NewObjectID().Hex(), http.StatusNotFound},
+		{"no AI client", &AIService{BaseService: service.BaseService}, exhausted.MessageID.Hex(), http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.service.RegenerateChatMessage(ctx, tc.messageID)
+			if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != tc.status {
+				t.Errorf("Expected status %d, got %v", tc.status, err)
+			}
+		})
+	}
+}
+
+func TestAIService_GetRatedChatExchanges(t *testing.T) {
+	threadID := primitive.NewObjectID()
+	rating := func(value string) *models.ChatFeedback {
+		return &models.ChatFeedback{Rating: value, CreatedAt: time.Now()}
+	}
+	mongoRepo := &mockMongoDBRepo{chatHistory: []models.ChatMessage{
+		{
+			ID: primitive.NewObjectID(), UserID: 1, ThreadID: threadID, Message: "Squats?",
+			Response: "Four sets.", Feedback: rating("up"), CreatedAt: time.Now(),
+			Variants: []models.ChatVariant{{Response: "Three sets.", Feedback: rating("down")}},
+		},
+		{ID: primitive.NewObjectID(), UserID: 2, ThreadID: threadID, Message: "Rest?", Response: "Two minutes.", CreatedAt: time.Now()},
+		{ID: primitive.NewObjectID(), UserID: 2, ThreadID: threadID, Message: "Plank?", Response: "Hold it.", Feedback: rating("down"), CreatedAt: time.Now()},
+	}}
+	service := &AIService{BaseService: BaseService{MongoDBRepo: mongoRepo}}
+
+	testCases := []struct {
+		name      string
+		rating    string
+		responses []string
+		status    int
+	}{
+		{"all ratings", "", []string{"Hold it.", "Three sets.", "Four sets."}, 0},
+		{"thumbs down", "down", []string{"Hold it.", "Three sets."}, 0},
+		{"thumbs up", "up", []string{"Four sets."}, 0},
+		{"invalid rating", "meh", nil, http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exchanges, err := service.GetRatedChatExchanges(context.Background(), tc.rating, time.Time{}, 0)
+			if tc.status != 0 {
+				if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != tc.status {
+					t.Errorf("Expected status %d, got %v", tc.status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(exchanges) != len(tc.responses) {
+				t.Fatalf("Expected %d exchanges, got %+v", len(tc.responses), exchanges)
+			}
+			for i, exchange := range exchanges {
+				if exchange.Response != tc.responses[i] {
+					t.Errorf("Expected '%s' at %d, got '%s'", tc.responses[i], i, exchange.Response)
+				}
+			}
+		})
+	}
+
+	exchanges, _ := service.GetRatedChatExchanges(context.Background(), "up", time.Time{}, 0)
+	if !exchanges[0].Current || len(exchanges[0].Alternatives) != 1 || exchanges[0].Alternatives[0] != "Three sets." {
+		t.Errorf("Expected the current answer with the replaced one as alternative, got %+v", exchanges[0])
+	}
+}
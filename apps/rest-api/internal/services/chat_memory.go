@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// chatRecentExchanges is the number of newest exchanges kept verbatim when the summary is extended
+	chatRecentExchanges = 10
+	// chatSummaryBatch is the number of further exchanges that pile up before they are summarized
+	chatSummaryBatch = 10
+
+	// defaultChatContextTokens is assumed for models without a context length in the catalog
+	defaultChatContextTokens = 8192
+	// maxChatInputTokens caps the prompt size of large-context models to keep chat cheap
+	maxChatInputTokens = 16000
+	minChatInputTokens = 1024
+)
+
+var summaryRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   1 * time.Second,
+	MaxDelay:    5 * time.Second,
+	Budget:      90 * time.Second,
+}
+
+// chatBudget splits the prompt tokens of a chat request
+type chatBudget struct {
+	// input is the total prompt size: system prompt, summary, history and message
+	input int
+	// summary bounds the length of the rolling summary
+	summary int
+}
+
+// chatBudget derives the token budget from the primary model's context
+// length, leaving room for the completion
+func (s *AIService) chatBudget() chatBudget {
+	contextLength, completion := defaultChatContextTokens, 0
+	if s.Client != nil {
+		if routed := s.Client.Router().Models(); len(routed) > 0 {
+			if model, ok := s.Client.Catalog().Find(routed[0]); ok {
+				if model.ContextLength > 0 {
+					contextLength = model.ContextLength
+				}
+				completion = model.MaxTokens
+			}
+		}
+	}
+
+	input := min(max(contextLength-completion, minChatInputTokens), maxChatInputTokens)
+	return chatBudget{
+		input:   input,
+		summary: min(max(input/8, 150), 1000),
+	}
+}
+
+func exchangeTokens(message models.ChatMessage) int {
+	return estimateTokens(message.Message) + estimateTokens(message.Response) + 8
+}
+
+// unsummarizedHistory returns the messages newer than the summary
+func unsummarizedHistory(history []models.ChatMessage, summary *models.ChatSummary) []models.ChatMessage {
+	if summary == nil {
+		return history
+	}
+	for i, message := range history {
+		if message.CreatedAt.After(summary.SummarizedUntil) {
+			return history[i:]
+		}
+	}
+	return nil
+}
+
+// recentHistory returns the newest messages, at most limit, that fit into tokens
+func recentHistory(history []models.ChatMessage, limit, tokens int) []models.ChatMessage {
+	start := len(history)
+	for start > 0 && len(history)-start < limit {
+		cost := exchangeTokens(history[start-1])
+		if cost > tokens {
+			break
+		}
+		tokens -= cost
+		start--
+	}
+	return history[start:]
+}
+
+// summaryDue reports whether enough unsummarized history piled up to extend
+// the summary, or whether it no longer fits into the history budget
+func summaryDue(unsummarized []models.ChatMessage, historyTokens int) bool {
+	if len(unsummarized) >= chatRecentExchanges+chatSummaryBatch {
+		return true
+	}
+	return len(recentHistory(unsummarized, len(unsummarized), historyTokens)) < len(unsummarized)
+}
+
+// truncateToTokens shortens text to about tokens, cutting after the last full sentence
+func truncateToTokens(text string, tokens int) string {
+	limit := tokens * 4
+	if len(text) <= limit {
+		return text
+	}
+	cut := text[:limit]
+	if i := strings.LastIndexAny(cut, ".!?"); i > limit/2 {
+		return cut[:i+1]
+	}
+	return strings.TrimSpace(cut) + "..."
+}
+
+// startChatSummary extends the thread's summary in the background. Only one
+// update per thread runs at a time.
+func (s *AIService) startChatSummary(ctx context.Context, userID int, threadID primitive.ObjectID) {
+	if _, running := s.summarizing.LoadOrStore(threadID, true); running {
+		return
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		defer s.summarizing.Delete(threadID)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*summaryRetryPolicy.Budget)
+		defer cancel()
+		if err := s.updateChatSummary(ctx, userID, threadID); err != nil {
+			fmt.Printf("Failed to update chat summary for user %d, thread %s: %v\n", userID, threadID.Hex(), err)
+		}
+	}()
+}
+
+// updateChatSummary folds all but the newest exchanges of the thread that are
+// not yet summarized into the stored summary
+func (s *AIService) updateChatSummary(ctx context.Context, userID int, threadID primitive.ObjectID) error {
+	history, err := s.MongoDBRepo.GetChatHistory(ctx, userID, threadID)
+	if err != nil {
+		return err
+	}
+	summary, err := s.MongoDBRepo.GetChatSummary(ctx, userID, threadID)
+	if err != nil {
+		return err
+	}
+
+	budget := s.chatBudget()
+	// Half of the input is left for the system prompt, summary and new message
+	historyTokens := budget.input / 2
+	unsummarized := unsummarizedHistory(history, summary)
+	if !summaryDue(unsummarized, historyTokens) {
+		return nil
+	}
+
+	// Keep the newest exchanges verbatim, but only as many as comfortably fit
+	keep := recentHistory(unsummarized, chatRecentExchanges, historyTokens/2)
+	fold := unsummarized[:len(unsummarized)-len(keep)]
+	if len(fold) == 0 {
+		return nil
+	}
+
+	ctx = withAIFeature(ctx, models.AIFeatureSummary)
+	if err := s.checkQuota(ctx); err != nil {
+		return err
+	}
+
+	prompt, err := s.selectPrompt(promptSummary, userID)
+	if err != nil {
+		return err
+	}
+
+	data := summaryPromptData{Messages: fold, MaxWords: budget.summary * 3 / 4}
+	if summary != nil {
+		data.Summary = summary.Summary
+	} else {
+		summary = &models.ChatSummary{UserID: userID, ThreadID: threadID}
+	}
+	messages, err := renderPrompt(prompt, data, "system", "user")
+	if err != nil {
+		return err
+	}
+
+	response, err := s.Client.CreateChatCompletionWithPolicy(ctx, messages, false, summaryRetryPolicy)
+	if err != nil {
+		return err
+	}
+
+	summary.Summary = truncateToTokens(strings.TrimSpace(response), budget.summary)
+	summary.SummarizedUntil = fold[len(fold)-1].CreatedAt
+	summary.MessageCount += len(fold)
+	summary.PromptVersion = prompt.ID()
+	summary.UpdatedAt = time.Now()
+	return s.MongoDBRepo.SaveChatSummary(ctx, summary)
+}
+
+// WaitBackground blocks until background work such as summary updates has
+// finished or ctx is done
+func (s *AIService) WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
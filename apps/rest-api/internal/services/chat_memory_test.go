@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rest-api/internal/config"
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+func testHistory(n int) []models.ChatMessage {
+	start := time.Now().Add(-time.Hour)
+	history := make([]models.ChatMessage, n)
+	for i := range history {
+		history[i] = models.ChatMessage{
+			UserID:    1,
+			Message:   fmt.Sprintf("question %d", i),
+			Response:  fmt.Sprintf("answer %d", i),
+			IsUser:    true,
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	return history
+}
+
+func TestAIService_ChatBudget(t *testing.T) {
+	testCases := []struct {
+		name     string
+		model    config.AIModel
+		expected chatBudget
+	}{
+		{"small context", config.AIModel{ContextLength: 8192, MaxTokens: 4096}, chatBudget{input: 4096, summary: 512}},
+		{"large context is capped", config.AIModel{ContextLength: 163840, MaxTokens: 8192}, chatBudget{input: maxChatInputTokens, summary: 1000}},
+		{"completion fills the context", config.AIModel{ContextLength: 4096, MaxTokens: 4096}, chatBudget{input: minChatInputTokens, summary: 150}},
+		{"unknown context length", config.AIModel{}, chatBudget{input: defaultChatContextTokens, summary: 1000}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newTestClient("http://unused")
+			tc.model.ID, tc.model.Priority, tc.model.Enabled = "model-a", 1, true
+			client.SetCatalog(&config.ModelCatalog{Models: []config.AIModel{tc.model}})
+			service := &AIService{Client: client}
+
+			if budget := service.chatBudget(); budget != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, budget)
+			}
+		})
+	}
+}
+
+func TestChatHistorySelection(t *testing.T) {
+	history := testHistory(25)
+
+	summary := &models.ChatSummary{SummarizedUntil: history[14].CreatedAt}
+	unsummarized := unsummarizedHistory(history, summary)
+	if len(unsummarized) != 10 || unsummarized[0].Message != "question 15" {
+		t.Errorf("Expected the 10 messages after the summary, got %d", len(unsummarized))
+	}
+	if len(unsummarizedHistory(history, nil)) != 25 {
+		t.Error("Expected the whole history without a summary")
+	}
+
+	recent := recentHistory(history, 10, 1000)
+	if len(recent) != 10 || recent[9].Message != "question 24" {
+		t.Errorf("Expected the newest 10 messages, got %d", len(recent))
+	}
+	if recent := recentHistory(history, 10, 3*exchangeTokens(history[24])); len(recent) != 3 {
+		t.Errorf("Expected the token budget to limit the history to 3 messages, got %d", len(recent))
+	}
+
+	if summaryDue(history[:19], 10000) {
+		t.Error("Expected no summary below the batch size")
+	}
+	if !summaryDue(history[:20], 10000) {
+		t.Error("Expected a summary once the batch is full")
+	}
+	if !summaryDue(history[:5], 2*exchangeTokens(history[0])) {
+		t.Error("Expected a summary when the history overflows the budget")
+	}
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	if text := truncateToTokens("Short.", 10); text != "Short." {
+		t.Errorf("Expected short text unchanged, got '%s'", text)
+	}
+	text := truncateToTokens("The user wants to lose weight. The user has knee pain and avoids running.", 10)
+	if text != "The user wants to lose weight." {
+		t.Errorf("Expected the text cut after the first sentence, got '%s'", text)
+	}
+}
+
+// memoryServer answers summary requests with summary and chat requests with "Sure."
+func memoryServer(t *testing.T, summary string, requests *[]OpenRouterRequest) *httptest.Server {
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenRouterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		mu.Lock()
+		*requests = append(*requests, req)
+		mu.Unlock()
+
+		content := "Sure."
+		if strings.Contains(req.Messages[0].Content, "long-term memory") {
+			content = summary
+		}
+		encoded, _ := json.Marshal(content)
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%s}}]}`, encoded)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAIService_Chat_UsesSummary(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := memoryServer(t, "", &requests)
+
+	history := testHistory(15)
+	mongoRepo := &mockMongoDBRepo{
+		chatHistory: history,
+		chatSummary: &models.ChatSummary{UserID: 1, Summary: "The user has knee pain.", SummarizedUntil: history[9].CreatedAt},
+	}
+	service := &AIService{
+		BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo},
+		Client:      newTestClient(server.URL, "model-a"),
+	}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	if _, err := service.Chat(ctx, "", "Can I squat?"); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := requests[0].Messages
+	if !strings.Contains(messages[0].Content, "The user has knee pain.") {
+		t.Errorf("Expected the summary in the system prompt, got: %s", messages[0].Content)
+	}
+	// System prompt, 5 unsummarized exchanges and the new message
+	if len(messages) != 1+2*5+1 || messages[1].Content != "question 10" {
+		t.Errorf("Expected only the unsummarized history, got %d messages starting with '%s'", len(messages), messages[1].Content)
+	}
+}
+
+func TestAIService_Chat_ExtendsSummaryInBackground(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := memoryServer(t, "The user trains for a marathon.", &requests)
+
+	history := testHistory(20)
+	mongoRepo := &mockMongoDBRepo{chatHistory: history}
+	service := &AIService{
+		BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo},
+		Client:      newTestClient(server.URL, "model-a"),
+	}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	if _, err := service.Chat(ctx, "", "What about tapering?"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.WaitBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := mongoRepo.chatSummary
+	if summary == nil {
+		t.Fatal("Expected a chat summary to be saved")
+	}
+	// 21 messages now exist, the newest 10 stay verbatim
+	if summary.Summary != "The user trains for a marathon." || summary.MessageCount != 11 || summary.PromptVersion != "summary/v1" {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if !summary.SummarizedUntil.Equal(history[10].CreatedAt) {
+		t.Errorf("Expected the summary to end at message 10, got %v", summary.SummarizedUntil)
+	}
+
+	summaryRequest := requests[len(requests)-1].Messages[1].Content
+	if !strings.Contains(summaryRequest, "question 0") || !strings.Contains(summaryRequest, "answer 10") || strings.Contains(summaryRequest, "question 11") {
+		t.Errorf("Expected messages 0-10 to be summarized, got: %s", summaryRequest)
+	}
+
+	// The next update builds on the stored summary
+	threadID := summary.ThreadID
+	mongoRepo.chatHistory = append(mongoRepo.chatHistory, testHistory(20)...)
+	for i := 21; i < len(mongoRepo.chatHistory); i++ {
+		mongoRepo.chatHistory[i].ThreadID = threadID
+		mongoRepo.chatHistory[i].CreatedAt = time.Now().Add(time.Duration(i) * time.Second)
+	}
+	if err := service.updateChatSummary(context.Background(), 1, threadID); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(requests[len(requests)-1].Messages[1].Content, "The user trains for a marathon.") {
+		t.Error("Expected the previous summary to be sent for an incremental update")
+	}
+	if mongoRepo.chatSummary.MessageCount != 31 {
+		t.Errorf("Expected 31 summarized messages, got %d", mongoRepo.chatSummary.MessageCount)
+	}
+}
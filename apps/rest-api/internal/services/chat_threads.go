@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// newChatThreadTitle is used when a thread is created without a title
+	newChatThreadTitle    = "New conversation"
+	maxChatThreadTitleLen = 100
+)
+
+func chatThreadTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", NewServiceError(
+			http.StatusBadRequest,
+			"Thread title must not be empty",
+			nil,
+		)
+	}
+	if len([]rune(title)) > maxChatThreadTitleLen {
+		return "", NewServiceError(
+			http.StatusBadRequest,
+			"Thread title must be at most 100 characters",
+			nil,
+		)
+	}
+	return title, nil
+}
+
+// defaultChatThread returns the user's default thread, creating it and moving
+// older messages into it on first use
+func (s *AIService) defaultChatThread(ctx context.Context, userID int) (*models.ChatThread, error) {
+	thread, err := s.MongoDBRepo.EnsureDefaultChatThread(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get default chat thread",
+			err,
+		)
+	}
+	return thread, nil
+}
+
+// chatThread looks up a thread of the user, an empty threadID selects the default thread
+func (s *AIService) chatThread(ctx context.Context, userID int, threadID string) (*models.ChatThread, error) {
+	if threadID == "" {
+		return s.defaultChatThread(ctx, userID)
+	}
+
+	id, err := primitive.ObjectIDFromHex(threadID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Invalid thread ID format",
+			err,
+		)
+	}
+
+	thread, err := s.MongoDBRepo.GetChatThread(ctx, userID, id)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get chat thread",
+			err,
+		)
+	}
+	if thread == nil {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Chat thread not found",
+			nil,
+		)
+	}
+	return thread, nil
+}
+
+// activeChatThread is the thread a new message goes to. Archived threads are read-only.
+func (s *AIService) activeChatThread(ctx context.Context, userID int, threadID string) (*models.ChatThread, error) {
+	thread, err := s.chatThread(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	if thread.Archived {
+		return nil, NewServiceError(
+			http.StatusConflict,
+			"Chat thread is archived, unarchive it to continue the conversation",
+			nil,
+		)
+	}
+	return thread, nil
+}
+
+// CreateChatThread starts a new conversation for the current user
+func (s *AIService) CreateChatThread(ctx context.Context, req *models.CreateChatThreadRequest) (*models.ChatThread, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	title := newChatThreadTitle
+	if strings.TrimSpace(req.Title) != "" {
+		if title, err = chatThreadTitle(req.Title); err != nil {
+			return nil, err
+		}
+	}
+
+	// Older messages must land in the default thread before the user has several to choose from
+	if _, err := s.defaultChatThread(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	thread := &models.ChatThread{
+		UserID:        userID,
+		Title:         title,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		LastMessageAt: now,
+	}
+	if err := s.MongoDBRepo.CreateChatThread(ctx, thread); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to create chat thread",
+			err,
+		)
+	}
+	return thread, nil
+}
+
+// GetChatThreads lists the user's threads, most recently active first
+func (s *AIService) GetChatThreads(ctx context.Context, includeArchived bool) ([]models.ChatThread, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.defaultChatThread(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	threads, err := s.MongoDBRepo.GetChatThreads(ctx, userID, includeArchived)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get chat threads",
+			err,
+		)
+	}
+	return threads, nil
+}
+
+// UpdateChatThread renames or (un)archives a thread. The default thread cannot be archived.
+func (s *AIService) UpdateChatThread(ctx context.Context, threadID string, req *models.UpdateChatThreadRequest) (*models.ChatThread, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	thread, err := s.chatThread(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != nil {
+		if thread.Title, err = chatThreadTitle(*req.Title); err != nil {
+			return nil, err
+		}
+	}
+	if req.Archived != nil {
+		if *req.Archived && thread.IsDefault {
+			return nil, NewServiceError(
+				http.StatusBadRequest,
+				"The default chat thread cannot be archived",
+				nil,
+			)
+		}
+		thread.Archived = *req.Archived
+	}
+
+	thread.UpdatedAt = time.Now()
+	if err := s.MongoDBRepo.UpdateChatThread(ctx, thread); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NewServiceError(
+				http.StatusNotFound,
+				"Chat thread not found",
+				err,
+			)
+		}
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to update chat thread",
+			err,
+		)
+	}
+	return thread, nil
+}
+
+// DeleteChatThread removes a thread with all its messages. The default thread cannot be deleted.
+func (s *AIService) DeleteChatThread(ctx context.Context, threadID string) error {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	thread, err := s.chatThread(ctx, userID, threadID)
+	if err != nil {
+		return err
+	}
+	if thread.IsDefault {
+		return NewServiceError(
+			http.StatusBadRequest,
+			"The default chat thread cannot be deleted",
+			nil,
+		)
+	}
+
+	if err := s.MongoDBRepo.DeleteChatThread(ctx, userID, thread.ID); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return NewServiceError(
+				http.StatusNotFound,
+				"Chat thread not found",
+				err,
+			)
+		}
+		return NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to delete chat thread",
+			err,
+		)
+	}
+	return nil
+}
+
+// GetChatHistory returns the messages of a thread, an empty threadID selects the default thread
+func (s *AIService) GetChatHistory(ctx context.Context, threadID string) ([]models.ChatMessage, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	thread, err := s.chatThread(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.MongoDBRepo.GetChatHistory(ctx, userID, thread.ID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get chat history",
+			err,
+		)
+	}
+	return history, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+func TestAIService_ChatThreads(t *testing.T) {
+	mongoRepo := &mockMongoDBRepo{chatHistory: testHistory(3)}
+	service := &AIService{BaseService: BaseService{MongoDBRepo: mongoRepo}}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	threads, err := service.GetChatThreads(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || !threads[0].IsDefault || threads[0].Title != models.DefaultChatThreadTitle {
+		t.Fatalf("Expected the default thread to be created, got %+v", threads)
+	}
+
+	// Messages from before threads existed are moved into the default thread
+	history, err := service.GetChatHistory(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].ThreadID != threads[0].ID {
+		t.Errorf("Expected 3 migrated messages in the default thread, got %d", len(history))
+	}
+
+	thread, err := service.CreateChatThread(ctx, &models.CreateChatThreadRequest{Title: "  Marathon prep "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thread.Title != "Marathon prep" || thread.IsDefault {
+		t.Errorf("Unexpected thread %+v", thread)
+	}
+
+	history, err = service.GetChatHistory(ctx, thread.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Errorf("Expected a new thread to be empty, got %d messages", len(history))
+	}
+
+	title, archived := "Half marathon", true
+	updated, err := service.UpdateChatThread(ctx, thread.ID.Hex(), &models.UpdateChatThreadRequest{Title: &title, Archived: &archived})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != title || !updated.Archived {
+		t.Errorf("Unexpected thread %+v", updated)
+	}
+
+	if threads, _ := service.GetChatThreads(ctx, false); len(threads) != 1 {
+		t.Errorf("Expected archived threads to be hidden, got %d threads", len(threads))
+	}
+	if threads, _ := service.GetChatThreads(ctx, true); len(threads) != 2 {
+		t.Errorf("Expected archived threads to be listed on request, got %d threads", len(threads))
+	}
+
+	if err := service.DeleteChatThread(ctx, thread.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if threads, _ := service.GetChatThreads(ctx, true); len(threads) != 1 {
+		t.Errorf("Expected the thread to be deleted, got %d threads", len(threads))
+	}
+}
+
+func TestAIService_ChatThreadErrors(t *testing.T) {
+	mongoRepo := &mockMongoDBRepo{}
+	service := &AIService{
+		BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo},
+		Client:      newTestClient("http://unused", "model-a"),
+	}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	defaultThread, err := mongoRepo.EnsureDefaultChatThread(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived := &models.ChatThread{UserID: 1, Title: "Old", Archived: true}
+	otherUser := &models.ChatThread{UserID: 2, Title: "Not mine"}
+	for _, thread := range []*models.ChatThread{archived, otherUser} {
+		if err := mongoRepo.CreateChatThread(ctx, thread); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	yes, empty, long := true, " ", strings.Repeat("a", 101)
+	testCases := []struct {
+		name     string
+		call     func() error
+		expected int
+	}{
+		{"invalid thread ID", func() error {
+			_, err := service.GetChatHistory(ctx, "not-an-id")
+			return err
+		}, http.StatusBadRequest},
+		{"thread of another user", func() error {
+			_, err := service.GetChatHistory(ctx, otherUser.ID.Hex())
+			return err
+		}, http.StatusNotFound},
+		{"chat in archived thread", func() error {
+			_, err := service.Chat(ctx, archived.ID.Hex(), "Hi")
+			return err
+		}, http.StatusConflict},
+		{"empty title", func() error {
+			_, err := service.UpdateChatThread(ctx, archived.ID.Hex(), &models.UpdateChatThreadRequest{Title: &empty})
+			return err
+		}, http.StatusBadRequest},
+		{"title too long", func() error {
+			_, err := service.CreateChatThread(ctx, &models.CreateChatThreadRequest{Title: long})
+			return err
+		}, http.StatusBadRequest},
+		{"archive default thread", func() error {
+			_, err := service.UpdateChatThread(ctx, defaultThread.ID.Hex(), &models.UpdateChatThreadRequest{Archived: &yes})
+			return err
+		}, http.StatusBadRequest},
+		{"delete default thread", func() error {
+			return service.DeleteChatThread(ctx, defaultThread.ID.Hex())
+		}, http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.call()
+			svcErr, ok := err.(ServiceError)
+			if !ok || svcErr.Code != tc.expected {
+				t.Errorf("Expected status %d, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestAIService_Chat_UsesThreadHistory(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := memoryServer(t, "", &requests)
+
+	mongoRepo := &mockMongoDBRepo{chatHistory: testHistory(4)}
+	service := &AIService{
+		BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo},
+		Client:      newTestClient(server.URL, "model-a"),
+	}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	thread, err := service.CreateChatThread(ctx, &models.CreateChatThreadRequest{Title: "Nutrition"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Chat(ctx, thread.ID.Hex(), "What should I eat?"); err != nil {
+		t.Fatal(err)
+	}
+	// System prompt and the new message, none of the default thread's history
+	if messages := requests[0].Messages; len(messages) != 2 {
+		t.Errorf("Expected only the new thread's history, got %d messages", len(messages))
+	}
+
+	if _, err := service.Chat(ctx, "", "And before a run?"); err != nil {
+		t.Fatal(err)
+	}
+	if messages := requests[1].Messages; len(messages) != 1+2*4+1 {
+		t.Errorf("Expected the default thread's history, got %d messages", len(messages))
+	}
+
+	history, err := service.GetChatHistory(ctx, thread.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Message != "What should I eat?" {
+		t.Errorf("Expected the message to be saved in its thread, got %+v", history)
+	}
+}
@@ -0,0 +1,562 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tools of the chat coach. Read tools run right away, the plan changes are
+// only proposed and wait for the user's confirmation.
+const (
+	toolGetProfile          = "get_profile"
+	toolGetUpcomingWorkouts = "get_upcoming_workouts"
+	toolGetProgress         = "get_progress"
+	toolReplaceExercise     = models.ChatActionReplaceExercise
+	toolRescheduleWorkout   = models.ChatActionRescheduleWorkout
+
+	// maxToolRounds bounds the tool calls of one chat message, the model has to answer after that
+	maxToolRounds = 4
+	// upcomingWorkoutsLimit is the number of planned workouts get_upcoming_workouts returns
+	upcomingWorkoutsLimit = 7
+	// chatActionTTL is how long a proposed change can be confirmed
+	chatActionTTL = 24 * time.Hour
+
+	toolDateLayout = "2006-01-02"
+)
+
+var chatTools = []OpenRouterTool{
+	chatTool(toolGetProfile,
+		"Get the user's fitness profile: goal, level, age, height, weight, health issues, equipment and available minutes per workout.",
+		`{"type":"object","properties":{}}`),
+	chatTool(toolGetUpcomingWorkouts,
+		"Get today's date and the user's next planned workouts with their IDs, dates and exercises.",
+		`{"type":"object","properties":{}}`),
+	chatTool(toolGetProgress,
+		"Get the user's progress: total workouts, current streak and level.",
+		`{"type":"object","properties":{}}`),
+	chatTool(toolReplaceExercise,
+		"Propose replacing an exercise of a planned workout. The user has to confirm the change before it is applied.",
+		`{"type":"object","properties":{
+			"workout_id":{"type":"string","description":"ID from get_upcoming_workouts"},
+			"exercise_name":{"type":"string","description":"Name of the exercise to replace"},
+			"new_exercise":{"type":"object","properties":{
+				"name":{"type":"string"},
+				"muscle_group":{"type":"string"},
+				"sets":{"type":"integer","description":"Defaults to the replaced exercise"},
+				"reps":{"type":"integer","description":"Defaults to the replaced exercise"},
+				"rest_sec":{"type":"integer","description":"Defaults to the replaced exercise"},
+				"notes":{"type":"string"}
+			},"required":["name","muscle_group"]}
+		},"required":["workout_id","exercise_name","new_exercise"]}`),
+	chatTool(toolRescheduleWorkout,
+		"Propose moving a planned workout to another day. The user has to confirm the change before it is applied.",
+		`{"type":"object","properties":{
+			"workout_id":{"type":"string","description":"ID from get_upcoming_workouts"},
+			"date":{"type":"string","description":"New date as YYYY-MM-DD, today or later"}
+		},"required":["workout_id","date"]}`),
+}
+
+func chatTool(name, description, parameters string) OpenRouterTool {
+	return OpenRouterTool{
+		Type: "function",
+		Function: OpenRouterToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters:  json.RawMessage(parameters),
+		},
+	}
+}
+
+type replaceExerciseArgs struct {
+	WorkoutID    string          `json:"workout_id"`
+	ExerciseName string          `json:"exercise_name"`
+	NewExercise  models.Exercise `json:"new_exercise"`
+}
+
+type rescheduleWorkoutArgs struct {
+	WorkoutID string `json:"workout_id"`
+	Date      string `json:"date"`
+}
+
+// toolWorkout is the compact view of a workout shown to the model
+type toolWorkout struct {
+	WorkoutID string         `json:"workout_id"`
+	Name      string         `json:"name"`
+	Date      string         `json:"date"`
+	Weekday   string         `json:"weekday"`
+	Exercises []toolExercise `json:"exercises"`
+}
+
+type toolExercise struct {
+	Name        string `json:"name"`
+	MuscleGroup string `json:"muscle_group"`
+	Sets        int    `json:"sets"`
+	Reps        int    `json:"reps"`
+}
+
+// toolError is returned to the model so it can correct the call or explain the problem
+type toolError struct {
+	Error string `json:"error"`
+}
+
+// runChatTools asks the model until it answers with content, executing the
+// tools it calls in between. Proposed plan changes are returned as pending actions.
+func (s *AIService) runChatTools(ctx context.Context, userID int, threadID primitive.ObjectID, messages []OpenRouterMessage) (string, []models.ChatAction, error) {
+	messages = append([]OpenRouterMessage(nil), messages...)
+	var actions []models.ChatAction
+
+	for round := 0; ; round++ {
+		tools := chatTools
+		if round == maxToolRounds {
+			tools = nil
+		}
+
+		reply, err := s.Client.CreateChatCompletionWithTools(ctx, messages, tools, chatRetryPolicy)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(reply.ToolCalls) == 0 || tools == nil {
+			return reply.Content, actions, nil
+		}
+
+		messages = append(messages, *reply)
+		for _, call := range reply.ToolCalls {
+			result, action := s.callChatTool(ctx, userID, threadID, call)
+			if action != nil {
+				actions = append(actions, *action)
+			}
+			content, _ := json.Marshal(result)
+			messages = append(messages, OpenRouterMessage{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Content:    string(content),
+			})
+		}
+	}
+}
+
+// callChatTool executes one tool call and returns its result for the model
+func (s *AIService) callChatTool(ctx context.Context, userID int, threadID primitive.ObjectID, call OpenRouterToolCall) (any, *models.ChatAction) {
+	arguments := call.Function.Arguments
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+
+	switch call.Function.Name {
+	case toolGetProfile:
+		profile, err := s.Repo.GetFitnessProfile(ctx, userID)
+		if err != nil || profile == nil {
+			return toolError{Error: "the user has no fitness profile yet"}, nil
+		}
+		return profile, nil
+
+	case toolGetUpcomingWorkouts:
+		workouts, err := s.upcomingWorkouts(ctx, userID)
+		if err != nil {
+			return toolError{Error: err.Error()}, nil
+		}
+		now := time.Now()
+		return map[string]any{
+			"today":    now.Format(toolDateLayout),
+			"weekday":  now.Weekday().String(),
+			"workouts": workouts,
+		}, nil
+
+	case toolGetProgress:
+		progress, err := s.MongoDBRepo.GetUserProgress(ctx, userID)
+		if err != nil || progress == nil {
+			return toolError{Error: "no progress recorded yet"}, nil
+		}
+		return progress, nil
+
+	case toolReplaceExercise:
+		var args replaceExerciseArgs
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return toolError{Error: "invalid arguments: " + err.Error()}, nil
+		}
+		action, err := s.replaceExerciseAction(ctx, userID, args)
+		if err != nil {
+			return toolError{Error: err.Error()}, nil
+		}
+		return s.proposeChatAction(ctx, userID, threadID, action)
+
+	case toolRescheduleWorkout:
+		var args rescheduleWorkoutArgs
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return toolError{Error: "invalid arguments: " + err.Error()}, nil
+		}
+		action, err := s.rescheduleWorkoutAction(ctx, userID, args)
+		if err != nil {
+			return toolError{Error: err.Error()}, nil
+		}
+		return s.proposeChatAction(ctx, userID, threadID, action)
+
+	default:
+		return toolError{Error: fmt.Sprintf("unknown tool %q", call.Function.Name)}, nil
+	}
+}
+
+// upcomingWorkouts returns the next planned workouts, soonest first
+func (s *AIService) upcomingWorkouts(ctx context.Context, userID int) ([]toolWorkout, error) {
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the workout plan")
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("the user has no workout plan yet")
+	}
+
+	var planned []models.Workout
+	for _, workout := range plan.Workouts {
+		if workout.Status == "planned" {
+			planned = append(planned, workout)
+		}
+	}
+	sort.Slice(planned, func(i, j int) bool {
+		return planned[i].ScheduledDate.Before(planned[j].ScheduledDate)
+	})
+
+	workouts := make([]toolWorkout, 0, upcomingWorkoutsLimit)
+	for _, workout := range planned[:min(len(planned), upcomingWorkoutsLimit)] {
+		view := toolWorkout{
+			WorkoutID: workout.WorkoutID.Hex(),
+			Name:      workout.Name,
+			Date:      workout.ScheduledDate.Format(toolDateLayout),
+			Weekday:   workout.ScheduledDate.Weekday().String(),
+		}
+		for _, exercise := range workout.Exercises {
+			view.Exercises = append(view.Exercises, toolExercise{
+				Name:        exercise.Name,
+				MuscleGroup: exercise.MuscleGroup,
+				Sets:        exercise.Sets,
+				Reps:        exercise.Reps,
+			})
+		}
+		workouts = append(workouts, view)
+	}
+	return workouts, nil
+}
+
+// plannedWorkout finds a workout of the user's plan that can still be changed
+func plannedWorkout(plan *models.WorkoutPlan, workoutID string) (*models.Workout, error) {
+	if plan == nil {
+		return nil, fmt.Errorf("the user has no workout plan yet")
+	}
+	for i := range plan.Workouts {
+		workout := &plan.Workouts[i]
+		if workout.WorkoutID.Hex() != workoutID {
+			continue
+		}
+		if workout.Status != "planned" {
+			return nil, fmt.Errorf("workout %q is %s and can no longer be changed", workout.Name, workout.Status)
+		}
+		return workout, nil
+	}
+	return nil, fmt.Errorf("workout %s not found, use get_upcoming_workouts for valid IDs", workoutID)
+}
+
+// exerciseIndex finds an exercise of the workout by name, ignoring case
+func exerciseIndex(workout *models.Workout, name string) int {
+	for i, exercise := range workout.Exercises {
+		if strings.EqualFold(strings.TrimSpace(exercise.Name), strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// replaceExerciseAction validates a replace_exercise call against the current plan
+func (s *AIService) replaceExerciseAction(ctx context.Context, userID int, args replaceExerciseArgs) (*models.ChatAction, error) {
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the workout plan")
+	}
+	workout, err := plannedWorkout(plan, args.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+	i := exerciseIndex(workout, args.ExerciseName)
+	if i < 0 {
+		return nil, fmt.Errorf("workout %q has no exercise %q", workout.Name, args.ExerciseName)
+	}
+
+	// Unspecified volume is taken over from the replaced exercise
+	old := workout.Exercises[i]
+	exercise := args.NewExercise
+	exercise.Name = strings.TrimSpace(exercise.Name)
+	if exercise.Sets == 0 {
+		exercise.Sets = old.Sets
+	}
+	if exercise.Reps == 0 {
+		exercise.Reps = old.Reps
+	}
+	if exercise.RestSec == 0 {
+		exercise.RestSec = old.RestSec
+	}
+	if problems := validateExercise("new_exercise", exercise); len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return &models.ChatAction{
+		Type:    models.ChatActionReplaceExercise,
+		Summary: fmt.Sprintf("Replace %s with %s in %s on %s", old.Name, exercise.Name, workout.Name, workout.ScheduledDate.Format("Monday, Jan 2")),
+		Params: models.ChatActionParams{
+			WorkoutID:    args.WorkoutID,
+			ExerciseName: old.Name,
+			NewExercise:  &exercise,
+		},
+	}, nil
+}
+
+// startOfDay returns midnight of the day of t in loc. Workouts are scheduled
+// at midnight, so dates are compared by calendar day.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// rescheduleWorkoutAction validates a reschedule_workout call against the current plan
+func (s *AIService) rescheduleWorkoutAction(ctx context.Context, userID int, args rescheduleWorkoutArgs) (*models.ChatAction, error) {
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the workout plan")
+	}
+	workout, err := plannedWorkout(plan, args.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	day, err := time.ParseInLocation(toolDateLayout, args.Date, workout.ScheduledDate.Location())
+	if err != nil {
+		return nil, fmt.Errorf("date must be YYYY-MM-DD")
+	}
+	// The workout keeps its time of day
+	scheduled := workout.ScheduledDate
+	date := time.Date(day.Year(), day.Month(), day.Day(), scheduled.Hour(), scheduled.Minute(), scheduled.Second(), 0, scheduled.Location())
+	today := startOfDay(time.Now(), scheduled.Location())
+	if date.Before(today) {
+		return nil, fmt.Errorf("date %s is in the past, today is %s", args.Date, today.Format(toolDateLayout))
+	}
+
+	return &models.ChatAction{
+		Type:    models.ChatActionRescheduleWorkout,
+		Summary: fmt.Sprintf("Move %s from %s to %s", workout.Name, scheduled.Format("Monday, Jan 2"), date.Format("Monday, Jan 2")),
+		Params: models.ChatActionParams{
+			WorkoutID:     args.WorkoutID,
+			ScheduledDate: &date,
+		},
+	}, nil
+}
+
+// proposeChatAction stores a validated change as pending and tells the model to ask for confirmation
+func (s *AIService) proposeChatAction(ctx context.Context, userID int, threadID primitive.ObjectID, action *models.ChatAction) (any, *models.ChatAction) {
+	now := time.Now()
+	action.UserID = userID
+	action.ThreadID = threadID
+	action.Status = models.ChatActionPending
+	action.CreatedAt = now
+	action.ExpiresAt = now.Add(chatActionTTL)
+	if err := s.MongoDBRepo.SaveChatAction(ctx, action); err != nil {
+		return toolError{Error: "failed to save the proposed change"}, nil
+	}
+
+	return map[string]string{
+		"status":  "awaiting_user_confirmation",
+		"summary": action.Summary,
+		"note":    "Nothing has changed yet. Describe the change and ask the user to confirm it in the app.",
+	}, action
+}
+
+// chatAction looks up a pending action of the current user
+func (s *AIService) chatAction(ctx context.Context, userID int, actionID string) (*models.ChatAction, error) {
+	id, err := primitive.ObjectIDFromHex(actionID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Invalid action ID format",
+			err,
+		)
+	}
+
+	action, err := s.MongoDBRepo.GetChatAction(ctx, userID, id)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get chat action",
+			err,
+		)
+	}
+	if action == nil {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Chat action not found",
+			nil,
+		)
+	}
+	if action.Status != models.ChatActionPending {
+		return nil, NewServiceError(
+			http.StatusConflict,
+			fmt.Sprintf("Chat action is already %s", action.Status),
+			nil,
+		)
+	}
+	if !time.Now().Before(action.ExpiresAt) {
+		return nil, NewServiceError(
+			http.StatusConflict,
+			"Chat action has expired, ask the coach again",
+			nil,
+		)
+	}
+	return action, nil
+}
+
+// resolveChatAction moves a pending action to status, failing when another request resolved it first
+func (s *AIService) resolveChatAction(ctx context.Context, action *models.ChatAction, status string) error {
+	resolved, err := s.MongoDBRepo.UpdateChatActionStatus(ctx, action.UserID, action.ID, models.ChatActionPending, status)
+	if err != nil {
+		return NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to update chat action",
+			err,
+		)
+	}
+	if !resolved {
+		return NewServiceError(
+			http.StatusConflict,
+			"Chat action was already resolved",
+			nil,
+		)
+	}
+
+	now := time.Now()
+	action.Status = status
+	action.ResolvedAt = &now
+	return nil
+}
+
+// ConfirmChatAction applies a change proposed by the chat coach to the user's plan
+func (s *AIService) ConfirmChatAction(ctx context.Context, actionID string) (*models.ChatActionResult, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := s.chatAction(ctx, userID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	// Claim the action first so it is applied at most once
+	if err := s.resolveChatAction(ctx, action, models.ChatActionConfirmed); err != nil {
+		return nil, err
+	}
+
+	workout, err := s.applyChatAction(ctx, userID, action)
+	if err != nil {
+		// Let the user retry, e.g. after a database error
+		if _, reopenErr := s.MongoDBRepo.UpdateChatActionStatus(ctx, userID, action.ID, models.ChatActionConfirmed, models.ChatActionPending); reopenErr != nil {
+			fmt.Printf("Failed to reopen chat action %s: %v\n", action.ID.Hex(), reopenErr)
+		}
+		return nil, err
+	}
+
+	return &models.ChatActionResult{Action: *action, Workout: workout}, nil
+}
+
+// CancelChatAction rejects a change proposed by the chat coach
+func (s *AIService) CancelChatAction(ctx context.Context, actionID string) (*models.ChatAction, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := s.chatAction(ctx, userID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveChatAction(ctx, action, models.ChatActionCancelled); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// applyChatAction changes the plan, checking again that the change still fits it
+func (s *AIService) applyChatAction(ctx context.Context, userID int, action *models.ChatAction) (*models.Workout, error) {
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout plan",
+			err,
+		)
+	}
+
+	params := action.Params
+	workout, err := plannedWorkout(plan, params.WorkoutID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusConflict,
+			"The workout can no longer be changed",
+			err,
+		)
+	}
+
+	switch action.Type {
+	case models.ChatActionReplaceExercise:
+		i := exerciseIndex(workout, params.ExerciseName)
+		if i < 0 || params.NewExercise == nil {
+			return nil, NewServiceError(
+				http.StatusConflict,
+				fmt.Sprintf("The workout no longer contains %s", params.ExerciseName),
+				nil,
+			)
+		}
+		exercise := *params.NewExercise
+		exercise.ExerciseID = primitive.NewObjectID()
+		workout.Exercises[i] = exercise
+	case models.ChatActionRescheduleWorkout:
+		if params.ScheduledDate == nil {
+			return nil, NewServiceError(
+				http.StatusConflict,
+				"The action has no date",
+				nil,
+			)
+		}
+		if params.ScheduledDate.Before(startOfDay(time.Now(), params.ScheduledDate.Location())) {
+			return nil, NewServiceError(
+				http.StatusConflict,
+				"The new date has already passed",
+				nil,
+			)
+		}
+		workout.ScheduledDate = *params.ScheduledDate
+	default:
+		return nil, NewServiceError(
+			http.StatusConflict,
+			fmt.Sprintf("Unknown action type %q", action.Type),
+			nil,
+		)
+	}
+
+	plan.UpdatedAt = time.Now()
+	if err := s.savePlan(ctx, plan, nil, planChange{
+		reason:   models.PlanChangeChat,
+		author:   models.PlanAuthorUser,
+		comments: action.Summary,
+	}); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save workout plan",
+			err,
+		)
+	}
+	return workout, nil
+}
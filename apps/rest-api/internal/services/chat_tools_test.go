@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api/internal/config"
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// toolServer answers with the given message JSON objects in turn, repeating the last one
+func toolServer(t *testing.T, replies []string, requests *[]OpenRouterRequest) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenRouterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		*requests = append(*requests, req)
+		fmt.Fprintf(w, `{"choices":[{"message":%s}]}`, replies[min(len(*requests), len(replies))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func toolCallReply(name, arguments string) string {
+	encoded, _ := json.Marshal(arguments)
+	return fmt.Sprintf(`{"content":"","tool_calls":[{"id":"call-%s","type":"function","function":{"name":"%s","arguments":%s}}]}`, name, name, encoded)
+}
+
+func newToolTestService(url string, mongoRepo *mockMongoDBRepo) *AIService {
+	client := newTestClient(url, "model-a")
+	client.SetCatalog(&config.ModelCatalog{Models: []config.AIModel{
+		{ID: "model-a", Priority: 1, SupportsTools: true, Enabled: true},
+	}})
+	return &AIService{
+		BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo},
+		Client:      client,
+	}
+}
+
+// testPlan has a leg day tomorrow and a completed workout yesterday
+func testPlan(userID int) *models.WorkoutPlan {
+	tomorrow := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	return &models.WorkoutPlan{
+		UserID: userID,
+		Workouts: []models.Workout{
+			{
+				WorkoutID:     primitive.NewObjectID(),
+				Name:          "Leg Day",
+				Status:        "planned",
+				ScheduledDate: tomorrow,
+				Exercises: []models.Exercise{
+					{Name: "Squats", MuscleGroup: "Legs", Sets: 4, Reps: 10, RestSec: 90},
+					{Name: "Calf Raises", MuscleGroup: "Calves", Sets: 3, Reps: 15, RestSec: 45},
+					{Name: "Plank", MuscleGroup: "Core", Sets: 3, Reps: 1, RestSec: 45},
+				},
+			},
+			{
+				WorkoutID:     primitive.NewObjectID(),
+				Name:          "Upper Body",
+				Status:        "completed",
+				ScheduledDate: tomorrow.Add(-48 * time.Hour),
+			},
+		},
+	}
+}
+
+func TestAIService_Chat_ProposesPlanChange(t *testing.T) {
+	plan := testPlan(1)
+	workoutID := plan.Workouts[0].WorkoutID.Hex()
+	mongoRepo := &mockMongoDBRepo{plans: map[int]*models.WorkoutPlan{1: plan}}
+
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{
+		toolCallReply(toolGetUpcomingWorkouts, `{}`),
+		toolCallReply(toolReplaceExercise, fmt.Sprintf(`{"workout_id":"%s","exercise_name":"squats","new_exercise":{"name":"Lunges","muscle_group":"Legs"}}`, workoutID)),
+		`{"content":"I can swap squats for lunges, please confirm."}`,
+	}, &requests)
+	service := newToolTestService(server.URL, mongoRepo)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	response, err := service.Chat(ctx, "", "Swap tomorrow's squats for lunges")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 3 || len(requests[0].Tools) != len(chatTools) {
+		t.Fatalf("Expected 3 requests with tools, got %d", len(requests))
+	}
+	if !strings.Contains(requests[0].Messages[0].Content, "replace_exercise") {
+		t.Error("Expected the system prompt to explain the tools")
+	}
+	toolResult := requests[1].Messages[len(requests[1].Messages)-1]
+	if toolResult.Role != "tool" || toolResult.ToolCallID != "call-get_upcoming_workouts" || !strings.Contains(toolResult.Content, workoutID) {
+		t.Errorf("Expected the upcoming workouts as tool result, got %+v", toolResult)
+	}
+	if strings.Contains(toolResult.Content, "Upper Body") {
+		t.Error("Expected completed workouts to be left out")
+	}
+
+	if response.Response != "I can swap squats for lunges, please confirm." || len(response.PendingActions) != 1 {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+	action := response.PendingActions[0]
+	if action.Type != models.ChatActionReplaceExercise || action.Status != models.ChatActionPending ||
+		!strings.HasPrefix(action.Summary, "Replace Squats with Lunges in Leg Day") {
+		t.Errorf("Unexpected action %+v", action)
+	}
+	if plan.Workouts[0].Exercises[0].Name != "Squats" {
+		t.Fatal("Expected the plan to stay unchanged until the user confirms")
+	}
+
+	result, err := service.ConfirmChatAction(ctx, action.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exercise := mongoRepo.plans[1].Workouts[0].Exercises[0]
+	if exercise.Name != "Lunges" || exercise.Sets != 4 || exercise.Reps != 10 || exercise.RestSec != 90 {
+		t.Errorf("Expected lunges with the volume of the squats, got %+v", exercise)
+	}
+	if result.Action.Status != models.ChatActionConfirmed || result.Workout.Exercises[0].Name != "Lunges" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	_, err = service.ConfirmChatAction(ctx, action.ID.Hex())
+	if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != http.StatusConflict {
+		t.Errorf("Expected a second confirmation to conflict, got %v", err)
+	}
+}
+
+func TestAIService_Chat_LimitsToolRounds(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{`{"content":"Checking.","tool_calls":[{"id":"call-1","type":"function","function":{"name":"get_progress","arguments":"{}"}}]}`}, &requests)
+	service := newToolTestService(server.URL, &mockMongoDBRepo{})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	response, err := service.Chat(ctx, "", "How am I doing?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != maxToolRounds+1 || len(requests[maxToolRounds].Tools) != 0 {
+		t.Errorf("Expected the last of %d requests without tools, got %d requests", maxToolRounds+1, len(requests))
+	}
+	if response.Response != "Checking." {
+		t.Errorf("Unexpected response '%s'", response.Response)
+	}
+}
+
+func TestAIService_CallChatTool(t *testing.T) {
+	plan := testPlan(1)
+	leg, upper := plan.Workouts[0].WorkoutID.Hex(), plan.Workouts[1].WorkoutID.Hex()
+	mongoRepo := &mockMongoDBRepo{plans: map[int]*models.WorkoutPlan{1: plan}}
+	service := &AIService{BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo}}
+	inTwoDays := time.Now().Add(48 * time.Hour).Format(toolDateLayout)
+	today := time.Now().In(plan.Workouts[0].ScheduledDate.Location()).Format(toolDateLayout)
+
+	testCases := []struct {
+		name      string
+		tool      string
+		arguments string
+		expected  string
+	}{
+		{"unknown tool", "delete_plan", `{}`, "unknown tool"},
+		{"invalid arguments", toolRescheduleWorkout, `{"date":`, "invalid arguments"},
+		{"unknown workout", toolRescheduleWorkout, `{"workout_id":"abc","date":"` + inTwoDays + `"}`, "not found"},
+		{"completed workout", toolRescheduleWorkout, `{"workout_id":"` + upper + `","date":"` + inTwoDays + `"}`, "can no longer be changed"},
+		{"date in the past", toolRescheduleWorkout, `{"workout_id":"` + leg + `","date":"2020-01-01"}`, "in the past"},
+		{"today", toolRescheduleWorkout, `{"workout_id":"` + leg + `","date":"` + today + `"}`, "awaiting_user_confirmation"},
+		{"invalid date", toolRescheduleWorkout, `{"workout_id":"` + leg + `","date":"next friday"}`, "YYYY-MM-DD"},
+		{"unknown exercise", toolReplaceExercise, `{"workout_id":"` + leg + `","exercise_name":"Deadlift","new_exercise":{"name":"Lunges","muscle_group":"Legs"}}`, "has no exercise"},
+		{"invalid exercise", toolReplaceExercise, `{"workout_id":"` + leg + `","exercise_name":"Squats","new_exercise":{"name":"Lunges","muscle_group":"Legs","reps":200}}`, "reps 200 out of range"},
+		{"reschedule", toolRescheduleWorkout, `{"workout_id":"` + leg + `","date":"` + inTwoDays + `"}`, "awaiting_user_confirmation"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			call := OpenRouterToolCall{ID: "call-1", Type: "function", Function: OpenRouterFunctionCall{Name: tc.tool, Arguments: tc.arguments}}
+			result, action := service.callChatTool(context.Background(), 1, primitive.NewObjectID(), call)
+			encoded, _ := json.Marshal(result)
+			if !strings.Contains(string(encoded), tc.expected) {
+				t.Errorf("Expected '%s' in the result, got %s", tc.expected, encoded)
+			}
+			if (action != nil) != (tc.expected == "awaiting_user_confirmation") {
+				t.Errorf("Unexpected action %+v", action)
+			}
+		})
+	}
+
+	// A rescheduled workout keeps its time of day
+	action := mongoRepo.actions[len(mongoRepo.actions)-1]
+	scheduled := plan.Workouts[0].ScheduledDate
+	if date := action.Params.ScheduledDate; date.Format(toolDateLayout) != inTwoDays || date.Hour() != scheduled.Hour() {
+		t.Errorf("Expected %s at %d:00, got %v", inTwoDays, scheduled.Hour(), date)
+	}
+}
+
+func TestAIService_ChatActionLifecycle(t *testing.T) {
+	plan := testPlan(1)
+	mongoRepo := &mockMongoDBRepo{plans: map[int]*models.WorkoutPlan{1: plan}}
+	service := &AIService{BaseService: BaseService{MongoDBRepo: mongoRepo}}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	newAction := func(expiresAt time.Time) *models.ChatAction {
+		date := time.Now().Add(72 * time.Hour)
+		action := &models.ChatAction{
+			UserID:    1,
+			Type:      models.ChatActionRescheduleWorkout,
+			Status:    models.ChatActionPending,
+			ExpiresAt: expiresAt,
+			Params:    models.ChatActionParams{WorkoutID: plan.Workouts[0].WorkoutID.Hex(), ScheduledDate: &date},
+		}
+		if err := mongoRepo.SaveChatAction(ctx, action); err != nil {
+			t.Fatal(err)
+		}
+		return action
+	}
+
+	cancelled := newAction(time.Now().Add(time.Hour))
+	if _, err := service.CancelChatAction(ctx, cancelled.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	expired := newAction(time.Now().Add(-time.Minute))
+	otherUser := newAction(time.Now().Add(time.Hour))
+	mongoRepo.actions[len(mongoRepo.actions)-1].UserID = 2
+
+	testCases := []struct {
+		name     string
+		actionID string
+		expected int
+	}{
+		{"invalid ID", "abc", http.StatusBadRequest},
+		{"action of another user", otherUser.ID.Hex(), http.StatusNotFound},
+		{"cancelled action", cancelled.ID.Hex(), http.StatusConflict},
+		{"expired action", expired.ID.Hex(), http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.ConfirmChatAction(ctx, tc.actionID)
+			if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != tc.expected {
+				t.Errorf("Expected status %d, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	if !plan.Workouts[0].ScheduledDate.Before(time.Now().Add(48 * time.Hour)) {
+		t.Error("Expected no rejected action to change the plan")
+	}
+
+	// A failed confirmation can be retried
+	plan.Workouts[0].Status = "completed"
+	pending := newAction(time.Now().Add(time.Hour))
+	if _, err := service.ConfirmChatAction(ctx, pending.ID.Hex()); err == nil {
+		t.Fatal("Expected a completed workout not to be rescheduled")
+	}
+	if status := mongoRepo.actions[len(mongoRepo.actions)-1].Status; status != models.ChatActionPending {
+		t.Errorf("Expected the action to be pending again, got %s", status)
+	}
+
+	// A move to today is confirmed after midnight has passed
+	plan.Workouts[0].Status = "planned"
+	today := startOfDay(time.Now(), plan.Workouts[0].ScheduledDate.Location())
+	mongoRepo.actions[len(mongoRepo.actions)-1].Params.ScheduledDate = &today
+	if _, err := service.ConfirmChatAction(ctx, pending.ID.Hex()); err != nil {
+		t.Fatalf("Expected a move to today to be applied, got %v", err)
+	}
+	if !plan.Workouts[0].ScheduledDate.Equal(today) {
+		t.Errorf("Expected the workout on %s, got %s", today, plan.Workouts[0].ScheduledDate)
+	}
+}
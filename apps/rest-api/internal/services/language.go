@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"rest-api/internal/i18n"
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+	"rest-api/internal/prompts"
+)
+
+// responseLanguage is the language AI responses must be in: the profile's
+// language, else the client's Accept-Language preference. ok is false
+// without a preference, the model then follows the user's language.
+func responseLanguage(ctx context.Context, profile *models.FitnessProfile) (i18n.Language, bool) {
+	code := ""
+	if profile != nil {
+		code = profile.Language
+	}
+	if code == "" {
+		code = middleware.LanguageFromContext(ctx)
+	}
+	return i18n.Get(code)
+}
+
+// promptLanguage names the language for the templates, "" without a preference
+func promptLanguage(language i18n.Language) string {
+	if language.Code == "" {
+		return ""
+	}
+	return language.PromptName()
+}
+
+// translatePrompt uses the prompt version's translation into the language if there is one
+func (s *AIService) translatePrompt(prompt *prompts.Prompt, language i18n.Language) *prompts.Prompt {
+	if language.Code == "" {
+		return prompt
+	}
+	return s.promptStore().Translate(prompt, language.Code)
+}
+
+// enforceLanguage asks the model once to rewrite a response that is clearly
+// not in the user's language. The response is kept when the rewrite fails.
+func (s *AIService) enforceLanguage(ctx context.Context, messages []OpenRouterMessage, response string, language i18n.Language, policy RetryPolicy) string {
+	if language.Code == "" || !i18n.Mismatch(response, language.Code) {
+		return response
+	}
+
+	fmt.Printf("AI response is not in %s, asking for a rewrite\n", language.Name)
+	retry := append(slices.Clone(messages),
+		OpenRouterMessage{Role: "assistant", Content: response},
+		OpenRouterMessage{Role: "user", Content: fmt.Sprintf(
+			"Your answer is not in %s. Rewrite it in %s only, keeping the content.",
+			language.PromptName(), language.PromptName(),
+		)},
+	)
+	rewritten, err := s.Client.CreateChatCompletionWithPolicy(ctx, retry, false, policy)
+	if err != nil || strings.TrimSpace(rewritten) == "" {
+		fmt.Printf("Failed to rewrite AI response in %s: %v\n", language.Name, err)
+		return response
+	}
+	return rewritten
+}
+
+// planLanguageProblem reports a generated plan whose texts are in another
+// language, "" when the plan is fine or the language can't be told
+func planLanguageProblem(plan *generatedPlan, language i18n.Language) string {
+	texts := []string{plan.Title}
+	for _, workout := range plan.Workouts {
+		texts = append(texts, workout.Name, workout.Description)
+		for _, exercise := range workout.Exercises {
+			texts = append(texts, exercise.Notes, exercise.Technique)
+		}
+	}
+	return languageProblem(texts, language, "the title, names, descriptions, notes and technique")
+}
+
+// mealPlanLanguageProblem is planLanguageProblem for meal plans
+func mealPlanLanguageProblem(plan *generatedMealPlan, language i18n.Language) string {
+	texts := []string{plan.Title}
+	for _, day := range plan.Days {
+		for _, meal := range day.Meals {
+			texts = append(texts, meal.Name, meal.Description)
+		}
+	}
+	return languageProblem(texts, language, "the title, meal names and descriptions")
+}
+
+// languageProblem asks to rewrite the named fields when the texts are detected
+// in another language than the user's
+func languageProblem(texts []string, language i18n.Language, fields string) string {
+	if language.Code == "" {
+		return ""
+	}
+
+	detected := i18n.Detect(strings.Join(texts, ". "))
+	if detected == "" || detected == language.Code {
+		return ""
+	}
+
+	found, _ := i18n.Get(detected)
+	return fmt.Sprintf(
+		"the texts are in %s: write %s in %s",
+		found.Name, fields, language.PromptName(),
+	)
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api/internal/i18n"
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+	"rest-api/internal/prompts"
+)
+
+func TestResponseLanguage(t *testing.T) {
+	header := context.WithValue(context.Background(), middleware.LanguageKey, "es")
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		profile  *models.FitnessProfile
+		expected string
+	}{
+		{"profile wins", header, &models.FitnessProfile{Language: "de"}, "de"},
+		{"header without profile language", header, &models.FitnessProfile{}, "es"},
+		{"header without profile", header, nil, "es"},
+		{"no preference", context.Background(), nil, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			language, ok := responseLanguage(tc.ctx, tc.profile)
+			if language.Code != tc.expected || ok != (tc.expected != "") {
+				t.Errorf("Expected '%s', got '%s' (%v)", tc.expected, language.Code, ok)
+			}
+		})
+	}
+}
+
+func TestPromptTranslations(t *testing.T) {
+	store := prompts.Default()
+	chat := chatPromptData{Beginner: true, Summary: "The user runs.", Tools: true, Caution: []string{"injury"}, Language: "x"}
+	motivation := motivationPromptData{Progress: &models.UserProgress{TotalWorkouts: 3}, Language: "x"}
+
+	for _, code := range i18n.Codes() {
+		if code == i18n.Default {
+			continue
+		}
+		for name, data := range map[string]any{promptChat: chat, promptMotivation: motivation} {
+			base, _ := store.Get(name, "v1")
+			translated := store.Translate(base, code)
+			if translated == base {
+				t.Errorf("Expected a %s translation of %s", code, name)
+				continue
+			}
+			messages, err := renderPrompt(translated, data, "system")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name == promptChat && !strings.Contains(messages[0].Content, "replace_exercise") {
+				t.Errorf("Expected the %s chat prompt to name the tools", code)
+			}
+		}
+	}
+}
+
+func TestAIService_Chat_EnforcesLanguage(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{
+		`{"content":"Keep your back straight and lower the weight slowly with control."}`,
+		`{"content":"Halte den Rücken gerade und senke das Gewicht langsam und kontrolliert, damit die Knie stabil sind."}`,
+	}, &requests)
+	service := newToolTestService(server.URL, &mockMongoDBRepo{})
+	service.Repo.SaveFitnessProfile(context.Background(), 1, &models.FitnessProfile{FitnessLevel: "intermediate", Language: "de"})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	response, err := service.Chat(ctx, "", "How do I squat?")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(response.Response, "Halte den Rücken gerade") {
+		t.Errorf("Expected the German rewrite, got '%s'", response.Response)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Expected the answer and a rewrite, got %d requests", len(requests))
+	}
+	if !strings.Contains(requests[0].Messages[0].Content, "Antworte immer auf Deutsch") {
+		t.Errorf("Expected the German system prompt, got '%s'", requests[0].Messages[0].Content)
+	}
+	rewrite := requests[1].Messages
+	if !strings.Contains(rewrite[len(rewrite)-1].Content, "Rewrite it in German") || len(requests[1].Tools) != 0 {
+		t.Errorf("Expected a rewrite request without tools, got %+v", rewrite[len(rewrite)-1])
+	}
+	if history := service.MongoDBRepo.(*mockMongoDBRepo).chatHistory; history[0].PromptVersion != "chat/v1.de" {
+		t.Errorf("Expected the translated prompt version, got '%s'", history[0].PromptVersion)
+	}
+}
+
+func TestAIService_GenerateMotivationalMessage_Language(t *testing.T) {
+	english := `{"content":"You are doing great, keep showing up for every workout and the results will come."}`
+
+	testCases := []struct {
+		name     string
+		language string
+		replies  []string
+		expected string
+		requests int
+	}{
+		{"rewritten", "es", []string{english, `{"content":"¡Lo estás haciendo muy bien! Sigue así con cada entrenamiento y los resultados llegarán."}`}, "¡Lo estás haciendo muy bien!", 2},
+		{"fallback after failed rewrite", "de", []string{english}, "Du rockst das!", 2},
+		{"no preference", "", []string{english}, "You are doing great", 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []OpenRouterRequest
+			server := toolServer(t, tc.replies, &requests)
+			service := newToolTestService(server.URL, &mockMongoDBRepo{progress: &models.UserProgress{TotalWorkouts: 5}})
+			service.Cache = NewResponseCache("memory", NewLRUCache(10), map[string]time.Duration{AICallMotivation: time.Hour})
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+			ctx = context.WithValue(ctx, middleware.LanguageKey, tc.language)
+
+			message, err := service.GenerateMotivationalMessage(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(message, tc.expected) || len(requests) != tc.requests {
+				t.Errorf("Expected '%s' after %d requests, got '%s' after %d", tc.expected, tc.requests, message, len(requests))
+			}
+
+			// Answers in the wrong language are not cached
+			requests = nil
+			service.GenerateMotivationalMessage(ctx)
+			if cached := len(requests) == 0; cached != (tc.language == "") {
+				t.Errorf("Expected caching only for the accepted answer, got %d requests", len(requests))
+			}
+		})
+	}
+}
+
+func TestAIService_GenerateMotivationalMessage_LocalizedFallback(t *testing.T) {
+	service := &AIService{BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: &mockMongoDBRepo{}}}
+	service.Repo.SaveFitnessProfile(context.Background(), 1, &models.FitnessProfile{Language: "fr"})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	message, err := service.GenerateMotivationalMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message != i18n.Message("fr", i18n.MessageDoingAmazing) {
+		t.Errorf("Expected the French fallback, got '%s'", message)
+	}
+}
+
+func TestGenerateValidatedPlan_RepairsLanguage(t *testing.T) {
+	english := generatedPlan{Title: "Strength Plan", Workouts: []models.Workout{validTestWorkout("Day A"), validTestWorkout("Day B")}}
+	english.Workouts[0].Description = "Keep your back straight and lower the weight slowly with control for every rep of the set."
+	german := english
+	german.Workouts = []models.Workout{validTestWorkout("Tag A"), validTestWorkout("Tag B")}
+	german.Workouts[0].Description = "Halte den Rücken gerade und senke das Gewicht bei jeder Wiederholung langsam und kontrolliert ab."
+	englishJSON, _ := json.Marshal(english)
+	germanJSON, _ := json.Marshal(german)
+
+	var requests []OpenRouterRequest
+	server := planServer(t, []string{string(englishJSON), string(germanJSON)}, &requests)
+	defer server.Close()
+
+	service := &AIService{Client: newTestClient(server.URL, "model-a")}
+	prompt, _ := prompts.Default().Get(promptPlan, "v1")
+	deLanguage, _ := i18n.Get("de")
+
+	plan, err := service.generateValidatedPlan(context.Background(), prompt, []OpenRouterMessage{{Role: "user", Content: "plan"}}, 2, deLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Workouts[0].Name != "Tag A" || len(requests) != 2 {
+		t.Fatalf("Expected the German plan after one repair, got %+v after %d requests", plan.Workouts[0], len(requests))
+	}
+	repair := requests[1].Messages
+	if !strings.Contains(repair[len(repair)-1].Content, "the texts are in English") {
+		t.Errorf("Expected the language problem in the repair prompt, got %s", repair[len(repair)-1].Content)
+	}
+
+	// A plan that stays in the wrong language is accepted in the last round
+	requests = nil
+	server = planServer(t, []string{string(englishJSON)}, &requests)
+	defer server.Close()
+	service.Client = newTestClient(server.URL, "model-a")
+	if _, err := service.generateValidatedPlan(context.Background(), prompt, []OpenRouterMessage{{Role: "user", Content: "plan"}}, 2, deLanguage); err != nil {
+		t.Errorf("Expected the plan to be accepted, got %v", err)
+	}
+	if len(requests) != maxPlanRepairAttempts+1 {
+		t.Errorf("Expected %d attempts, got %d", maxPlanRepairAttempts+1, len(requests))
+	}
+}
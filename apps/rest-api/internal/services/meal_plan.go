@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"rest-api/internal/i18n"
+	"rest-api/internal/models"
+	"rest-api/internal/prompts"
+)
+
+// GenerateMealPlan creates a weekly meal plan for the user's calorie and
+// macro targets, diet and allergies, replacing the current meal plan
+func (s *AIService) GenerateMealPlan(ctx context.Context) (*models.MealPlan, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Client == nil {
+		return nil, NewServiceError(
+			http.StatusServiceUnavailable,
+			"AI service unavailable",
+			nil,
+		)
+	}
+
+	reportPlanStage(ctx, models.PlanStageProfile)
+	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Complete your profile first",
+			err,
+		)
+	}
+
+	targets := nutritionTargets(profile)
+
+	reportPlanStage(ctx, models.PlanStageGenerating)
+	generated, err := s.generateAIMealPlan(ctx, userID, profile, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	plan := &models.MealPlan{
+		UserID:              userID,
+		Title:               generated.Title,
+		Goal:                profile.Goal,
+		Targets:             targets,
+		Days:                generated.Days,
+		PromptVersion:       generated.PromptVersion,
+		DietaryRestrictions: profile.DietaryRestrictions,
+		Allergies:           profile.Allergies,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+
+	reportPlanStage(ctx, models.PlanStageSaving)
+	if err := s.MongoDBRepo.SaveMealPlan(ctx, plan); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save meal plan",
+			err,
+		)
+	}
+	return plan, nil
+}
+
+// GetMealPlan returns the user's current meal plan
+func (s *AIService) GetMealPlan(ctx context.Context) (*models.MealPlan, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.MongoDBRepo.GetMealPlan(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get meal plan",
+			err,
+		)
+	}
+	if plan == nil {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Meal plan not found",
+			nil,
+		)
+	}
+	return plan, nil
+}
+
+// generateAIMealPlan asks the model for the days of a new meal plan
+func (s *AIService) generateAIMealPlan(ctx context.Context, userID int, profile *models.FitnessProfile, targets models.NutritionTargets) (*generatedMealPlan, error) {
+	ctx = withAIFeature(ctx, models.AIFeatureMealPlan)
+
+	prompt, err := s.selectPrompt(promptMeal, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	language, _ := responseLanguage(ctx, profile)
+	messages, err := renderPrompt(prompt, mealPromptData{
+		Profile:  profile,
+		Targets:  targets,
+		Rules:    mealPlanConstraintsPrompt(targets, profile),
+		Language: promptLanguage(language),
+	}, "system", "user")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.generateValidatedMealPlan(ctx, prompt, messages, targets, profile, language)
+}
+
+// generateValidatedMealPlan is generateValidatedPlan for meal plans: invalid
+// plans are sent back with their problems for a bounded number of repairs
+func (s *AIService) generateValidatedMealPlan(ctx context.Context, prompt *prompts.Prompt, messages []OpenRouterMessage, targets models.NutritionTargets, profile *models.FitnessProfile, language i18n.Language) (*generatedMealPlan, error) {
+	var problems []string
+
+	for attempt := 0; attempt <= maxPlanRepairAttempts; attempt++ {
+		content, err := s.Client.CreateChatCompletionWithPolicy(ctx, messages, true, planRetryPolicy)
+		if err != nil {
+			fmt.Printf("AI REQUEST FAILED during meal plan generation: %v\n", err)
+			return nil, newAIRequestError(err)
+		}
+
+		plan, err := parseGeneratedMealPlan(content)
+		if err != nil {
+			problems = []string{fmt.Sprintf("response is not valid JSON: %v", err)}
+		} else {
+			problems = validateMealPlan(plan, targets, profile)
+		}
+
+		if len(problems) == 0 {
+			languageProblem := mealPlanLanguageProblem(plan, language)
+			if languageProblem == "" || attempt == maxPlanRepairAttempts {
+				plan.PromptVersion = prompt.ID()
+				return plan, nil
+			}
+			problems = []string{languageProblem}
+		}
+
+		fmt.Printf("Generated meal plan rejected (attempt %d): %s\n", attempt+1, strings.Join(problems, "; "))
+
+		repair, err := prompt.Execute("repair", repairPromptData{
+			Problems: problems,
+			Rules:    mealPlanConstraintsPrompt(targets, profile),
+		})
+		if err != nil {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to build AI prompt",
+				err,
+			)
+		}
+
+		messages = append(messages,
+			OpenRouterMessage{Role: "assistant", Content: content},
+			OpenRouterMessage{Role: "user", Content: repair},
+		)
+		reportPlanStage(ctx, models.PlanStageRepairing)
+	}
+
+	return nil, NewServiceError(
+		http.StatusInternalServerError,
+		"AI generated an invalid meal plan",
+		fmt.Errorf("invalid meal plan: %s", strings.Join(problems, "; ")),
+	)
+}
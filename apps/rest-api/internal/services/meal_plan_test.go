@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+// mealPlanReply is a completion message with the plan as its content
+func mealPlanReply(t *testing.T, plan *generatedMealPlan) string {
+	t.Helper()
+	reply, err := json.Marshal(map[string]string{"content": testMealPlanJSON(t, plan)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(reply)
+}
+
+func TestAIService_GenerateMealPlan_RepairsInvalidPlan(t *testing.T) {
+	profile := testNutritionProfile()
+	profile.DietaryRestrictions = []string{models.DietVegetarian}
+	targets := nutritionTargets(profile)
+
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{
+		mealPlanReply(t, testMealPlan(targets, "150 g chicken breast", "100 g rice")),
+		mealPlanReply(t, testMealPlan(targets)),
+	}, &requests)
+	mongoRepo := &mockMongoDBRepo{}
+	service := newToolTestService(server.URL, mongoRepo)
+	service.Repo.SaveFitnessProfile(context.Background(), 1, profile)
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	plan, err := service.GenerateMealPlan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected a repair request, got %d requests", len(requests))
+	}
+	system := requests[0].Messages[0].Content
+	if !strings.Contains(system, "every meal must be vegetarian") || !strings.Contains(system, "2484-3036 calories") {
+		t.Errorf("Expected the rules in the prompt, got %s", system)
+	}
+	repair := requests[1].Messages[len(requests[1].Messages)-1].Content
+	if !strings.Contains(repair, "contains chicken, which is not vegetarian") {
+		t.Errorf("Expected the diet problem to be sent back, got %s", repair)
+	}
+
+	if len(plan.Days) != mealPlanDays || plan.Targets != targets || plan.PromptVersion != "meal/v1" {
+		t.Errorf("Expected the repaired plan with its targets, got %+v", plan)
+	}
+	if saved := mongoRepo.mealPlans[1]; saved != plan {
+		t.Errorf("Expected the plan to be saved, got %+v", saved)
+	}
+
+	current, err := service.GetMealPlan(ctx)
+	if err != nil || current != plan {
+		t.Errorf("Expected the saved plan, got %+v (%v)", current, err)
+	}
+}
+
+func TestAIService_GenerateMealPlan_Errors(t *testing.T) {
+	targets := nutritionTargets(testNutritionProfile())
+	invalid := testMealPlan(targets)
+	invalid.Days = invalid.Days[:3]
+
+	testCases := []struct {
+		name    string
+		client  bool
+		profile bool
+		status  int
+	}{
+		{"no AI", false, true, http.StatusServiceUnavailable},
+		{"no profile", true, false, http.StatusBadRequest},
+		{"invalid after repairs", true, true, http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []OpenRouterRequest
+			server := toolServer(t, []string{mealPlanReply(t, invalid)}, &requests)
+			mongoRepo := &mockMongoDBRepo{}
+			service := newToolTestService(server.URL, mongoRepo)
+			if !tc.client {
+				service.Client = nil
+			}
+			if tc.profile {
+				service.Repo.SaveFitnessProfile(context.Background(), 1, testNutritionProfile())
+			}
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+			_, err := service.GenerateMealPlan(ctx)
+			if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != tc.status {
+				t.Errorf("Expected status %d, got %v", tc.status, err)
+			}
+			if tc.status == http.StatusInternalServerError && len(requests) != maxPlanRepairAttempts+1 {
+				t.Errorf("Expected %d attempts, got %d", maxPlanRepairAttempts+1, len(requests))
+			}
+			if mongoRepo.mealPlans[1] != nil {
+				t.Error("Expected no meal plan to be saved")
+			}
+		})
+	}
+
+	service := newToolTestService("http://localhost", &mockMongoDBRepo{})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+	if _, err := service.GetMealPlan(ctx); err == nil || err.(ServiceError).Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a meal plan, got %v", err)
+	}
+}
+
+func TestAIService_SubmitPlanJob_MealPlan(t *testing.T) {
+	targets := nutritionTargets(testNutritionProfile())
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{mealPlanReply(t, testMealPlan(targets))}, &requests)
+	mongoRepo := &mockMongoDBRepo{}
+	service := newToolTestService(server.URL, mongoRepo)
+	service.Repo.SaveFitnessProfile(context.Background(), 1, testNutritionProfile())
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	submitted, err := service.SubmitPlanJob(ctx, models.PlanJobMealPlan, models.PlanJobOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := service.WaitBackground(waitCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := service.GetPlanJob(ctx, submitted.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != models.PlanJobSucceeded || job.MealPlan == nil || job.Plan != nil {
+		t.Errorf("Expected a finished job with the meal plan, got %+v", job)
+	}
+
+	service.Client = nil
+	if _, err := service.SubmitPlanJob(ctx, models.PlanJobMealPlan, models.PlanJobOptions{}); err == nil || err.(ServiceError).Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without the AI, got %v", err)
+	}
+}
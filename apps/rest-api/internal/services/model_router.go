@@ -0,0 +1,280 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"rest-api/internal/models"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+const (
+	defaultFailureThreshold = 3
+	defaultOpenTimeout      = 30 * time.Second
+	// defaultRateLimitCooldown applies to 429 responses without a Retry-After header
+	defaultRateLimitCooldown = 5 * time.Second
+)
+
+var ErrNoModelAvailable = errors.New("no AI model available: all models have open circuit breakers or are cooling down")
+
+// modelBreaker tracks the circuit breaker state and health stats of one model
+type modelBreaker struct {
+	state               BreakerState
+	consecutiveFailures int
+	openedAt            time.Time
+	probeInFlight       bool
+	cooldownUntil       time.Time
+
+	requests     int
+	failures     int
+	totalLatency time.Duration
+	lastLatency  time.Duration
+	lastError    string
+	lastUsedAt   time.Time
+}
+
+// ModelRouter picks models in priority order, skipping the ones whose
+// circuit breaker is open. It is safe for concurrent use.
+type ModelRouter struct {
+	mu               sync.Mutex
+	models           []string
+	breakers         map[string]*modelBreaker
+	failureThreshold int
+	openTimeout      time.Duration
+	now              func() time.Time
+}
+
+func NewModelRouter(modelIDs []string) *ModelRouter {
+	r := &ModelRouter{
+		breakers:         make(map[string]*modelBreaker),
+		failureThreshold: defaultFailureThreshold,
+		openTimeout:      defaultOpenTimeout,
+		now:              time.Now,
+	}
+	r.SetModels(modelIDs)
+	return r
+}
+
+// SetModels replaces the routed models, keeping breaker state for models that stay
+func (r *ModelRouter) SetModels(modelIDs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.models = append([]string(nil), modelIDs...)
+	breakers := make(map[string]*modelBreaker, len(modelIDs))
+	for _, id := range modelIDs {
+		if b, ok := r.breakers[id]; ok {
+			breakers[id] = b
+		} else {
+			breakers[id] = &modelBreaker{state: BreakerClosed}
+		}
+	}
+	r.breakers = breakers
+}
+
+// Models returns the routed model IDs in priority order
+func (r *ModelRouter) Models() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.models...)
+}
+
+// Acquire returns the highest priority model that accepts requests and is not
+// in skip. Models already tried are passed in skip so a retry moves on to the
+// next model; when every allowed model was skipped the first one is reused.
+func (r *ModelRouter) Acquire(skip map[string]bool) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fallback := ""
+	for _, id := range r.models {
+		b := r.breakers[id]
+		if !r.allow(b) {
+			continue
+		}
+		if skip[id] {
+			if fallback == "" {
+				fallback = id
+			}
+			continue
+		}
+		r.take(b)
+		return id, nil
+	}
+
+	if fallback != "" {
+		r.take(r.breakers[fallback])
+		return fallback, nil
+	}
+	return "", ErrNoModelAvailable
+}
+
+// allow reports whether a breaker lets a request through, moving an open
+// breaker to half-open once its timeout has passed
+func (r *ModelRouter) allow(b *modelBreaker) bool {
+	// The provider asked us to back off from this model (Retry-After or 429)
+	if r.now().Before(b.cooldownUntil) {
+		return false
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		if r.now().Sub(b.openedAt) < r.openTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probeInFlight = false
+		return true
+	case BreakerHalfOpen:
+		// Only a single probe request is allowed while half-open
+		return !b.probeInFlight
+	default:
+		return true
+	}
+}
+
+func (r *ModelRouter) take(b *modelBreaker) {
+	if b.state == BreakerHalfOpen {
+		b.probeInFlight = true
+	}
+	b.lastUsedAt = r.now()
+}
+
+// Report records the outcome of a request made with an acquired model.
+// Only errors that point at the model itself count against its breaker and
+// only a successful response closes it. Other errors, like a cancelled
+// request, release a half-open breaker's probe without closing it.
+func (r *ModelRouter) Report(modelID string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[modelID]
+	if !ok {
+		return
+	}
+
+	b.requests++
+	b.totalLatency += latency
+	b.lastLatency = latency
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		cooldown := apiErr.RetryAfter
+		if cooldown == 0 && errors.Is(err, ErrRateLimited) {
+			cooldown = defaultRateLimitCooldown
+		}
+		if cooldown > 0 {
+			b.cooldownUntil = r.now().Add(cooldown)
+		}
+	}
+
+	if err == nil {
+		b.consecutiveFailures = 0
+		b.probeInFlight = false
+		b.state = BreakerClosed
+		return
+	}
+	if !IsModelFailure(err) {
+		b.lastError = err.Error()
+		b.probeInFlight = false
+		return
+	}
+
+	b.failures++
+	b.consecutiveFailures++
+	b.lastError = err.Error()
+	b.probeInFlight = false
+
+	if b.state == BreakerHalfOpen || b.consecutiveFailures >= r.failureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = r.now()
+	}
+}
+
+// NextAvailableIn returns how long until a currently unavailable model accepts
+// requests again. It reports false when no model is expected to recover on its own.
+func (r *ModelRouter) NextAvailableIn() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var earliest time.Time
+	for _, id := range r.models {
+		b := r.breakers[id]
+
+		availableAt := b.cooldownUntil
+		if b.state == BreakerOpen {
+			if reopen := b.openedAt.Add(r.openTimeout); reopen.After(availableAt) {
+				availableAt = reopen
+			}
+		} else if b.state == BreakerHalfOpen && b.probeInFlight {
+			// Depends on the outcome of the probe
+			continue
+		}
+
+		if availableAt.IsZero() {
+			// Nothing holds this model back
+			return 0, true
+		}
+		if earliest.IsZero() || availableAt.Before(earliest) {
+			earliest = availableAt
+		}
+	}
+
+	if earliest.IsZero() {
+		return 0, false
+	}
+	if wait := earliest.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// Snapshot returns breaker states and health stats of all routed models
+func (r *ModelRouter) Snapshot() []models.AIModelStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]models.AIModelStatus, 0, len(r.models))
+	for i, id := range r.models {
+		b := r.breakers[id]
+		status := models.AIModelStatus{
+			Model:               id,
+			Priority:            i + 1,
+			State:               string(b.state),
+			Requests:            b.requests,
+			Failures:            b.failures,
+			ConsecutiveFailures: b.consecutiveFailures,
+			LastLatencyMs:       b.lastLatency.Milliseconds(),
+			LastError:           b.lastError,
+		}
+		if b.requests > 0 {
+			status.ErrorRate = float64(b.failures) / float64(b.requests)
+			status.AvgLatencyMs = (b.totalLatency / time.Duration(b.requests)).Milliseconds()
+		}
+		if !b.lastUsedAt.IsZero() {
+			lastUsed := b.lastUsedAt
+			status.LastUsedAt = &lastUsed
+		}
+		var retryAt time.Time
+		if b.state == BreakerOpen {
+			retryAt = b.openedAt.Add(r.openTimeout)
+		}
+		if b.cooldownUntil.After(retryAt) && b.cooldownUntil.After(r.now()) {
+			retryAt = b.cooldownUntil
+		}
+		if !retryAt.IsZero() {
+			status.RetryAt = &retryAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
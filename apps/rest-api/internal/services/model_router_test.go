@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestRouter(modelIDs ...string) (*ModelRouter, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	router := NewModelRouter(modelIDs)
+	router.now = func() time.Time { return now }
+	return router, &now
+}
+
+func TestModelRouter_AcquireInPriorityOrder(t *testing.T) {
+	router, _ := newTestRouter("model-a", "model-b")
+
+	model, err := router.Acquire(nil)
+	if err != nil || model != "model-a" {
+		t.Errorf("Expected model-a, got %s (%v)", model, err)
+	}
+
+	model, err = router.Acquire(map[string]bool{"model-a": true})
+	if err != nil || model != "model-b" {
+		t.Errorf("Expected model-b when model-a was tried, got %s (%v)", model, err)
+	}
+
+	model, err = router.Acquire(map[string]bool{"model-a": true, "model-b": true})
+	if err != nil || model != "model-a" {
+		t.Errorf("Expected to start over with model-a, got %s (%v)", model, err)
+	}
+}
+
+func TestModelRouter_BreakerOpensAndRecovers(t *testing.T) {
+	router, now := newTestRouter("model-a", "model-b")
+	failure := newAPIError("model-a", 503, nil, "overloaded")
+
+	for i := 0; i < defaultFailureThreshold; i++ {
+		router.Report("model-a", 10*time.Millisecond, failure)
+	}
+
+	if state := router.Snapshot()[0].State; state != string(BreakerOpen) {
+		t.Fatalf("Expected breaker to be open, got %s", state)
+	}
+
+	model, _ := router.Acquire(nil)
+	if model != "model-b" {
+		t.Errorf("Expected open model to be skipped, got %s", model)
+	}
+
+	// After the timeout a single probe is let through
+	*now = now.Add(defaultOpenTimeout)
+	model, _ = router.Acquire(nil)
+	if model != "model-a" {
+		t.Errorf("Expected half-open probe on model-a, got %s", model)
+	}
+	if state := router.Snapshot()[0].State; state != string(BreakerHalfOpen) {
+		t.Errorf("Expected breaker to be half-open, got %s", state)
+	}
+	model, _ = router.Acquire(nil)
+	if model != "model-b" {
+		t.Errorf("Expected second request to avoid the probing model, got %s", model)
+	}
+
+	router.Report("model-a", 10*time.Millisecond, nil)
+	if state := router.Snapshot()[0].State; state != string(BreakerClosed) {
+		t.Errorf("Expected breaker to close after a successful probe, got %s", state)
+	}
+}
+
+func TestModelRouter_FailedProbeReopens(t *testing.T) {
+	router, now := newTestRouter("model-a")
+	failure := newAPIError("model-a", 429, nil, "rate limited")
+
+	for i := 0; i < defaultFailureThreshold; i++ {
+		router.Report("model-a", 0, failure)
+	}
+	*now = now.Add(defaultOpenTimeout)
+
+	if _, err := router.Acquire(nil); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	router.Report("model-a", 0, failure)
+
+	if _, err := router.Acquire(nil); !errors.Is(err, ErrNoModelAvailable) {
+		t.Errorf("Expected ErrNoModelAvailable, got %v", err)
+	}
+}
+
+func TestModelRouter_CancelledProbeStaysHalfOpen(t *testing.T) {
+	router, now := newTestRouter("model-a")
+	failure := newAPIError("model-a", 503, nil, "overloaded")
+
+	for i := 0; i < defaultFailureThreshold; i++ {
+		router.Report("model-a", 0, failure)
+	}
+	*now = now.Add(defaultOpenTimeout)
+
+	if _, err := router.Acquire(nil); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	router.Report("model-a", 0, context.Canceled)
+
+	if state := router.Snapshot()[0].State; state != string(BreakerHalfOpen) {
+		t.Errorf("Expected breaker to stay half-open after a cancelled probe, got %s", state)
+	}
+	// The probe slot is free for the next request
+	if model, err := router.Acquire(nil); err != nil || model != "model-a" {
+		t.Fatalf("Expected another probe on model-a, got %s (%v)", model, err)
+	}
+	router.Report("model-a", 0, newAPIError("model-a", 400, nil, "bad request"))
+	if state := router.Snapshot()[0].State; state != string(BreakerHalfOpen) {
+		t.Errorf("Expected breaker to stay half-open after a bad request, got %s", state)
+	}
+
+	router.Acquire(nil)
+	router.Report("model-a", 0, nil)
+	if state := router.Snapshot()[0].State; state != string(BreakerClosed) {
+		t.Errorf("Expected breaker to close after a successful probe, got %s", state)
+	}
+}
+
+func TestModelRouter_FatalErrorsDoNotTripBreaker(t *testing.T) {
+	router, _ := newTestRouter("model-a")
+
+	for i := 0; i < defaultFailureThreshold*2; i++ {
+		router.Report("model-a", 0, newAPIError("model-a", 400, nil, "bad request"))
+	}
+
+	status := router.Snapshot()[0]
+	if status.State != string(BreakerClosed) {
+		t.Errorf("Expected breaker to stay closed, got %s", status.State)
+	}
+	if status.Failures != 0 {
+		t.Errorf("Expected no model failures, got %d", status.Failures)
+	}
+}
+
+func TestModelRouter_Stats(t *testing.T) {
+	router, _ := newTestRouter("model-a")
+
+	router.Report("model-a", 100*time.Millisecond, nil)
+	router.Report("model-a", 300*time.Millisecond, newAPIError("model-a", 502, nil, "bad gateway"))
+
+	status := router.Snapshot()[0]
+	if status.Requests != 2 || status.Failures != 1 {
+		t.Errorf("Expected 2 requests and 1 failure, got %d and %d", status.Requests, status.Failures)
+	}
+	if status.ErrorRate != 0.5 {
+		t.Errorf("Expected error rate 0.5, got %v", status.ErrorRate)
+	}
+	if status.AvgLatencyMs != 200 {
+		t.Errorf("Expected average latency 200ms, got %d", status.AvgLatencyMs)
+	}
+}
+
+func TestModelRouter_ConcurrentUse(t *testing.T) {
+	router := NewModelRouter([]string{"model-a", "model-b", "model-c"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			model, err := router.Acquire(nil)
+			if err != nil {
+				return
+			}
+			var reportErr error
+			if i%2 == 0 {
+				reportErr = newAPIError(model, 503, nil, "unavailable")
+			}
+			router.Report(model, time.Millisecond, reportErr)
+			_ = router.Snapshot()
+		}(i)
+	}
+	wg.Wait()
+}
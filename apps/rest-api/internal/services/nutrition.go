@@ -0,0 +1,405 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"rest-api/internal/models"
+	"rest-api/internal/promptguard"
+)
+
+// Limits an AI-generated meal plan must respect
+const (
+	mealPlanDays   = 7
+	minMealsPerDay = 3
+	maxMealsPerDay = 6
+
+	// minDailyCalories is the lowest calorie target, also when losing weight
+	minDailyCalories = 1200
+	// maxProteinShare caps protein at this share of the calories
+	maxProteinShare = 0.35
+	fatShare        = 0.3
+
+	// Tolerances of the daily totals around the targets
+	calorieTolerance = 0.1
+	macroTolerance   = 0.2
+	// mealEnergyTolerance is how far a meal's calories may differ from its macros
+	mealEnergyTolerance = 0.2
+)
+
+// proteinPerKg is the daily protein target in g per kg of body weight by goal
+var proteinPerKg = map[string]float64{
+	"weight_loss": 2.0,
+	"muscle_gain": 1.8,
+	"endurance":   1.4,
+}
+
+var mealTypes = []string{models.MealBreakfast, models.MealLunch, models.MealDinner, models.MealSnack}
+
+// nutritionTargets derives the daily targets from the profile: the BMR by
+// Mifflin-St Jeor, the TDEE from the weekly training time and the goal
+func nutritionTargets(profile *models.FitnessProfile) models.NutritionTargets {
+	// The sex constants are +5 and -161, their mean is used when unknown
+	offset := -78.0
+	switch profile.Sex {
+	case models.SexMale:
+		offset = 5
+	case models.SexFemale:
+		offset = -161
+	}
+	bmr := 10*profile.Weight + 6.25*profile.Height - 5*float64(profile.Age) + offset
+	tdee := bmr * activityFactor(profile.AvailableMinutes)
+
+	calories := tdee
+	switch profile.Goal {
+	case "weight_loss":
+		calories = math.Max(tdee-500, minDailyCalories)
+	case "muscle_gain":
+		calories = tdee + 300
+	}
+	calories = math.Round(calories/10) * 10
+
+	perKg, ok := proteinPerKg[profile.Goal]
+	if !ok {
+		perKg = 1.6
+	}
+	protein := math.Round(math.Min(perKg*profile.Weight, maxProteinShare*calories/4))
+	fat := math.Round(fatShare * calories / 9)
+	carbs := math.Round((calories - 4*protein - 9*fat) / 4)
+
+	return models.NutritionTargets{
+		BMR:      int(math.Round(bmr)),
+		TDEE:     int(math.Round(tdee)),
+		Calories: nutrientRange(calories, calorieTolerance),
+		ProteinG: nutrientRange(protein, macroTolerance),
+		CarbsG:   nutrientRange(carbs, macroTolerance),
+		FatG:     nutrientRange(fat, macroTolerance),
+	}
+}
+
+// activityFactor maps weekly training minutes to the usual TDEE multipliers
+func activityFactor(weeklyMinutes int) float64 {
+	switch {
+	case weeklyMinutes < 60:
+		return 1.2
+	case weeklyMinutes < 150:
+		return 1.375
+	case weeklyMinutes < 300:
+		return 1.55
+	case weeklyMinutes < 450:
+		return 1.725
+	default:
+		return 1.9
+	}
+}
+
+func nutrientRange(target, tolerance float64) models.NutrientRange {
+	return models.NutrientRange{
+		Target: target,
+		Min:    math.Round(target * (1 - tolerance)),
+		Max:    math.Round(target * (1 + tolerance)),
+	}
+}
+
+// generatedMealPlan is the JSON document the model is asked to produce
+type generatedMealPlan struct {
+	Title string           `json:"title"`
+	Days  []models.MealDay `json:"days"`
+	// PromptVersion is the ID of the prompt the plan was generated with
+	PromptVersion string `json:"-"`
+}
+
+// parseGeneratedMealPlan decodes a model response, numbers the days and sums their totals
+func parseGeneratedMealPlan(content string) (*generatedMealPlan, error) {
+	var plan generatedMealPlan
+	if err := decodeStrict(content, &plan); err != nil {
+		return nil, err
+	}
+
+	// Extra days are harmless, trim them instead of asking for a repair
+	if len(plan.Days) > mealPlanDays {
+		plan.Days = plan.Days[:mealPlanDays]
+	}
+	for i := range plan.Days {
+		day := &plan.Days[i]
+		day.Day = i + 1
+		day.Totals = models.Macros{}
+		for j := range day.Meals {
+			day.Meals[j].Type = strings.ToLower(strings.TrimSpace(day.Meals[j].Type))
+			day.Totals = day.Totals.Add(day.Meals[j].Macros)
+		}
+	}
+	return &plan, nil
+}
+
+// mealPlanConstraintsPrompt describes the validation rules to the model up front
+func mealPlanConstraintsPrompt(targets models.NutritionTargets, profile *models.FitnessProfile) string {
+	rules := fmt.Sprintf(`RULES:
+- EXACTLY %d days, each with %d to %d meals
+- meal type must be one of: %s
+- every meal has a name, ingredients, and calories that match its macros (4 kcal per g of protein and carbs, 9 kcal per g of fat)
+- the meals of each day add up to %.0f-%.0f calories, %.0f-%.0f g protein, %.0f-%.0f g carbs and %.0f-%.0f g fat`,
+		mealPlanDays, minMealsPerDay, maxMealsPerDay,
+		strings.Join(mealTypes, ", "),
+		targets.Calories.Min, targets.Calories.Max,
+		targets.ProteinG.Min, targets.ProteinG.Max,
+		targets.CarbsG.Min, targets.CarbsG.Max,
+		targets.FatG.Min, targets.FatG.Max)
+
+	if len(profile.DietaryRestrictions) > 0 {
+		rules += fmt.Sprintf("\n- every meal must be %s", strings.Join(profile.DietaryRestrictions, ", "))
+	}
+	if len(profile.Allergies) > 0 {
+		rules += fmt.Sprintf("\n- the user is allergic to %s: never use them or foods containing them", strings.Join(profile.Allergies, ", "))
+	}
+	return rules
+}
+
+// validateMealPlan returns a human (and model) readable list of problems, empty when valid
+func validateMealPlan(plan *generatedMealPlan, targets models.NutritionTargets, profile *models.FitnessProfile) []string {
+	var problems []string
+
+	if len(plan.Days) != mealPlanDays {
+		problems = append(problems, fmt.Sprintf("expected exactly %d days, got %d", mealPlanDays, len(plan.Days)))
+	}
+
+	for _, day := range plan.Days {
+		label := fmt.Sprintf("day %d", day.Day)
+		if n := len(day.Meals); n < minMealsPerDay || n > maxMealsPerDay {
+			problems = append(problems, fmt.Sprintf("%s: has %d meals, must have %d to %d",
+				label, n, minMealsPerDay, maxMealsPerDay))
+		}
+
+		for j, meal := range day.Meals {
+			mealLabel := fmt.Sprintf("%s meal %d", label, j+1)
+			if strings.TrimSpace(meal.Name) != "" {
+				mealLabel = fmt.Sprintf("%s meal %d (%s)", label, j+1, meal.Name)
+			}
+			problems = append(problems, validateMeal(mealLabel, meal)...)
+			problems = append(problems, dietProblems(mealLabel, meal, profile)...)
+		}
+
+		totals := []struct {
+			name   string
+			unit   string
+			value  float64
+			target models.NutrientRange
+		}{
+			{"calories", "kcal", day.Totals.Calories, targets.Calories},
+			{"protein", "g", day.Totals.ProteinG, targets.ProteinG},
+			{"carbs", "g", day.Totals.CarbsG, targets.CarbsG},
+			{"fat", "g", day.Totals.FatG, targets.FatG},
+		}
+		for _, total := range totals {
+			if !total.target.Contains(total.value) {
+				problems = append(problems, fmt.Sprintf("%s: %s total %.0f %s is outside the target %.0f-%.0f %s",
+					label, total.name, total.value, total.unit, total.target.Min, total.target.Max, total.unit))
+			}
+		}
+	}
+
+	return problems
+}
+
+// validateMeal checks a single meal and that its calories match its macros
+func validateMeal(label string, meal models.Meal) []string {
+	var problems []string
+	if strings.TrimSpace(meal.Name) == "" {
+		problems = append(problems, label+": name is empty")
+	}
+	if !slices.Contains(mealTypes, meal.Type) {
+		problems = append(problems, fmt.Sprintf("%s: unknown type %q", label, meal.Type))
+	}
+	if len(meal.Ingredients) == 0 {
+		problems = append(problems, label+": has no ingredients")
+	}
+	for _, text := range append([]string{meal.Name, meal.Description}, meal.Ingredients...) {
+		if problem := promptguard.CheckContent(text); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s: %q %s", label, text, problem))
+		}
+	}
+	if meal.Calories <= 0 || meal.ProteinG < 0 || meal.CarbsG < 0 || meal.FatG < 0 {
+		problems = append(problems, label+": calories must be positive and macros must not be negative")
+		return problems
+	}
+
+	energy := 4*meal.ProteinG + 4*meal.CarbsG + 9*meal.FatG
+	if math.Abs(energy-meal.Calories) > mealEnergyTolerance*meal.Calories+20 {
+		problems = append(problems, fmt.Sprintf("%s: %.0f calories don't match the macros, which add up to %.0f kcal",
+			label, meal.Calories, energy))
+	}
+	return problems
+}
+
+// foodGroup is a set of foods a diet or allergy excludes. Terms are matched
+// as whole words, also in plural. A term right after one of its modifiers is
+// another food and allowed, like "almond milk" or "gluten free bread". Diet
+// modifiers such as "vegan" only excuse terms for diets, they don't rule out
+// allergens.
+type foodGroup struct {
+	terms         []string
+	modifiers     []string
+	dietModifiers []string
+}
+
+var (
+	meatFoods = foodGroup{
+		terms:         []string{"meat", "chicken", "beef", "pork", "turkey", "lamb", "veal", "duck", "bacon", "ham", "sausage", "salami", "prosciutto", "steak", "chorizo", "pepperoni", "venison", "gelatin", "lard"},
+		dietModifiers: []string{"vegan", "vegetarian", "plant based", "meatless", "veggie"},
+	}
+	fishFoods = foodGroup{
+		terms:         []string{"fish", "salmon", "tuna", "cod", "trout", "sardine", "anchovy", "mackerel", "tilapia", "halibut", "herring", "sea bass"},
+		dietModifiers: []string{"vegan", "plant based"},
+	}
+	shellfishFoods = foodGroup{
+		terms:         []string{"shellfish", "shrimp", "prawn", "crab", "lobster", "mussel", "clam", "oyster", "scallop", "squid", "octopus"},
+		dietModifiers: []string{"vegan", "plant based"},
+	}
+	dairyFoods = foodGroup{
+		terms:         []string{"dairy", "milk", "cheese", "butter", "buttermilk", "cream", "yogurt", "yoghurt", "whey", "casein", "ghee", "kefir", "mozzarella", "parmesan", "feta", "ricotta", "skyr", "quark"},
+		modifiers:     []string{"dairy free", "almond", "oat", "soy", "coconut", "rice", "cashew", "peanut", "nut", "cocoa"},
+		dietModifiers: []string{"vegan", "plant based"},
+	}
+	lactoseFoods = foodGroup{
+		terms:         dairyFoods.terms,
+		modifiers:     append([]string{"lactose free"}, dairyFoods.modifiers...),
+		dietModifiers: dairyFoods.dietModifiers,
+	}
+	eggFoods = foodGroup{
+		terms:         []string{"egg", "mayonnaise"},
+		modifiers:     []string{"egg free"},
+		dietModifiers: []string{"vegan"},
+	}
+	glutenFoods = foodGroup{
+		terms:     []string{"wheat", "barley", "rye", "spelt", "bulgur", "couscous", "semolina", "seitan", "bread", "pasta", "flour", "breadcrumbs", "cracker"},
+		modifiers: []string{"gluten free", "rice", "corn", "almond", "coconut", "buckwheat", "chickpea"},
+	}
+	nutFoods = foodGroup{
+		terms: []string{"nut", "almond", "walnut", "cashew", "pecan", "pistachio", "hazelnut", "macadamia", "brazil nut"},
+	}
+	peanutFoods = foodGroup{terms: []string{"peanut"}}
+	soyFoods    = foodGroup{terms: []string{"soy", "soya", "tofu", "tempeh", "edamame", "miso"}}
+	sesameFoods = foodGroup{terms: []string{"sesame", "tahini"}}
+	honeyFoods  = foodGroup{terms: []string{"honey"}}
+	porkFoods   = foodGroup{
+		terms:     []string{"pork", "bacon", "ham", "lard", "prosciutto", "salami", "chorizo", "pepperoni", "gelatin"},
+		modifiers: []string{"turkey", "beef", "chicken", "halal", "kosher"},
+	}
+	alcoholFoods = foodGroup{terms: []string{"wine", "beer", "rum", "brandy", "alcohol"}}
+)
+
+// restrictionFoods are the foods each dietary restriction excludes
+var restrictionFoods = map[string][]foodGroup{
+	models.DietVegetarian:  {meatFoods, fishFoods, shellfishFoods},
+	models.DietVegan:       {meatFoods, fishFoods, shellfishFoods, dairyFoods, eggFoods, honeyFoods},
+	models.DietPescatarian: {meatFoods},
+	models.DietGlutenFree:  {glutenFoods},
+	models.DietLactoseFree: {lactoseFoods},
+	models.DietHalal:       {porkFoods, alcoholFoods},
+	models.DietKosher:      {porkFoods, shellfishFoods},
+}
+
+// allergenFoods maps common allergy names to the foods containing the allergen,
+// other allergies exclude foods named like them
+var allergenFoods = map[string]foodGroup{
+	"dairy":     dairyFoods,
+	"milk":      dairyFoods,
+	"lactose":   dairyFoods,
+	"egg":       eggFoods,
+	"gluten":    glutenFoods,
+	"wheat":     glutenFoods,
+	"fish":      fishFoods,
+	"shellfish": shellfishFoods,
+	"nut":       nutFoods,
+	"tree nut":  nutFoods,
+	"peanut":    peanutFoods,
+	"soy":       soyFoods,
+	"sesame":    sesameFoods,
+}
+
+// dietProblems reports ingredients of a meal that the user's diet or allergies exclude
+func dietProblems(label string, meal models.Meal, profile *models.FitnessProfile) []string {
+	var problems []string
+	for _, restriction := range profile.DietaryRestrictions {
+		for _, group := range restrictionFoods[restriction] {
+			if food := group.find(meal.Ingredients, false); food != "" {
+				problems = append(problems, fmt.Sprintf("%s: contains %s, which is not %s", label, food, restriction))
+			}
+		}
+	}
+	for _, allergy := range profile.Allergies {
+		key := singularFood(normalizeFoodText(allergy))
+		group, ok := allergenFoods[key]
+		if !ok {
+			group = foodGroup{terms: []string{key}}
+		}
+		if food := group.find(meal.Ingredients, true); food != "" {
+			problems = append(problems, fmt.Sprintf("%s: contains %s, the user is allergic to %s", label, food, allergy))
+		}
+	}
+	return problems
+}
+
+// find returns the first excluded food mentioned in the texts, "" when there
+// is none. Allergy checks ignore the diet modifiers.
+func (g foodGroup) find(texts []string, allergy bool) string {
+	modifiers := g.modifiers
+	if !allergy {
+		modifiers = slices.Concat(g.modifiers, g.dietModifiers)
+	}
+	for _, text := range texts {
+		normalized := " " + normalizeFoodText(text) + " "
+		for _, term := range g.terms {
+			forms := []string{term, term + "s", term + "es"}
+			if stem, ok := strings.CutSuffix(term, "y"); ok {
+				forms = append(forms, stem+"ies")
+			}
+			for _, form := range forms {
+				if containsUnmodified(normalized, form, modifiers) {
+					return term
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// containsUnmodified reports whether the word occurs in the normalized text,
+// padded with spaces, without one of the modifiers right before it
+func containsUnmodified(text, word string, modifiers []string) bool {
+	needle := " " + word + " "
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], needle)
+		if i < 0 {
+			return false
+		}
+		before := text[:offset+i+1]
+		if !slices.ContainsFunc(modifiers, func(modifier string) bool {
+			return strings.HasSuffix(before, " "+modifier+" ")
+		}) {
+			return true
+		}
+		offset += i + 1
+	}
+}
+
+// normalizeFoodText lowercases text and reduces it to words separated by single spaces
+func normalizeFoodText(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ")
+}
+
+// singularFood turns a plural allergy like "peanuts" or "strawberries" into the term matched
+func singularFood(food string) string {
+	if stem, ok := strings.CutSuffix(food, "ies"); ok {
+		return stem + "y"
+	}
+	if food == "shellfish" || !strings.HasSuffix(food, "s") {
+		return food
+	}
+	return strings.TrimSuffix(food, "s")
+}
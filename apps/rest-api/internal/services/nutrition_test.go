@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"rest-api/internal/models"
+)
+
+func testNutritionProfile() *models.FitnessProfile {
+	return &models.FitnessProfile{
+		Age: 30, Height: 180, Weight: 80, Sex: models.SexMale,
+		Goal: "general_fitness", FitnessLevel: "intermediate", AvailableMinutes: 150,
+	}
+}
+
+// testMealPlan has 7 days of 3 meals that exactly meet the targets
+func testMealPlan(targets models.NutritionTargets, ingredients ...string) *generatedMealPlan {
+	if len(ingredients) == 0 {
+		ingredients = []string{"100 g rolled oats", "150 g lentils", "1 apple"}
+	}
+	protein := targets.ProteinG.Target / 3
+	carbs := targets.CarbsG.Target / 3
+	fat := targets.FatG.Target / 3
+
+	plan := &generatedMealPlan{Title: "Balanced Week"}
+	for day := 1; day <= mealPlanDays; day++ {
+		mealDay := models.MealDay{Day: day}
+		for _, mealType := range []string{models.MealBreakfast, models.MealLunch, models.MealDinner} {
+			meal := models.Meal{
+				Type:        mealType,
+				Name:        "Lentil Bowl",
+				Ingredients: ingredients,
+				Macros:      models.Macros{Calories: 4*protein + 4*carbs + 9*fat, ProteinG: protein, CarbsG: carbs, FatG: fat},
+			}
+			mealDay.Meals = append(mealDay.Meals, meal)
+			mealDay.Totals = mealDay.Totals.Add(meal.Macros)
+		}
+		plan.Days = append(plan.Days, mealDay)
+	}
+	return plan
+}
+
+func testMealPlanJSON(t *testing.T, plan *generatedMealPlan) string {
+	t.Helper()
+	content, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestNutritionTargets(t *testing.T) {
+	testCases := []struct {
+		name     string
+		profile  *models.FitnessProfile
+		bmr      int
+		calories float64
+		protein  float64
+		carbs    float64
+		fat      float64
+	}{
+		{"maintenance", testNutritionProfile(), 1780, 2760, 128, 355, 92},
+		{
+			"weight loss keeps the minimum",
+			&models.FitnessProfile{Age: 40, Height: 160, Weight: 55, Sex: models.SexFemale, Goal: "weight_loss", AvailableMinutes: 30},
+			1189, 1200, 105, 105, 40,
+		},
+		{
+			"muscle gain without sex",
+			&models.FitnessProfile{Age: 25, Height: 175, Weight: 70, Goal: "muscle_gain", AvailableMinutes: 500},
+			1591, 3320, 126, 454, 111,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			targets := nutritionTargets(tc.profile)
+			if targets.BMR != tc.bmr || targets.Calories.Target != tc.calories {
+				t.Errorf("Expected BMR %d and %.0f kcal, got %d and %.0f", tc.bmr, tc.calories, targets.BMR, targets.Calories.Target)
+			}
+			if targets.ProteinG.Target != tc.protein || targets.CarbsG.Target != tc.carbs || targets.FatG.Target != tc.fat {
+				t.Errorf("Expected %.0f/%.0f/%.0f g, got %+v", tc.protein, tc.carbs, tc.fat, targets)
+			}
+			if targets.TDEE < targets.BMR || !targets.Calories.Contains(tc.calories*1.09) || targets.Calories.Contains(tc.calories*1.11) {
+				t.Errorf("Expected a TDEE above the BMR and a 10%% calorie range, got %+v", targets)
+			}
+		})
+	}
+}
+
+func TestValidateMealPlan(t *testing.T) {
+	profile := testNutritionProfile()
+	targets := nutritionTargets(profile)
+
+	testCases := []struct {
+		name     string
+		change   func(plan *generatedMealPlan, profile *models.FitnessProfile)
+		expected string
+	}{
+		{"valid", func(*generatedMealPlan, *models.FitnessProfile) {}, ""},
+		{"missing day", func(plan *generatedMealPlan, _ *models.FitnessProfile) {
+			plan.Days = plan.Days[:6]
+		}, "expected exactly 7 days, got 6"},
+		{"too few meals", func(plan *generatedMealPlan, _ *models.FitnessProfile) {
+			plan.Days[1].Meals = plan.Days[1].Meals[:2]
+		}, "day 2: has 2 meals, must have 3 to 6"},
+		{"unknown type", func(plan *generatedMealPlan, _ *models.FitnessProfile) {
+			plan.Days[0].Meals[0].Type = "brunch"
+		}, `day 1 meal 1 (Lentil Bowl): unknown type "brunch"`},
+		{"link in ingredients", func(plan *generatedMealPlan, _ *models.FitnessProfile) {
+			plan.Days[0].Meals[0].Ingredients = append(plan.Days[0].Meals[0].Ingredients, "www.example.com")
+		}, `day 1 meal 1 (Lentil Bowl): "www.example.com" contains a link`},
+		{"calories don't match macros", func(plan *generatedMealPlan, _ *models.FitnessProfile) {
+			plan.Days[0].Meals[0].Calories = 200
+		}, "200 calories don't match the macros"},
+		{"day over target", func(plan *generatedMealPlan, _ *models.FitnessProfile) {
+			plan.Days[2].Totals.Calories = 4000
+		}, "day 3: calories total 4000 kcal is outside the target 2484-3036 kcal"},
+		{"protein under target", func(plan *generatedMealPlan, _ *models.FitnessProfile) {
+			plan.Days[3].Totals.ProteinG = 60
+		}, "day 4: protein total 60 g is outside the target"},
+		{"diet", func(plan *generatedMealPlan, profile *models.FitnessProfile) {
+			profile.DietaryRestrictions = []string{models.DietVegetarian}
+			plan.Days[0].Meals[1].Ingredients = []string{"150 g grilled chicken breast"}
+		}, "day 1 meal 2 (Lentil Bowl): contains chicken, which is not vegetarian"},
+		{"allergy", func(plan *generatedMealPlan, profile *models.FitnessProfile) {
+			profile.Allergies = []string{"peanuts"}
+			plan.Days[0].Meals[0].Ingredients = []string{"2 tbsp peanut butter"}
+		}, "contains peanut, the user is allergic to peanuts"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := testMealPlan(targets)
+			changed := *profile
+			tc.change(plan, &changed)
+
+			problems := validateMealPlan(plan, targets, &changed)
+			if tc.expected == "" {
+				if len(problems) > 0 {
+					t.Errorf("Expected no problems, got %v", problems)
+				}
+				return
+			}
+			if !strings.Contains(strings.Join(problems, "\n"), tc.expected) {
+				t.Errorf("Expected a problem containing '%s', got %v", tc.expected, problems)
+			}
+		})
+	}
+}
+
+func TestDietProblems(t *testing.T) {
+	testCases := []struct {
+		name         string
+		restrictions []string
+		allergies    []string
+		ingredient   string
+		expected     string
+	}{
+		{"vegan plant milk", []string{models.DietVegan}, nil, "200 ml oat milk", ""},
+		{"vegan honey", []string{models.DietVegan}, nil, "1 tsp honey", "honey"},
+		{"vegan eggs", []string{models.DietVegan}, nil, "2 boiled eggs", "egg"},
+		{"eggplant is no egg", []string{models.DietVegan}, nil, "1 grilled eggplant", ""},
+		{"pescatarian fish", []string{models.DietPescatarian}, nil, "150 g salmon", ""},
+		{"pescatarian meat", []string{models.DietPescatarian}, nil, "100 g beef mince", "beef"},
+		{"graham is no ham", []string{models.DietHalal}, nil, "2 graham crackers", ""},
+		{"halal bacon", []string{models.DietHalal}, nil, "3 slices bacon", "bacon"},
+		{"halal turkey bacon", []string{models.DietHalal}, nil, "3 slices turkey bacon", ""},
+		{"kosher shellfish", []string{models.DietKosher}, nil, "100 g shrimps", "shrimp"},
+		{"gluten free bread", []string{models.DietGlutenFree}, nil, "2 slices gluten-free bread", ""},
+		{"gluten", []string{models.DietGlutenFree}, nil, "80 g whole wheat pasta", "wheat"},
+		{"lactose free milk", []string{models.DietLactoseFree}, nil, "200 ml lactose-free milk", ""},
+		{"dairy allergy keeps lactose free milk out", nil, []string{"Dairy"}, "200 ml lactose-free milk", "milk"},
+		{"tree nuts", nil, []string{"tree nuts"}, "30 g walnuts", "walnut"},
+		{"plural allergy", nil, []string{"strawberries"}, "100 g strawberries", "strawberry"},
+		{"unknown allergy", nil, []string{"kiwi"}, "1 kiwi", "kiwi"},
+		{"milk allergy with rice pudding", nil, []string{"milk"}, "rice pudding with whole milk", "milk"},
+		{"milk allergy with oat porridge", nil, []string{"milk"}, "oat porridge with milk and butter", "milk"},
+		{"milk allergy with almond milk", nil, []string{"milk"}, "200 ml almond milk", ""},
+		{"milk allergy with plant milk and butter", nil, []string{"milk"}, "oat milk and butter", "butter"},
+		{"milk allergy ignores vegan", nil, []string{"milk"}, "vegan cheese", "cheese"},
+		{"vegan cheese", []string{models.DietVegan}, nil, "vegan cheese", ""},
+		{"gluten allergy with rice and bread", nil, []string{"gluten"}, "chicken, rice and bread", "bread"},
+		{"gluten allergy with rice flour", nil, []string{"gluten"}, "2 tbsp rice flour", ""},
+		{"gluten allergy with gluten free bread", nil, []string{"gluten"}, "gluten free bread and rye crackers", "rye"},
+		{"egg allergy ignores vegan", nil, []string{"egg"}, "1 tbsp vegan mayonnaise", "mayonnaise"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			profile := &models.FitnessProfile{DietaryRestrictions: tc.restrictions, Allergies: tc.allergies}
+			meal := models.Meal{Name: "Meal", Ingredients: []string{tc.ingredient}}
+
+			problems := dietProblems("meal", meal, profile)
+			if tc.expected == "" {
+				if len(problems) > 0 {
+					t.Errorf("Expected no problems, got %v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0], "contains "+tc.expected+",") {
+				t.Errorf("Expected '%s' to be excluded, got %v", tc.expected, problems)
+			}
+		})
+	}
+}
+
+func TestParseGeneratedMealPlan(t *testing.T) {
+	content := "```json\n" + `{"title":"Week","days":[
+		{"day":5,"totals":{"calories":9999},"meals":[
+			{"type":" Breakfast ","name":"Oats","ingredients":["oats"],"calories":400,"protein_g":15,"carbs_g":60,"fat_g":10},
+			{"type":"lunch","name":"Salad","ingredients":["lettuce"],"calories":300,"protein_g":20,"carbs_g":20,"fat_g":15}
+		]},{},{},{},{},{},{},{}]}` + "\n```"
+
+	plan, err := parseGeneratedMealPlan(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Days) != mealPlanDays {
+		t.Errorf("Expected extra days to be trimmed, got %d", len(plan.Days))
+	}
+	day := plan.Days[0]
+	if day.Day != 1 || day.Meals[0].Type != models.MealBreakfast {
+		t.Errorf("Expected the day numbered and the type normalized, got %+v", day)
+	}
+	if day.Totals != (models.Macros{Calories: 700, ProteinG: 35, CarbsG: 80, FatG: 25}) {
+		t.Errorf("Expected the totals summed from the meals, got %+v", day.Totals)
+	}
+}
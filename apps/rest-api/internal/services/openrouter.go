@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -8,7 +9,10 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"rest-api/internal/config"
 )
 
 const (
@@ -17,35 +21,91 @@ const (
 	siteTitle         = "TriviaHealth"
 )
 
-// List of available models with priorities
-var availableModels = []string{
-	"deepseek/deepseek-chat-v3-0324:free",     // BEST
-	"openrouter/cypher-alpha:free",            // Cypher Alpha
-	"google/gemma-7b-it:free",                 // From Google
-	"openchat/openchat-7b:free",               // Optimized for chat
-	"anthropic/claude-3-haiku:free",           // Claude 3 (most powerful free model)
-	"meta-llama/llama-3-8b-instruct:free",     // LLaMA 3
-	"nousresearch/nous-hermes-2-mixtral:free", // Mixtral
-	"microsoft/phi-3-mini-128k-instruct:free", // Phi-3
-}
-
 type OpenRouterMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCalls are the functions an assistant message asks to call
+	ToolCalls []OpenRouterToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a "tool" message with its result to the call
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// OpenRouterTool declares a function the model may call
+type OpenRouterTool struct {
+	Type     string                 `json:"type"`
+	Function OpenRouterToolFunction `json:"function"`
+}
+
+type OpenRouterToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is the JSON schema of the arguments
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// OpenRouterToolCall is a function call requested by the model
+type OpenRouterToolCall struct {
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Function OpenRouterFunctionCall `json:"function"`
+}
+
+type OpenRouterFunctionCall struct {
+	Name string `json:"name"`
+	// Arguments is a JSON object encoded as a string
+	Arguments string `json:"arguments"`
+}
+
+type OpenRouterResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type OpenRouterStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type OpenRouterRequest struct {
-	Model    string              `json:"model"`
-	Messages []OpenRouterMessage `json:"messages"`
-	Stream   bool                `json:"stream,omitempty"`
+	Model          string                    `json:"model"`
+	Messages       []OpenRouterMessage       `json:"messages"`
+	Stream         bool                      `json:"stream,omitempty"`
+	StreamOptions  *OpenRouterStreamOptions  `json:"stream_options,omitempty"`
+	MaxTokens      int                       `json:"max_tokens,omitempty"`
+	Temperature    *float64                  `json:"temperature,omitempty"`
+	ResponseFormat *OpenRouterResponseFormat `json:"response_format,omitempty"`
+	Tools          []OpenRouterTool          `json:"tools,omitempty"`
+}
+
+// OpenRouterUsage is the token usage block of a completion
+type OpenRouterUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type OpenRouterResponse struct {
 	Choices []struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string               `json:"content"`
+			ToolCalls []OpenRouterToolCall `json:"tool_calls"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *OpenRouterUsage `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// OpenRouterStreamChunk is a single server-sent event of a streamed completion
+type OpenRouterStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	// Usage is only sent with the last chunk
+	Usage *OpenRouterUsage `json:"usage"`
 	Error struct {
 		Message string `json:"message"`
 		Code    int    `json:"code"`
@@ -53,120 +113,386 @@ type OpenRouterResponse struct {
 }
 
 type OpenRouterClient struct {
-	apiKey            string
-	httpClient        *http.Client
-	currentModelIndex int
+	apiKey       string
+	baseURL      string
+	httpClient   *http.Client
+	streamClient *http.Client
+	router       *ModelRouter
+	catalog      atomic.Pointer[config.ModelCatalog]
+	onUsage      UsageFunc
 }
 
-func NewOpenRouterClient(apiKey string) *OpenRouterClient {
-	return &OpenRouterClient{
-		apiKey: apiKey,
+// TokenUsage is the token count of one completion
+type TokenUsage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	// Estimated is set when the provider did not report usage
+	Estimated bool
+}
+
+// UsageFunc receives the token usage of every completion the provider answered,
+// including answers later rejected by the caller
+type UsageFunc func(ctx context.Context, usage TokenUsage)
+
+// NewOpenRouterClient creates a client routing over the catalog's enabled
+// models; a nil catalog means the built-in default catalog
+func NewOpenRouterClient(apiKey string, catalog *config.ModelCatalog) *OpenRouterClient {
+	c := &OpenRouterClient{
+		apiKey:  apiKey,
+		baseURL: openRouterBaseURL,
 		httpClient: &http.Client{
 			Timeout: 90 * time.Second,
 		},
-		currentModelIndex: 0,
+		// Streams can legitimately stay open longer than a single response,
+		// so they are bounded by the request context instead of a timeout
+		streamClient: &http.Client{},
+		router:       NewModelRouter(nil),
 	}
+	if catalog == nil {
+		catalog = config.DefaultModelCatalog()
+	}
+	c.SetCatalog(catalog)
+	return c
 }
 
-func (c *OpenRouterClient) getCurrentModel() string {
-	return availableModels[c.currentModelIndex]
+// SetCatalog swaps the model catalog at runtime. Models that stay in the
+// catalog keep their circuit breaker state.
+func (c *OpenRouterClient) SetCatalog(catalog *config.ModelCatalog) {
+	enabled := catalog.EnabledModels()
+	ids := make([]string, len(enabled))
+	for i, m := range enabled {
+		ids[i] = m.ID
+	}
+
+	c.catalog.Store(catalog)
+	c.router.SetModels(ids)
 }
 
-func (c *OpenRouterClient) switchToNextModel() {
-	c.currentModelIndex = (c.currentModelIndex + 1) % len(availableModels)
+// SetBaseURL points the client at another OpenRouter compatible API, e.g.
+// the fake provider. It must be called before the client is used.
+func (c *OpenRouterClient) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
 }
 
-func (c *OpenRouterClient) isModelError(err error) bool {
-	errMsg := strings.ToLower(err.Error())
-	return strings.Contains(errMsg, "model") ||
-		strings.Contains(errMsg, "provider") ||
-		strings.Contains(errMsg, "unavailable") ||
-		strings.Contains(errMsg, "503") ||
-		strings.Contains(errMsg, "overloaded")
+// SetTransport replaces the HTTP transport of regular and streamed requests,
+// e.g. with a cassette. It must be called before the client is used.
+func (c *OpenRouterClient) SetTransport(transport http.RoundTripper) {
+	c.httpClient.Transport = transport
+	c.streamClient.Transport = transport
 }
 
-func (c *OpenRouterClient) CreateChatCompletion(ctx context.Context, messages []OpenRouterMessage, requireJSON bool) (string, error) {
-	var response string
-	var err error
-	attempts := 0
-	maxAttempts := len(availableModels) * 2 // Maximum 2 rounds through all models
-
-	for attempts = 0; attempts < maxAttempts; attempts++ {
-		response, err = c.sendRequest(ctx, messages, requireJSON)
-		if err == nil {
-			break
+// SetUsageFunc registers the function that records token usage. It must be
+// called before the client is used.
+func (c *OpenRouterClient) SetUsageFunc(fn UsageFunc) {
+	c.onUsage = fn
+}
+
+// reportUsage passes the provider's usage to the usage function, estimating
+// it from the text when the provider sent none
+func (c *OpenRouterClient) reportUsage(ctx context.Context, model string, messages []OpenRouterMessage, content string, usage *OpenRouterUsage) {
+	if c.onUsage == nil {
+		return
+	}
+
+	tokenUsage := TokenUsage{Model: model}
+	if usage != nil {
+		tokenUsage.PromptTokens = usage.PromptTokens
+		tokenUsage.CompletionTokens = usage.CompletionTokens
+	} else {
+		tokenUsage.PromptTokens = estimateMessageTokens(messages)
+		tokenUsage.CompletionTokens = estimateTokens(content)
+		tokenUsage.Estimated = true
+	}
+	c.onUsage(ctx, tokenUsage)
+}
+
+// Catalog returns the model catalog currently in use
+func (c *OpenRouterClient) Catalog() *config.ModelCatalog {
+	return c.catalog.Load()
+}
+
+// buildRequest fills in the per-model parameters from the catalog
+func (c *OpenRouterClient) buildRequest(model string, messages []OpenRouterMessage, requireJSON, stream bool) OpenRouterRequest {
+	request := OpenRouterRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   stream,
+	}
+
+	if m, ok := c.Catalog().Find(model); ok {
+		request.MaxTokens = m.MaxTokens
+		temperature := m.Temperature
+		request.Temperature = &temperature
+		if requireJSON && m.SupportsJSON {
+			request.ResponseFormat = &OpenRouterResponseFormat{Type: "json_object"}
 		}
+	}
+
+	if stream {
+		request.StreamOptions = &OpenRouterStreamOptions{IncludeUsage: true}
+	}
+
+	return request
+}
+
+// Router exposes the model router, e.g. for health reporting
+func (c *OpenRouterClient) Router() *ModelRouter {
+	return c.router
+}
 
-		// Try next model on error
-		if c.isModelError(err) {
-			c.switchToNextModel()
+// CreateChatCompletion requests a completion using the default retry policy
+func (c *OpenRouterClient) CreateChatCompletion(ctx context.Context, messages []OpenRouterMessage, requireJSON bool) (string, error) {
+	return c.CreateChatCompletionWithPolicy(ctx, messages, requireJSON, DefaultRetryPolicy)
+}
+
+// CreateChatCompletionWithPolicy requests a completion, retrying across models
+// as allowed by policy. The policy budget bounds the whole call, including
+// the provider requests themselves.
+func (c *OpenRouterClient) CreateChatCompletionWithPolicy(ctx context.Context, messages []OpenRouterMessage, requireJSON bool, policy RetryPolicy) (string, error) {
+	reply, err := c.complete(ctx, messages, requireJSON, nil, policy)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+// CreateChatCompletionWithTools requests a completion the model may answer
+// with tool calls instead of content. Models without tool support in the
+// catalog get the request without tools and answer with content.
+func (c *OpenRouterClient) CreateChatCompletionWithTools(ctx context.Context, messages []OpenRouterMessage, tools []OpenRouterTool, policy RetryPolicy) (*OpenRouterMessage, error) {
+	return c.complete(ctx, messages, false, tools, policy)
+}
+
+func (c *OpenRouterClient) complete(ctx context.Context, messages []OpenRouterMessage, requireJSON bool, tools []OpenRouterTool, policy RetryPolicy) (*OpenRouterMessage, error) {
+	var reply *OpenRouterMessage
+
+	err := c.retry(ctx, policy, func(ctx context.Context, model string, deadline time.Time) (bool, error) {
+		attemptCtx := ctx
+		if !deadline.IsZero() {
+			var cancel context.CancelFunc
+			attemptCtx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
 		}
 
-		// Small delay before retry
-		time.Sleep(1 * time.Second)
+		var err error
+		reply, err = c.sendRequest(attemptCtx, model, messages, requireJSON, tools)
+		if err != nil && ctx.Err() == nil && attemptCtx.Err() != nil {
+			return true, budgetError(ctx, policy, err)
+		}
+		return false, err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return response, err
+	return reply, nil
 }
 
-func (c *OpenRouterClient) sendRequest(ctx context.Context, messages []OpenRouterMessage, requireJSON bool) (string, error) {
-	// If JSON response is required, add instruction to system message
+func (c *OpenRouterClient) sendRequest(ctx context.Context, model string, messages []OpenRouterMessage, requireJSON bool, tools []OpenRouterTool) (*OpenRouterMessage, error) {
+	// If JSON response is required, add instruction to system message.
+	// Work on a copy so retries don't append the instruction again.
 	if requireJSON && len(messages) > 0 && messages[0].Role == "system" {
+		messages = append([]OpenRouterMessage(nil), messages...)
 		messages[0].Content += "\n\nIMPORTANT: Respond ONLY with valid JSON. Do not include any explanation or additional text."
 	}
 
-	requestBody := OpenRouterRequest{
-		Model:    c.getCurrentModel(),
-		Messages: messages,
+	requestBody := c.buildRequest(model, messages, requireJSON, false)
+	if m, ok := c.Catalog().Find(model); ok && m.SupportsTools {
+		requestBody.Tools = tools
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("encoding error: %w", err)
+		return nil, fmt.Errorf("encoding error: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", openRouterBaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := c.newRequest(ctx, jsonData)
 	if err != nil {
-		return "", fmt.Errorf("request creation failed: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("HTTP-Referer", referer)
-	req.Header.Set("X-Title", siteTitle)
-
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("API request failed: %w", err)
+		return nil, fmt.Errorf("API request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		// Try to parse error
-		var errorResp struct {
-			Error struct {
-				Message string `json:"message"`
-			} `json:"error"`
-		}
-		if json.Unmarshal(body, &errorResp) == nil && errorResp.Error.Message != "" {
-			return "", fmt.Errorf("API error [%d]: %s", resp.StatusCode, errorResp.Error.Message)
-		}
-		return "", fmt.Errorf("API error [%d]: %s", resp.StatusCode, string(body))
+		return nil, parseAPIError(model, resp, body)
 	}
 
 	var response OpenRouterResponse
 	if err := json.Unmarshal(body, &response); err != nil {
-		return "", fmt.Errorf("response parsing failed: %w", err)
+		return nil, newAPIError(model, resp.StatusCode, ErrProviderFailure, "response parsing failed: "+err.Error())
 	}
 
 	if response.Error.Message != "" {
-		return "", fmt.Errorf("model error: %s", response.Error.Message)
+		return nil, newAPIError(model, response.Error.Code, nil, "model error: "+response.Error.Message)
 	}
 
 	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("empty response from AI")
+		return nil, newAPIError(model, resp.StatusCode, ErrProviderFailure, "empty response from AI")
+	}
+
+	message := response.Choices[0].Message
+	reply := &OpenRouterMessage{
+		Role:      "assistant",
+		Content:   message.Content,
+		ToolCalls: message.ToolCalls,
+	}
+
+	generated := reply.Content
+	for _, call := range reply.ToolCalls {
+		generated += call.Function.Name + call.Function.Arguments
+	}
+	c.reportUsage(ctx, model, messages, generated, response.Usage)
+	return reply, nil
+}
+
+// CreateChatCompletionStream requests a streamed completion and calls onDelta
+// for every content delta as it arrives. The accumulated content is returned
+// even when the stream is interrupted, so callers can persist partial answers.
+// Retries only happen while nothing has been delivered to onDelta yet, and the
+// policy budget bounds only that phase, not the stream itself.
+func (c *OpenRouterClient) CreateChatCompletionStream(ctx context.Context, messages []OpenRouterMessage, policy RetryPolicy, onDelta func(string) error) (string, error) {
+	var response string
+
+	// Failures of the consumer (e.g. a disconnected client) are not the model's fault
+	deliver := func(delta string) error {
+		if err := onDelta(delta); err != nil {
+			return &consumerError{err: err}
+		}
+		return nil
+	}
+
+	err := c.retry(ctx, policy, func(ctx context.Context, model string, deadline time.Time) (bool, error) {
+		var started bool
+		var err error
+		response, started, err = c.sendStreamRequest(ctx, model, messages, deliver)
+		return started, err
+	})
+
+	return response, err
+}
+
+func (c *OpenRouterClient) sendStreamRequest(ctx context.Context, model string, messages []OpenRouterMessage, onDelta func(string) error) (string, bool, error) {
+	requestBody := c.buildRequest(model, messages, false, true)
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", false, fmt.Errorf("encoding error: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, jsonData)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", false, parseAPIError(model, resp, body)
+	}
+
+	var sb strings.Builder
+	var usage *OpenRouterUsage
+	started := false
+	// Tokens are billed for whatever was generated, even when the stream breaks
+	defer func() {
+		if started {
+			c.reportUsage(context.WithoutCancel(ctx), model, messages, sb.String(), usage)
+		}
+	}()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip blank separators and SSE comments (OpenRouter sends keep-alive comments)
+		if line == "" || strings.HasPrefix(line, ":") || !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return sb.String(), started, nil
+		}
+
+		var chunk OpenRouterStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return sb.String(), started, newAPIError(model, resp.StatusCode, ErrProviderFailure, "stream chunk parsing failed: "+err.Error())
+		}
+
+		if chunk.Error.Message != "" {
+			return sb.String(), started, newAPIError(model, chunk.Error.Code, nil, "model error: "+chunk.Error.Message)
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			sb.WriteString(choice.Delta.Content)
+			started = true
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return sb.String(), started, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return sb.String(), started, fmt.Errorf("stream read failed: %w", err)
+	}
+
+	if !started {
+		return "", false, newAPIError(model, resp.StatusCode, ErrProviderFailure, "empty response from AI")
+	}
+
+	// The provider closed the stream without [DONE], keep what we received
+	return sb.String(), started, nil
+}
+
+func (c *OpenRouterClient) newRequest(ctx context.Context, jsonData []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("request creation failed: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("HTTP-Referer", referer)
+	req.Header.Set("X-Title", siteTitle)
+
+	return req, nil
+}
+
+func parseAPIError(model string, resp *http.Response, body []byte) error {
+	message := string(body)
+
+	// Try to parse error
+	var errorResp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errorResp) == nil && errorResp.Error.Message != "" {
+		message = errorResp.Error.Message
 	}
 
-	return response.Choices[0].Message.Content, nil
+	apiErr := newAPIError(model, resp.StatusCode, nil, message)
+	apiErr.RetryAfter = parseRetryAfter(resp.Header, time.Now())
+	return apiErr
 }
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Error kinds of provider responses, matched with errors.Is
+var (
+	ErrBadRequest          = errors.New("AI provider rejected the request")
+	ErrUnauthorized        = errors.New("AI provider rejected the credentials")
+	ErrInsufficientCredits = errors.New("AI provider credits exhausted")
+	ErrRateLimited         = errors.New("AI provider rate limit exceeded")
+	ErrModelUnavailable    = errors.New("AI model unavailable")
+	ErrProviderFailure     = errors.New("AI provider failure")
+)
+
+// APIError is a failed OpenRouter call, classified by HTTP status
+type APIError struct {
+	Model      string
+	StatusCode int
+	Message    string
+	// RetryAfter is how long the provider asked us to wait before calling the model again
+	RetryAfter time.Duration
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error [%d] from %s: %s", e.StatusCode, e.Model, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// newAPIError builds an APIError, deriving its kind from the status code unless given
+func newAPIError(model string, statusCode int, kind error, message string) *APIError {
+	if kind == nil {
+		kind = classifyStatus(statusCode)
+	}
+	return &APIError{
+		Model:      model,
+		StatusCode: statusCode,
+		Message:    message,
+		kind:       kind,
+	}
+}
+
+func classifyStatus(statusCode int) error {
+	switch {
+	case statusCode == http.StatusBadRequest,
+		statusCode == http.StatusRequestEntityTooLarge,
+		statusCode == http.StatusUnprocessableEntity:
+		return ErrBadRequest
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case statusCode == http.StatusPaymentRequired:
+		return ErrInsufficientCredits
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode == http.StatusNotFound, statusCode == http.StatusRequestTimeout:
+		return ErrModelUnavailable
+	default:
+		return ErrProviderFailure
+	}
+}
+
+// IsRetryable reports whether another attempt (possibly with another model) may succeed
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	return !errors.Is(err, ErrBadRequest) &&
+		!errors.Is(err, ErrUnauthorized) &&
+		!errors.Is(err, ErrInsufficientCredits) &&
+		!errors.Is(err, ErrNoModelAvailable) &&
+		!errors.Is(err, ErrRetryBudgetExhausted) &&
+		!errors.Is(err, context.Canceled)
+}
+
+// IsModelFailure reports whether an error should count against the model's circuit breaker
+func IsModelFailure(err error) bool {
+	return IsRetryable(err)
+}
+
+// newAIRequestError converts a client error into the ServiceError returned to handlers
+func newAIRequestError(err error) ServiceError {
+	switch {
+	case errors.Is(err, ErrRetryBudgetExhausted), errors.Is(err, context.DeadlineExceeded):
+		return NewServiceError(
+			http.StatusGatewayTimeout,
+			"AI request timed out",
+			err,
+		)
+	case errors.Is(err, ErrNoModelAvailable),
+		errors.Is(err, ErrRateLimited),
+		errors.Is(err, ErrInsufficientCredits):
+		return NewServiceError(
+			http.StatusServiceUnavailable,
+			"AI service temporarily unavailable",
+			err,
+		)
+	default:
+		return NewServiceError(
+			http.StatusInternalServerError,
+			"AI request failed",
+			err,
+		)
+	}
+}
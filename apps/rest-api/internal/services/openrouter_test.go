@@ -1,70 +1,190 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"rest-api/internal/cassette"
+	"rest-api/internal/config"
+	"rest-api/internal/fakeopenrouter"
+	"rest-api/internal/middleware"
 )
 
-func TestOpenRouterClient_GetCurrentModel(t *testing.T) {
-	client := NewOpenRouterClient("test-key")
+func TestOpenRouterClient_RouterUsesAvailableModels(t *testing.T) {
+	client := NewOpenRouterClient("test-key", nil)
 
-	model := client.getCurrentModel()
-	if model != availableModels[0] {
-		t.Errorf("Expected first model %s, got %s", availableModels[0], model)
+	model, err := client.Router().Acquire(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := config.DefaultModelCatalog().EnabledModels()[0].ID; model != expected {
+		t.Errorf("Expected first model %s, got %s", expected, model)
 	}
 }
 
-func TestOpenRouterClient_SwitchToNextModel(t *testing.T) {
-	client := NewOpenRouterClient("test-key")
+func TestClassifyAPIErrors(t *testing.T) {
+	testCases := []struct {
+		status    int
+		kind      error
+		retryable bool
+	}{
+		{http.StatusBadRequest, ErrBadRequest, false},
+		{http.StatusUnauthorized, ErrUnauthorized, false},
+		{http.StatusPaymentRequired, ErrInsufficientCredits, false},
+		{http.StatusNotFound, ErrModelUnavailable, true},
+		{http.StatusTooManyRequests, ErrRateLimited, true},
+		{http.StatusServiceUnavailable, ErrProviderFailure, true},
+		{http.StatusBadGateway, ErrProviderFailure, true},
+	}
 
-	initialModel := client.getCurrentModel()
-	client.switchToNextModel()
-	nextModel := client.getCurrentModel()
+	for _, tc := range testCases {
+		err := newAPIError("test-model", tc.status, nil, "boom")
+		if !errors.Is(err, tc.kind) {
+			t.Errorf("For status %d, expected kind %v", tc.status, tc.kind)
+		}
+		if IsRetryable(err) != tc.retryable {
+			t.Errorf("For status %d, expected retryable %v", tc.status, tc.retryable)
+		}
+	}
 
-	if initialModel == nextModel {
-		t.Error("Model should have changed after switching")
+	if IsRetryable(&mockError{"network timeout"}) != true {
+		t.Error("Expected transport errors to be retryable")
 	}
+}
+
+func TestOpenRouterClient_CreateChatCompletion_Failover(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenRouterRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		requested = append(requested, req.Model)
+
+		if req.Model == "broken-model" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":{"message":"overloaded"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer server.Close()
 
-	if nextModel != availableModels[1] {
-		t.Errorf("Expected second model %s, got %s", availableModels[1], nextModel)
+	client := NewOpenRouterClient("test-key", nil)
+	client.baseURL = server.URL
+	client.router = NewModelRouter([]string{"broken-model", "healthy-model"})
+
+	response, err := client.CreateChatCompletion(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response != "ok" {
+		t.Errorf("Expected 'ok', got '%s'", response)
+	}
+	if len(requested) != 2 || requested[1] != "healthy-model" {
+		t.Errorf("Expected failover to healthy-model, got %v", requested)
 	}
 }
 
-func TestOpenRouterClient_IsModelError(t *testing.T) {
-	client := NewOpenRouterClient("test-key")
+func TestOpenRouterClient_CreateChatCompletion_FatalError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"message":"invalid key"}}`)
+	}))
+	defer server.Close()
 
-	testCases := []struct {
-		error    string
-		expected bool
-	}{
-		{"model unavailable", true},
-		{"provider error", true},
-		{"503 service unavailable", true},
-		{"overloaded", true},
-		{"network timeout", false},
-		{"invalid request", false},
+	client := NewOpenRouterClient("bad-key", nil)
+	client.baseURL = server.URL
+	client.router = NewModelRouter([]string{"model-a", "model-b"})
+
+	_, err := client.CreateChatCompletion(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, false)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
 	}
+	if calls != 1 {
+		t.Errorf("Expected a single call for a fatal error, got %d", calls)
+	}
+}
 
-	for _, tc := range testCases {
-		result := client.isModelError(&mockError{tc.error})
-		if result != tc.expected {
-			t.Errorf("For error '%s', expected %v, got %v", tc.error, tc.expected, result)
-		}
+func TestOpenRouterClient_SetCatalog(t *testing.T) {
+	client := NewOpenRouterClient("test-key", nil)
+
+	client.SetCatalog(&config.ModelCatalog{Models: []config.AIModel{
+		{ID: "model-low", Priority: 2, ContextLength: 1000, Enabled: true},
+		{ID: "model-off", Priority: 1, ContextLength: 1000, Enabled: false},
+		{ID: "model-high", Priority: 1, ContextLength: 1000, Enabled: true},
+	}})
+
+	routed := client.Router().Models()
+	if len(routed) != 2 || routed[0] != "model-high" || routed[1] != "model-low" {
+		t.Errorf("Expected enabled models by priority, got %v", routed)
 	}
 }
 
-func TestAvailableModels_Count(t *testing.T) {
-	if len(availableModels) < 5 {
-		t.Errorf("Expected at least 5 models, got %d", len(availableModels))
+func TestOpenRouterClient_BuildRequest(t *testing.T) {
+	client := NewOpenRouterClient("test-key", &config.ModelCatalog{Models: []config.AIModel{
+		{ID: "json-model", Priority: 1, SupportsJSON: true, ContextLength: 8000, MaxTokens: 2000, Temperature: 0.3, Enabled: true},
+		{ID: "text-model", Priority: 2, ContextLength: 8000, MaxTokens: 1000, Temperature: 0.9, Enabled: true},
+	}})
+
+	request := client.buildRequest("json-model", nil, true, false)
+	if request.MaxTokens != 2000 || request.Temperature == nil || *request.Temperature != 0.3 {
+		t.Errorf("Expected catalog parameters, got max_tokens=%d temperature=%v", request.MaxTokens, request.Temperature)
+	}
+	if request.ResponseFormat == nil || request.ResponseFormat.Type != "json_object" {
+		t.Error("Expected JSON response format for a model supporting JSON mode")
+	}
+
+	request = client.buildRequest("text-model", nil, true, false)
+	if request.ResponseFormat != nil {
+		t.Error("Expected no response format for a model without JSON mode")
 	}
+}
+
+func TestOpenRouterClient_CreateChatCompletionWithTools(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenRouterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, req)
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"","tool_calls":[{"id":"call-1","type":"function","function":{"name":"get_progress","arguments":"{}"}}]}}]}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, "tool-model")
+	client.SetCatalog(&config.ModelCatalog{Models: []config.AIModel{
+		{ID: "tool-model", Priority: 1, SupportsTools: true, Enabled: true},
+		{ID: "text-model", Priority: 2, Enabled: true},
+	}})
+	tools := []OpenRouterTool{chatTool("get_progress", "Get progress", `{"type":"object","properties":{}}`)}
+	messages := []OpenRouterMessage{{Role: "user", Content: "How am I doing?"}}
 
-	// Check that DeepSeek is first
-	if availableModels[0] != "deepseek/deepseek-chat-v3-0324:free" {
-		t.Errorf("Expected DeepSeek as first model, got %s", availableModels[0])
+	reply, err := client.CreateChatCompletionWithTools(context.Background(), messages, tools, fastRetryPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests[0].Tools) != 1 || requests[0].Tools[0].Function.Name != "get_progress" {
+		t.Errorf("Expected the tools to be sent, got %+v", requests[0].Tools)
+	}
+	if len(reply.ToolCalls) != 1 || reply.ToolCalls[0].ID != "call-1" || reply.Role != "assistant" {
+		t.Errorf("Expected the tool call in the reply, got %+v", reply)
 	}
 
-	// Check that Cypher Alpha is second
-	if availableModels[1] != "openrouter/cypher-alpha:free" {
-		t.Errorf("Expected Cypher Alpha as second model, got %s", availableModels[1])
+	if _, err := client.sendRequest(context.Background(), "text-model", messages, false, tools); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests[1].Tools) != 0 {
+		t.Error("Expected no tools for a model without tool support")
 	}
 }
 
@@ -75,3 +195,261 @@ type mockError struct {
 func (e *mockError) Error() string {
 	return e.message
 }
+
+func TestOpenRouterClient_CreateChatCompletionStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenRouterRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("Expected stream flag to be set")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": OPENROUTER PROCESSING\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\", world\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewOpenRouterClient("test-key", nil)
+	client.baseURL = server.URL
+
+	var deltas []string
+	response, err := client.CreateChatCompletionStream(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, DefaultRetryPolicy, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response != "Hello, world" {
+		t.Errorf("Expected 'Hello, world', got '%s'", response)
+	}
+	if len(deltas) != 2 {
+		t.Errorf("Expected 2 deltas, got %d", len(deltas))
+	}
+}
+
+func TestOpenRouterClient_CreateChatCompletionStream_Aborted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Partial\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\" answer\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewOpenRouterClient("test-key", nil)
+	client.baseURL = server.URL
+
+	abortErr := errors.New("client disconnected")
+	response, err := client.CreateChatCompletionStream(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, DefaultRetryPolicy, func(delta string) error {
+		return abortErr
+	})
+
+	if !errors.Is(err, abortErr) {
+		t.Errorf("Expected abort error, got %v", err)
+	}
+	if response != "Partial" {
+		t.Errorf("Expected partial response 'Partial', got '%s'", response)
+	}
+}
+
+func TestOpenRouterClient_ReportsUsage(t *testing.T) {
+	withUsage := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if withUsage {
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":120,"completion_tokens":30,"total_tokens":150}}`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"12345678"}}]}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, "model-a")
+	var reported []TokenUsage
+	client.SetUsageFunc(func(ctx context.Context, usage TokenUsage) {
+		reported = append(reported, usage)
+	})
+
+	messages := []OpenRouterMessage{{Role: "user", Content: "1234"}}
+	if _, err := client.CreateChatCompletion(context.Background(), messages, false); err != nil {
+		t.Fatal(err)
+	}
+	withUsage = false
+	if _, err := client.CreateChatCompletion(context.Background(), messages, false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TokenUsage{
+		{Model: "model-a", PromptTokens: 120, CompletionTokens: 30},
+		{Model: "model-a", PromptTokens: 5, CompletionTokens: 2, Estimated: true},
+	}
+	if len(reported) != len(expected) {
+		t.Fatalf("Expected %d usage reports, got %d", len(expected), len(reported))
+	}
+	for i := range expected {
+		if reported[i] != expected[i] {
+			t.Errorf("Expected usage %+v, got %+v", expected[i], reported[i])
+		}
+	}
+}
+
+func TestOpenRouterClient_StreamReportsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenRouterRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Error("Expected stream usage to be requested")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":40,\"completion_tokens\":2,\"total_tokens\":42}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, "model-a")
+	var reported []TokenUsage
+	client.SetUsageFunc(func(ctx context.Context, usage TokenUsage) {
+		reported = append(reported, usage)
+	})
+
+	_, err := client.CreateChatCompletionStream(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, DefaultRetryPolicy, func(string) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reported) != 1 || reported[0] != (TokenUsage{Model: "model-a", PromptTokens: 40, CompletionTokens: 2}) {
+		t.Errorf("Expected the streamed usage to be reported once, got %+v", reported)
+	}
+}
+
+func TestOpenRouterClient_FakeProvider(t *testing.T) {
+	testCases := []struct {
+		name     string
+		replies  []fakeopenrouter.Reply
+		stream   bool
+		expected string
+		deltas   int
+		kind     error
+		requests int
+	}{
+		{
+			name:     "malformed JSON fails over",
+			replies:  []fakeopenrouter.Reply{{Raw: `{"choices":[`}, {Content: "ok"}},
+			expected: "ok",
+			requests: 2,
+		},
+		{
+			name:     "rate limit fails over",
+			replies:  []fakeopenrouter.Reply{{Status: http.StatusTooManyRequests, RetryAfter: "1"}, {Content: "ok"}},
+			expected: "ok",
+			requests: 2,
+		},
+		{
+			name:     "credits exhausted",
+			replies:  []fakeopenrouter.Reply{{Status: http.StatusPaymentRequired, Error: "no credits"}},
+			kind:     ErrInsufficientCredits,
+			requests: 1,
+		},
+		{
+			name:     "slow stream",
+			replies:  []fakeopenrouter.Reply{{Content: "Keep your chest up.", ChunkSize: 4, ChunkDelayMS: 5}},
+			stream:   true,
+			expected: "Keep your chest up.",
+			deltas:   5,
+			requests: 1,
+		},
+		{
+			name:     "stream breaks midway",
+			replies:  []fakeopenrouter.Reply{{Content: "Keep your", StreamError: "provider crashed"}},
+			stream:   true,
+			expected: "Keep your",
+			deltas:   2,
+			kind:     ErrProviderFailure,
+			requests: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := fakeopenrouter.New(fakeopenrouter.Script{})
+			fake.Enqueue(tc.replies...)
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			client := newTestClient(server.URL, "model-a", "model-b")
+			messages := []OpenRouterMessage{{Role: "user", Content: "hi"}}
+
+			var response string
+			var err error
+			deltas := 0
+			if tc.stream {
+				response, err = client.CreateChatCompletionStream(context.Background(), messages, fastRetryPolicy, func(string) error {
+					deltas++
+					return nil
+				})
+			} else {
+				response, err = client.CreateChatCompletionWithPolicy(context.Background(), messages, false, fastRetryPolicy)
+			}
+
+			if tc.kind == nil && err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tc.kind != nil && !errors.Is(err, tc.kind) {
+				t.Errorf("Expected %v, got %v", tc.kind, err)
+			}
+			if response != tc.expected || deltas != tc.deltas {
+				t.Errorf("Expected '%s' in %d deltas, got '%s' in %d", tc.expected, tc.deltas, response, deltas)
+			}
+			if requests := fake.Requests(); len(requests) != tc.requests {
+				t.Errorf("Expected %d requests, got %d", tc.requests, len(requests))
+			}
+		})
+	}
+}
+
+func TestAIService_Chat_CassetteReplay(t *testing.T) {
+	fake := fakeopenrouter.New(fakeopenrouter.Script{Rules: []fakeopenrouter.Rule{
+		{Contains: "squat", Reply: fakeopenrouter.Reply{Content: "Keep your chest up."}},
+	}})
+	server := httptest.NewServer(fake)
+	path := filepath.Join(t.TempDir(), "chat.json")
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	chat := func(mode string) string {
+		t.Helper()
+		transport, err := cassette.New(path, mode, nil, "test-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		service := newToolTestService(server.URL, &mockMongoDBRepo{})
+		service.Client.SetTransport(transport)
+
+		response, err := service.Chat(ctx, "", "How do I squat?")
+		if err != nil {
+			t.Fatalf("Chat failed in %s mode: %v", mode, err)
+		}
+		return response.Response
+	}
+
+	recorded := chat(cassette.ModeRecord)
+	server.Close()
+	replayed := chat(cassette.ModeReplay)
+
+	if recorded != "Keep your chest up." || replayed != recorded {
+		t.Errorf("Expected the recorded answer to be replayed, got '%s' and '%s'", recorded, replayed)
+	}
+	if len(fake.Requests()) != 1 {
+		t.Errorf("Expected only the recording to reach the provider, got %d requests", len(fake.Requests()))
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "test-key") {
+		t.Error("Expected the API key to be redacted from the cassette")
+	}
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"rest-api/internal/models"
+	"rest-api/internal/safety"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultPlanJobWorkers = 4
+	defaultPlanJobQueue   = 100
+
+	// planJobHeartbeat is how often queued and running jobs are marked alive.
+	// Active jobs without a heartbeat for planJobStaleAfter were abandoned,
+	// e.g. by a restart, and are failed so the user can submit again.
+	planJobHeartbeat  = 30 * time.Second
+	planJobStaleAfter = 3 * planJobHeartbeat
+)
+
+var (
+	// planJobTimeout bounds a job, the AI call alone may retry for planRetryPolicy.Budget
+	planJobTimeout = planRetryPolicy.Budget + time.Minute
+	// planJobPollInterval is how often WatchPlanJob reloads the job
+	planJobPollInterval = time.Second
+)
+
+// planStageProgress is the progress in percent reported when a stage starts
+var planStageProgress = map[string]int{
+	models.PlanStageQueued:     0,
+	models.PlanStageProfile:    5,
+	models.PlanStageGenerating: 20,
+	models.PlanStageRepairing:  60,
+	models.PlanStageScheduling: 80,
+	models.PlanStageSaving:     90,
+	models.PlanStageDone:       100,
+}
+
+// planTask is a job owned by this process. mu serializes changes and writes
+// of the job, so an older state never overwrites a newer one.
+type planTask struct {
+	ctx  context.Context
+	mu   sync.Mutex
+	job  *models.PlanJob
+	done chan struct{}
+}
+
+// updatePlanTask changes the job and stores it. Failures are logged, the job keeps running.
+func (s *AIService) updatePlanTask(task *planTask, change func(job *models.PlanJob)) {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	change(task.job)
+	task.job.UpdatedAt = time.Now()
+	if err := s.MongoDBRepo.UpdatePlanJob(task.ctx, task.job); err != nil {
+		fmt.Printf("Failed to update plan job %s: %v\n", task.job.ID.Hex(), err)
+	}
+}
+
+type planProgressKey struct{}
+
+// withPlanProgress makes plan generation report its stages to onStage
+func withPlanProgress(ctx context.Context, onStage func(stage string)) context.Context {
+	return context.WithValue(ctx, planProgressKey{}, onStage)
+}
+
+// reportPlanStage tells the job running plan generation, if any, which stage started
+func reportPlanStage(ctx context.Context, stage string) {
+	if onStage, ok := ctx.Value(planProgressKey{}).(func(string)); ok {
+		onStage(stage)
+	}
+}
+
+// StartPlanJobs starts the workers that run plan jobs. Only the first call
+// has an effect; submitting a job starts the default pool if needed.
+func (s *AIService) StartPlanJobs(workers, queueSize int) {
+	s.planJobsOnce.Do(func() {
+		s.planQueue = make(chan *planTask, queueSize)
+		for range workers {
+			go func() {
+				for task := range s.planQueue {
+					s.runPlanTask(task)
+				}
+			}()
+		}
+	})
+}
+
+// SubmitPlanJob queues a plan generation (models.PlanJobGenerate with a
+// generator), regeneration (models.PlanJobRegenerate with comments), single
+// workout regeneration (models.PlanJobRegenerateWorkout with a workout ID and
+// comments), generation of the plan phases the user reached
+// (models.PlanJobPhase) or meal plan generation (models.PlanJobMealPlan). When the user
+// already has an active job of the same type and options, that job is returned.
+func (s *AIService) SubmitPlanJob(ctx context.Context, jobType string, options models.PlanJobOptions) (*models.PlanJob, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plans and their phases fall back to the rule-based generator
+	if jobType != models.PlanJobGenerate && jobType != models.PlanJobPhase && s.Client == nil {
+		return nil, NewServiceError(
+			http.StatusServiceUnavailable,
+			"AI service unavailable",
+			nil,
+		)
+	}
+
+	// Comments are pasted into the prompt, an override attempt is not run
+	if verdict := injectionVerdict(options.Comments, safety.ActionRefuse); verdict.Flagged() {
+		s.logSafetyEvent(ctx, &models.SafetyEvent{
+			UserID:  userID,
+			Feature: models.AIFeatureRegenerate,
+			Input:   options.Comments,
+		}, verdict)
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Comments must describe changes to your plan, not instructions for the AI",
+			nil,
+		)
+	}
+
+	// A workout that cannot be regenerated is reported right away instead of by a failed job
+	if jobType == models.PlanJobRegenerateWorkout {
+		plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+		if err != nil {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to get workout plan",
+				err,
+			)
+		}
+		if _, err := changeableWorkout(plan, options.WorkoutID); err != nil {
+			return nil, err
+		}
+	}
+
+	// A job whose active predecessor just finished or went stale gets a second try
+	for attempt := 0; attempt < 2; attempt++ {
+		now := time.Now()
+		job := &models.PlanJob{
+			UserID:         userID,
+			Type:           jobType,
+			Status:         models.PlanJobQueued,
+			Active:         true,
+			Stage:          models.PlanStageQueued,
+			PlanJobOptions: options,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+
+		err := s.MongoDBRepo.CreatePlanJob(ctx, job)
+		if err == nil {
+			return s.enqueuePlanJob(ctx, job)
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to create plan job",
+				err,
+			)
+		}
+
+		active, err := s.MongoDBRepo.GetActivePlanJob(ctx, userID)
+		if err != nil {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to get active plan job",
+				err,
+			)
+		}
+		if active == nil || s.failStalePlanJob(ctx, active) {
+			continue
+		}
+		if active.Type != jobType || active.WorkoutID != options.WorkoutID {
+			return nil, NewServiceError(
+				http.StatusConflict,
+				"Another plan job is already running",
+				nil,
+			)
+		}
+		return active, nil
+	}
+
+	return nil, NewServiceError(
+		http.StatusConflict,
+		"Another plan job is already running",
+		nil,
+	)
+}
+
+// enqueuePlanJob hands a new job to the workers. The job outlives the
+// request, but keeps its values such as the user ID.
+func (s *AIService) enqueuePlanJob(ctx context.Context, job *models.PlanJob) (*models.PlanJob, error) {
+	s.StartPlanJobs(defaultPlanJobWorkers, defaultPlanJobQueue)
+
+	// The workers own the job from now on, the caller gets a copy
+	submitted := *job
+	task := &planTask{
+		ctx:  context.WithoutCancel(ctx),
+		job:  job,
+		done: make(chan struct{}),
+	}
+
+	s.background.Add(1)
+	select {
+	case s.planQueue <- task:
+	default:
+		s.background.Done()
+		busy := NewServiceError(
+			http.StatusServiceUnavailable,
+			"Too many plans are being generated, try again later",
+			nil,
+		)
+		s.finishPlanTask(task, busy)
+		return nil, busy
+	}
+
+	go s.heartbeatPlanTask(task)
+	return &submitted, nil
+}
+
+func (s *AIService) heartbeatPlanTask(task *planTask) {
+	ticker := time.NewTicker(planJobHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-task.done:
+			return
+		case <-ticker.C:
+			s.updatePlanTask(task, func(*models.PlanJob) {})
+		}
+	}
+}
+
+func (s *AIService) runPlanTask(task *planTask) {
+	defer s.background.Done()
+	defer close(task.done)
+
+	started := time.Now()
+	s.updatePlanTask(task, func(job *models.PlanJob) {
+		job.Status = models.PlanJobRunning
+		job.StartedAt = &started
+	})
+
+	ctx, cancel := context.WithTimeout(task.ctx, planJobTimeout)
+	defer cancel()
+	ctx = withPlanProgress(ctx, func(stage string) {
+		s.updatePlanTask(task, func(job *models.PlanJob) {
+			job.Stage = stage
+			job.Progress = planStageProgress[stage]
+		})
+	})
+
+	// Type and options never change, so they can be read without the lock
+	var err error
+	switch task.job.Type {
+	case models.PlanJobRegenerate:
+		_, err = s.RegenerateWorkoutPlan(ctx, task.job.Comments)
+	case models.PlanJobRegenerateWorkout:
+		_, err = s.RegenerateWorkout(ctx, task.job.WorkoutID, task.job.Comments)
+	case models.PlanJobMealPlan:
+		_, err = s.GenerateMealPlan(ctx)
+	case models.PlanJobPhase:
+		_, err = s.GenerateDuePhases(ctx)
+	default:
+		_, err = s.GenerateWorkoutPlan(ctx, task.job.Generator)
+	}
+	if err != nil {
+		fmt.Printf("Plan job %s failed: %v\n", task.job.ID.Hex(), err)
+	}
+	s.finishPlanTask(task, err)
+}
+
+// finishPlanTask records the outcome of a job and releases the user's active slot
+func (s *AIService) finishPlanTask(task *planTask, err error) {
+	finished := time.Now()
+	s.updatePlanTask(task, func(job *models.PlanJob) {
+		job.Active = false
+		job.FinishedAt = &finished
+		if err == nil {
+			job.Status = models.PlanJobSucceeded
+			job.Stage = models.PlanStageDone
+			job.Progress = planStageProgress[models.PlanStageDone]
+			return
+		}
+
+		job.Status = models.PlanJobFailed
+		job.Error = "Internal server error"
+		job.ErrorCode = http.StatusInternalServerError
+		var svcErr ServiceError
+		if errors.As(err, &svcErr) {
+			job.Error = svcErr.Message
+			job.ErrorCode = svcErr.Code
+		}
+	})
+}
+
+// failStalePlanJob fails an active job that lost its heartbeat and reports whether it did
+func (s *AIService) failStalePlanJob(ctx context.Context, job *models.PlanJob) bool {
+	if job.Finished() || time.Since(job.UpdatedAt) < planJobStaleAfter {
+		return false
+	}
+
+	now := time.Now()
+	job.Active = false
+	job.Status = models.PlanJobFailed
+	job.Error = "Plan job was interrupted, please submit it again"
+	job.ErrorCode = http.StatusServiceUnavailable
+	job.FinishedAt = &now
+	job.UpdatedAt = now
+	if err := s.MongoDBRepo.UpdatePlanJob(ctx, job); err != nil {
+		fmt.Printf("Failed to fail stale plan job %s: %v\n", job.ID.Hex(), err)
+	}
+	return true
+}
+
+// GetPlanJob returns a job of the current user, with the user's plan or meal plan once it succeeded
+func (s *AIService) GetPlanJob(ctx context.Context, jobID string) (*models.PlanJob, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Invalid job ID format",
+			err,
+		)
+	}
+
+	job, err := s.MongoDBRepo.GetPlanJob(ctx, userID, id)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get plan job",
+			err,
+		)
+	}
+	if job == nil {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Plan job not found",
+			nil,
+		)
+	}
+
+	s.failStalePlanJob(ctx, job)
+	if job.Status == models.PlanJobSucceeded && job.Type == models.PlanJobMealPlan {
+		plan, err := s.MongoDBRepo.GetMealPlan(ctx, userID)
+		if err != nil {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to get meal plan",
+				err,
+			)
+		}
+		job.MealPlan = plan
+	} else if job.Status == models.PlanJobSucceeded {
+		plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to get workout plan",
+				err,
+			)
+		}
+		job.Plan = plan
+	}
+	return job, nil
+}
+
+// WatchPlanJob calls onUpdate with the job whenever its status or stage
+// changes, until the job finished or ctx is done. Jobs may run on another
+// instance, so the job is polled from the database.
+func (s *AIService) WatchPlanJob(ctx context.Context, jobID string, onUpdate func(*models.PlanJob) error) error {
+	ticker := time.NewTicker(planJobPollInterval)
+	defer ticker.Stop()
+
+	var last *models.PlanJob
+	for {
+		job, err := s.GetPlanJob(ctx, jobID)
+		if err != nil {
+			return err
+		}
+		if last == nil || job.Status != last.Status || job.Stage != last.Stage {
+			if err := onUpdate(job); err != nil {
+				return err
+			}
+			last = job
+		}
+		if job.Finished() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
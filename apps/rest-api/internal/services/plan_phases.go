@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"rest-api/internal/models"
+	"rest-api/internal/progression"
+	"rest-api/internal/promptguard"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mesocycle is a phase of a timeframe layout
+type mesocycle struct {
+	focus string
+	weeks int
+}
+
+// mesocycleLayouts split long timeframes into phases, the weeks add up to the
+// timeframe. Shorter timeframes are a single block without phases.
+var mesocycleLayouts = map[string][]mesocycle{
+	"6months": {
+		{models.PhaseHypertrophy, 8}, {models.PhaseStrength, 8}, {models.PhasePeaking, 5}, {models.PhaseRecovery, 3},
+	},
+	"1year": {
+		{models.PhaseHypertrophy, 8}, {models.PhaseStrength, 8}, {models.PhasePeaking, 6}, {models.PhaseRecovery, 4},
+		{models.PhaseHypertrophy, 8}, {models.PhaseStrength, 8}, {models.PhasePeaking, 6}, {models.PhaseRecovery, 4},
+	},
+}
+
+// phaseFocus tells the model what the workouts of a phase train
+var phaseFocus = map[string]string{
+	models.PhaseHypertrophy: "Hypertrophy: build muscle and work capacity with moderate loads, 3-4 sets of 8-12 reps and 60-90 seconds rest",
+	models.PhaseStrength:    "Strength: heavier variations of the main movements, 4-5 sets of 4-6 reps and 2-3 minutes rest, fewer accessory exercises",
+	models.PhasePeaking:     "Peaking: low volume at the highest intensity, 3-5 sets of 2-5 reps of the main movements with full rest",
+	models.PhaseRecovery:    "Recovery: light loads, 2-3 sets of 10-15 reps, mobility and technique work and no sets to failure",
+}
+
+// phaseLeadTime is how long before its start a phase is generated, so the
+// user sees the workouts of the coming week
+const phaseLeadTime = 7 * 24 * time.Hour
+
+// planPhases lays out the phases of a plan that starts on start, nil for
+// timeframes without phases
+func planPhases(timeframe string, start time.Time) []models.PlanPhase {
+	layout := mesocycleLayouts[timeframe]
+	if len(layout) == 0 {
+		return nil
+	}
+
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	phases := make([]models.PlanPhase, 0, len(layout))
+	week := 1
+	for i, cycle := range layout {
+		phases = append(phases, models.PlanPhase{
+			Number:    i + 1,
+			Focus:     cycle.focus,
+			StartWeek: week,
+			Weeks:     cycle.weeks,
+			StartDate: start.AddDate(0, 0, 7*(week-1)),
+		})
+		week += cycle.weeks
+	}
+	return phases
+}
+
+// firstPhase is the part of the timeframe scheduled when a plan is created:
+// the first phase, or the whole timeframe as phase 0 without phases
+func (s *AIService) firstPhase(timeframe string, phases []models.PlanPhase, start time.Time) models.PlanPhase {
+	if len(phases) > 0 {
+		return phases[0]
+	}
+	return models.PlanPhase{
+		StartWeek: 1,
+		Weeks:     s.getWeeksFromTimeframe(timeframe),
+		StartDate: start,
+	}
+}
+
+// planGuidance describes the weeks to plan in the prompt: the phase at index
+// of long timeframes, else the whole timeframe. Previous lists the workouts
+// of the phase before, whose exercises the new phase should vary.
+func (s *AIService) planGuidance(profile *models.FitnessProfile, phases []models.PlanPhase, index int, previous []models.Workout) string {
+	if len(phases) == 0 {
+		return s.getTimeframeGuidance(profile.Timeframe, profile.AvailableMinutes)
+	}
+
+	phase := phases[index]
+	workoutsPerWeek := workoutsPerWeekFor(profile)
+	outline := make([]string, len(phases))
+	for i, p := range phases {
+		outline[i] = fmt.Sprintf("%d. %s (%d weeks)", p.Number, p.Focus, p.Weeks)
+	}
+
+	guidance := fmt.Sprintf("PLAN: %d workouts per week for the %d weeks of phase %d of %d (weeks %d-%d of %d). The other phases are planned separately.\nSCHEDULE: %s\nFOCUS: %s. Adapt it to the user's goal.\nPHASES: %s",
+		workoutsPerWeek, phase.Weeks, phase.Number, len(phases), phase.StartWeek, phase.StartWeek+phase.Weeks-1,
+		s.getWeeksFromTimeframe(profile.Timeframe), s.generateWorkoutSchedule(workoutsPerWeek, phase.Weeks),
+		phaseFocus[phase.Focus], strings.Join(outline, ", "))
+
+	var exercises []string
+	for _, workout := range previous {
+		for _, exercise := range workout.Exercises {
+			if !slices.Contains(exercises, exercise.Name) {
+				exercises = append(exercises, exercise.Name)
+			}
+		}
+	}
+	if len(exercises) > 0 {
+		guidance += fmt.Sprintf("\nPREVIOUS PHASE EXERCISES: %s\nVary them where the focus allows.", promptguard.Quote(strings.Join(exercises, ", ")))
+	}
+	return guidance
+}
+
+// phaseProgression selects the progression of a phase's workouts. It starts
+// over in every phase; recovery phases keep the prescription of their base
+// workouts.
+func (s *AIService) phaseProgression(profile *models.FitnessProfile, phase models.PlanPhase) progression.Model {
+	if phase.Focus == models.PhaseRecovery {
+		return progression.Model{ID: progression.TypeNone, Type: progression.TypeNone}
+	}
+	return s.progressionModel(profile)
+}
+
+// workoutProgression returns the progression of a scheduled workout and the
+// week of its phase the progression is at
+func (s *AIService) workoutProgression(plan *models.WorkoutPlan, profile *models.FitnessProfile, workout *models.Workout) (progression.Model, int) {
+	if workout.Phase < 1 || workout.Phase > len(plan.Phases) {
+		return s.progressionModel(profile), workout.Week
+	}
+	phase := plan.Phases[workout.Phase-1]
+	return s.phaseProgression(profile, phase), workout.Week - phase.StartWeek + 1
+}
+
+// duePhase returns the index of the first phase that is not generated yet
+// and starts within phaseLeadTime, -1 when there is none
+func duePhase(plan *models.WorkoutPlan) int {
+	index := slices.IndexFunc(plan.Phases, func(phase models.PlanPhase) bool {
+		return !phase.Generated
+	})
+	if index < 0 || time.Until(plan.Phases[index].StartDate) > phaseLeadTime {
+		return -1
+	}
+	return index
+}
+
+// GetWorkoutPlan returns the user's stored plan without generating one.
+// Without a plan it returns the generate job that is creating it, if any.
+// When the user reached a phase that is not generated yet, a phase job is
+// queued and returned with the plan; the plan itself is returned as stored.
+func (s *AIService) GetWorkoutPlan(ctx context.Context) (*models.WorkoutPlan, *models.PlanJob, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout plan",
+			err,
+		)
+	}
+	if plan == nil {
+		job, err := s.MongoDBRepo.GetActivePlanJob(ctx, userID)
+		if err != nil {
+			return nil, nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to get active plan job",
+				err,
+			)
+		}
+		if job != nil && job.Type == models.PlanJobGenerate && !s.failStalePlanJob(ctx, job) {
+			return nil, job, nil
+		}
+		return nil, nil, NewServiceError(
+			http.StatusNotFound,
+			"Workout plan not found",
+			nil,
+		)
+	}
+	if duePhase(plan) < 0 {
+		return plan, nil, nil
+	}
+
+	// The user's active job coalesces repeated requests. When the job cannot
+	// be submitted, e.g. while another job runs, the failure is returned as a
+	// failed phase job that is not stored, and a later request tries again.
+	job, err := s.SubmitPlanJob(ctx, models.PlanJobPhase, models.PlanJobOptions{})
+	if err != nil {
+		now := time.Now()
+		job = &models.PlanJob{
+			UserID:     userID,
+			Type:       models.PlanJobPhase,
+			Status:     models.PlanJobFailed,
+			Stage:      models.PlanStageQueued,
+			Error:      "Failed to queue the next phase of the plan",
+			ErrorCode:  http.StatusInternalServerError,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			FinishedAt: &now,
+		}
+		var svcErr ServiceError
+		if errors.As(err, &svcErr) {
+			job.Error = svcErr.Message
+			job.ErrorCode = svcErr.Code
+		}
+	}
+	pending := *plan
+	pending.PhaseJob = job
+	return &pending, nil, nil
+}
+
+// GenerateDuePhases generates the phases of the user's plan that are due, in
+// order. Every phase is saved on its own, so a failure keeps the ones before.
+func (s *AIService) GenerateDuePhases(ctx context.Context) (*models.WorkoutPlan, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout plan",
+			err,
+		)
+	}
+	if plan == nil {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Workout plan not found",
+			nil,
+		)
+	}
+
+	for index := duePhase(plan); index >= 0; index = duePhase(plan) {
+		plan, err = s.generatePlanPhase(ctx, plan, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// generatePlanPhase generates the base workouts of the phase at index, with
+// the generator of the plan, and adds its schedule to a copy of the plan.
+// The base workouts replace those of the short plan.
+func (s *AIService) generatePlanPhase(ctx context.Context, plan *models.WorkoutPlan, index int) (*models.WorkoutPlan, error) {
+	reportPlanStage(ctx, models.PlanStageProfile)
+	profile, err := s.Repo.GetFitnessProfile(ctx, plan.UserID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Complete your profile first",
+			err,
+		)
+	}
+
+	phase := plan.Phases[index]
+	workoutsPerWeek := workoutsPerWeekFor(profile)
+	var previous []models.Workout
+	for _, workout := range plan.Workouts {
+		if workout.Phase == phase.Number-1 {
+			previous = append(previous, workout)
+		}
+	}
+
+	var generated *generatedPlan
+	source := models.PlanSourceAI
+	if plan.Source == models.PlanSourceRules || s.Client == nil {
+		generated = generateRuleBasedPhase(profile, workoutsPerWeek, phase)
+		source = models.PlanSourceRules
+	} else {
+		reportPlanStage(ctx, models.PlanStageGenerating)
+		generated, err = s.generateAIPlan(ctx, plan.UserID, profile, workoutsPerWeek, s.planGuidance(profile, plan.Phases, index, previous))
+		if err != nil {
+			if ctx.Err() != nil || IsQuotaExceeded(err) {
+				return nil, err
+			}
+			fmt.Printf("AI phase generation failed, using rule-based phase: %v\n", err)
+			generated = generateRuleBasedPhase(profile, workoutsPerWeek, phase)
+			source = models.PlanSourceRules
+		}
+	}
+
+	reportPlanStage(ctx, models.PlanStageScheduling)
+	language, _ := responseLanguage(ctx, profile)
+	schedule := s.generateFullSchedule(generated.Workouts, workoutsPerWeek, phase, language.Code, s.phaseProgression(profile, phase))
+
+	now := time.Now()
+	updated := *plan
+	updated.Workouts = append(slices.Clone(plan.Workouts), schedule...)
+	updated.Phases = slices.Clone(plan.Phases)
+	updated.Phases[index].Generated = true
+	updated.Phases[index].Source = source
+	updated.Disclaimers = slices.Clone(plan.Disclaimers)
+	for _, disclaimer := range s.guardPlan(ctx, plan.UserID, profile, generated.Workouts) {
+		if !slices.Contains(updated.Disclaimers, disclaimer) {
+			updated.Disclaimers = append(updated.Disclaimers, disclaimer)
+		}
+	}
+	updated.UpdatedAt = now
+
+	shortPlan, err := s.MongoDBRepo.GetShortPlan(ctx, plan.UserID)
+	if err != nil || shortPlan == nil {
+		shortPlan = &models.ShortWorkoutPlan{
+			UserID:    plan.UserID,
+			Title:     plan.Title,
+			Timeframe: profile.Timeframe,
+			Status:    true,
+			CreatedAt: now,
+		}
+	}
+	shortPlan.BaseWorkouts = generated.Workouts
+	shortPlan.WorkoutsPerWeek = workoutsPerWeek
+	shortPlan.Phase = phase.Number
+	shortPlan.Source = source
+	shortPlan.PromptVersion = generated.PromptVersion
+	shortPlan.UpdatedAt = now
+
+	reportPlanStage(ctx, models.PlanStageSaving)
+	if err := s.savePlan(ctx, &updated, shortPlan, planChange{
+		reason:   models.PlanChangePhase,
+		author:   source,
+		comments: fmt.Sprintf("Phase %d: %s", phase.Number, phase.Focus),
+	}); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save workout plan",
+			err,
+		)
+	}
+	return &updated, nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+	"rest-api/internal/promptguard"
+)
+
+func TestPlanPhases(t *testing.T) {
+	service := &AIService{}
+	start := time.Date(2026, 3, 2, 15, 30, 0, 0, time.UTC)
+
+	for _, timeframe := range []string{"1month", "3months", "6months", "1year"} {
+		phases := planPhases(timeframe, start)
+		if _, long := mesocycleLayouts[timeframe]; !long {
+			if phases != nil {
+				t.Errorf("Expected no phases for %s, got %+v", timeframe, phases)
+			}
+			continue
+		}
+
+		week := 1
+		for i, phase := range phases {
+			if phase.Number != i+1 || phase.StartWeek != week || phase.Generated {
+				t.Errorf("Expected phase %d of %s to start in week %d, got %+v", i+1, timeframe, week, phase)
+			}
+			if expected := time.Date(2026, 3, 2+7*(week-1), 0, 0, 0, 0, time.UTC); !phase.StartDate.Equal(expected) {
+				t.Errorf("Expected phase %d of %s to start on %s, got %s", phase.Number, timeframe, expected, phase.StartDate)
+			}
+			if phaseFocus[phase.Focus] == "" {
+				t.Errorf("Expected a focus description for %s", phase.Focus)
+			}
+			week += phase.Weeks
+		}
+		if weeks := service.getWeeksFromTimeframe(timeframe); week-1 != weeks {
+			t.Errorf("Expected the phases of %s to cover %d weeks, got %d", timeframe, weeks, week-1)
+		}
+	}
+}
+
+func TestAIService_PlanGuidance(t *testing.T) {
+	service := &AIService{}
+	profile := &models.FitnessProfile{Goal: "muscle_gain", Timeframe: "1year", AvailableMinutes: 150}
+	phases := planPhases(profile.Timeframe, time.Now())
+	previous := []models.Workout{
+		{Exercises: []models.Exercise{{Name: "Push-ups"}, {Name: "Plank"}}},
+		{Exercises: []models.Exercise{{Name: "Push-ups"}}},
+	}
+
+	guidance := service.planGuidance(profile, phases, 4, previous)
+	for _, expected := range []string{
+		"3 workouts per week for the 8 weeks of phase 5 of 8 (weeks 27-34 of 52)",
+		"FOCUS: " + phaseFocus[models.PhaseHypertrophy],
+		"4. recovery (4 weeks)",
+		"PREVIOUS PHASE EXERCISES: " + promptguard.Quote("Push-ups, Plank"),
+	} {
+		if !strings.Contains(guidance, expected) {
+			t.Errorf("Expected the guidance to contain %q, got %s", expected, guidance)
+		}
+	}
+
+	profile.Timeframe = "3months"
+	if guidance := service.planGuidance(profile, nil, 0, nil); strings.Contains(guidance, "phase") {
+		t.Errorf("Expected the timeframe guidance without phases, got %s", guidance)
+	}
+}
+
+func TestAIService_GenerateWorkoutPlan_Phases(t *testing.T) {
+	repo := newMockProfileRepo()
+	repo.profiles[1] = &models.FitnessProfile{
+		Goal:             "muscle_gain",
+		FitnessLevel:     "intermediate",
+		AvailableMinutes: 150,
+		Timeframe:        "6months",
+	}
+	mongoRepo := &mockMongoDBRepo{}
+	service := &AIService{BaseService: BaseService{Repo: repo, MongoDBRepo: mongoRepo}}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	plan, err := service.GenerateWorkoutPlan(ctx, models.PlanSourceRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the 8 weeks of the hypertrophy phase are scheduled
+	if len(plan.Phases) != 4 || !plan.Phases[0].Generated || plan.Phases[0].Source != models.PlanSourceRules || plan.Phases[1].Generated {
+		t.Fatalf("Expected four phases with the first generated, got %+v", plan.Phases)
+	}
+	if len(plan.Workouts) != 3*8 || plan.Workouts[len(plan.Workouts)-1].Week != 8 || plan.Workouts[0].Phase != 1 {
+		t.Fatalf("Expected the workouts of the first phase, got %d", len(plan.Workouts))
+	}
+	if mongoRepo.shortPlans[1].Phase != 1 {
+		t.Errorf("Expected the short plan to hold the first phase, got %d", mongoRepo.shortPlans[1].Phase)
+	}
+
+	// The next phase is far away, nothing is generated
+	if plan, _, err = service.GetWorkoutPlan(ctx); err != nil || len(plan.Workouts) != 3*8 || plan.PhaseJob != nil {
+		t.Fatalf("Expected the stored plan without a job, got %v", err)
+	}
+
+	// Eight weeks later the user reaches the strength phase
+	for i := range plan.Phases {
+		plan.Phases[i].StartDate = plan.Phases[i].StartDate.AddDate(0, 0, -7*8)
+	}
+	pending, _, err := service.GetWorkoutPlan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.PhaseJob == nil || pending.PhaseJob.Type != models.PlanJobPhase || len(pending.Workouts) != 3*8 {
+		t.Fatalf("Expected the stored plan with a phase job, got %+v", pending.PhaseJob)
+	}
+	// A repeated request gets the same job
+	if again, _, err := service.GetWorkoutPlan(ctx); err != nil || again.PhaseJob == nil || again.PhaseJob.ID != pending.PhaseJob.ID {
+		t.Errorf("Expected the running phase job, got %+v", again.PhaseJob)
+	}
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := service.WaitBackground(waitCtx); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := service.GetPlanJob(ctx, pending.PhaseJob.ID.Hex()); err != nil || job.Status != models.PlanJobSucceeded {
+		t.Fatalf("Expected the phase job to succeed, got %+v", job)
+	}
+
+	plan = mongoRepo.plans[1]
+	if len(plan.Workouts) != 3*16 || !plan.Phases[1].Generated || plan.Phases[2].Generated {
+		t.Fatalf("Expected only the second phase to be added, got %d workouts and %+v", len(plan.Workouts), plan.Phases)
+	}
+
+	first := plan.Workouts[3*8]
+	if first.Phase != 2 || first.Week != 9 || !first.ScheduledDate.Equal(plan.Phases[1].StartDate) {
+		t.Errorf("Expected the strength phase to start in week 9 on %s, got %+v", plan.Phases[1].StartDate, first)
+	}
+	// Muscle gain for intermediates is 4x10, the strength phase 5x6 and its progression starts over
+	found := false
+	for _, exercise := range first.Exercises {
+		found = found || exercise.Sets == 5 && exercise.Reps == 6
+	}
+	if !found {
+		t.Errorf("Expected the strength prescription in the first week of the phase, got %+v", first.Exercises)
+	}
+
+	if mongoRepo.shortPlans[1].Phase != 2 || mongoRepo.shortPlans[1].BaseWorkouts[0].Name != first.BaseWorkout {
+		t.Errorf("Expected the plan and the base workouts of the phase to be stored, got %+v", mongoRepo.shortPlans[1])
+	}
+	if latest := mongoRepo.versions[len(mongoRepo.versions)-1]; latest.Reason != models.PlanChangePhase || latest.Author != models.PlanSourceRules {
+		t.Errorf("Expected the phase to be kept as a version, got %+v", latest)
+	}
+
+	// Changing the last week of the first phase leaves the next phase alone
+	last := &plan.Workouts[3*8-1]
+	for _, occurrence := range upcomingOccurrences(plan, last) {
+		if occurrence.Phase != 1 {
+			t.Errorf("Expected only occurrences of the first phase, got %+v", occurrence)
+		}
+	}
+}
+
+func TestAIService_GetWorkoutPlan(t *testing.T) {
+	repo := newMockProfileRepo()
+	repo.profiles[1] = &models.FitnessProfile{FitnessLevel: "beginner", AvailableMinutes: 150, Timeframe: "6months"}
+	mongoRepo := &mockMongoDBRepo{}
+	service := &AIService{BaseService: BaseService{Repo: repo, MongoDBRepo: mongoRepo}}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	// Reading never generates a plan
+	if _, _, err := service.GetWorkoutPlan(ctx); err == nil || err.(ServiceError).Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 without a plan, got %v", err)
+	}
+
+	// While the plan is generated, the generate job is returned
+	generating := &models.PlanJob{UserID: 1, Type: models.PlanJobGenerate, Status: models.PlanJobRunning, Active: true, UpdatedAt: time.Now()}
+	if err := mongoRepo.CreatePlanJob(ctx, generating); err != nil {
+		t.Fatal(err)
+	}
+	plan, job, err := service.GetWorkoutPlan(ctx)
+	if err != nil || plan != nil || job == nil || job.ID != generating.ID {
+		t.Fatalf("Expected the generate job, got %+v (%v)", job, err)
+	}
+	if mongoRepo.plans[1] != nil {
+		t.Error("Expected no plan to be generated")
+	}
+
+	// A phase that cannot be queued behind another job is reported as a failed phase job
+	if _, err := service.GenerateWorkoutPlan(ctx, models.PlanSourceRules); err != nil {
+		t.Fatal(err)
+	}
+	for i := range mongoRepo.plans[1].Phases {
+		mongoRepo.plans[1].Phases[i].StartDate = mongoRepo.plans[1].Phases[i].StartDate.AddDate(0, 0, -7*8)
+	}
+	plan, job, err = service.GetWorkoutPlan(ctx)
+	if err != nil || job != nil {
+		t.Fatalf("Expected the plan, got %+v (%v)", job, err)
+	}
+	if plan.PhaseJob == nil || plan.PhaseJob.Status != models.PlanJobFailed || plan.PhaseJob.ErrorCode != http.StatusConflict || plan.PhaseJob.Error == "" {
+		t.Errorf("Expected a failed phase job, got %+v", plan.PhaseJob)
+	}
+	if plan.Phases[1].Generated {
+		t.Error("Expected the phase not to be generated")
+	}
+}
+
+func TestAIService_GenerateDuePhases_QuotaExceeded(t *testing.T) {
+	service, mongoRepo, calls := newUsageTestService(t, AIQuota{MonthlyTokens: 100})
+	service.Repo.(*mockProfileRepo).profiles[1] = &models.FitnessProfile{
+		Goal: "general_fitness", FitnessLevel: "beginner", AvailableMinutes: 120, Timeframe: "6months",
+	}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+	if _, err := service.GenerateWorkoutPlan(ctx, models.PlanSourceRules); err != nil {
+		t.Fatal(err)
+	}
+	plan := mongoRepo.plans[1]
+	plan.Source = models.PlanSourceAI
+	for i := range plan.Phases {
+		plan.Phases[i].StartDate = plan.Phases[i].StartDate.AddDate(0, 0, -7*8)
+	}
+	mongoRepo.usage = []models.AIUsageRecord{{UserID: 1, Feature: models.AIFeatureChat, TotalTokens: 100, CreatedAt: time.Now()}}
+
+	if _, err := service.GenerateDuePhases(ctx); !IsQuotaExceeded(err) {
+		t.Fatalf("Expected the quota error instead of a rule-based phase, got %v", err)
+	}
+	if *calls != 0 || mongoRepo.plans[1].Phases[1].Generated {
+		t.Errorf("Expected the phase not to be generated, got %d model calls", *calls)
+	}
+}
@@ -0,0 +1,408 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"rest-api/internal/models"
+)
+
+// Exercise categories used by the rule-based generator
+const (
+	categoryPush     = "push"
+	categoryPull     = "pull"
+	categoryLegs     = "legs"
+	categoryCore     = "core"
+	categoryCardio   = "cardio"
+	categoryMobility = "mobility"
+)
+
+// Contraindication tags of library exercises
+const (
+	tagKnee      = "knee"
+	tagBack      = "back"
+	tagShoulder  = "shoulder"
+	tagWrist     = "wrist"
+	tagImpact    = "impact"
+	tagIntensity = "intensity"
+)
+
+// equipmentNone marks exercises that only need body weight
+const equipmentNone = "none"
+
+// libraryExercise is an entry of the exercise library used for offline plans
+type libraryExercise struct {
+	Name        string
+	MuscleGroup string
+	Category    string
+	Equipment   string
+	// Level is the minimum fitness level: 1 beginner, 2 intermediate, 3 advanced
+	Level int
+	// Hold exercises are timed instead of counted
+	Hold      bool
+	Avoid     []string
+	Technique string
+}
+
+// exerciseLibrary is ordered from the most to the least preferred exercise of each category
+var exerciseLibrary = []libraryExercise{
+	// Push
+	{Name: "Push-ups", MuscleGroup: "Chest", Category: categoryPush, Equipment: equipmentNone, Level: 1, Avoid: []string{tagWrist}, Technique: "Hands under shoulders, body in a straight line, lower the chest to just above the floor"},
+	{Name: "Dumbbell Bench Press", MuscleGroup: "Chest", Category: categoryPush, Equipment: "dumbbells", Level: 1, Technique: "Lie on a bench or the floor, press the dumbbells up over the chest and lower with control"},
+	{Name: "Band Chest Press", MuscleGroup: "Chest", Category: categoryPush, Equipment: "resistance_bands", Level: 1, Technique: "Anchor the band behind you and press forward until the arms are straight"},
+	{Name: "Incline Push-ups", MuscleGroup: "Chest", Category: categoryPush, Equipment: equipmentNone, Level: 1, Technique: "Hands on a bench or table, keep the body straight and lower the chest to the edge"},
+	{Name: "Dumbbell Shoulder Press", MuscleGroup: "Shoulders", Category: categoryPush, Equipment: "dumbbells", Level: 1, Avoid: []string{tagShoulder}, Technique: "Press the dumbbells from shoulder height overhead without arching the lower back"},
+	{Name: "Bench Dips", MuscleGroup: "Triceps", Category: categoryPush, Equipment: equipmentNone, Level: 1, Avoid: []string{tagShoulder, tagWrist}, Technique: "Hands on the edge of a chair, bend the elbows to 90 degrees and press back up"},
+	{Name: "Dumbbell Lateral Raise", MuscleGroup: "Shoulders", Category: categoryPush, Equipment: "dumbbells", Level: 1, Technique: "Raise the dumbbells to the sides up to shoulder height with slightly bent elbows"},
+	{Name: "Barbell Bench Press", MuscleGroup: "Chest", Category: categoryPush, Equipment: "barbell", Level: 2, Avoid: []string{tagShoulder}, Technique: "Lower the bar to mid chest with elbows at 45 degrees and press back up"},
+	{Name: "Pike Push-ups", MuscleGroup: "Shoulders", Category: categoryPush, Equipment: equipmentNone, Level: 2, Avoid: []string{tagShoulder, tagWrist}, Technique: "Hips high in an inverted V, bend the elbows to bring the head towards the floor"},
+	{Name: "Diamond Push-ups", MuscleGroup: "Triceps", Category: categoryPush, Equipment: equipmentNone, Level: 3, Avoid: []string{tagWrist}, Technique: "Hands together under the chest forming a diamond, keep the elbows close to the body"},
+
+	// Pull
+	{Name: "Dumbbell Row", MuscleGroup: "Back", Category: categoryPull, Equipment: "dumbbells", Level: 1, Technique: "Support one hand on a bench, pull the dumbbell to the hip keeping the back flat"},
+	{Name: "Band Row", MuscleGroup: "Back", Category: categoryPull, Equipment: "resistance_bands", Level: 1, Technique: "Anchor the band in front, pull the handles to the ribs and squeeze the shoulder blades"},
+	{Name: "Reverse Snow Angels", MuscleGroup: "Back", Category: categoryPull, Equipment: equipmentNone, Level: 1, Technique: "Lie face down, lift the arms slightly and sweep them from the hips to overhead"},
+	{Name: "Superman Hold", MuscleGroup: "Lower Back", Category: categoryPull, Equipment: equipmentNone, Level: 1, Hold: true, Avoid: []string{tagBack}, Technique: "Lie face down and lift arms and legs a few centimetres off the floor"},
+	{Name: "Dumbbell Biceps Curl", MuscleGroup: "Biceps", Category: categoryPull, Equipment: "dumbbells", Level: 1, Technique: "Keep the elbows at your sides and curl the dumbbells up without swinging"},
+	{Name: "Band Pull-Aparts", MuscleGroup: "Back", Category: categoryPull, Equipment: "resistance_bands", Level: 1, Technique: "Hold the band at shoulder height and pull it apart until it touches the chest"},
+	{Name: "Inverted Row", MuscleGroup: "Back", Category: categoryPull, Equipment: equipmentNone, Level: 2, Technique: "Lie under a sturdy table, grip the edge and pull the chest up to it"},
+	{Name: "Chin-ups", MuscleGroup: "Biceps", Category: categoryPull, Equipment: "pull_up_bar", Level: 2, Avoid: []string{tagShoulder}, Technique: "Palms facing you, pull until the chin is over the bar and lower slowly"},
+	{Name: "Barbell Row", MuscleGroup: "Back", Category: categoryPull, Equipment: "barbell", Level: 2, Avoid: []string{tagBack}, Technique: "Hinge at the hips with a flat back and pull the bar to the lower chest"},
+	{Name: "Pull-ups", MuscleGroup: "Back", Category: categoryPull, Equipment: "pull_up_bar", Level: 3, Avoid: []string{tagShoulder}, Technique: "Palms facing away, pull until the chin is over the bar without kipping"},
+
+	// Legs
+	{Name: "Bodyweight Squats", MuscleGroup: "Legs", Category: categoryLegs, Equipment: equipmentNone, Level: 1, Avoid: []string{tagKnee}, Technique: "Feet shoulder width apart, sit back and down keeping the chest up and heels on the floor"},
+	{Name: "Glute Bridges", MuscleGroup: "Glutes", Category: categoryLegs, Equipment: equipmentNone, Level: 1, Technique: "Lie on your back with knees bent and drive the hips up by squeezing the glutes"},
+	{Name: "Goblet Squat", MuscleGroup: "Legs", Category: categoryLegs, Equipment: "dumbbells", Level: 1, Avoid: []string{tagKnee}, Technique: "Hold a dumbbell at the chest and squat between the knees"},
+	{Name: "Calf Raises", MuscleGroup: "Calves", Category: categoryLegs, Equipment: equipmentNone, Level: 1, Technique: "Rise onto the balls of the feet, pause at the top and lower slowly"},
+	{Name: "Side-lying Leg Raises", MuscleGroup: "Hips", Category: categoryLegs, Equipment: equipmentNone, Level: 1, Technique: "Lie on your side and lift the top leg without rolling the hips back"},
+	{Name: "Wall Sit", MuscleGroup: "Quads", Category: categoryLegs, Equipment: equipmentNone, Level: 1, Hold: true, Avoid: []string{tagKnee}, Technique: "Back against a wall, slide down until the knees are at 90 degrees and hold"},
+	{Name: "Dumbbell Romanian Deadlift", MuscleGroup: "Hamstrings", Category: categoryLegs, Equipment: "dumbbells", Level: 2, Avoid: []string{tagBack}, Technique: "Soft knees, push the hips back and lower the dumbbells along the legs with a flat back"},
+	{Name: "Reverse Lunges", MuscleGroup: "Legs", Category: categoryLegs, Equipment: equipmentNone, Level: 2, Avoid: []string{tagKnee}, Technique: "Step back and lower the back knee towards the floor, front knee over the ankle"},
+	{Name: "Kettlebell Swing", MuscleGroup: "Glutes", Category: categoryLegs, Equipment: "kettlebell", Level: 2, Avoid: []string{tagBack}, Technique: "Hinge at the hips and snap them forward to swing the kettlebell to chest height"},
+	{Name: "Single-leg Glute Bridge", MuscleGroup: "Glutes", Category: categoryLegs, Equipment: equipmentNone, Level: 2, Technique: "One foot on the floor, other leg straight, drive the hips up through the heel"},
+	{Name: "Barbell Back Squat", MuscleGroup: "Legs", Category: categoryLegs, Equipment: "barbell", Level: 3, Avoid: []string{tagKnee, tagBack}, Technique: "Bar on the upper back, brace the core and squat to parallel or below"},
+
+	// Core
+	{Name: "Plank", MuscleGroup: "Core", Category: categoryCore, Equipment: equipmentNone, Level: 1, Hold: true, Technique: "Forearms under shoulders, body in a straight line, squeeze glutes and abs"},
+	{Name: "Dead Bug", MuscleGroup: "Core", Category: categoryCore, Equipment: equipmentNone, Level: 1, Technique: "Lie on your back, lower the opposite arm and leg while pressing the lower back into the floor"},
+	{Name: "Bird Dog", MuscleGroup: "Core", Category: categoryCore, Equipment: equipmentNone, Level: 1, Technique: "On hands and knees, extend the opposite arm and leg and keep the hips level"},
+	{Name: "Side Plank", MuscleGroup: "Obliques", Category: categoryCore, Equipment: equipmentNone, Level: 2, Hold: true, Avoid: []string{tagShoulder}, Technique: "On one forearm, lift the hips so the body forms a straight line"},
+	{Name: "Bicycle Crunches", MuscleGroup: "Abs", Category: categoryCore, Equipment: equipmentNone, Level: 2, Technique: "Bring the opposite elbow towards the knee while extending the other leg"},
+	{Name: "Russian Twists", MuscleGroup: "Obliques", Category: categoryCore, Equipment: equipmentNone, Level: 2, Avoid: []string{tagBack}, Technique: "Sit with the torso leaned back and rotate from side to side"},
+	{Name: "Mountain Climbers", MuscleGroup: "Core", Category: categoryCore, Equipment: equipmentNone, Level: 2, Avoid: []string{tagWrist, tagIntensity}, Technique: "From a high plank, drive the knees towards the chest one after another"},
+	{Name: "Hanging Knee Raises", MuscleGroup: "Abs", Category: categoryCore, Equipment: "pull_up_bar", Level: 3, Avoid: []string{tagShoulder}, Technique: "Hang from the bar and raise the knees to the chest without swinging"},
+
+	// Cardio
+	{Name: "Marching in Place", MuscleGroup: "Cardio", Category: categoryCardio, Equipment: equipmentNone, Level: 1, Technique: "Lift the knees to hip height and swing the arms at a steady pace"},
+	{Name: "Shadow Boxing", MuscleGroup: "Cardio", Category: categoryCardio, Equipment: equipmentNone, Level: 1, Technique: "Light on your feet, throw controlled punches while keeping the guard up"},
+	{Name: "Jumping Jacks", MuscleGroup: "Cardio", Category: categoryCardio, Equipment: equipmentNone, Level: 1, Avoid: []string{tagImpact, tagKnee}, Technique: "Jump the feet out while raising the arms overhead, then back together"},
+	{Name: "High Knees", MuscleGroup: "Cardio", Category: categoryCardio, Equipment: equipmentNone, Level: 2, Avoid: []string{tagImpact, tagIntensity}, Technique: "Run in place driving the knees up to hip height"},
+	{Name: "Skater Hops", MuscleGroup: "Cardio", Category: categoryCardio, Equipment: equipmentNone, Level: 2, Avoid: []string{tagImpact, tagKnee}, Technique: "Leap sideways from one foot to the other, landing softly"},
+	{Name: "Burpees", MuscleGroup: "Full Body", Category: categoryCardio, Equipment: equipmentNone, Level: 3, Avoid: []string{tagImpact, tagIntensity, tagWrist}, Technique: "Squat, kick the feet back to a plank, return and jump up"},
+
+	// Mobility
+	{Name: "Cat-Cow", MuscleGroup: "Mobility", Category: categoryMobility, Equipment: equipmentNone, Level: 1, Technique: "On hands and knees, alternate rounding and arching the spine with the breath"},
+	{Name: "Hip Flexor Stretch", MuscleGroup: "Hips", Category: categoryMobility, Equipment: equipmentNone, Level: 1, Hold: true, Technique: "Half kneeling, tuck the pelvis and shift forward until the front of the hip stretches"},
+	{Name: "Hamstring Stretch", MuscleGroup: "Hamstrings", Category: categoryMobility, Equipment: equipmentNone, Level: 1, Hold: true, Technique: "One leg straight, hinge forward from the hips with a long spine"},
+	{Name: "Thoracic Rotations", MuscleGroup: "Mobility", Category: categoryMobility, Equipment: equipmentNone, Level: 1, Technique: "On hands and knees, place one hand behind the head and rotate the elbow to the ceiling"},
+	{Name: "Child's Pose", MuscleGroup: "Flexibility", Category: categoryMobility, Equipment: equipmentNone, Level: 1, Hold: true, Technique: "Sit back on the heels with the arms stretched forward and relax the back"},
+	{Name: "Arm Circles", MuscleGroup: "Shoulders", Category: categoryMobility, Equipment: equipmentNone, Level: 1, Technique: "Draw slow, growing circles with straight arms in both directions"},
+	{Name: "World's Greatest Stretch", MuscleGroup: "Full Body", Category: categoryMobility, Equipment: equipmentNone, Level: 2, Technique: "From a lunge, place the hand inside the front foot and rotate the other arm up"},
+}
+
+// healthRestrictions maps health issue keywords to the tags of exercises to avoid
+var healthRestrictions = []struct {
+	keywords []string
+	avoid    []string
+}{
+	{[]string{"knee", "menisc", "acl"}, []string{tagKnee, tagImpact}},
+	{[]string{"back", "spine", "disc", "hernia", "sciatica"}, []string{tagBack}},
+	{[]string{"shoulder", "rotator"}, []string{tagShoulder}},
+	{[]string{"wrist", "carpal", "elbow"}, []string{tagWrist}},
+	{[]string{"ankle", "hip", "joint", "arthritis", "osteopor", "pregnan", "obes"}, []string{tagImpact}},
+	{[]string{"heart", "cardiac", "hypertension", "blood pressure", "asthma"}, []string{tagIntensity, tagImpact}},
+}
+
+// workoutTemplate describes one workout of a split by the categories it draws from
+type workoutTemplate struct {
+	Name        string
+	Description string
+	// Slots are filled in order until the workout has enough exercises
+	Slots []string
+}
+
+var (
+	fullBodyA = workoutTemplate{"Full Body A", "Balanced strength session for the whole body",
+		[]string{categoryLegs, categoryPush, categoryPull, categoryCore, categoryLegs, categoryPush, categoryPull, categoryCardio, categoryMobility}}
+	fullBodyB = workoutTemplate{"Full Body B", "Whole body session with a pulling emphasis",
+		[]string{categoryPull, categoryLegs, categoryPush, categoryCore, categoryPull, categoryLegs, categoryCore, categoryCardio, categoryMobility}}
+	fullBodyC = workoutTemplate{"Full Body C", "Whole body session with a lower body emphasis",
+		[]string{categoryLegs, categoryPull, categoryPush, categoryLegs, categoryCore, categoryPush, categoryCore, categoryCardio, categoryMobility}}
+	upperBody = workoutTemplate{"Upper Body", "Chest, back, shoulders and arms",
+		[]string{categoryPush, categoryPull, categoryPush, categoryPull, categoryCore, categoryPush, categoryPull, categoryMobility}}
+	lowerBody = workoutTemplate{"Lower Body", "Legs, glutes and core",
+		[]string{categoryLegs, categoryLegs, categoryLegs, categoryCore, categoryLegs, categoryCore, categoryCardio, categoryMobility}}
+	pushDay = workoutTemplate{"Push", "Chest, shoulders and triceps",
+		[]string{categoryPush, categoryPush, categoryPush, categoryCore, categoryPush, categoryCore, categoryCardio, categoryMobility}}
+	pullDay = workoutTemplate{"Pull", "Back and biceps",
+		[]string{categoryPull, categoryPull, categoryPull, categoryCore, categoryPull, categoryCore, categoryCardio, categoryMobility}}
+	legDay = workoutTemplate{"Legs", "Quads, hamstrings, glutes and calves",
+		[]string{categoryLegs, categoryLegs, categoryLegs, categoryLegs, categoryCore, categoryLegs, categoryCore, categoryMobility}}
+	conditioning = workoutTemplate{"Conditioning", "Cardio intervals with core and leg work",
+		[]string{categoryCardio, categoryCore, categoryCardio, categoryLegs, categoryCardio, categoryCore, categoryMobility, categoryCardio}}
+	mobilityDay = workoutTemplate{"Mobility & Core", "Stretching, joint mobility and core stability",
+		[]string{categoryMobility, categoryCore, categoryMobility, categoryMobility, categoryCore, categoryMobility, categoryCore, categoryMobility}}
+)
+
+// weeklySplits is the base split for each number of workouts per week
+var weeklySplits = map[int][]workoutTemplate{
+	2: {fullBodyA, fullBodyB},
+	3: {fullBodyA, fullBodyB, fullBodyC},
+	4: {upperBody, lowerBody, upperBody, lowerBody},
+	5: {pushDay, pullDay, legDay, upperBody, lowerBody},
+	6: {pushDay, pullDay, legDay, pushDay, pullDay, legDay},
+}
+
+// prescription is the sets, reps and rest used for every non-timed exercise
+type prescription struct {
+	Sets    int
+	Reps    int
+	RestSec int
+}
+
+var goalPrescriptions = map[string]prescription{
+	"muscle_gain":     {Sets: 4, Reps: 10, RestSec: 90},
+	"weight_loss":     {Sets: 3, Reps: 15, RestSec: 45},
+	"endurance":       {Sets: 3, Reps: 20, RestSec: 30},
+	"flexibility":     {Sets: 2, Reps: 12, RestSec: 30},
+	"general_fitness": {Sets: 3, Reps: 12, RestSec: 60},
+}
+
+var goalTitles = map[string]string{
+	"muscle_gain":     "Muscle Gain",
+	"weight_loss":     "Weight Loss",
+	"endurance":       "Endurance",
+	"flexibility":     "Flexibility",
+	"general_fitness": "General Fitness",
+}
+
+func fitnessLevelRank(level string) int {
+	switch level {
+	case "advanced":
+		return 3
+	case "intermediate":
+		return 2
+	default:
+		return 1
+	}
+}
+
+// avoidedTags collects the contraindication tags implied by the user's health issues
+func avoidedTags(healthIssues []string) map[string]bool {
+	avoid := make(map[string]bool)
+	for _, issue := range healthIssues {
+		issue = strings.ToLower(issue)
+		for _, restriction := range healthRestrictions {
+			for _, keyword := range restriction.keywords {
+				if strings.Contains(issue, keyword) {
+					for _, tag := range restriction.avoid {
+						avoid[tag] = true
+					}
+					break
+				}
+			}
+		}
+	}
+	return avoid
+}
+
+// availableExercises filters the library by fitness level, equipment and health issues
+func availableExercises(profile *models.FitnessProfile) map[string][]libraryExercise {
+	level := fitnessLevelRank(profile.FitnessLevel)
+	avoid := avoidedTags(profile.HealthIssues)
+	equipment := map[string]bool{equipmentNone: true}
+	for _, item := range profile.Equipment {
+		equipment[item] = true
+	}
+
+	byCategory := make(map[string][]libraryExercise)
+	for _, exercise := range exerciseLibrary {
+		if exercise.Level > level || !equipment[exercise.Equipment] {
+			continue
+		}
+		allowed := true
+		for _, tag := range exercise.Avoid {
+			if avoid[tag] {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			byCategory[exercise.Category] = append(byCategory[exercise.Category], exercise)
+		}
+	}
+	return byCategory
+}
+
+// planSplit picks the weekly split, swapping in goal specific sessions
+func planSplit(goal string, workoutsPerWeek int) []workoutTemplate {
+	split := append([]workoutTemplate(nil), weeklySplits[workoutsPerWeek]...)
+	if len(split) == 0 {
+		split = []workoutTemplate{fullBodyA, fullBodyB}
+	}
+
+	switch goal {
+	case "weight_loss", "endurance":
+		if len(split) >= 3 {
+			split[len(split)-1] = conditioning
+		}
+	case "flexibility":
+		split[len(split)-1] = mobilityDay
+	}
+	return split
+}
+
+// exercisesPerWorkout scales the workout length with the time available per session
+func exercisesPerWorkout(availableMinutes, workoutsPerWeek int) int {
+	count := availableMinutes / workoutsPerWeek / 8
+	if count < minExercisesPerWorkout {
+		count = minExercisesPerWorkout
+	}
+	if count > 8 {
+		count = 8
+	}
+	return count
+}
+
+// generateRuleBasedPlan builds a complete plan from the exercise library without
+// calling the AI. The result is deterministic for a given profile.
+func generateRuleBasedPlan(profile *models.FitnessProfile, workoutsPerWeek int) *generatedPlan {
+	return generateRuleBasedPhase(profile, workoutsPerWeek, models.PlanPhase{})
+}
+
+// generateRuleBasedPhase builds the base workouts of a plan phase. The
+// prescription follows the focus of the phase and every phase starts further
+// down the library for variety.
+func generateRuleBasedPhase(profile *models.FitnessProfile, workoutsPerWeek int, phase models.PlanPhase) *generatedPlan {
+	library := availableExercises(profile)
+	split := planSplit(profile.Goal, workoutsPerWeek)
+	count := exercisesPerWorkout(profile.AvailableMinutes, workoutsPerWeek)
+
+	base, ok := goalPrescriptions[profile.Goal]
+	if !ok {
+		base = goalPrescriptions["general_fitness"]
+	}
+	level := fitnessLevelRank(profile.FitnessLevel)
+	holdSec := []int{20, 30, 45}[level-1]
+	switch level {
+	case 1:
+		base.Sets = max(base.Sets-1, 2)
+		base.RestSec += 15
+	case 3:
+		base.Sets++
+	}
+	base = phasePrescription(base, phase.Focus)
+	variation := max(phase.Number-1, 0)
+
+	workouts := make([]models.Workout, 0, len(split))
+	occurrences := make(map[string]int)
+	for _, template := range split {
+		occurrence := occurrences[template.Name]
+		occurrences[template.Name]++
+
+		name := template.Name
+		if occurrence > 0 {
+			name = fmt.Sprintf("%s %d", template.Name, occurrence+1)
+		}
+
+		workouts = append(workouts, models.Workout{
+			Name:        name,
+			Description: template.Description,
+			Status:      "planned",
+			Exercises:   pickExercises(library, template.Slots, count, occurrence+variation, base, holdSec),
+		})
+	}
+
+	title, ok := goalTitles[profile.Goal]
+	if !ok {
+		title = goalTitles["general_fitness"]
+	}
+
+	return &generatedPlan{
+		Title:    fmt.Sprintf("%s Plan - %d workouts per week", title, workoutsPerWeek),
+		Workouts: workouts,
+	}
+}
+
+// phasePrescription adapts a prescription to the focus of a plan phase
+func phasePrescription(base prescription, focus string) prescription {
+	switch focus {
+	case models.PhaseStrength:
+		base.Sets++
+		base.Reps = max(base.Reps*2/3, 5)
+		base.RestSec += 60
+	case models.PhasePeaking:
+		base.Reps = max(base.Reps/2, 3)
+		base.RestSec += 90
+	case models.PhaseRecovery:
+		base.Sets = max(base.Sets-1, 2)
+	}
+	return base
+}
+
+// pickExercises fills the template slots from the library. Repeated workouts
+// of the same template start further down each category for variety.
+func pickExercises(library map[string][]libraryExercise, slots []string, count, occurrence int, base prescription, holdSec int) []models.Exercise {
+	// Core and mobility are always available, so short workouts can be padded with them
+	slots = append(append([]string(nil), slots...), categoryCore, categoryMobility, categoryCore, categoryMobility)
+
+	used := make(map[string]bool)
+	cursors := make(map[string]int)
+	var exercises []models.Exercise
+
+	for _, category := range slots {
+		if len(exercises) == count {
+			break
+		}
+		candidates := library[category]
+		for len(candidates) > 0 && cursors[category] < len(candidates) {
+			candidate := candidates[(occurrence*2+cursors[category])%len(candidates)]
+			cursors[category]++
+			if used[candidate.Name] {
+				continue
+			}
+			used[candidate.Name] = true
+			exercises = append(exercises, prescribe(candidate, base, holdSec))
+			break
+		}
+	}
+
+	return exercises
+}
+
+func prescribe(exercise libraryExercise, base prescription, holdSec int) models.Exercise {
+	result := models.Exercise{
+		Name:        exercise.Name,
+		MuscleGroup: exercise.MuscleGroup,
+		Sets:        base.Sets,
+		Reps:        base.Reps,
+		RestSec:     base.RestSec,
+		Technique:   exercise.Technique,
+	}
+
+	switch {
+	case exercise.Hold:
+		result.Reps = 1
+		result.Notes = fmt.Sprintf("Hold for %d seconds", holdSec)
+	case exercise.Category == categoryMobility:
+		result.Sets = 2
+		result.Reps = 10
+		result.RestSec = 30
+	case exercise.Category == categoryCardio:
+		result.Reps = min(base.Reps*2, maxReps)
+		result.Notes = "Keep a steady pace, count each leg or arm movement as one rep"
+	}
+
+	return result
+}
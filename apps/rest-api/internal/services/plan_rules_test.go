@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+func TestGenerateRuleBasedPlan_AlwaysValid(t *testing.T) {
+	goals := []string{"weight_loss", "muscle_gain", "endurance", "flexibility", "general_fitness"}
+	levels := []string{"beginner", "intermediate", "advanced"}
+	equipmentSets := [][]string{nil, {"dumbbells", "pull_up_bar"}, {"barbell", "kettlebell", "resistance_bands", "bench"}}
+	healthSets := [][]string{nil, {"Knee pain", "Lower back injury", "Shoulder impingement", "Wrist pain", "Hypertension"}}
+
+	for _, goal := range goals {
+		for _, level := range levels {
+			for _, minutes := range []int{30, 150, 240, 1000} {
+				for _, equipment := range equipmentSets {
+					for _, health := range healthSets {
+						profile := &models.FitnessProfile{
+							Goal:             goal,
+							FitnessLevel:     level,
+							AvailableMinutes: minutes,
+							Equipment:        equipment,
+							HealthIssues:     health,
+						}
+						workoutsPerWeek := workoutsPerWeekFor(profile)
+
+						plan := generateRuleBasedPlan(profile, workoutsPerWeek)
+						if problems := validateWorkoutPlan(plan, workoutsPerWeek); len(problems) > 0 {
+							t.Errorf("Invalid plan for %s/%s/%d min/%v/%v: %v", goal, level, minutes, equipment, health, problems)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateRuleBasedPlan_RespectsProfile(t *testing.T) {
+	profile := &models.FitnessProfile{
+		Goal:             "muscle_gain",
+		FitnessLevel:     "beginner",
+		AvailableMinutes: 200,
+		HealthIssues:     []string{"Knee injury"},
+	}
+
+	plan := generateRuleBasedPlan(profile, workoutsPerWeekFor(profile))
+
+	byName := make(map[string]libraryExercise)
+	for _, exercise := range exerciseLibrary {
+		byName[exercise.Name] = exercise
+	}
+
+	for _, workout := range plan.Workouts {
+		for _, exercise := range workout.Exercises {
+			entry := byName[exercise.Name]
+			if entry.Equipment != equipmentNone {
+				t.Errorf("Expected body weight exercises only, got %s (%s)", exercise.Name, entry.Equipment)
+			}
+			if entry.Level > 1 {
+				t.Errorf("Expected beginner exercises only, got %s", exercise.Name)
+			}
+			for _, tag := range entry.Avoid {
+				if tag == tagKnee || tag == tagImpact {
+					t.Errorf("Expected knee friendly exercises only, got %s", exercise.Name)
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateRuleBasedPlan_UsesEquipment(t *testing.T) {
+	profile := &models.FitnessProfile{
+		Goal:             "general_fitness",
+		FitnessLevel:     "intermediate",
+		AvailableMinutes: 200,
+		Equipment:        []string{"dumbbells"},
+	}
+
+	plan := generateRuleBasedPlan(profile, workoutsPerWeekFor(profile))
+
+	found := false
+	for _, workout := range plan.Workouts {
+		for _, exercise := range workout.Exercises {
+			if strings.HasPrefix(exercise.Name, "Dumbbell") || exercise.Name == "Goblet Squat" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected dumbbell exercises when dumbbells are available")
+	}
+}
+
+func TestGenerateRuleBasedPlan_Deterministic(t *testing.T) {
+	profile := &models.FitnessProfile{Goal: "weight_loss", FitnessLevel: "advanced", AvailableMinutes: 300}
+
+	first := generateRuleBasedPlan(profile, workoutsPerWeekFor(profile))
+	second := generateRuleBasedPlan(profile, workoutsPerWeekFor(profile))
+
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Expected the same plan for the same profile")
+	}
+	if last := first.Workouts[len(first.Workouts)-1]; last.Name != conditioning.Name {
+		t.Errorf("Expected a conditioning session for weight loss, got %s", last.Name)
+	}
+}
+
+func TestAvoidedTags(t *testing.T) {
+	testCases := []struct {
+		issues   []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"Knee pain"}, []string{tagKnee, tagImpact}},
+		{[]string{"herniated DISC"}, []string{tagBack}},
+		{[]string{"High blood pressure"}, []string{tagIntensity, tagImpact}},
+		{[]string{"Allergy"}, nil},
+	}
+
+	for _, tc := range testCases {
+		avoid := avoidedTags(tc.issues)
+		if len(avoid) != len(tc.expected) {
+			t.Errorf("For %v, expected %v, got %v", tc.issues, tc.expected, avoid)
+			continue
+		}
+		for _, tag := range tc.expected {
+			if !avoid[tag] {
+				t.Errorf("For %v, expected tag %s", tc.issues, tag)
+			}
+		}
+	}
+}
+
+func TestAIService_GenerateWorkoutPlan_RulesWithoutClient(t *testing.T) {
+	repo := newMockProfileRepo()
+	repo.profiles[1] = &models.FitnessProfile{
+		Goal:             "general_fitness",
+		FitnessLevel:     "beginner",
+		AvailableMinutes: 120,
+		Timeframe:        "1month",
+	}
+	service := &AIService{
+		BaseService: BaseService{Repo: repo, MongoDBRepo: &mockMongoDBRepo{}},
+	}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	plan, err := service.GenerateWorkoutPlan(ctx, models.PlanSourceAI)
+	if err != nil {
+		t.Fatalf("Expected a rule-based plan, got %v", err)
+	}
+	if plan.Source != models.PlanSourceRules {
+		t.Errorf("Expected source '%s', got '%s'", models.PlanSourceRules, plan.Source)
+	}
+	if len(plan.Workouts) != 2*4 {
+		t.Errorf("Expected 8 scheduled workouts, got %d", len(plan.Workouts))
+	}
+}
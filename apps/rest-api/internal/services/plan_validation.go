@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"rest-api/internal/i18n"
+	"rest-api/internal/models"
+	"rest-api/internal/promptguard"
+	"rest-api/internal/prompts"
+)
+
+// Limits an AI-generated plan must respect
+const (
+	minExercisesPerWorkout = 3
+	maxExercisesPerWorkout = 12
+	minSets                = 1
+	maxSets                = 10
+	minReps                = 1
+	maxReps                = 50
+	minRestSec             = 10
+	maxRestSec             = 300
+
+	// maxPlanRepairAttempts is how many times invalid output is sent back to the model
+	maxPlanRepairAttempts = 2
+)
+
+// knownMuscleGroups are the muscle_group values accepted in generated plans
+var knownMuscleGroups = map[string]bool{
+	"chest":       true,
+	"back":        true,
+	"lower back":  true,
+	"shoulders":   true,
+	"biceps":      true,
+	"triceps":     true,
+	"arms":        true,
+	"forearms":    true,
+	"core":        true,
+	"abs":         true,
+	"obliques":    true,
+	"legs":        true,
+	"quads":       true,
+	"hamstrings":  true,
+	"glutes":      true,
+	"calves":      true,
+	"hips":        true,
+	"upper body":  true,
+	"lower body":  true,
+	"full body":   true,
+	"cardio":      true,
+	"mobility":    true,
+	"flexibility": true,
+}
+
+// generatedPlan is the JSON document the model is asked to produce
+type generatedPlan struct {
+	Title    string           `json:"title"`
+	Workouts []models.Workout `json:"workouts"`
+	// PromptVersion is the ID of the prompt the plan was generated with
+	PromptVersion string `json:"-"`
+}
+
+// PlanValidationError lists everything wrong with a generated plan
+type PlanValidationError struct {
+	Problems []string
+}
+
+func (e *PlanValidationError) Error() string {
+	return "invalid workout plan: " + strings.Join(e.Problems, "; ")
+}
+
+// isKnownMuscleGroup accepts a known group or a combination like "Chest/Triceps"
+func isKnownMuscleGroup(group string) bool {
+	normalized := strings.ToLower(strings.TrimSpace(group))
+	if normalized == "" {
+		return false
+	}
+	if knownMuscleGroups[normalized] {
+		return true
+	}
+
+	parts := muscleGroupParts(normalized)
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if !knownMuscleGroups[part] {
+			return false
+		}
+	}
+	return true
+}
+
+// muscleGroupParts splits a combination like "Chest/Triceps" into lower case groups
+func muscleGroupParts(group string) []string {
+	normalized := strings.ToLower(strings.TrimSpace(group))
+	parts := strings.FieldsFunc(normalized, func(r rune) bool {
+		return r == '/' || r == ',' || r == '&' || r == '+'
+	})
+	if len(parts) < 2 {
+		parts = strings.Split(normalized, " and ")
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func muscleGroupList() string {
+	groups := make([]string, 0, len(knownMuscleGroups))
+	for group := range knownMuscleGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return strings.Join(groups, ", ")
+}
+
+// planConstraintsPrompt describes the validation rules to the model up front
+func planConstraintsPrompt(expectedWorkouts int) string {
+	return fmt.Sprintf(`RULES:
+- EXACTLY %d workouts, each with a non-empty name
+- %d to %d exercises per workout, each with a non-empty name
+- sets between %d and %d, reps between %d and %d, rest_sec between %d and %d
+- muscle_group must be one of: %s`,
+		expectedWorkouts,
+		minExercisesPerWorkout, maxExercisesPerWorkout,
+		minSets, maxSets, minReps, maxReps, minRestSec, maxRestSec,
+		muscleGroupList())
+}
+
+// validateWorkoutPlan returns a human (and model) readable list of problems, empty when valid
+func validateWorkoutPlan(plan *generatedPlan, expectedWorkouts int) []string {
+	var problems []string
+
+	if len(plan.Workouts) != expectedWorkouts {
+		problems = append(problems, fmt.Sprintf("expected exactly %d workouts, got %d", expectedWorkouts, len(plan.Workouts)))
+	}
+	if problem := promptguard.CheckContent(plan.Title); problem != "" {
+		problems = append(problems, "title "+problem)
+	}
+
+	for i, workout := range plan.Workouts {
+		label := fmt.Sprintf("workout %d", i+1)
+		if strings.TrimSpace(workout.Name) == "" {
+			problems = append(problems, label+": name is empty")
+		} else {
+			label = fmt.Sprintf("workout %d (%s)", i+1, workout.Name)
+		}
+		for _, field := range []struct{ name, text string }{
+			{"name", workout.Name},
+			{"description", workout.Description},
+		} {
+			if problem := promptguard.CheckContent(field.text); problem != "" {
+				problems = append(problems, fmt.Sprintf("%s: %s %s", label, field.name, problem))
+			}
+		}
+
+		if n := len(workout.Exercises); n < minExercisesPerWorkout || n > maxExercisesPerWorkout {
+			problems = append(problems, fmt.Sprintf("%s: has %d exercises, must have %d to %d",
+				label, n, minExercisesPerWorkout, maxExercisesPerWorkout))
+		}
+
+		for j, exercise := range workout.Exercises {
+			exLabel := fmt.Sprintf("%s exercise %d", label, j+1)
+			if strings.TrimSpace(exercise.Name) != "" {
+				exLabel = fmt.Sprintf("%s exercise %d (%s)", label, j+1, exercise.Name)
+			}
+			problems = append(problems, validateExercise(exLabel, exercise)...)
+		}
+	}
+
+	return problems
+}
+
+// validateExercise checks a single exercise against the plan limits
+func validateExercise(label string, exercise models.Exercise) []string {
+	var problems []string
+	if strings.TrimSpace(exercise.Name) == "" {
+		problems = append(problems, label+": name is empty")
+	}
+	if exercise.Sets < minSets || exercise.Sets > maxSets {
+		problems = append(problems, fmt.Sprintf("%s: sets %d out of range %d-%d", label, exercise.Sets, minSets, maxSets))
+	}
+	if exercise.Reps < minReps || exercise.Reps > maxReps {
+		problems = append(problems, fmt.Sprintf("%s: reps %d out of range %d-%d", label, exercise.Reps, minReps, maxReps))
+	}
+	if exercise.RestSec < minRestSec || exercise.RestSec > maxRestSec {
+		problems = append(problems, fmt.Sprintf("%s: rest_sec %d out of range %d-%d", label, exercise.RestSec, minRestSec, maxRestSec))
+	}
+	if !isKnownMuscleGroup(exercise.MuscleGroup) {
+		problems = append(problems, fmt.Sprintf("%s: unknown muscle_group %q", label, exercise.MuscleGroup))
+	}
+	for _, field := range []struct{ name, text string }{
+		{"name", exercise.Name},
+		{"notes", exercise.Notes},
+		{"technique", exercise.Technique},
+	} {
+		if problem := promptguard.CheckContent(field.text); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s %s", label, field.name, problem))
+		}
+	}
+	return problems
+}
+
+// cleanJSONContent removes markdown code fences around a JSON response
+func cleanJSONContent(content string) string {
+	cleanContent := strings.TrimSpace(content)
+	if strings.HasPrefix(cleanContent, "```json") {
+		cleanContent = strings.TrimPrefix(cleanContent, "```json")
+		cleanContent = strings.TrimSuffix(cleanContent, "```")
+		cleanContent = strings.TrimSpace(cleanContent)
+	}
+	if strings.HasPrefix(cleanContent, "```") {
+		cleanContent = strings.TrimPrefix(cleanContent, "```")
+		cleanContent = strings.TrimSuffix(cleanContent, "```")
+		cleanContent = strings.TrimSpace(cleanContent)
+	}
+	return cleanContent
+}
+
+// decodeStrict decodes a model response into v. Fields outside the schema
+// are an error, a model that was talked out of the format is repaired.
+func decodeStrict(content string, v any) error {
+	decoder := json.NewDecoder(strings.NewReader(cleanJSONContent(content)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected content after the JSON document")
+	}
+	return nil
+}
+
+// parseGeneratedPlan decodes a model response and fills in optional fields
+func parseGeneratedPlan(content string, expectedWorkouts int) (*generatedPlan, error) {
+	var plan generatedPlan
+	if err := decodeStrict(content, &plan); err != nil {
+		return nil, err
+	}
+
+	// Fill missing fields with default values
+	for i := range plan.Workouts {
+		if plan.Workouts[i].Status == "" {
+			plan.Workouts[i].Status = "planned"
+		}
+		for j := range plan.Workouts[i].Exercises {
+			if plan.Workouts[i].Exercises[j].RestSec == 0 {
+				plan.Workouts[i].Exercises[j].RestSec = 60
+			}
+		}
+	}
+
+	// Extra workouts are harmless, trim them instead of asking for a repair
+	if len(plan.Workouts) > expectedWorkouts {
+		plan.Workouts = plan.Workouts[:expectedWorkouts]
+	}
+
+	return &plan, nil
+}
+
+// generateValidatedPlan asks the model for a plan and, while the result is
+// malformed or breaks the plan rules, sends the specific problems back for a
+// bounded number of repair rounds before giving up. Texts in another language
+// than the user's are repaired too, but don't fail the last round.
+func (s *AIService) generateValidatedPlan(ctx context.Context, prompt *prompts.Prompt, messages []OpenRouterMessage, expectedWorkouts int, language i18n.Language) (*generatedPlan, error) {
+	var problems []string
+
+	for attempt := 0; attempt <= maxPlanRepairAttempts; attempt++ {
+		content, err := s.Client.CreateChatCompletionWithPolicy(ctx, messages, true, planRetryPolicy)
+		if err != nil {
+			fmt.Printf("AI REQUEST FAILED during plan generation: %v\n", err)
+			return nil, newAIRequestError(err)
+		}
+
+		plan, err := parseGeneratedPlan(content, expectedWorkouts)
+		if err != nil {
+			problems = []string{fmt.Sprintf("response is not valid JSON: %v", err)}
+		} else {
+			problems = validateWorkoutPlan(plan, expectedWorkouts)
+		}
+
+		if len(problems) == 0 {
+			languageProblem := planLanguageProblem(plan, language)
+			if languageProblem == "" || attempt == maxPlanRepairAttempts {
+				plan.PromptVersion = prompt.ID()
+				return plan, nil
+			}
+			problems = []string{languageProblem}
+		}
+
+		fmt.Printf("Generated plan rejected (attempt %d): %s\n", attempt+1, strings.Join(problems, "; "))
+
+		repair, err := prompt.Execute("repair", repairPromptData{
+			Problems: problems,
+			Rules:    planConstraintsPrompt(expectedWorkouts),
+		})
+		if err != nil {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to build AI prompt",
+				err,
+			)
+		}
+
+		messages = append(messages,
+			OpenRouterMessage{Role: "assistant", Content: content},
+			OpenRouterMessage{Role: "user", Content: repair},
+		)
+		reportPlanStage(ctx, models.PlanStageRepairing)
+	}
+
+	return nil, NewServiceError(
+		http.StatusInternalServerError,
+		"AI generated an invalid workout plan",
+		&PlanValidationError{Problems: problems},
+	)
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"rest-api/internal/i18n"
+	"rest-api/internal/models"
+	"rest-api/internal/prompts"
+)
+
+func validTestWorkout(name string) models.Workout {
+	return models.Workout{
+		Name:   name,
+		Status: "planned",
+		Exercises: []models.Exercise{
+			{Name: "Push-ups", MuscleGroup: "Chest", Sets: 3, Reps: 12, RestSec: 60},
+			{Name: "Squats", MuscleGroup: "Legs", Sets: 4, Reps: 10, RestSec: 90},
+			{Name: "Plank", MuscleGroup: "Core", Sets: 3, Reps: 1, RestSec: 45},
+		},
+	}
+}
+
+func TestIsKnownMuscleGroup(t *testing.T) {
+	testCases := []struct {
+		group    string
+		expected bool
+	}{
+		{"Chest", true},
+		{" full body ", true},
+		{"Chest/Triceps", true},
+		{"Glutes & Hamstrings", true},
+		{"Back and Biceps", true},
+		{"", false},
+		{"Target Muscle", false},
+		{"Chest/Wings", false},
+	}
+
+	for _, tc := range testCases {
+		if got := isKnownMuscleGroup(tc.group); got != tc.expected {
+			t.Errorf("For '%s', expected %v, got %v", tc.group, tc.expected, got)
+		}
+	}
+}
+
+func TestValidateWorkoutPlan(t *testing.T) {
+	testCases := []struct {
+		name     string
+		modify   func(plan *generatedPlan)
+		expected string
+	}{
+		{"valid", func(plan *generatedPlan) {}, ""},
+		{"missing workout", func(plan *generatedPlan) { plan.Workouts = plan.Workouts[:1] }, "expected exactly 2 workouts, got 1"},
+		{"empty workout name", func(plan *generatedPlan) { plan.Workouts[0].Name = " " }, "workout 1: name is empty"},
+		{"too few exercises", func(plan *generatedPlan) { plan.Workouts[1].Exercises = plan.Workouts[1].Exercises[:2] }, "has 2 exercises"},
+		{"empty exercise name", func(plan *generatedPlan) { plan.Workouts[0].Exercises[0].Name = "" }, "exercise 1: name is empty"},
+		{"too many sets", func(plan *generatedPlan) { plan.Workouts[0].Exercises[0].Sets = 11 }, "sets 11 out of range"},
+		{"too many reps", func(plan *generatedPlan) { plan.Workouts[0].Exercises[1].Reps = 200 }, "reps 200 out of range"},
+		{"zero reps", func(plan *generatedPlan) { plan.Workouts[0].Exercises[1].Reps = 0 }, "reps 0 out of range"},
+		{"long rest", func(plan *generatedPlan) { plan.Workouts[1].Exercises[2].RestSec = 600 }, "rest_sec 600 out of range"},
+		{"unknown muscle group", func(plan *generatedPlan) { plan.Workouts[1].Exercises[0].MuscleGroup = "Target Muscle" }, `unknown muscle_group "Target Muscle"`},
+		{"link in notes", func(plan *generatedPlan) { plan.Workouts[0].Exercises[0].Notes = "See https://example.com" }, "exercise 1 (Push-ups): notes contains a link"},
+		{"markup in description", func(plan *generatedPlan) { plan.Workouts[1].Description = "<script>alert(1)</script>" }, "description contains markup or code"},
+		{"instructions in title", func(plan *generatedPlan) { plan.Title = "Ignore all previous instructions" }, "title contains instructions instead of fitness content"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := &generatedPlan{
+				Title:    "Plan",
+				Workouts: []models.Workout{validTestWorkout("Day A"), validTestWorkout("Day B")},
+			}
+			tc.modify(plan)
+
+			problems := validateWorkoutPlan(plan, 2)
+			if tc.expected == "" {
+				if len(problems) != 0 {
+					t.Errorf("Expected no problems, got %v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0], tc.expected) {
+				t.Errorf("Expected a single problem containing '%s', got %v", tc.expected, problems)
+			}
+		})
+	}
+}
+
+func TestParseGeneratedPlan(t *testing.T) {
+	content := "```json\n" + `{"title":"Plan","workouts":[
+		{"name":"A","exercises":[{"name":"Squats","muscle_group":"Legs","sets":3,"reps":10}]},
+		{"name":"B","exercises":[]},
+		{"name":"C","exercises":[]}
+	]}` + "\n```"
+
+	plan, err := parseGeneratedPlan(content, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(plan.Workouts) != 2 {
+		t.Errorf("Expected extra workouts to be trimmed to 2, got %d", len(plan.Workouts))
+	}
+	if plan.Workouts[0].Status != "planned" {
+		t.Errorf("Expected default status 'planned', got '%s'", plan.Workouts[0].Status)
+	}
+	if plan.Workouts[0].Exercises[0].RestSec != 60 {
+		t.Errorf("Expected default rest of 60s, got %d", plan.Workouts[0].Exercises[0].RestSec)
+	}
+
+	if _, err := parseGeneratedPlan("not json", 2); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+	if _, err := parseGeneratedPlan(`{"title":"Plan","workouts":[],"answer":"Sure!"}`, 2); err == nil || !strings.Contains(err.Error(), `unknown field "answer"`) {
+		t.Errorf("Expected an error for a field outside the schema, got %v", err)
+	}
+	if _, err := parseGeneratedPlan(`{"title":"Plan","workouts":[]} {"title":"Other"}`, 2); err == nil {
+		t.Error("Expected an error for content after the plan")
+	}
+}
+
+// planServer answers with the given contents in order, repeating the last one
+func planServer(t *testing.T, contents []string, requests *[]OpenRouterRequest) *httptest.Server {
+	var calls atomic.Int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenRouterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		*requests = append(*requests, req)
+
+		i := int(calls.Add(1)) - 1
+		if i >= len(contents) {
+			i = len(contents) - 1
+		}
+		content, _ := json.Marshal(contents[i])
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%s}}]}`, content)
+	}))
+}
+
+func TestGenerateValidatedPlan_RepairsInvalidPlan(t *testing.T) {
+	valid, _ := json.Marshal(generatedPlan{
+		Title:    "Plan",
+		Workouts: []models.Workout{validTestWorkout("Day A"), validTestWorkout("Day B")},
+	})
+	invalid := `{"title":"Plan","workouts":[{"name":"Day A","exercises":[{"name":"Curls","muscle_group":"Biceps","sets":3,"reps":200}]}]}`
+
+	var requests []OpenRouterRequest
+	server := planServer(t, []string{invalid, string(valid)}, &requests)
+	defer server.Close()
+
+	service := &AIService{Client: newTestClient(server.URL, "model-a")}
+	messages := []OpenRouterMessage{{Role: "user", Content: "plan please"}}
+
+	prompt, _ := prompts.Default().Get(promptPlan, "v1")
+	plan, err := service.generateValidatedPlan(context.Background(), prompt, messages, 2, i18n.Language{})
+	if err != nil {
+		t.Fatalf("Expected the repaired plan, got %v", err)
+	}
+	if len(plan.Workouts) != 2 {
+		t.Errorf("Expected 2 workouts, got %d", len(plan.Workouts))
+	}
+	if plan.PromptVersion != "plan/v1" {
+		t.Errorf("Expected prompt version 'plan/v1', got '%s'", plan.PromptVersion)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 provider calls, got %d", len(requests))
+	}
+
+	repair := requests[1].Messages
+	last := repair[len(repair)-1].Content
+	if repair[len(repair)-2].Role != "assistant" {
+		t.Errorf("Expected the invalid plan to be sent back as the assistant message")
+	}
+	for _, want := range []string{"expected exactly 2 workouts, got 1", "reps 200 out of range", "has 1 exercises"} {
+		if !strings.Contains(last, want) {
+			t.Errorf("Expected repair prompt to mention '%s', got: %s", want, last)
+		}
+	}
+	if len(messages) != 1 {
+		t.Errorf("Expected caller messages to stay untouched, got %d", len(messages))
+	}
+}
+
+func TestGenerateValidatedPlan_GivesUpAfterRepairs(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := planServer(t, []string{"not json"}, &requests)
+	defer server.Close()
+
+	service := &AIService{Client: newTestClient(server.URL, "model-a")}
+
+	prompt, _ := prompts.Default().Get(promptPlan, "v1")
+	_, err := service.generateValidatedPlan(context.Background(), prompt, []OpenRouterMessage{{Role: "user", Content: "plan"}}, 2, i18n.Language{})
+
+	serviceErr, ok := err.(ServiceError)
+	if !ok || serviceErr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected a 500 service error, got %v", err)
+	}
+	var validationErr *PlanValidationError
+	if !errors.As(serviceErr.Err, &validationErr) {
+		t.Errorf("Expected the validation problems to be wrapped, got %v", serviceErr.Err)
+	}
+	if len(requests) != maxPlanRepairAttempts+1 {
+		t.Errorf("Expected %d provider calls, got %d", maxPlanRepairAttempts+1, len(requests))
+	}
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rest-api/internal/models"
+)
+
+// planChange describes why a plan is saved, for its version
+type planChange struct {
+	reason   string
+	author   string
+	comments string
+	// restoredFrom is the version number a restore copied
+	restoredFrom int
+}
+
+// savePlan stores the plan and, when given, the short plan, and keeps the
+// result as a new version. The plan a user had before versions were kept is
+// stored as their first version, so it can still be restored. Failing to
+// store a version does not fail the save.
+func (s *AIService) savePlan(ctx context.Context, plan *models.WorkoutPlan, shortPlan *models.ShortWorkoutPlan, change planChange) error {
+	s.keepInitialVersion(ctx, plan.UserID)
+
+	if err := s.MongoDBRepo.SaveWorkoutPlan(ctx, plan); err != nil {
+		return err
+	}
+	if shortPlan != nil {
+		if err := s.MongoDBRepo.SaveShortPlan(ctx, shortPlan); err != nil {
+			fmt.Printf("Failed to save short plan: %v\n", err)
+		}
+	}
+
+	version := &models.WorkoutPlanVersion{
+		UserID:       plan.UserID,
+		Reason:       change.reason,
+		Author:       change.author,
+		Comments:     change.comments,
+		Title:        plan.Title,
+		RestoredFrom: change.restoredFrom,
+		Plan:         plan,
+		ShortPlan:    shortPlan,
+		CreatedAt:    time.Now(),
+	}
+	if version.ShortPlan == nil {
+		// Edits that keep the base workouts snapshot the stored short plan
+		current, err := s.MongoDBRepo.GetShortPlan(ctx, plan.UserID)
+		if err != nil {
+			fmt.Printf("Failed to get short plan for version: %v\n", err)
+		}
+		version.ShortPlan = current
+	}
+	if err := s.MongoDBRepo.SaveWorkoutPlanVersion(ctx, version); err != nil {
+		fmt.Printf("Failed to save workout plan version: %v\n", err)
+	}
+	return nil
+}
+
+// keepInitialVersion stores the current plan as the first version of a user
+// who has a plan but no versions yet
+func (s *AIService) keepInitialVersion(ctx context.Context, userID int) {
+	versions, err := s.MongoDBRepo.GetWorkoutPlanVersions(ctx, userID)
+	if err != nil {
+		fmt.Printf("Failed to get workout plan versions: %v\n", err)
+		return
+	}
+	if len(versions) > 0 {
+		return
+	}
+
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil || plan == nil {
+		return
+	}
+	shortPlan, err := s.MongoDBRepo.GetShortPlan(ctx, userID)
+	if err != nil {
+		shortPlan = nil
+	}
+	if err := s.MongoDBRepo.SaveWorkoutPlanVersion(ctx, &models.WorkoutPlanVersion{
+		UserID:    userID,
+		Reason:    models.PlanChangeInitial,
+		Author:    plan.Source,
+		Title:     plan.Title,
+		Plan:      plan,
+		ShortPlan: shortPlan,
+		CreatedAt: plan.UpdatedAt,
+	}); err != nil {
+		fmt.Printf("Failed to save initial workout plan version: %v\n", err)
+	}
+}
+
+// GetWorkoutPlanVersions lists the user's plan versions, newest first
+func (s *AIService) GetWorkoutPlanVersions(ctx context.Context) ([]models.WorkoutPlanVersion, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.MongoDBRepo.GetWorkoutPlanVersions(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout plan versions",
+			err,
+		)
+	}
+	return versions, nil
+}
+
+// workoutPlanVersion loads a version of the user's plan by its ID
+func (s *AIService) workoutPlanVersion(ctx context.Context, userID int, versionID string) (*models.WorkoutPlanVersion, error) {
+	id, err := primitive.ObjectIDFromHex(versionID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Invalid version ID format",
+			err,
+		)
+	}
+
+	version, err := s.MongoDBRepo.GetWorkoutPlanVersion(ctx, userID, id)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout plan version",
+			err,
+		)
+	}
+	if version == nil || version.Plan == nil {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Workout plan version not found",
+			nil,
+		)
+	}
+	return version, nil
+}
+
+// DiffWorkoutPlanVersions compares two versions of the user's plan. An empty
+// toID compares with the latest version.
+func (s *AIService) DiffWorkoutPlanVersions(ctx context.Context, fromID, toID string) (*models.WorkoutPlanDiff, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if toID == "" {
+		versions, err := s.MongoDBRepo.GetWorkoutPlanVersions(ctx, userID)
+		if err != nil {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to get workout plan versions",
+				err,
+			)
+		}
+		if len(versions) == 0 {
+			return nil, NewServiceError(
+				http.StatusNotFound,
+				"Workout plan version not found",
+				nil,
+			)
+		}
+		toID = versions[0].ID.Hex()
+	}
+
+	from, err := s.workoutPlanVersion(ctx, userID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.workoutPlanVersion(ctx, userID, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &models.WorkoutPlanDiff{
+		From:     *from,
+		To:       *to,
+		Workouts: diffWorkoutPlans(from.Plan, to.Plan),
+	}
+	diff.From.Plan, diff.From.ShortPlan = nil, nil
+	diff.To.Plan, diff.To.ShortPlan = nil, nil
+	return diff, nil
+}
+
+// diffWorkoutPlans compares the scheduled workouts of two plans. The n-th
+// occurrences of a base workout are compared with each other, occurrences
+// only one plan has are added or removed.
+func diffWorkoutPlans(from, to *models.WorkoutPlan) []models.WorkoutDiff {
+	fromWorkouts, fromBases := occurrencesByBase(from)
+	toWorkouts, toBases := occurrencesByBase(to)
+	for _, base := range fromBases {
+		if _, ok := toWorkouts[base]; !ok {
+			toBases = append(toBases, base)
+		}
+	}
+
+	diffs := []models.WorkoutDiff{}
+	for _, base := range toBases {
+		before, after := fromWorkouts[base], toWorkouts[base]
+		// Equal changes of the base workout's occurrences are merged
+		merged := make(map[string]int)
+		add := func(key string, diff models.WorkoutDiff, name string) {
+			if i, ok := merged[key]; ok {
+				diffs[i].Workouts = append(diffs[i].Workouts, name)
+				return
+			}
+			merged[key] = len(diffs)
+			diff.BaseWorkout = base
+			diff.Workouts = []string{name}
+			diffs = append(diffs, diff)
+		}
+
+		for i := 0; i < max(len(before), len(after)); i++ {
+			switch {
+			case i >= len(before):
+				add(models.WorkoutDiffAdded, models.WorkoutDiff{
+					Change: models.WorkoutDiffAdded,
+					Added:  diffExercises(after[i].Exercises),
+				}, after[i].Name)
+			case i >= len(after):
+				add(models.WorkoutDiffRemoved, models.WorkoutDiff{
+					Change:  models.WorkoutDiffRemoved,
+					Removed: diffExercises(before[i].Exercises),
+				}, before[i].Name)
+			default:
+				diff := diffWorkout(before[i], after[i])
+				if len(diff.Added)+len(diff.Removed)+len(diff.Changed) == 0 {
+					continue
+				}
+				add(fmt.Sprintf("%+v", diff), diff, after[i].Name)
+			}
+		}
+	}
+	return diffs
+}
+
+// occurrencesByBase groups the plan's workouts by base workout, in schedule order
+func occurrencesByBase(plan *models.WorkoutPlan) (map[string][]models.Workout, []string) {
+	workouts := make(map[string][]models.Workout)
+	var bases []string
+	if plan == nil {
+		return workouts, bases
+	}
+	for _, workout := range plan.Workouts {
+		base := baseWorkoutName(workout)
+		if _, ok := workouts[base]; !ok {
+			bases = append(bases, base)
+		}
+		workouts[base] = append(workouts[base], workout)
+	}
+	return workouts, bases
+}
+
+// diffWorkout matches the exercises of two workouts by name
+func diffWorkout(from, to models.Workout) models.WorkoutDiff {
+	diff := models.WorkoutDiff{Change: models.WorkoutDiffChanged}
+	matched := make([]bool, len(from.Exercises))
+	for _, after := range to.Exercises {
+		i := -1
+		for j, before := range from.Exercises {
+			if !matched[j] && sameExercise(before, after) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			diff.Added = append(diff.Added, diffExercises([]models.Exercise{after})...)
+			continue
+		}
+		matched[i] = true
+		if fields := changedFields(from.Exercises[i], after); len(fields) > 0 {
+			pair := diffExercises([]models.Exercise{from.Exercises[i], after})
+			diff.Changed = append(diff.Changed, models.ExerciseChange{
+				Name:   after.Name,
+				Fields: fields,
+				From:   pair[0],
+				To:     pair[1],
+			})
+		}
+	}
+	for i, exercise := range from.Exercises {
+		if !matched[i] {
+			diff.Removed = append(diff.Removed, diffExercises([]models.Exercise{exercise})...)
+		}
+	}
+	return diff
+}
+
+func sameExercise(a, b models.Exercise) bool {
+	return strings.EqualFold(strings.TrimSpace(a.Name), strings.TrimSpace(b.Name))
+}
+
+// changedFields names the JSON fields in which two versions of an exercise differ
+func changedFields(from, to models.Exercise) []string {
+	var fields []string
+	if !strings.EqualFold(from.MuscleGroup, to.MuscleGroup) {
+		fields = append(fields, "muscle_group")
+	}
+	if from.Sets != to.Sets {
+		fields = append(fields, "sets")
+	}
+	if from.Reps != to.Reps {
+		fields = append(fields, "reps")
+	}
+	if from.RestSec != to.RestSec {
+		fields = append(fields, "rest_sec")
+	}
+	if from.Notes != to.Notes {
+		fields = append(fields, "notes")
+	}
+	if from.Technique != to.Technique {
+		fields = append(fields, "technique")
+	}
+	return fields
+}
+
+// diffExercises copies exercises without their IDs, which every occurrence
+// has its own of, so equal changes of occurrences compare equal
+func diffExercises(exercises []models.Exercise) []models.Exercise {
+	copied := make([]models.Exercise, len(exercises))
+	for i, exercise := range exercises {
+		exercise.ExerciseID = primitive.NilObjectID
+		copied[i] = exercise
+	}
+	return copied
+}
+
+// RestoreWorkoutPlanVersion makes a copy of an earlier version the current
+// plan, stored as a new version. Workouts of the version that were completed
+// since are kept done, the others are planned again and expire as usual.
+func (s *AIService) RestoreWorkoutPlanVersion(ctx context.Context, versionID string) (*models.WorkoutPlan, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.workoutPlanVersion(ctx, userID, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	completions, err := s.MongoDBRepo.GetWorkoutCompletions(ctx, userID, time.Time{}, time.Now())
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout history",
+			err,
+		)
+	}
+	done := make(map[primitive.ObjectID]bool)
+	for _, completion := range completions {
+		done[completion.WorkoutID] = true
+	}
+
+	now := time.Now()
+	plan := *version.Plan
+	plan.ID = primitive.NilObjectID
+	plan.UpdatedAt = now
+	plan.Workouts = slices.Clone(plan.Workouts)
+	for i := range plan.Workouts {
+		if done[plan.Workouts[i].WorkoutID] {
+			plan.Workouts[i].Status = "done"
+		} else {
+			plan.Workouts[i].Status = "planned"
+		}
+	}
+
+	var shortPlan *models.ShortWorkoutPlan
+	if version.ShortPlan != nil {
+		restored := *version.ShortPlan
+		restored.ID = primitive.NilObjectID
+		restored.UpdatedAt = now
+		shortPlan = &restored
+	}
+
+	if err := s.savePlan(ctx, &plan, shortPlan, planChange{
+		reason:       models.PlanChangeRestore,
+		author:       models.PlanAuthorUser,
+		restoredFrom: version.Version,
+	}); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save workout plan",
+			err,
+		)
+	}
+	return &plan, nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDiffWorkoutPlans(t *testing.T) {
+	squats := models.Exercise{Name: "Squats", MuscleGroup: "Legs", Sets: 3, Reps: 10}
+	plank := models.Exercise{Name: "Plank", MuscleGroup: "Core", Sets: 3, Reps: 30}
+	pushUps := models.Exercise{Name: "Push-ups", MuscleGroup: "Chest", Sets: 3, Reps: 12}
+	heavySquats := models.Exercise{Name: "squats", MuscleGroup: "Legs", Sets: 4, Reps: 10}
+	lunges := models.Exercise{Name: "Lunges", MuscleGroup: "Legs", Sets: 3, Reps: 12}
+	rows := models.Exercise{Name: "Rows", MuscleGroup: "Back", Sets: 3, Reps: 10}
+
+	// Every occurrence has its own exercise IDs
+	workout := func(name, base string, exercises ...models.Exercise) models.Workout {
+		copied := make([]models.Exercise, len(exercises))
+		for i, exercise := range exercises {
+			exercise.ExerciseID = primitive.NewObjectID()
+			copied[i] = exercise
+		}
+		return models.Workout{WorkoutID: primitive.NewObjectID(), Name: name, BaseWorkout: base, Exercises: copied}
+	}
+	from := &models.WorkoutPlan{Workouts: []models.Workout{
+		workout("Leg Day - Week 1", "Leg Day", squats, plank),
+		workout("Push - Week 1", "Push", pushUps),
+		workout("Leg Day - Week 2", "Leg Day", squats, plank),
+		workout("Push - Week 2", "Push", pushUps),
+	}}
+	to := &models.WorkoutPlan{Workouts: []models.Workout{
+		workout("Leg Day - Week 1", "Leg Day", heavySquats, lunges),
+		workout("Pull - Week 1", "Pull", rows),
+		workout("Leg Day - Week 2", "Leg Day", heavySquats, lunges),
+		workout("Leg Day - Week 3", "Leg Day", heavySquats),
+	}}
+
+	expected := []models.WorkoutDiff{
+		{
+			BaseWorkout: "Leg Day",
+			Change:      models.WorkoutDiffChanged,
+			Workouts:    []string{"Leg Day - Week 1", "Leg Day - Week 2"},
+			Added:       []models.Exercise{lunges},
+			Removed:     []models.Exercise{plank},
+			Changed:     []models.ExerciseChange{{Name: "squats", Fields: []string{"sets"}, From: squats, To: heavySquats}},
+		},
+		{
+			BaseWorkout: "Leg Day",
+			Change:      models.WorkoutDiffAdded,
+			Workouts:    []string{"Leg Day - Week 3"},
+			Added:       []models.Exercise{heavySquats},
+		},
+		{
+			BaseWorkout: "Pull",
+			Change:      models.WorkoutDiffAdded,
+			Workouts:    []string{"Pull - Week 1"},
+			Added:       []models.Exercise{rows},
+		},
+		{
+			BaseWorkout: "Push",
+			Change:      models.WorkoutDiffRemoved,
+			Workouts:    []string{"Push - Week 1", "Push - Week 2"},
+			Removed:     []models.Exercise{pushUps},
+		},
+	}
+	if diff := diffWorkoutPlans(from, to); !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, diff)
+	}
+
+	if diff := diffWorkoutPlans(from, from); len(diff) != 0 {
+		t.Errorf("Expected no changes between equal plans, got %+v", diff)
+	}
+}
+
+// versionTestPlan is a plan of two workouts with fixed IDs, so versions of it
+// share their workouts
+func versionTestPlan(title string, sets int, ids ...primitive.ObjectID) *models.WorkoutPlan {
+	plan := &models.WorkoutPlan{UserID: 1, Title: title, Source: models.PlanSourceRules}
+	for i, id := range ids {
+		plan.Workouts = append(plan.Workouts, models.Workout{
+			WorkoutID:     id,
+			Name:          "Full Body - Week 1",
+			BaseWorkout:   "Full Body",
+			Status:        "planned",
+			ScheduledDate: time.Now().AddDate(0, 0, i+1),
+			Exercises:     []models.Exercise{{Name: "Squats", MuscleGroup: "Legs", Sets: sets, Reps: 10}},
+		})
+	}
+	return plan
+}
+
+func TestAIService_PlanVersions(t *testing.T) {
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	mongoRepo := &mockMongoDBRepo{
+		plans:      map[int]*models.WorkoutPlan{1: versionTestPlan("Starter", 3, first, second)},
+		shortPlans: map[int]*models.ShortWorkoutPlan{1: {UserID: 1, Title: "Starter", Timeframe: "1month"}},
+	}
+	service := &AIService{BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo}}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	// The plan from before versions were kept becomes the first version
+	if err := service.savePlan(ctx, versionTestPlan("Stronger", 4, first, second), nil, planChange{
+		reason:   models.PlanChangeRegenerate,
+		author:   models.PlanSourceAI,
+		comments: "More sets",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := service.GetWorkoutPlanVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected the initial and the regenerated version, got %+v", versions)
+	}
+	latest, initial := versions[0], versions[1]
+	if latest.Version != 2 || latest.Reason != models.PlanChangeRegenerate || latest.Author != models.PlanSourceAI || latest.Comments != "More sets" || latest.Title != "Stronger" {
+		t.Errorf("Unexpected latest version %+v", latest)
+	}
+	if initial.Version != 1 || initial.Reason != models.PlanChangeInitial || initial.Author != models.PlanSourceRules || initial.Title != "Starter" {
+		t.Errorf("Unexpected initial version %+v", initial)
+	}
+	if latest.Plan != nil || mongoRepo.versions[1].ShortPlan == nil || mongoRepo.versions[1].ShortPlan.Title != "Starter" {
+		t.Errorf("Expected lists without snapshots and the stored short plan in the snapshot, got %+v", mongoRepo.versions[1])
+	}
+
+	diff, err := service.DiffWorkoutPlanVersions(ctx, initial.ID.Hex(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.From.Version != 1 || diff.To.Version != 2 || diff.To.Plan != nil {
+		t.Errorf("Expected the diff of version 1 and the latest version, got %+v", diff)
+	}
+	if len(diff.Workouts) != 1 || len(diff.Workouts[0].Workouts) != 2 || diff.Workouts[0].Changed[0].To.Sets != 4 {
+		t.Errorf("Expected both workouts to get a set more, got %+v", diff.Workouts)
+	}
+
+	// The second workout was completed since
+	mongoRepo.completions = append(mongoRepo.completions, models.WorkoutCompletion{UserID: 1, WorkoutID: second, CompletedAt: time.Now().Add(-time.Minute)})
+
+	restored, err := service.RestoreWorkoutPlanVersion(ctx, initial.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Title != "Starter" || restored.Workouts[0].Exercises[0].Sets != 3 {
+		t.Errorf("Expected the initial plan, got %+v", restored)
+	}
+	if restored.Workouts[0].Status != "planned" || restored.Workouts[1].Status != "done" {
+		t.Errorf("Expected only the completed workout to be done, got %+v", restored.Workouts)
+	}
+	if mongoRepo.plans[1].Title != "Starter" || mongoRepo.shortPlans[1].Title != "Starter" {
+		t.Errorf("Expected the restored plans to be stored, got %+v and %+v", mongoRepo.plans[1], mongoRepo.shortPlans[1])
+	}
+	restore := mongoRepo.versions[len(mongoRepo.versions)-1]
+	if restore.Version != 3 || restore.Reason != models.PlanChangeRestore || restore.Author != models.PlanAuthorUser || restore.RestoredFrom != 1 {
+		t.Errorf("Expected the restore to be kept as version 3, got %+v", restore)
+	}
+	if mongoRepo.versions[0].Plan.Workouts[1].Status != "planned" {
+		t.Error("Expected the restored version to be unchanged")
+	}
+}
+
+func TestAIService_PlanVersions_Errors(t *testing.T) {
+	service := &AIService{BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: &mockMongoDBRepo{}}}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	testCases := []struct {
+		name     string
+		call     func() error
+		expected int
+	}{
+		{"restore invalid ID", func() error {
+			_, err := service.RestoreWorkoutPlanVersion(ctx, "latest")
+			return err
+		}, http.StatusBadRequest},
+		{"restore unknown version", func() error {
+			_, err := service.RestoreWorkoutPlanVersion(ctx, primitive.NewObjectID().Hex())
+			return err
+		}, http.StatusNotFound},
+		{"diff without versions", func() error {
+			_, err := service.DiffWorkoutPlanVersions(ctx, primitive.NewObjectID().Hex(), "")
+			return err
+		}, http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.call()
+			if err == nil || err.(ServiceError).Code != tc.expected {
+				t.Errorf("Expected %d, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -2,12 +2,23 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
+	"rest-api/internal/i18n"
 	"rest-api/internal/models"
+	"rest-api/internal/promptguard"
 	"rest-api/internal/repository"
 )
 
+// Limits of the allergies in a profile
+const (
+	maxAllergies  = 20
+	maxAllergyLen = 50
+)
+
 type ProfileService struct {
 	BaseService
 }
@@ -24,6 +35,37 @@ func (s *ProfileService) SaveProfile(ctx context.Context, profile models.Fitness
 		return err
 	}
 
+	// Tags like "pt-BR" are stored as the supported language
+	if profile.Language != "" {
+		language := i18n.Normalize(profile.Language)
+		if language == "" {
+			return NewServiceError(
+				http.StatusBadRequest,
+				fmt.Sprintf("Language must be one of: %s", strings.Join(i18n.Codes(), ", ")),
+				nil,
+			)
+		}
+		profile.Language = language
+	}
+
+	if err := normalizeEquipment(&profile); err != nil {
+		return err
+	}
+	if err := normalizeNutrition(&profile); err != nil {
+		return err
+	}
+
+	// Health issues and allergies end up in every plan prompt
+	for _, text := range append(append([]string{}, profile.HealthIssues...), profile.Allergies...) {
+		if len(promptguard.Detect(text)) > 0 {
+			return NewServiceError(
+				http.StatusBadRequest,
+				"Health issues and allergies must not contain instructions for the AI",
+				nil,
+			)
+		}
+	}
+
 	profile.UserID = userID
 	if err := s.Repo.SaveFitnessProfile(ctx, userID, &profile); err != nil {
 		return NewServiceError(
@@ -58,3 +100,76 @@ func (s *ProfileService) GetProfile(ctx context.Context) (*models.FitnessProfile
 	}
 	return profile, nil
 }
+
+// normalizeEquipment validates the equipment and stores it lowercase and without duplicates
+func normalizeEquipment(profile *models.FitnessProfile) error {
+	var equipment []string
+	for _, item := range profile.Equipment {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if !slices.Contains(models.Equipment, item) {
+			return NewServiceError(
+				http.StatusBadRequest,
+				fmt.Sprintf("Equipment must be one of: %s", strings.Join(models.Equipment, ", ")),
+				nil,
+			)
+		}
+		if !slices.Contains(equipment, item) {
+			equipment = append(equipment, item)
+		}
+	}
+	profile.Equipment = equipment
+	return nil
+}
+
+// normalizeNutrition validates the nutrition fields and stores them lowercase and without duplicates
+func normalizeNutrition(profile *models.FitnessProfile) error {
+	profile.Sex = strings.ToLower(strings.TrimSpace(profile.Sex))
+	if profile.Sex != "" && profile.Sex != models.SexMale && profile.Sex != models.SexFemale {
+		return NewServiceError(
+			http.StatusBadRequest,
+			"Sex must be 'male' or 'female'",
+			nil,
+		)
+	}
+
+	var restrictions []string
+	for _, restriction := range profile.DietaryRestrictions {
+		restriction = strings.ToLower(strings.TrimSpace(restriction))
+		if !slices.Contains(models.DietaryRestrictions, restriction) {
+			return NewServiceError(
+				http.StatusBadRequest,
+				fmt.Sprintf("Dietary restrictions must be one of: %s", strings.Join(models.DietaryRestrictions, ", ")),
+				nil,
+			)
+		}
+		if !slices.Contains(restrictions, restriction) {
+			restrictions = append(restrictions, restriction)
+		}
+	}
+	profile.DietaryRestrictions = restrictions
+
+	var allergies []string
+	for _, allergy := range profile.Allergies {
+		allergy = strings.ToLower(strings.TrimSpace(allergy))
+		if allergy == "" || slices.Contains(allergies, allergy) {
+			continue
+		}
+		if len([]rune(allergy)) > maxAllergyLen {
+			return NewServiceError(
+				http.StatusBadRequest,
+				fmt.Sprintf("Allergies must be at most %d characters", maxAllergyLen),
+				nil,
+			)
+		}
+		allergies = append(allergies, allergy)
+	}
+	if len(allergies) > maxAllergies {
+		return NewServiceError(
+			http.StatusBadRequest,
+			fmt.Sprintf("At most %d allergies can be listed", maxAllergies),
+			nil,
+		)
+	}
+	profile.Allergies = allergies
+	return nil
+}
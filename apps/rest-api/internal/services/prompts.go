@@ -0,0 +1,124 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+
+	"rest-api/internal/models"
+	"rest-api/internal/prompts"
+)
+
+// Prompt template names, see internal/prompts/templates
+const (
+	promptPlan       = "plan"
+	promptRegenerate = "regenerate"
+	promptChat       = "chat"
+	promptMotivation = "motivation"
+	promptSummary    = "summary"
+	promptMeal       = "meal"
+	promptWorkout    = "workout"
+	promptReport     = "report"
+)
+
+// planPromptData is rendered by the plan, regenerate and workout templates
+type planPromptData struct {
+	Profile           *models.FitnessProfile
+	WorkoutsPerWeek   int
+	Rules             string
+	TimeframeGuidance string
+	Beginner          bool
+	// Language of the plan texts, empty without a preference
+	Language string
+	// Plan and Comments are only set when regenerating
+	Plan     *models.ShortWorkoutPlan
+	Comments string
+	// Workout is the base workout a single workout regeneration replaces
+	Workout *models.Workout
+}
+
+// mealPromptData is rendered by the meal template
+type mealPromptData struct {
+	Profile *models.FitnessProfile
+	Targets models.NutritionTargets
+	Rules   string
+	// Language of the meal plan texts, empty without a preference
+	Language string
+}
+
+// repairPromptData is rendered by the "repair" part of the plan and meal templates
+type repairPromptData struct {
+	Problems []string
+	Rules    string
+}
+
+type chatPromptData struct {
+	Beginner bool
+	// Summary is the rolling summary of older history
+	Summary string
+	// Tools is set when the coach may call tools to read and change the plan
+	Tools bool
+	// Caution lists the health-safety categories the user's message touches
+	Caution []string
+	// Language of the answer, empty without a preference
+	Language string
+}
+
+type summaryPromptData struct {
+	Summary  string
+	Messages []models.ChatMessage
+	MaxWords int
+}
+
+type motivationPromptData struct {
+	Progress *models.UserProgress
+	Language string
+}
+
+// reportPromptData is rendered by the report template
+type reportPromptData struct {
+	// Week is the date range of the report
+	Week         string
+	Goal         string
+	FitnessLevel string
+	Stats        models.WeeklyStats
+	// WeightChange is the signed weight change in kg, empty without an earlier report
+	WeightChange string
+	Language     string
+}
+
+func (s *AIService) promptStore() *prompts.Store {
+	if s.Prompts != nil {
+		return s.Prompts
+	}
+	return prompts.Default()
+}
+
+// selectPrompt picks the prompt version for a user, keeping each user on the same A/B arm
+func (s *AIService) selectPrompt(name string, userID int) (*prompts.Prompt, error) {
+	prompt, err := s.promptStore().Select(name, strconv.Itoa(userID))
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to build AI prompt",
+			err,
+		)
+	}
+	return prompt, nil
+}
+
+// renderPrompt renders the given parts of a prompt as consecutive messages
+func renderPrompt(prompt *prompts.Prompt, data any, parts ...string) ([]OpenRouterMessage, error) {
+	messages := make([]OpenRouterMessage, 0, len(parts))
+	for _, part := range parts {
+		content, err := prompt.Execute(part, data)
+		if err != nil {
+			return nil, NewServiceError(
+				http.StatusInternalServerError,
+				"Failed to build AI prompt",
+				err,
+			)
+		}
+		messages = append(messages, OpenRouterMessage{Role: part, Content: content})
+	}
+	return messages, nil
+}
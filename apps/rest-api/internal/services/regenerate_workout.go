@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rest-api/internal/models"
+	"rest-api/internal/progression"
+)
+
+// RegenerateWorkout regenerates the base workout of a planned workout from
+// the user's comments. The result replaces this and every later planned
+// occurrence of the base workout, keeping their IDs and dates; completed and
+// expired workouts and the other base workouts stay as they are.
+func (s *AIService) RegenerateWorkout(ctx context.Context, workoutID, userComments string) (*models.WorkoutPlan, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Client == nil {
+		return nil, NewServiceError(
+			http.StatusServiceUnavailable,
+			"AI service unavailable",
+			nil,
+		)
+	}
+
+	ctx = withAIFeature(ctx, models.AIFeatureRegenerate)
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+
+	reportPlanStage(ctx, models.PlanStageProfile)
+	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Complete your profile first",
+			err,
+		)
+	}
+
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout plan",
+			err,
+		)
+	}
+	workout, err := changeableWorkout(plan, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The other base workouts give the model context, the plan works without them
+	shortPlan, err := s.MongoDBRepo.GetShortPlan(ctx, userID)
+	if err != nil {
+		fmt.Printf("Failed to get short plan: %v\n", err)
+		shortPlan = nil
+	}
+	baseName := baseWorkoutName(*workout)
+	base := shortPlanWorkout(shortPlan, workout.Phase, baseName)
+	if base == nil {
+		current := *workout
+		current.Name = baseName
+		base = &current
+	}
+
+	prompt, err := s.selectPrompt(promptWorkout, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	language, _ := responseLanguage(ctx, profile)
+	messages, err := renderPrompt(prompt, planPromptData{
+		Profile:         profile,
+		WorkoutsPerWeek: workoutsPerWeekFor(profile),
+		Rules:           planConstraintsPrompt(1),
+		Beginner:        profile.FitnessLevel == "beginner",
+		Language:        promptLanguage(language),
+		Plan:            shortPlan,
+		Comments:        userComments,
+		Workout:         base,
+	}, "system", "user")
+	if err != nil {
+		return nil, err
+	}
+
+	reportPlanStage(ctx, models.PlanStageGenerating)
+	generated, err := s.generateValidatedPlan(ctx, prompt, messages, 1, language)
+	if err != nil {
+		return nil, err
+	}
+
+	disclaimers := s.guardPlan(ctx, userID, profile, generated.Workouts)
+	regenerated := generated.Workouts[0]
+	regenerated.Status = "planned"
+
+	reportPlanStage(ctx, models.PlanStageScheduling)
+	for _, occurrence := range upcomingOccurrences(plan, workout) {
+		model, week := s.workoutProgression(plan, profile, occurrence)
+		applyBaseWorkout(occurrence, baseName, regenerated, model, week)
+	}
+	for _, disclaimer := range disclaimers {
+		if !slices.Contains(plan.Disclaimers, disclaimer) {
+			plan.Disclaimers = append(plan.Disclaimers, disclaimer)
+		}
+	}
+	now := time.Now()
+	plan.UpdatedAt = now
+
+	// Later full regenerations start from the new base workout
+	var updatedShortPlan *models.ShortWorkoutPlan
+	if current := shortPlanWorkout(shortPlan, workout.Phase, baseName); current != nil {
+		*current = regenerated
+		shortPlan.UpdatedAt = now
+		updatedShortPlan = shortPlan
+	}
+
+	reportPlanStage(ctx, models.PlanStageSaving)
+	if err := s.savePlan(ctx, plan, updatedShortPlan, planChange{
+		reason:   models.PlanChangeRegenerateWorkout,
+		author:   models.PlanSourceAI,
+		comments: userComments,
+	}); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save updated workout plan",
+			err,
+		)
+	}
+
+	return plan, nil
+}
+
+// shortPlanWorkout finds a base workout of the short plan by name, nil when
+// the short plan holds the base workouts of another phase
+func shortPlanWorkout(shortPlan *models.ShortWorkoutPlan, phase int, name string) *models.Workout {
+	if shortPlan == nil || shortPlan.Phase != phase {
+		return nil
+	}
+	for i := range shortPlan.BaseWorkouts {
+		if shortPlan.BaseWorkouts[i].Name == name {
+			return &shortPlan.BaseWorkouts[i]
+		}
+	}
+	return nil
+}
+
+// applyBaseWorkout replaces the contents of a scheduled workout with a new
+// version of its base workout, keeping its ID, date, status and week suffix.
+// The exercises are progressed to the given week of the model.
+func applyBaseWorkout(workout *models.Workout, oldBase string, base models.Workout, model progression.Model, week int) {
+	suffix := ""
+	if strings.HasPrefix(workout.Name, oldBase) {
+		suffix = workout.Name[len(oldBase):]
+	}
+	workout.Name = base.Name + suffix
+	workout.BaseWorkout = base.Name
+	workout.Description = base.Description
+
+	workout.Exercises = make([]models.Exercise, len(base.Exercises))
+	for i, exercise := range base.Exercises {
+		exercise.ExerciseID = primitive.NewObjectID()
+		workout.Exercises[i] = model.Apply(exercise, week)
+	}
+}
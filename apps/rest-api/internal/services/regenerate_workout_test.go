@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+// workoutReply is a completion message with a single workout plan as its content
+func workoutReply(t *testing.T, name string) string {
+	t.Helper()
+	plan := generatedPlan{Title: name, Workouts: []models.Workout{{
+		Name:        name,
+		Description: "Hips and glutes",
+		Status:      "planned",
+		Exercises: []models.Exercise{
+			{Name: "Glute Bridges", MuscleGroup: "Glutes", Sets: 3, Reps: 15, RestSec: 45},
+			{Name: "Reverse Lunges", MuscleGroup: "Legs", Sets: 3, Reps: 10, RestSec: 60},
+			{Name: "Side-lying Leg Raises", MuscleGroup: "Hips", Sets: 3, Reps: 12, RestSec: 30},
+		},
+	}}}
+	content, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := json.Marshal(map[string]string{"content": string(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(reply)
+}
+
+func TestAIService_RegenerateWorkout(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{workoutReply(t, "Glute Day")}, &requests)
+	mongoRepo := &mockMongoDBRepo{}
+	plan := substitutionPlan(1)
+	mongoRepo.SaveWorkoutPlan(context.Background(), plan)
+	mongoRepo.SaveShortPlan(context.Background(), &models.ShortWorkoutPlan{
+		UserID: 1,
+		BaseWorkouts: []models.Workout{
+			{Name: "Leg Day", Exercises: plan.Workouts[0].Exercises},
+			{Name: "Full Body", Exercises: plan.Workouts[3].Exercises},
+		},
+	})
+	before := append([]models.Workout(nil), plan.Workouts...)
+	service := newToolTestService(server.URL, mongoRepo)
+	service.Repo.SaveFitnessProfile(context.Background(), 1, &models.FitnessProfile{FitnessLevel: "intermediate", AvailableMinutes: 150})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	updated, err := service.RegenerateWorkout(ctx, before[1].WorkoutID.Hex(), "More glutes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := requests[0].Messages[1].Content
+	for _, want := range []string{"Workout to update: Leg Day", "Exercise 1: Goblet Squat", "- Full Body: Goblet Squat, Plank", "More glutes"} {
+		if !strings.Contains(user, want) {
+			t.Errorf("Expected the prompt to contain '%s', got %s", want, user)
+		}
+	}
+
+	for i, workout := range updated.Workouts {
+		if workout.WorkoutID != before[i].WorkoutID || !workout.ScheduledDate.Equal(before[i].ScheduledDate) || workout.Status != before[i].Status {
+			t.Errorf("Expected workout %d to keep its ID, date and status, got %+v", i, workout)
+		}
+	}
+	for _, i := range []int{0, 3} {
+		if updated.Workouts[i].Name != before[i].Name || updated.Workouts[i].Exercises[0].ExerciseID != before[i].Exercises[0].ExerciseID {
+			t.Errorf("Expected %s to be unchanged, got %+v", before[i].Name, updated.Workouts[i])
+		}
+	}
+	for i, name := range map[int]string{1: "Glute Day - Week 2", 2: "Glute Day - Week 3"} {
+		workout := updated.Workouts[i]
+		if workout.Name != name || workout.BaseWorkout != "Glute Day" || len(workout.Exercises) != 3 || workout.Exercises[0].ExerciseID.IsZero() {
+			t.Errorf("Expected %s with new exercises, got %+v", name, workout)
+		}
+	}
+	if updated.Workouts[1].Exercises[0].ExerciseID == updated.Workouts[2].Exercises[0].ExerciseID {
+		t.Error("Expected every occurrence to get its own exercise IDs")
+	}
+
+	bases := mongoRepo.shortPlans[1].BaseWorkouts
+	if bases[0].Name != "Glute Day" || bases[1].Name != "Full Body" {
+		t.Errorf("Expected only the regenerated base workout to change, got %+v", bases)
+	}
+
+	version := mongoRepo.versions[len(mongoRepo.versions)-1]
+	if version.Reason != models.PlanChangeRegenerateWorkout || version.Comments != "More glutes" || version.ShortPlan.BaseWorkouts[0].Name != "Glute Day" {
+		t.Errorf("Expected the regeneration to be kept as a version, got %+v", version)
+	}
+}
+
+func TestAIService_RegenerateWorkout_Errors(t *testing.T) {
+	plan := substitutionPlan(1)
+
+	testCases := []struct {
+		name      string
+		client    bool
+		workoutID string
+		status    int
+	}{
+		{"no AI", false, plan.Workouts[1].WorkoutID.Hex(), http.StatusServiceUnavailable},
+		{"unknown workout", true, "unknown", http.StatusNotFound},
+		{"completed workout", true, plan.Workouts[0].WorkoutID.Hex(), http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []OpenRouterRequest
+			server := toolServer(t, []string{workoutReply(t, "Glute Day")}, &requests)
+			mongoRepo := &mockMongoDBRepo{}
+			mongoRepo.SaveWorkoutPlan(context.Background(), substitutionPlan(1))
+			mongoRepo.plans[1].Workouts = plan.Workouts
+			service := newToolTestService(server.URL, mongoRepo)
+			if !tc.client {
+				service.Client = nil
+			}
+			service.Repo.SaveFitnessProfile(context.Background(), 1, &models.FitnessProfile{FitnessLevel: "beginner"})
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+			_, err := service.RegenerateWorkout(ctx, tc.workoutID, "More glutes")
+			if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != tc.status {
+				t.Errorf("Expected status %d, got %v", tc.status, err)
+			}
+
+			// Submitting the job reports the same error without queueing it
+			_, err = service.SubmitPlanJob(ctx, models.PlanJobRegenerateWorkout, models.PlanJobOptions{WorkoutID: tc.workoutID, Comments: "More glutes"})
+			if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != tc.status {
+				t.Errorf("Expected the job to be rejected with %d, got %v", tc.status, err)
+			}
+			if len(requests) != 0 || len(mongoRepo.jobs) != 0 {
+				t.Errorf("Expected no model call and no job, got %d calls and %d jobs", len(requests), len(mongoRepo.jobs))
+			}
+		})
+	}
+}
+
+func TestAIService_SubmitPlanJob_RegenerateWorkout(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{workoutReply(t, "Glute Day")}, &requests)
+	mongoRepo := &mockMongoDBRepo{}
+	mongoRepo.SaveWorkoutPlan(context.Background(), substitutionPlan(1))
+	workoutID := mongoRepo.plans[1].Workouts[1].WorkoutID.Hex()
+	service := newToolTestService(server.URL, mongoRepo)
+	service.Repo.SaveFitnessProfile(context.Background(), 1, &models.FitnessProfile{FitnessLevel: "beginner"})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	submitted, err := service.SubmitPlanJob(ctx, models.PlanJobRegenerateWorkout, models.PlanJobOptions{WorkoutID: workoutID, Comments: "More glutes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submitted.WorkoutID != workoutID || submitted.Comments != "More glutes" {
+		t.Errorf("Expected the options on the job, got %+v", submitted)
+	}
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := service.WaitBackground(waitCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := service.GetPlanJob(ctx, submitted.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != models.PlanJobSucceeded || job.Plan == nil || job.Plan.Workouts[1].Name != "Glute Day - Week 2" {
+		t.Errorf("Expected a finished job with the updated plan, got %+v", job)
+	}
+}
+
+func TestApplyBaseWorkout_Progression(t *testing.T) {
+	profile := &models.FitnessProfile{Goal: "endurance", FitnessLevel: "advanced", Timeframe: "6months"}
+	plan := &models.WorkoutPlan{Phases: planPhases(profile.Timeframe, time.Now())}
+	base := models.Workout{Name: "Glute Day", Exercises: []models.Exercise{{Name: "Glute Bridges", Sets: 3, Reps: 15}}}
+	workout := &models.Workout{Name: "Leg Day - Week 11", BaseWorkout: "Leg Day", Week: 11, Phase: 2}
+
+	// Week 11 is the third week of the strength phase
+	model, week := (&AIService{}).workoutProgression(plan, profile, workout)
+	applyBaseWorkout(workout, "Leg Day", base, model, week)
+
+	// Endurance adds 2 reps a week
+	if workout.Name != "Glute Day - Week 11" || workout.Exercises[0].Reps != 19 || workout.Exercises[0].Sets != 3 {
+		t.Errorf("Expected the new base workout progressed to week 3 of its phase, got %+v", workout)
+	}
+	if base.Exercises[0].Reps != 15 {
+		t.Errorf("Expected the base workout to be unchanged, got %+v", base.Exercises[0])
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var ErrRetryBudgetExhausted = errors.New("AI request deadline budget exhausted")
+
+// RetryPolicy controls how a completion call is retried across models
+type RetryPolicy struct {
+	// MaxAttempts is the total number of provider calls, across all models
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps a single delay
+	MaxDelay time.Duration
+	// Budget is the overall deadline of the call including all retries, 0 means no limit
+	Budget time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 6,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    8 * time.Second,
+	Budget:      2 * time.Minute,
+}
+
+// backoff returns the jittered exponential delay before retry number attempt (0-based).
+// The delay is picked uniformly from the upper half of the exponential step.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// consumerError marks a failure of the caller's stream consumer, which is
+// neither retried nor counted against the model
+type consumerError struct {
+	err error
+}
+
+func (e *consumerError) Error() string { return e.err.Error() }
+func (e *consumerError) Unwrap() error { return e.err }
+
+// attemptFunc performs one provider call with the given model. A final
+// result is never retried, even when the error is retryable.
+type attemptFunc func(ctx context.Context, model string, deadline time.Time) (final bool, err error)
+
+// retry runs attempt until it succeeds, fails fatally, the attempts or the
+// deadline budget run out, or ctx is cancelled
+func (c *OpenRouterClient) retry(ctx context.Context, policy RetryPolicy, attempt attemptFunc) error {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	var deadline time.Time
+	if policy.Budget > 0 {
+		deadline = time.Now().Add(policy.Budget)
+	}
+
+	tried := make(map[string]bool)
+	var lastErr error
+
+	for i := 0; i < maxAttempts; i++ {
+		model, err := c.router.Acquire(tried)
+		if err != nil {
+			// Every model is cooling down or has an open breaker, wait for the first to come back
+			wait, ok := c.router.NextAvailableIn()
+			if !ok {
+				return firstError(lastErr, err)
+			}
+			if err := c.sleep(ctx, wait, deadline); err != nil {
+				return budgetError(ctx, policy, firstError(lastErr, err))
+			}
+			continue
+		}
+		tried[model] = true
+
+		start := time.Now()
+		final, err := attempt(ctx, model, deadline)
+		if ctx.Err() != nil {
+			// Cancelled by the caller, not the model's fault
+			return ctx.Err()
+		}
+
+		var consumerErr *consumerError
+		if errors.As(err, &consumerErr) {
+			c.router.Report(model, time.Since(start), nil)
+			return consumerErr.err
+		}
+		if errors.Is(err, ErrRetryBudgetExhausted) {
+			return err
+		}
+
+		c.router.Report(model, time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		// Errors caused by our own request or credentials will not go away on retry
+		if final || !IsRetryable(err) || i == maxAttempts-1 {
+			break
+		}
+
+		if err := c.sleep(ctx, policy.backoff(i), deadline); err != nil {
+			return budgetError(ctx, policy, lastErr)
+		}
+	}
+
+	return lastErr
+}
+
+// sleep waits for d unless ctx is cancelled or the wait would overrun the deadline
+func (c *OpenRouterClient) sleep(ctx context.Context, d time.Duration, deadline time.Time) error {
+	if !deadline.IsZero() && time.Now().Add(d).After(deadline) {
+		return ErrRetryBudgetExhausted
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// budgetError explains why retrying stopped early
+func budgetError(ctx context.Context, policy RetryPolicy, lastErr error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(lastErr, ErrRetryBudgetExhausted) {
+		return lastErr
+	}
+	return fmt.Errorf("%w after %s: %w", ErrRetryBudgetExhausted, policy.Budget, lastErr)
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    20 * time.Millisecond,
+	Budget:      5 * time.Second,
+}
+
+func newTestClient(serverURL string, modelIDs ...string) *OpenRouterClient {
+	client := NewOpenRouterClient("test-key", nil)
+	client.baseURL = serverURL
+	client.router = NewModelRouter(modelIDs)
+	return client
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	testCases := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{10, time.Second},
+	}
+
+	for _, tc := range testCases {
+		for i := 0; i < 20; i++ {
+			delay := policy.backoff(tc.attempt)
+			if delay < tc.max/2 || delay > tc.max {
+				t.Errorf("Attempt %d: expected delay in [%v, %v], got %v", tc.attempt, tc.max/2, tc.max, delay)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-30 * time.Second).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+
+	for _, tc := range testCases {
+		header := http.Header{}
+		if tc.value != "" {
+			header.Set("Retry-After", tc.value)
+		}
+		if got := parseRetryAfter(header, now); got != tc.expected {
+			t.Errorf("For '%s', expected %v, got %v", tc.value, tc.expected, got)
+		}
+	}
+}
+
+func TestRetry_StopsOnContextCancel(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, "model-a")
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.CreateChatCompletionWithPolicy(ctx, []OpenRouterMessage{{Role: "user", Content: "hi"}}, false, policy)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected retries to stop with the context, took %v", elapsed)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected a single provider call, got %d", calls.Load())
+	}
+}
+
+func TestRetry_HonoursRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"rate limited"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, "model-a")
+
+	start := time.Now()
+	response, err := client.CreateChatCompletionWithPolicy(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, false, fastRetryPolicy)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response != "ok" {
+		t.Errorf("Expected 'ok', got '%s'", response)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected to wait for Retry-After, retried after %v", elapsed)
+	}
+}
+
+func TestRetry_RetryAfterBeyondBudget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, "model-a")
+	policy := fastRetryPolicy
+	policy.Budget = 200 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.CreateChatCompletionWithPolicy(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, false, policy)
+
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Errorf("Expected ErrRetryBudgetExhausted, got %v", err)
+	}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the rate limit error to be kept, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up immediately, took %v", elapsed)
+	}
+}
+
+func TestRetry_BudgetBoundsSlowRequests(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := newTestClient(server.URL, "model-a", "model-b")
+	policy := fastRetryPolicy
+	policy.Budget = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.CreateChatCompletionWithPolicy(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, false, policy)
+
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Errorf("Expected ErrRetryBudgetExhausted, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the budget to cut the request, took %v", elapsed)
+	}
+	if state := client.Router().Snapshot()[0].Failures; state != 0 {
+		t.Errorf("Expected budget expiry not to count against the model, got %d failures", state)
+	}
+}
+
+func TestRetry_BadRequestIsFatal(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"message":"invalid messages"}}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, "model-a", "model-b")
+
+	_, err := client.CreateChatCompletionWithPolicy(context.Background(), []OpenRouterMessage{{Role: "user", Content: "hi"}}, false, fastRetryPolicy)
+
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected a single provider call, got %d", calls.Load())
+	}
+}
+
+func TestSendRequest_DoesNotMutateMessages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, "model-a")
+	messages := []OpenRouterMessage{{Role: "system", Content: "system prompt"}, {Role: "user", Content: "hi"}}
+
+	_, _ = client.CreateChatCompletionWithPolicy(context.Background(), messages, true, fastRetryPolicy)
+
+	if messages[0].Content != "system prompt" {
+		t.Errorf("Expected system prompt to be untouched, got '%s'", messages[0].Content)
+	}
+}
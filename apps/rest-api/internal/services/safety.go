@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"rest-api/internal/i18n"
+	"rest-api/internal/models"
+	"rest-api/internal/promptguard"
+	"rest-api/internal/safety"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultSafetyEventLimit = 50
+	maxSafetyEventLimit     = 500
+
+	categoryPromptInjection = "prompt_injection"
+)
+
+func (s *AIService) safetyGuard() *safety.Guard {
+	if s.Safety != nil {
+		return s.Safety
+	}
+	return safety.Default()
+}
+
+// logSafetyEvent keeps a flagged exchange for review. A failure must not
+// block the answer, so it is only logged.
+func (s *AIService) logSafetyEvent(ctx context.Context, event *models.SafetyEvent, verdicts ...safety.Verdict) {
+	verdict := safety.Merge(verdicts...)
+	if !verdict.Flagged() {
+		return
+	}
+
+	event.Action = verdict.Action
+	event.Rules = verdict.RuleIDs()
+	event.Categories = verdict.Categories()
+	event.CreatedAt = time.Now()
+	if err := s.MongoDBRepo.SaveSafetyEvent(context.WithoutCancel(ctx), event); err != nil {
+		fmt.Printf("Failed to save safety event for user %d: %v\n", event.UserID, err)
+	}
+}
+
+// injectionVerdict reports the instruction-override attempts found in user text
+func injectionVerdict(text, action string) safety.Verdict {
+	patterns := promptguard.Detect(text)
+	if len(patterns) == 0 {
+		return safety.Verdict{}
+	}
+
+	verdict := safety.Verdict{Action: action}
+	for _, pattern := range patterns {
+		verdict.Matches = append(verdict.Matches, safety.Match{
+			RuleID:   "injection_" + pattern,
+			Category: categoryPromptInjection,
+			Action:   action,
+		})
+	}
+	return verdict
+}
+
+// leakVerdict refuses an answer that repeats the system prompt
+func leakVerdict(response, prompt string, language i18n.Language) safety.Verdict {
+	if !promptguard.Leaks(response, prompt) {
+		return safety.Verdict{}
+	}
+
+	message := i18n.Message(language.Code, i18n.MessageOffTopic)
+	return safety.Verdict{
+		Action: safety.ActionRefuse,
+		Matches: []safety.Match{{
+			RuleID:   "prompt_leak",
+			Category: categoryPromptInjection,
+			Action:   safety.ActionRefuse,
+			Message:  message,
+		}},
+	}
+}
+
+// guardedResponse is a chat answer after the output check
+type guardedResponse struct {
+	// response is returned and stored instead of the model's answer
+	response string
+	// addition follows the model's answer when it was already streamed to the client
+	addition string
+	notice   *models.SafetyNotice
+}
+
+// refuseChat answers a message that matched a refuse rule with the referral
+// text, without asking the model
+func (s *AIService) refuseChat(ctx context.Context, userID int, threadID primitive.ObjectID, message string, input safety.Verdict) (*models.ChatResponse, error) {
+	response := strings.Join(input.Messages(safety.ActionRefuse), "\n\n")
+
+	s.logSafetyEvent(ctx, &models.SafetyEvent{
+		UserID:   userID,
+		Feature:  models.AIFeatureChat,
+		ThreadID: threadID,
+		Input:    message,
+	}, input)
+
+	chatMsg := &models.ChatMessage{
+		UserID:   userID,
+		ThreadID: threadID,
+		Message:  message,
+		Response: response,
+		IsUser:   true,
+	}
+	if err := s.MongoDBRepo.SaveChatMessage(context.WithoutCancel(ctx), chatMsg); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save chat message",
+			err,
+		)
+	}
+
+	return &models.ChatResponse{
+		MessageID: chatMsg.ID,
+		Response:  response,
+		Safety: &models.SafetyNotice{
+			Action:     safety.ActionRefuse,
+			Categories: input.Categories(),
+		},
+	}, nil
+}
+
+// guardChatResponse scans the model's answer. Unsafe advice and answers
+// that repeat the system prompt are replaced with the rule's text,
+// disclaimers of the input and the output are appended.
+func (s *AIService) guardChatResponse(ctx context.Context, userID int, threadID primitive.ObjectID, message string, input safety.Verdict, request *chatRequest, response string) guardedResponse {
+	output := safety.Merge(
+		s.safetyGuard().CheckOutput(response),
+		leakVerdict(response, request.messages[0].Content, request.language),
+	)
+	// Override attempts are answered within the rules, they are only kept for review
+	s.logSafetyEvent(ctx, &models.SafetyEvent{
+		UserID:   userID,
+		Feature:  models.AIFeatureChat,
+		ThreadID: threadID,
+		Input:    message,
+		Output:   response,
+	}, input, output, injectionVerdict(message, safety.ActionFlag))
+
+	verdict := safety.Merge(input, output)
+	if output.Action == safety.ActionRefuse {
+		replacement := strings.Join(output.Messages(safety.ActionRefuse), "\n\n")
+		return guardedResponse{
+			response: replacement,
+			addition: "\n\n" + replacement,
+			notice: &models.SafetyNotice{
+				Action:     safety.ActionRefuse,
+				Categories: verdict.Categories(),
+			},
+		}
+	}
+
+	disclaimers := verdict.Messages(safety.ActionDisclaim)
+	if len(disclaimers) == 0 {
+		return guardedResponse{response: response}
+	}
+
+	addition := "\n\n" + strings.Join(disclaimers, "\n\n")
+	return guardedResponse{
+		response: response + addition,
+		addition: addition,
+		notice: &models.SafetyNotice{
+			Action:     safety.ActionDisclaim,
+			Categories: verdict.Categories(),
+		},
+	}
+}
+
+// guardPlan removes unsafe advice from generated workouts and returns the
+// disclaimers for the user's health issues and the plan content
+func (s *AIService) guardPlan(ctx context.Context, userID int, profile *models.FitnessProfile, workouts []models.Workout) []string {
+	guard := s.safetyGuard()
+	healthIssues := strings.Join(profile.HealthIssues, ", ")
+	input := guard.CheckInput(healthIssues)
+
+	// Unsafe notes are dropped, the rest of the plan stays usable
+	output := safety.Verdict{}
+	var removed []string
+	check := func(text *string) {
+		if *text == "" {
+			return
+		}
+		verdict := guard.CheckOutput(*text)
+		if verdict.Action == safety.ActionRefuse {
+			removed = append(removed, *text)
+			*text = ""
+		}
+		output = safety.Merge(output, verdict)
+	}
+	for i := range workouts {
+		check(&workouts[i].Description)
+		for j := range workouts[i].Exercises {
+			check(&workouts[i].Exercises[j].Notes)
+			check(&workouts[i].Exercises[j].Technique)
+		}
+	}
+
+	s.logSafetyEvent(ctx, &models.SafetyEvent{
+		UserID:  userID,
+		Feature: models.AIFeaturePlan,
+		Input:   healthIssues,
+		Output:  strings.Join(removed, "\n"),
+	}, input, output)
+
+	// Plans are still generated for risky health issues, the referral becomes a disclaimer
+	verdict := safety.Merge(input, output)
+	return append(input.Messages(safety.ActionRefuse), verdict.Messages(safety.ActionDisclaim)...)
+}
+
+// GetSafetyEvents lists the newest flagged exchanges for review
+func (s *AIService) GetSafetyEvents(ctx context.Context, limit int) ([]models.SafetyEvent, error) {
+	if limit <= 0 {
+		limit = defaultSafetyEventLimit
+	}
+	if limit > maxSafetyEventLimit {
+		limit = maxSafetyEventLimit
+	}
+
+	events, err := s.MongoDBRepo.GetSafetyEvents(ctx, limit)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get safety events",
+			err,
+		)
+	}
+	return events, nil
+}
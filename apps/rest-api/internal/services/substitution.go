@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rest-api/internal/models"
+)
+
+// maxSubstitutes is how many alternatives are proposed for an exercise
+const maxSubstitutes = 5
+
+// muscleGroupFamilies groups muscle groups whose exercises can stand in for
+// each other when the library has nothing for the exact group
+var muscleGroupFamilies = map[string]string{
+	"legs":        "legs",
+	"quads":       "legs",
+	"hamstrings":  "legs",
+	"glutes":      "legs",
+	"calves":      "legs",
+	"hips":        "legs",
+	"lower body":  "legs",
+	"core":        "core",
+	"abs":         "core",
+	"obliques":    "core",
+	"lower back":  "core",
+	"chest":       "upper body",
+	"back":        "upper body",
+	"shoulders":   "upper body",
+	"upper body":  "upper body",
+	"biceps":      "arms",
+	"triceps":     "arms",
+	"arms":        "arms",
+	"forearms":    "arms",
+	"cardio":      "conditioning",
+	"full body":   "conditioning",
+	"mobility":    "mobility",
+	"flexibility": "mobility",
+}
+
+// substituteCandidates lists the library exercises the user can do for the
+// muscle group, in library order. Exercises of related groups are only listed
+// when nothing matches the group, related reports whether they were.
+func substituteCandidates(profile *models.FitnessProfile, muscleGroup string, exclude map[string]bool) (candidates []libraryExercise, related bool) {
+	groups := make(map[string]bool)
+	families := make(map[string]bool)
+	for _, part := range muscleGroupParts(muscleGroup) {
+		groups[part] = true
+		if family, ok := muscleGroupFamilies[part]; ok {
+			families[family] = true
+		}
+	}
+
+	allowed := make(map[string]bool)
+	for _, exercises := range availableExercises(profile) {
+		for _, exercise := range exercises {
+			allowed[exercise.Name] = true
+		}
+	}
+
+	var exact, family []libraryExercise
+	for _, exercise := range exerciseLibrary {
+		if !allowed[exercise.Name] || exclude[strings.ToLower(exercise.Name)] {
+			continue
+		}
+		group := strings.ToLower(exercise.MuscleGroup)
+		switch {
+		case groups[group]:
+			exact = append(exact, exercise)
+		case families[muscleGroupFamilies[group]]:
+			family = append(family, exercise)
+		}
+	}
+
+	candidates = exact
+	if len(exact) == 0 {
+		candidates, related = family, true
+	}
+	if len(candidates) > maxSubstitutes {
+		candidates = candidates[:maxSubstitutes]
+	}
+	return candidates, related && len(candidates) > 0
+}
+
+// substituteExercise prescribes a library exercise with the volume of the
+// exercise it replaces
+func substituteExercise(candidate libraryExercise, old models.Exercise, profile *models.FitnessProfile) models.Exercise {
+	base := prescription{Sets: old.Sets, Reps: old.Reps, RestSec: old.RestSec}
+	// A replaced hold has no reps to take over
+	if base.Reps <= 1 {
+		goal, ok := goalPrescriptions[profile.Goal]
+		if !ok {
+			goal = goalPrescriptions["general_fitness"]
+		}
+		base.Reps = goal.Reps
+	}
+	holdSec := []int{20, 30, 45}[fitnessLevelRank(profile.FitnessLevel)-1]
+
+	exercise := prescribe(candidate, base, holdSec)
+	// The replaced exercise's reps already fit the slot
+	if candidate.Category == categoryCardio {
+		exercise.Reps = base.Reps
+	}
+	return exercise
+}
+
+// baseWorkoutName is the base workout a scheduled workout repeats. Plans
+// created before workouts recorded it are matched by the name without the
+// week suffix.
+func baseWorkoutName(workout models.Workout) string {
+	if workout.BaseWorkout != "" {
+		return workout.BaseWorkout
+	}
+	if i := strings.LastIndex(workout.Name, " - "); i > 0 {
+		return workout.Name[:i]
+	}
+	return workout.Name
+}
+
+// upcomingOccurrences finds the planned workouts of the same phase repeating
+// the same base workout, starting with the given one
+func upcomingOccurrences(plan *models.WorkoutPlan, workout *models.Workout) []*models.Workout {
+	base := baseWorkoutName(*workout)
+	var occurrences []*models.Workout
+	for i := range plan.Workouts {
+		candidate := &plan.Workouts[i]
+		if candidate.Status != "planned" || candidate.Phase != workout.Phase || candidate.ScheduledDate.Before(workout.ScheduledDate) {
+			continue
+		}
+		if baseWorkoutName(*candidate) == base {
+			occurrences = append(occurrences, candidate)
+		}
+	}
+	return occurrences
+}
+
+// changeableWorkout finds a planned workout of the plan for a request that changes it
+func changeableWorkout(plan *models.WorkoutPlan, workoutID string) (*models.Workout, error) {
+	if plan == nil {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Workout plan not found",
+			nil,
+		)
+	}
+
+	for i := range plan.Workouts {
+		workout := &plan.Workouts[i]
+		if workout.WorkoutID.Hex() != workoutID {
+			continue
+		}
+		if workout.Status != "planned" {
+			return nil, NewServiceError(
+				http.StatusConflict,
+				fmt.Sprintf("The workout is %s and can no longer be changed", workout.Status),
+				nil,
+			)
+		}
+		return workout, nil
+	}
+	return nil, NewServiceError(
+		http.StatusNotFound,
+		"Workout not found",
+		nil,
+	)
+}
+
+// SubstituteExercise proposes alternatives for an exercise of a planned
+// workout that train the same muscle group with the user's equipment and
+// health issues. With an alternative in the request it is applied to the
+// workout or to every upcoming occurrence of its base workout.
+func (s *AIService) SubstituteExercise(ctx context.Context, workoutID, exerciseID string, req *models.ExerciseSubstitutionRequest) (*models.ExerciseSubstitutionResponse, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := req.Scope
+	if scope == "" {
+		scope = models.SubstituteScopeWorkout
+	}
+	if scope != models.SubstituteScopeWorkout && scope != models.SubstituteScopeFuture {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"scope must be 'workout' or 'future'",
+			nil,
+		)
+	}
+
+	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			"Complete your profile first",
+			err,
+		)
+	}
+
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout plan",
+			err,
+		)
+	}
+	workout, err := changeableWorkout(plan, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	exclude := make(map[string]bool)
+	for i, exercise := range workout.Exercises {
+		if exercise.ExerciseID.Hex() == exerciseID {
+			index = i
+		}
+		exclude[strings.ToLower(strings.TrimSpace(exercise.Name))] = true
+	}
+	if index < 0 {
+		return nil, NewServiceError(
+			http.StatusNotFound,
+			"Exercise not found",
+			nil,
+		)
+	}
+
+	old := workout.Exercises[index]
+	candidates, related := substituteCandidates(profile, old.MuscleGroup, exclude)
+	response := &models.ExerciseSubstitutionResponse{Exercise: old}
+
+	if req.Alternative == "" {
+		for _, candidate := range candidates {
+			exercise := substituteExercise(candidate, old, profile)
+			response.Alternatives = append(response.Alternatives, models.ExerciseAlternative{
+				Name:        exercise.Name,
+				MuscleGroup: exercise.MuscleGroup,
+				Equipment:   candidate.Equipment,
+				Sets:        exercise.Sets,
+				Reps:        exercise.Reps,
+				RestSec:     exercise.RestSec,
+				Notes:       exercise.Notes,
+				Technique:   exercise.Technique,
+				Related:     related,
+			})
+		}
+		return response, nil
+	}
+
+	var chosen *libraryExercise
+	for i := range candidates {
+		if strings.EqualFold(candidates[i].Name, strings.TrimSpace(req.Alternative)) {
+			chosen = &candidates[i]
+			break
+		}
+	}
+	if chosen == nil {
+		return nil, NewServiceError(
+			http.StatusBadRequest,
+			fmt.Sprintf("%s is not an alternative for %s", req.Alternative, old.Name),
+			nil,
+		)
+	}
+
+	targets := []*models.Workout{workout}
+	if scope == models.SubstituteScopeFuture {
+		targets = upcomingOccurrences(plan, workout)
+	}
+	for _, target := range targets {
+		i := index
+		if target != workout {
+			// Occurrences have their own exercise IDs, so they are matched by name
+			i = exerciseIndex(target, old.Name)
+			if i < 0 || exerciseIndex(target, chosen.Name) >= 0 {
+				continue
+			}
+		}
+		exercise := substituteExercise(*chosen, target.Exercises[i], profile)
+		exercise.ExerciseID = primitive.NewObjectID()
+		target.Exercises[i] = exercise
+		response.Workouts = append(response.Workouts, *target)
+	}
+
+	var shortPlan *models.ShortWorkoutPlan
+	if scope == models.SubstituteScopeFuture {
+		shortPlan = s.substituteInShortPlan(ctx, userID, workout.Phase, baseWorkoutName(*workout), old.Name, *chosen, profile)
+	}
+
+	plan.UpdatedAt = time.Now()
+	if err := s.savePlan(ctx, plan, shortPlan, planChange{
+		reason:   models.PlanChangeSubstitute,
+		author:   models.PlanAuthorUser,
+		comments: fmt.Sprintf("%s replaced with %s", old.Name, chosen.Name),
+	}); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save workout plan",
+			err,
+		)
+	}
+	return response, nil
+}
+
+// substituteInShortPlan keeps the base workouts in line with the schedule so
+// regenerated plans start from the substituted exercise. It returns the
+// changed short plan, nil when there is nothing to change.
+func (s *AIService) substituteInShortPlan(ctx context.Context, userID, phase int, base, oldName string, chosen libraryExercise, profile *models.FitnessProfile) *models.ShortWorkoutPlan {
+	shortPlan, err := s.MongoDBRepo.GetShortPlan(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	workout := shortPlanWorkout(shortPlan, phase, base)
+	if workout == nil {
+		return nil
+	}
+	j := exerciseIndex(workout, oldName)
+	if j < 0 {
+		return nil
+	}
+	workout.Exercises[j] = substituteExercise(chosen, workout.Exercises[j], profile)
+	shortPlan.UpdatedAt = time.Now()
+	return shortPlan
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+)
+
+// substitutionPlan repeats a leg day over three weeks, the first one completed
+func substitutionPlan(userID int) *models.WorkoutPlan {
+	start := time.Now().Add(-24 * time.Hour).Truncate(time.Hour)
+	workout := func(name, base string, week int, status string) models.Workout {
+		return models.Workout{
+			WorkoutID:     primitive.NewObjectID(),
+			Name:          name,
+			BaseWorkout:   base,
+			Status:        status,
+			ScheduledDate: start.Add(time.Duration(week) * 7 * 24 * time.Hour),
+			Exercises: []models.Exercise{
+				{ExerciseID: primitive.NewObjectID(), Name: "Goblet Squat", MuscleGroup: "Legs", Sets: 4, Reps: 10 + week, RestSec: 90},
+				{ExerciseID: primitive.NewObjectID(), Name: "Plank", MuscleGroup: "Core", Sets: 3, Reps: 1, RestSec: 45},
+			},
+		}
+	}
+	return &models.WorkoutPlan{
+		UserID: userID,
+		Workouts: []models.Workout{
+			workout("Leg Day - Week 1", "Leg Day", 0, "completed"),
+			workout("Leg Day - Week 2", "Leg Day", 1, "planned"),
+			// Saved before workouts recorded their base workout
+			workout("Leg Day - Week 3", "", 2, "planned"),
+			workout("Full Body - Week 2", "Full Body", 1, "planned"),
+		},
+	}
+}
+
+func TestSubstituteCandidates(t *testing.T) {
+	testCases := []struct {
+		name        string
+		profile     *models.FitnessProfile
+		muscleGroup string
+		exclude     []string
+		expected    []string
+		related     bool
+	}{
+		{
+			"exact group only",
+			&models.FitnessProfile{FitnessLevel: "intermediate"},
+			"Legs", nil,
+			[]string{"Bodyweight Squats", "Reverse Lunges"},
+			false,
+		},
+		{
+			"equipment",
+			&models.FitnessProfile{FitnessLevel: "intermediate", Equipment: []string{"dumbbells"}},
+			"legs", []string{"goblet squat"},
+			[]string{"Bodyweight Squats", "Reverse Lunges"},
+			false,
+		},
+		{
+			"related groups without exact matches",
+			&models.FitnessProfile{FitnessLevel: "beginner", HealthIssues: []string{"Knee pain"}},
+			"Legs", nil,
+			[]string{"Glute Bridges", "Calf Raises", "Side-lying Leg Raises", "Hip Flexor Stretch", "Hamstring Stretch"},
+			true,
+		},
+		{
+			"combined group",
+			&models.FitnessProfile{FitnessLevel: "intermediate"},
+			"Chest/Triceps", []string{"push-ups"},
+			[]string{"Incline Push-ups", "Bench Dips"},
+			false,
+		},
+		{
+			"unknown group",
+			&models.FitnessProfile{FitnessLevel: "advanced"},
+			"Neck", nil,
+			nil,
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exclude := make(map[string]bool)
+			for _, name := range tc.exclude {
+				exclude[name] = true
+			}
+
+			candidates, related := substituteCandidates(tc.profile, tc.muscleGroup, exclude)
+			var names []string
+			for _, candidate := range candidates {
+				names = append(names, candidate.Name)
+			}
+			if !reflect.DeepEqual(names, tc.expected) || related != tc.related {
+				t.Errorf("Expected %v (related %t), got %v (related %t)", tc.expected, tc.related, names, related)
+			}
+		})
+	}
+}
+
+func TestAIService_SubstituteExercise(t *testing.T) {
+	mongoRepo := &mockMongoDBRepo{}
+	plan := substitutionPlan(1)
+	mongoRepo.SaveWorkoutPlan(context.Background(), plan)
+	mongoRepo.SaveShortPlan(context.Background(), &models.ShortWorkoutPlan{
+		UserID: 1,
+		BaseWorkouts: []models.Workout{
+			{Name: "Leg Day", Exercises: []models.Exercise{{Name: "Goblet Squat", MuscleGroup: "Legs", Sets: 4, Reps: 10, RestSec: 90}}},
+		},
+	})
+	service := newToolTestService("http://localhost", mongoRepo)
+	service.Repo.SaveFitnessProfile(context.Background(), 1, &models.FitnessProfile{
+		FitnessLevel: "intermediate", Equipment: []string{"dumbbells"}, HealthIssues: []string{"lower back pain"},
+	})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	workout := plan.Workouts[1]
+	squat := workout.Exercises[0]
+
+	proposed, err := service.SubstituteExercise(ctx, workout.WorkoutID.Hex(), squat.ExerciseID.Hex(), &models.ExerciseSubstitutionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proposed.Alternatives) == 0 || proposed.Workouts != nil {
+		t.Fatalf("Expected only alternatives, got %+v", proposed)
+	}
+	first := proposed.Alternatives[0]
+	if first.Name != "Bodyweight Squats" || first.Sets != 4 || first.Reps != 11 || first.RestSec != 90 {
+		t.Errorf("Expected the replaced volume, got %+v", first)
+	}
+	for _, alternative := range proposed.Alternatives {
+		if alternative.Name == "Dumbbell Romanian Deadlift" {
+			t.Error("Expected exercises straining the back to be left out")
+		}
+		if alternative.MuscleGroup != "Legs" || alternative.Related {
+			t.Errorf("Expected only exercises for the legs, got %+v", alternative)
+		}
+	}
+
+	applied, err := service.SubstituteExercise(ctx, workout.WorkoutID.Hex(), squat.ExerciseID.Hex(), &models.ExerciseSubstitutionRequest{Alternative: "reverse lunges"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := mongoRepo.plans[1].Workouts[1].Exercises[0]
+	if len(applied.Workouts) != 1 || changed.Name != "Reverse Lunges" || changed.ExerciseID == squat.ExerciseID {
+		t.Errorf("Expected the exercise replaced in this workout only, got %+v", applied.Workouts)
+	}
+	if mongoRepo.plans[1].Workouts[2].Exercises[0].Name != "Goblet Squat" {
+		t.Error("Expected the other weeks to be unchanged")
+	}
+
+	// Substitute the plank of the second week for every remaining leg day
+	plank := mongoRepo.plans[1].Workouts[1].Exercises[1]
+	applied, err = service.SubstituteExercise(ctx, workout.WorkoutID.Hex(), plank.ExerciseID.Hex(), &models.ExerciseSubstitutionRequest{Alternative: "Dead Bug", Scope: models.SubstituteScopeFuture})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied.Workouts) != 2 {
+		t.Fatalf("Expected both upcoming leg days, got %+v", applied.Workouts)
+	}
+	for _, w := range mongoRepo.plans[1].Workouts {
+		name := w.Exercises[1].Name
+		if w.Name == "Leg Day - Week 1" || w.Name == "Full Body - Week 2" {
+			if name != "Plank" {
+				t.Errorf("Expected %s to keep the plank, got %s", w.Name, name)
+			}
+		} else if name != "Dead Bug" || w.Exercises[1].Reps != 12 {
+			t.Errorf("Expected %s to get the dead bug with the goal's reps, got %+v", w.Name, w.Exercises[1])
+		}
+	}
+	if base := mongoRepo.shortPlans[1].BaseWorkouts[0].Exercises[0]; base.Name != "Goblet Squat" {
+		t.Errorf("Expected the base workout to change only for future substitutions, got %s", base.Name)
+	}
+
+	squat = mongoRepo.plans[1].Workouts[2].Exercises[0]
+	if _, err := service.SubstituteExercise(ctx, mongoRepo.plans[1].Workouts[2].WorkoutID.Hex(), squat.ExerciseID.Hex(), &models.ExerciseSubstitutionRequest{Alternative: "Bodyweight Squats", Scope: models.SubstituteScopeFuture}); err != nil {
+		t.Fatal(err)
+	}
+	if base := mongoRepo.shortPlans[1].BaseWorkouts[0].Exercises[0]; base.Name != "Bodyweight Squats" || base.Reps != 10 {
+		t.Errorf("Expected the base workout to be updated, got %+v", base)
+	}
+}
+
+func TestAIService_SubstituteExercise_Errors(t *testing.T) {
+	plan := substitutionPlan(1)
+	completed := plan.Workouts[0]
+	planned := plan.Workouts[1]
+
+	testCases := []struct {
+		name       string
+		workoutID  string
+		exerciseID string
+		req        models.ExerciseSubstitutionRequest
+		status     int
+	}{
+		{"invalid scope", planned.WorkoutID.Hex(), planned.Exercises[0].ExerciseID.Hex(), models.ExerciseSubstitutionRequest{Scope: "all"}, http.StatusBadRequest},
+		{"unknown workout", primitive.NewObjectID().Hex(), planned.Exercises[0].ExerciseID.Hex(), models.ExerciseSubstitutionRequest{}, http.StatusNotFound},
+		{"unknown exercise", planned.WorkoutID.Hex(), "invalid", models.ExerciseSubstitutionRequest{}, http.StatusNotFound},
+		{"completed workout", completed.WorkoutID.Hex(), completed.Exercises[0].ExerciseID.Hex(), models.ExerciseSubstitutionRequest{}, http.StatusConflict},
+		{"not an alternative", planned.WorkoutID.Hex(), planned.Exercises[0].ExerciseID.Hex(), models.ExerciseSubstitutionRequest{Alternative: "Push-ups"}, http.StatusBadRequest},
+	}
+
+	mongoRepo := &mockMongoDBRepo{}
+	mongoRepo.SaveWorkoutPlan(context.Background(), plan)
+	service := newToolTestService("http://localhost", mongoRepo)
+	service.Repo.SaveFitnessProfile(context.Background(), 1, &models.FitnessProfile{FitnessLevel: "beginner"})
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.SubstituteExercise(ctx, tc.workoutID, tc.exerciseID, &tc.req)
+			if svcErr, ok := err.(ServiceError); !ok || svcErr.Code != tc.status {
+				t.Errorf("Expected status %d, got %v", tc.status, err)
+			}
+		})
+	}
+
+	ctx = context.WithValue(context.Background(), middleware.UserIDKey, 2)
+	service.Repo.SaveFitnessProfile(context.Background(), 2, &models.FitnessProfile{FitnessLevel: "beginner"})
+	if _, err := service.SubstituteExercise(ctx, planned.WorkoutID.Hex(), "", &models.ExerciseSubstitutionRequest{}); err == nil || err.(ServiceError).Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a plan, got %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"rest-api/internal/i18n"
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+	"rest-api/internal/promptguard"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// reportHistoryWeeks is how far back completions are loaded for the streaks
+const reportHistoryWeeks = 52
+
+// weekStart returns Monday 00:00 UTC of the week that contains t
+func weekStart(t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	sinceMonday := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -sinceMonday)
+}
+
+// lastFinishedWeek returns the start of the week before the one that contains now
+func lastFinishedWeek(now time.Time) time.Time {
+	return weekStart(now).AddDate(0, 0, -7)
+}
+
+// computeWeeklyStats derives the stats of the week from the completions up to
+// its end and the plan's scheduled workouts
+func computeWeeklyStats(start time.Time, completions []models.WorkoutCompletion, plan *models.WorkoutPlan) models.WeeklyStats {
+	end := start.AddDate(0, 0, 7)
+	var stats models.WeeklyStats
+
+	workouts := make(map[primitive.ObjectID]*models.Workout)
+	if plan != nil {
+		for i := range plan.Workouts {
+			workout := &plan.Workouts[i]
+			workouts[workout.WorkoutID] = workout
+			if workout.ScheduledDate.Before(start) || !workout.ScheduledDate.Before(end) {
+				continue
+			}
+			stats.Scheduled++
+			switch workout.Status {
+			case "done":
+				stats.ScheduledDone++
+			case "expired":
+				stats.Missed++
+			}
+		}
+	}
+	if stats.Scheduled > 0 {
+		stats.Adherence = int(math.Round(float64(stats.ScheduledDone) * 100 / float64(stats.Scheduled)))
+	}
+
+	days := make(map[time.Time]bool)
+	weeks := make(map[time.Time]bool)
+	for _, completion := range completions {
+		if !completion.CompletedAt.Before(end) {
+			continue
+		}
+		days[completion.CompletedAt.UTC().Truncate(24*time.Hour)] = true
+		weeks[weekStart(completion.CompletedAt)] = true
+
+		switch {
+		case !completion.CompletedAt.Before(start):
+			stats.Completed++
+			// Completions of workouts that were since regenerated have no exercises to count
+			if workout := workouts[completion.WorkoutID]; workout != nil {
+				for _, exercise := range workout.Exercises {
+					group := strings.ToLower(strings.TrimSpace(exercise.MuscleGroup))
+					if group == "" {
+						continue
+					}
+					if stats.MuscleGroups == nil {
+						stats.MuscleGroups = make(map[string]int)
+					}
+					stats.MuscleGroups[group] += exercise.Sets
+				}
+			}
+		case !completion.CompletedAt.Before(start.AddDate(0, 0, -7)):
+			stats.PreviousCompleted++
+		}
+	}
+
+	var lastDay time.Time
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if days[day] {
+			stats.ActiveDays++
+			lastDay = day
+		}
+	}
+	if !lastDay.IsZero() {
+		for day := lastDay; days[day]; day = day.AddDate(0, 0, -1) {
+			stats.DayStreak++
+		}
+	}
+	for week := start; weeks[week]; week = week.AddDate(0, 0, -7) {
+		stats.WeekStreak++
+	}
+	return stats
+}
+
+// GetWeeklyReport returns the report of the week that contains date, a zero
+// date selects the last finished week. A report that was not generated yet
+// is generated now.
+func (s *AIService) GetWeeklyReport(ctx context.Context, date time.Time) (*models.WeeklyReport, error) {
+	userID, err := s.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	start := lastFinishedWeek(now)
+	if !date.IsZero() {
+		start = weekStart(date)
+		if start.AddDate(0, 0, 7).After(now) {
+			return nil, NewServiceError(
+				http.StatusBadRequest,
+				"Reports are available once the week is over",
+				nil,
+			)
+		}
+	}
+
+	report, err := s.MongoDBRepo.GetWeeklyReport(ctx, userID, start)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get weekly report",
+			err,
+		)
+	}
+	if report != nil {
+		return report, nil
+	}
+	return s.generateWeeklyReport(ctx, userID, start)
+}
+
+// generateWeeklyReport computes the stats of the week, has the model write the
+// summary and stores the report. Without a summary the report is returned but
+// not stored, so it is generated again the next time.
+func (s *AIService) generateWeeklyReport(ctx context.Context, userID int, start time.Time) (*models.WeeklyReport, error) {
+	end := start.AddDate(0, 0, 7)
+
+	completions, err := s.MongoDBRepo.GetWorkoutCompletions(ctx, userID, start.AddDate(0, 0, -7*reportHistoryWeeks), end)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout history",
+			err,
+		)
+	}
+	plan, err := s.MongoDBRepo.GetWorkoutPlan(ctx, userID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get workout plan",
+			err,
+		)
+	}
+	previous, err := s.MongoDBRepo.GetPreviousWeeklyReport(ctx, userID, start)
+	if err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to get weekly report",
+			err,
+		)
+	}
+
+	profile, err := s.Repo.GetFitnessProfile(ctx, userID)
+	if err != nil {
+		profile = nil
+	}
+	language, _ := responseLanguage(ctx, profile)
+
+	report := &models.WeeklyReport{
+		UserID:    userID,
+		WeekStart: start,
+		WeekEnd:   end,
+		Stats:     computeWeeklyStats(start, completions, plan),
+		Language:  language.Code,
+		CreatedAt: time.Now(),
+	}
+	// The profile only keeps the current weight, the trend comes from the stored
+	// reports. It is the weight of the week that just finished, not of older ones.
+	if profile != nil && profile.Weight > 0 && start.Equal(lastFinishedWeek(report.CreatedAt)) {
+		report.Stats.WeightKg = profile.Weight
+		if previous != nil && previous.Stats.WeightKg > 0 {
+			change := math.Round((profile.Weight-previous.Stats.WeightKg)*10) / 10
+			report.Stats.WeightChangeKg = &change
+		}
+	}
+
+	if err := s.writeReportSummary(ctx, report, profile, language); err != nil {
+		fmt.Printf("Failed to write weekly report summary for user %d: %v\n", userID, err)
+		return report, nil
+	}
+
+	if err := s.MongoDBRepo.SaveWeeklyReport(ctx, report); err != nil {
+		return nil, NewServiceError(
+			http.StatusInternalServerError,
+			"Failed to save weekly report",
+			err,
+		)
+	}
+	return report, nil
+}
+
+// writeReportSummary asks the model for the report's narrative
+func (s *AIService) writeReportSummary(ctx context.Context, report *models.WeeklyReport, profile *models.FitnessProfile, language i18n.Language) error {
+	if s.Client == nil {
+		return errors.New("AI service unavailable")
+	}
+
+	ctx = withAIFeature(ctx, models.AIFeatureReport)
+	if err := s.checkQuota(ctx); err != nil {
+		return err
+	}
+
+	prompt, err := s.selectPrompt(promptReport, report.UserID)
+	if err != nil {
+		return err
+	}
+	data := reportPromptData{
+		Week:     fmt.Sprintf("%s to %s", report.WeekStart.Format("2006-01-02"), report.WeekEnd.AddDate(0, 0, -1).Format("2006-01-02")),
+		Stats:    report.Stats,
+		Language: promptLanguage(language),
+	}
+	if profile != nil {
+		data.Goal = profile.Goal
+		data.FitnessLevel = profile.FitnessLevel
+	}
+	if change := report.Stats.WeightChangeKg; change != nil {
+		data.WeightChange = fmt.Sprintf("%+.1f", *change)
+	}
+	messages, err := renderPrompt(prompt, data, "system", "user")
+	if err != nil {
+		return err
+	}
+
+	response, err := s.Client.CreateChatCompletionWithPolicy(ctx, messages, false, reportRetryPolicy)
+	if err != nil {
+		return err
+	}
+	response = strings.TrimSpace(s.enforceLanguage(ctx, messages, response, language, reportRetryPolicy))
+	if response == "" {
+		return errors.New("empty summary")
+	}
+	if problem := promptguard.CheckContent(response); problem != "" {
+		return fmt.Errorf("summary %s", problem)
+	}
+
+	report.Summary = response
+	report.PromptVersion = prompt.ID()
+	return nil
+}
+
+// StartWeeklyReports generates the reports of the last finished week for all
+// active users right away and then every interval, until ctx is done
+func (s *AIService) StartWeeklyReports(ctx context.Context, interval time.Duration) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if generated := s.generateWeeklyReports(ctx, time.Now()); generated > 0 {
+				fmt.Printf("Generated %d weekly reports\n", generated)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// generateWeeklyReports generates the missing reports of the last finished
+// week for the users who were active since it started and returns how many
+// were stored. Failed reports are tried again on the next run.
+func (s *AIService) generateWeeklyReports(ctx context.Context, now time.Time) int {
+	// Reports without a summary are not stored, so there is nothing to do without the model
+	if s.Client == nil {
+		return 0
+	}
+
+	start := lastFinishedWeek(now)
+	userIDs, err := s.MongoDBRepo.GetActiveUserIDs(ctx, start)
+	if err != nil {
+		fmt.Printf("Failed to get active users for weekly reports: %v\n", err)
+		return 0
+	}
+
+	generated := 0
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		userCtx := context.WithValue(ctx, middleware.UserIDKey, userID)
+		existing, err := s.MongoDBRepo.GetWeeklyReport(userCtx, userID, start)
+		if err != nil {
+			fmt.Printf("Failed to get weekly report for user %d: %v\n", userID, err)
+			continue
+		}
+		if existing != nil {
+			continue
+		}
+
+		report, err := s.generateWeeklyReport(userCtx, userID, start)
+		if err != nil {
+			fmt.Printf("Failed to generate weekly report for user %d: %v\n", userID, err)
+			continue
+		}
+		if report.Summary != "" {
+			generated++
+		}
+	}
+	return generated
+}
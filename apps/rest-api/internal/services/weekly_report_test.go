@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api/internal/middleware"
+	"rest-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWeekStart(t *testing.T) {
+	testCases := []struct {
+		date     time.Time
+		expected string
+	}{
+		{time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC), "2026-10-05"},
+		{time.Date(2026, 10, 8, 15, 30, 0, 0, time.UTC), "2026-10-05"},
+		{time.Date(2026, 10, 11, 23, 59, 0, 0, time.UTC), "2026-10-05"},
+		{time.Date(2026, 10, 12, 1, 0, 0, 0, time.FixedZone("CEST", 2*3600)), "2026-10-05"},
+	}
+
+	for _, tc := range testCases {
+		if start := weekStart(tc.date).Format("2006-01-02"); start != tc.expected {
+			t.Errorf("Expected week of %s to start %s, got %s", tc.date, tc.expected, start)
+		}
+	}
+}
+
+func TestComputeWeeklyStats(t *testing.T) {
+	start := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	legDay := models.Workout{
+		WorkoutID:     primitive.NewObjectID(),
+		Status:        "done",
+		ScheduledDate: at(5, 9),
+		Exercises: []models.Exercise{
+			{Name: "Squats", MuscleGroup: "Legs", Sets: 4},
+			{Name: "Plank", MuscleGroup: "Core", Sets: 2},
+		},
+	}
+	plan := &models.WorkoutPlan{Workouts: []models.Workout{
+		legDay,
+		{WorkoutID: primitive.NewObjectID(), Status: "done", ScheduledDate: at(7, 9)},
+		{WorkoutID: primitive.NewObjectID(), Status: "expired", ScheduledDate: at(9, 9)},
+		{WorkoutID: primitive.NewObjectID(), Status: "planned", ScheduledDate: at(12, 9)},
+	}}
+	completions := []models.WorkoutCompletion{
+		{CompletedAt: time.Date(2026, 9, 28, 18, 0, 0, 0, time.UTC)},
+		{CompletedAt: at(4, 18)},
+		{WorkoutID: legDay.WorkoutID, CompletedAt: at(5, 18)},
+		{WorkoutID: legDay.WorkoutID, CompletedAt: at(6, 7)},
+		{CompletedAt: at(6, 19)},
+		{CompletedAt: at(12, 8)},
+	}
+
+	stats := computeWeeklyStats(start, completions, plan)
+
+	expected := models.WeeklyStats{
+		Completed:         3,
+		Scheduled:         3,
+		ScheduledDone:     2,
+		Missed:            1,
+		Adherence:         67,
+		ActiveDays:        2,
+		PreviousCompleted: 2,
+		DayStreak:         3,
+		WeekStreak:        2,
+		// Both leg days count, the other completion has no workout in the plan
+		MuscleGroups: map[string]int{"legs": 8, "core": 4},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+
+	empty := computeWeeklyStats(start, nil, nil)
+	if !reflect.DeepEqual(empty, models.WeeklyStats{}) {
+		t.Errorf("Expected empty stats without history, got %+v", empty)
+	}
+}
+
+// reportTestRepo has a plan with two workouts in the last finished week, one
+// of them completed, and a report of the week before
+func reportTestRepo(userID int) (*mockMongoDBRepo, time.Time) {
+	start := lastFinishedWeek(time.Now())
+	legDay := primitive.NewObjectID()
+	return &mockMongoDBRepo{
+		plans: map[int]*models.WorkoutPlan{userID: {
+			UserID: userID,
+			Workouts: []models.Workout{
+				{WorkoutID: legDay, Name: "Leg Day", Status: "done", ScheduledDate: start.Add(33 * time.Hour),
+					Exercises: []models.Exercise{{Name: "Squats", MuscleGroup: "Legs", Sets: 4}}},
+				{WorkoutID: primitive.NewObjectID(), Name: "Upper Body", Status: "expired", ScheduledDate: start.Add(81 * time.Hour)},
+			},
+		}},
+		completions: []models.WorkoutCompletion{
+			{UserID: userID, WorkoutID: legDay, CompletedAt: start.Add(34 * time.Hour)},
+		},
+		reports: []models.WeeklyReport{
+			{UserID: userID, WeekStart: start.AddDate(0, 0, -7), Summary: "Good week.", Stats: models.WeeklyStats{WeightKg: 81.2}},
+		},
+	}, start
+}
+
+func TestAIService_GetWeeklyReport(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{`{"content":"You trained on one day and kept your streak going."}`}, &requests)
+
+	mongoRepo, start := reportTestRepo(1)
+	service := newToolTestService(server.URL, mongoRepo)
+	service.Repo.(*mockProfileRepo).profiles[1] = &models.FitnessProfile{Goal: "weight_loss", FitnessLevel: "beginner", Weight: 80.4}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	report, err := service.GetWeeklyReport(ctx, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.WeekStart.Equal(start) || !report.WeekEnd.Equal(start.AddDate(0, 0, 7)) {
+		t.Errorf("Expected the last finished week from %s, got %s to %s", start, report.WeekStart, report.WeekEnd)
+	}
+	stats := report.Stats
+	if stats.Completed != 1 || stats.Scheduled != 2 || stats.Adherence != 50 || stats.MuscleGroups["legs"] != 4 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if stats.WeightKg != 80.4 || stats.WeightChangeKg == nil || *stats.WeightChangeKg != -0.8 {
+		t.Errorf("Expected a weight change of -0.8 kg, got %+v", stats)
+	}
+	if report.Summary != "You trained on one day and kept your streak going." || report.PromptVersion != "report/v1" {
+		t.Errorf("Expected the model's summary, got %+v", report)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("Expected one model call, got %d", len(requests))
+	}
+	prompt := requests[0].Messages[1].Content
+	for _, expected := range []string{"Goal: weight_loss", "Workouts completed: 1 (previous week: 0)", "adherence 50%", "legs 4;", "Weight: 80.4 kg (-0.8 kg since the last report)"} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("Expected the prompt to contain '%s', got:\n%s", expected, prompt)
+		}
+	}
+	if len(mongoRepo.reports) != 2 {
+		t.Fatalf("Expected the report to be stored, got %d reports", len(mongoRepo.reports))
+	}
+
+	// The stored report is returned without asking the model again
+	again, err := service.GetWeeklyReport(ctx, start.Add(50*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != mongoRepo.reports[1].ID || len(requests) != 1 {
+		t.Errorf("Expected the stored report without a model call, got %+v after %d calls", again, len(requests))
+	}
+}
+
+func TestAIService_GetWeeklyReport_Errors(t *testing.T) {
+	mongoRepo, start := reportTestRepo(1)
+	service := &AIService{BaseService: BaseService{Repo: newMockProfileRepo(), MongoDBRepo: mongoRepo}}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, 1)
+
+	if _, err := service.GetWeeklyReport(ctx, time.Now()); err == nil || err.(ServiceError).Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for the current week, got %v", err)
+	}
+
+	// Without the model the stats are returned, but nothing is stored
+	report, err := service.GetWeeklyReport(ctx, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary != "" || report.Stats.Completed != 1 {
+		t.Errorf("Expected the stats without a summary, got %+v", report)
+	}
+	if len(mongoRepo.reports) != 1 {
+		t.Errorf("Expected the report without a summary not to be stored, got %d reports", len(mongoRepo.reports))
+	}
+
+	// The current weight is not the weight of an older week
+	service.Repo.(*mockProfileRepo).profiles[1] = &models.FitnessProfile{Weight: 80.4}
+	past, err := service.GetWeeklyReport(ctx, start.AddDate(0, 0, -14))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if past.Stats.WeightKg != 0 || past.Stats.WeightChangeKg != nil {
+		t.Errorf("Expected no weight in the report of a past week, got %+v", past.Stats)
+	}
+}
+
+func TestAIService_GenerateWeeklyReports(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := toolServer(t, []string{`{"content":"A solid week."}`}, &requests)
+
+	mongoRepo, start := reportTestRepo(1)
+	otherRepo, _ := reportTestRepo(2)
+	mongoRepo.plans[2] = otherRepo.plans[2]
+	mongoRepo.completions = append(mongoRepo.completions, otherRepo.completions...)
+	mongoRepo.plans[3] = &models.WorkoutPlan{UserID: 3, Workouts: []models.Workout{
+		{WorkoutID: primitive.NewObjectID(), Status: "done", ScheduledDate: start.AddDate(0, 0, -14)},
+	}}
+	mongoRepo.completions = append(mongoRepo.completions, models.WorkoutCompletion{UserID: 3, CompletedAt: start.AddDate(0, 0, -14)})
+	// User 4 only has workouts scheduled, without completing any
+	mongoRepo.plans[4] = &models.WorkoutPlan{UserID: 4, Workouts: []models.Workout{
+		{WorkoutID: primitive.NewObjectID(), Status: "planned", ScheduledDate: start.AddDate(0, 0, 8)},
+	}}
+	// User 2 already has the report of the week
+	mongoRepo.reports = append(mongoRepo.reports, models.WeeklyReport{UserID: 2, WeekStart: start, Summary: "Done."})
+	service := newToolTestService(server.URL, mongoRepo)
+	service.Client.SetUsageFunc(service.recordUsage)
+
+	generated := service.generateWeeklyReports(context.Background(), time.Now())
+
+	if generated != 1 || len(requests) != 1 {
+		t.Fatalf("Expected one report for the active user without one, got %d after %d calls", generated, len(requests))
+	}
+	report := mongoRepo.reports[len(mongoRepo.reports)-1]
+	if report.UserID != 1 || !report.WeekStart.Equal(start) || report.Summary != "A solid week." {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	var usage []models.AIUsageRecord
+	for _, record := range mongoRepo.usage {
+		if record.Feature == models.AIFeatureReport {
+			usage = append(usage, record)
+		}
+	}
+	if len(usage) != 1 || usage[0].UserID != 1 {
+		t.Errorf("Expected the usage to be recorded for user 1, got %+v", usage)
+	}
+}
@@ -0,0 +1,3 @@
+-- Equipment available to the user, used by the rule-based plan generator
+ALTER TABLE fitness_profiles
+    ADD COLUMN equipment TEXT[] NOT NULL DEFAULT '{}';
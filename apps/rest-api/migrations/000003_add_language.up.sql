@@ -0,0 +1,3 @@
+-- Language of AI responses, empty follows the client's Accept-Language header
+ALTER TABLE fitness_profiles
+    ADD COLUMN language VARCHAR(8) NOT NULL DEFAULT '';
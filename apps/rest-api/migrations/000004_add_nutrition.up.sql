@@ -0,0 +1,6 @@
+-- Nutrition data for meal plans: sex refines the calorie estimate, diets
+-- and allergies exclude foods
+ALTER TABLE fitness_profiles
+    ADD COLUMN sex VARCHAR(10) NOT NULL DEFAULT '',
+    ADD COLUMN dietary_restrictions TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN allergies TEXT[] NOT NULL DEFAULT '{}';
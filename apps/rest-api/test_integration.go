@@ -15,6 +15,8 @@ import (
 	"rest-api/internal/services"
 
 	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Integration test for the rating endpoint
@@ -41,7 +43,7 @@ func TestRatingEndpointIntegration(t *testing.T) {
 	// Initialize services
 	authService := services.NewAuthService(mockPostgresRepo, cfg.JWTSecret, cfg.JWTExpiration, 7*24*time.Hour)
 	profileService := services.NewProfileService(mockPostgresRepo)
-	aiService := services.NewAIService(mockPostgresRepo, mockMongoRepo, "")
+	aiService := services.NewAIService(mockPostgresRepo, mockMongoRepo, "", nil, nil)
 	healthService := services.NewHealthService(mockPostgresRepo)
 
 	// Initialize services
@@ -110,10 +112,66 @@ func (m *mockMongoRepo) SaveChatMessage(ctx context.Context, message *models.Cha
 	return nil
 }
 
-func (m *mockMongoRepo) GetChatHistory(ctx context.Context, userID int) ([]models.ChatMessage, error) {
+func (m *mockMongoRepo) GetChatMessage(ctx context.Context, userID int, messageID primitive.ObjectID) (*models.ChatMessage, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) UpdateChatMessage(ctx context.Context, message *models.ChatMessage) error {
+	return mongo.ErrNoDocuments
+}
+
+func (m *mockMongoRepo) GetRatedChatMessages(ctx context.Context, rating string, since time.Time, limit int) ([]models.ChatMessage, error) {
 	return []models.ChatMessage{}, nil
 }
 
+func (m *mockMongoRepo) GetChatHistory(ctx context.Context, userID int, threadID primitive.ObjectID) ([]models.ChatMessage, error) {
+	return []models.ChatMessage{}, nil
+}
+
+func (m *mockMongoRepo) GetChatSummary(ctx context.Context, userID int, threadID primitive.ObjectID) (*models.ChatSummary, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) SaveChatSummary(ctx context.Context, summary *models.ChatSummary) error {
+	return nil
+}
+
+func (m *mockMongoRepo) EnsureDefaultChatThread(ctx context.Context, userID int) (*models.ChatThread, error) {
+	return &models.ChatThread{UserID: userID, Title: models.DefaultChatThreadTitle, IsDefault: true}, nil
+}
+
+func (m *mockMongoRepo) CreateChatThread(ctx context.Context, thread *models.ChatThread) error {
+	return nil
+}
+
+func (m *mockMongoRepo) GetChatThreads(ctx context.Context, userID int, includeArchived bool) ([]models.ChatThread, error) {
+	return []models.ChatThread{}, nil
+}
+
+func (m *mockMongoRepo) GetChatThread(ctx context.Context, userID int, threadID primitive.ObjectID) (*models.ChatThread, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) UpdateChatThread(ctx context.Context, thread *models.ChatThread) error {
+	return nil
+}
+
+func (m *mockMongoRepo) DeleteChatThread(ctx context.Context, userID int, threadID primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockMongoRepo) SaveChatAction(ctx context.Context, action *models.ChatAction) error {
+	return nil
+}
+
+func (m *mockMongoRepo) GetChatAction(ctx context.Context, userID int, actionID primitive.ObjectID) (*models.ChatAction, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) UpdateChatActionStatus(ctx context.Context, userID int, actionID primitive.ObjectID, from, to string) (bool, error) {
+	return false, nil
+}
+
 func (m *mockMongoRepo) SaveWorkoutPlan(ctx context.Context, plan *models.WorkoutPlan) error {
 	return nil
 }
@@ -142,6 +200,26 @@ func (m *mockMongoRepo) SaveShortPlan(ctx context.Context, plan *models.ShortWor
 	return nil
 }
 
+func (m *mockMongoRepo) SaveWorkoutPlanVersion(ctx context.Context, version *models.WorkoutPlanVersion) error {
+	return nil
+}
+
+func (m *mockMongoRepo) GetWorkoutPlanVersions(ctx context.Context, userID int) ([]models.WorkoutPlanVersion, error) {
+	return []models.WorkoutPlanVersion{}, nil
+}
+
+func (m *mockMongoRepo) GetWorkoutPlanVersion(ctx context.Context, userID int, versionID primitive.ObjectID) (*models.WorkoutPlanVersion, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) SaveMealPlan(ctx context.Context, plan *models.MealPlan) error {
+	return nil
+}
+
+func (m *mockMongoRepo) GetMealPlan(ctx context.Context, userID int) (*models.MealPlan, error) {
+	return nil, nil
+}
+
 func (m *mockMongoRepo) GetRating(ctx context.Context) ([]models.UserRating, error) {
 	return []models.UserRating{
 		{UserID: 1, TotalWorkouts: 25, MaxConsecutive: 7, Score: 32},
@@ -149,6 +227,26 @@ func (m *mockMongoRepo) GetRating(ctx context.Context) ([]models.UserRating, err
 	}, nil
 }
 
+func (m *mockMongoRepo) GetWorkoutCompletions(ctx context.Context, userID int, since, until time.Time) ([]models.WorkoutCompletion, error) {
+	return []models.WorkoutCompletion{}, nil
+}
+
+func (m *mockMongoRepo) GetActiveUserIDs(ctx context.Context, since time.Time) ([]int, error) {
+	return []int{}, nil
+}
+
+func (m *mockMongoRepo) SaveWeeklyReport(ctx context.Context, report *models.WeeklyReport) error {
+	return nil
+}
+
+func (m *mockMongoRepo) GetWeeklyReport(ctx context.Context, userID int, weekStart time.Time) (*models.WeeklyReport, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) GetPreviousWeeklyReport(ctx context.Context, userID int, weekStart time.Time) (*models.WeeklyReport, error) {
+	return nil, nil
+}
+
 func (m *mockMongoRepo) SaveExerciseMedia(ctx context.Context, media *models.ExerciseMedia) error {
 	return nil
 }
@@ -161,6 +259,54 @@ func (m *mockMongoRepo) DeleteExerciseMedia(ctx context.Context, mediaID string)
 	return nil
 }
 
+func (m *mockMongoRepo) GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) EnsureAICacheIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockMongoRepo) SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error {
+	return nil
+}
+
+func (m *mockMongoRepo) SaveAIUsage(ctx context.Context, record *models.AIUsageRecord) error {
+	return nil
+}
+
+func (m *mockMongoRepo) GetAIUsageTotals(ctx context.Context, userID int, since time.Time) ([]models.AIUsageTotal, error) {
+	return []models.AIUsageTotal{}, nil
+}
+
+func (m *mockMongoRepo) GetAIUsageByUser(ctx context.Context, since time.Time, limit int) ([]models.AIUsageUserTotal, error) {
+	return []models.AIUsageUserTotal{}, nil
+}
+
+func (m *mockMongoRepo) SaveSafetyEvent(ctx context.Context, event *models.SafetyEvent) error {
+	return nil
+}
+
+func (m *mockMongoRepo) GetSafetyEvents(ctx context.Context, limit int) ([]models.SafetyEvent, error) {
+	return []models.SafetyEvent{}, nil
+}
+
+func (m *mockMongoRepo) CreatePlanJob(ctx context.Context, job *models.PlanJob) error {
+	return nil
+}
+
+func (m *mockMongoRepo) GetPlanJob(ctx context.Context, userID int, jobID primitive.ObjectID) (*models.PlanJob, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) GetActivePlanJob(ctx context.Context, userID int) (*models.PlanJob, error) {
+	return nil, nil
+}
+
+func (m *mockMongoRepo) UpdatePlanJob(ctx context.Context, job *models.PlanJob) error {
+	return nil
+}
+
 // Benchmark test for rating calculation
 func BenchmarkRatingCalculation(b *testing.B) {
 	// Sample data for benchmarking